@@ -2,14 +2,16 @@ package assets
 
 import (
 	"embed"
+	"path"
+	"strings"
 
-	"github.com/shouni/go-character-kit/character"
 	"github.com/shouni/go-prompt-kit/resource"
 )
 
 const (
 	promptDir    = "prompts"
 	promptPrefix = "prompt_"
+	fontDir      = "fonts"
 )
 
 var (
@@ -21,6 +23,18 @@ var (
 	//go:embed characters/characters.json
 	characters []byte
 
+	// LayoutTemplates は、ページのコマ割りテンプレート定義（JSON 配列）です。
+	//go:embed layouts/layouts.json
+	LayoutTemplates []byte
+
+	// fonts は、吹き出しの写植に使用するフォントの配置先です（フォントは任意で配置します）。
+	//go:embed fonts
+	fonts embed.FS
+
+	// BundleTemplate は、オフライン閲覧用 HTML バンドルの index.html テンプレートです。
+	//go:embed export/bundle.html
+	BundleTemplate string
+
 	// Templates は、すべてのHTMLテンプレートを保持します。
 	//go:embed templates/*.html
 	Templates embed.FS
@@ -31,7 +45,24 @@ func LoadPrompts() (map[string]string, error) {
 	return resource.Load(promptFiles, promptDir, promptPrefix)
 }
 
-// LoadCharacters は埋め込まれたキャラクター定義ファイルを読み込みます。
-func LoadCharacters() (*character.Characters, error) {
-	return character.ParseCharacters(characters)
+// LoadLetteringFont は埋め込まれた写植用フォントを読み込みます。
+// フォントが配置されていない場合は nil を返します。
+func LoadLetteringFont() ([]byte, error) {
+	entries, err := fonts.ReadDir(fontDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		switch strings.ToLower(path.Ext(entry.Name())) {
+		case ".ttf", ".otf", ".ttc":
+			return fonts.ReadFile(path.Join(fontDir, entry.Name()))
+		}
+	}
+	return nil, nil
+}
+
+// DefaultCharacters は埋め込まれたキャラクター定義ファイル (JSON) の内容を返します。
+// 実行中に追加・編集したキャラクターは、この定義に重ねて使用します。
+func DefaultCharacters() []byte {
+	return characters
 }
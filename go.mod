@@ -5,7 +5,9 @@ go 1.26
 require (
 	github.com/caarlos0/env/v11 v11.4.1
 	github.com/go-chi/chi/v5 v5.3.0
+	github.com/gorilla/sessions v1.4.0
 	github.com/shouni/gcp-kit v1.1.4
+	github.com/shouni/gemini-image-kit v1.7.3
 	github.com/shouni/go-character-kit v1.0.2
 	github.com/shouni/go-gemini-client v1.6.7
 	github.com/shouni/go-http-kit v1.4.4
@@ -16,6 +18,8 @@ require (
 	github.com/shouni/go-utils v1.0.20
 	github.com/shouni/go-web-reader v1.0.8
 	github.com/shouni/netarmor v1.0.3
+	golang.org/x/image v0.25.0
+	google.golang.org/genai v1.61.0
 )
 
 require (
@@ -67,12 +71,10 @@ require (
 	github.com/googleapis/enterprise-certificate-proxy v0.3.16 // indirect
 	github.com/googleapis/gax-go/v2 v2.22.0 // indirect
 	github.com/gorilla/securecookie v1.1.2 // indirect
-	github.com/gorilla/sessions v1.4.0 // indirect
 	github.com/gorilla/websocket v1.5.3 // indirect
 	github.com/jellydator/ttlcache/v3 v3.4.0 // indirect
 	github.com/planetscale/vtprotobuf v0.6.1-0.20240319094008-0393e58bdf10 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
-	github.com/shouni/gemini-image-kit v1.7.3 // indirect
 	github.com/shouni/go-web-exact/v2 v2.3.1 // indirect
 	github.com/slack-go/slack v0.26.0 // indirect
 	github.com/spiffe/go-spiffe/v2 v2.6.0 // indirect
@@ -94,7 +96,6 @@ require (
 	golang.org/x/text v0.38.0 // indirect
 	golang.org/x/time v0.15.0 // indirect
 	google.golang.org/api v0.283.0 // indirect
-	google.golang.org/genai v1.61.0 // indirect
 	google.golang.org/genproto v0.0.0-20260319201613-d00831a3d3e7 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20260401024825-9d38bb4040a9 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20260523011958-0a33c5d7ca68 // indirect
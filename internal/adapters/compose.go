@@ -0,0 +1,101 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"log/slog"
+
+	"github.com/shouni/go-manga-kit/asset"
+	"github.com/shouni/go-manga-kit/ports"
+
+	"ap-manga-web/internal/domain"
+	"ap-manga-web/internal/imaging"
+	"ap-manga-web/internal/prompts"
+)
+
+// composedPageSize は合成するページ画像の大きさです（縦横比 1:√2）。
+var composedPageSize = image.Pt(1600, 2263)
+
+// composePages は生成済みのパネル画像をコマ割りに従って並べ、ページ画像として保存します。
+// ファイル名は AI によるページ生成と同じ manga_page_N.png とし、プレビューや公開処理からはそのまま参照できます。
+// ローカル写植が有効な場合、パネル画像は写植済みのため、そのまま合成します。
+func (w *WorkflowsAdapter) composePages(ctx context.Context, plot *domain.MangaPlot, imagePrompt *prompts.ImageBuilder, outputPath string) ([]string, error) {
+	if len(plot.Panels) == 0 {
+		return nil, fmt.Errorf("プロットにページデータが含まれていません")
+	}
+
+	basePath, err := asset.ResolveOutputPath(asset.ResolveBaseURL(outputPath), asset.DefaultPageImagePath())
+	if err != nil {
+		return nil, fmt.Errorf("出力パスの解決に失敗しました: %w", err)
+	}
+
+	var pagePaths []string
+	for i, panels := range w.pageChunks(plot) {
+		pagePath, err := w.composePage(ctx, i+1, panels, imagePrompt, basePath)
+		if err != nil {
+			return nil, err
+		}
+		pagePaths = append(pagePaths, pagePath)
+	}
+	return pagePaths, nil
+}
+
+// composePage は1ページ分のパネル画像を合成し、ページ番号 page の連番を付けて basePath (manga_page.png) に保存します。
+func (w *WorkflowsAdapter) composePage(ctx context.Context, page int, panels []domain.PlotPanel, imagePrompt *prompts.ImageBuilder, basePath string) (string, error) {
+	images := make([]image.Image, len(panels))
+	for j, p := range panels {
+		if p.ReferenceURL == "" {
+			slog.WarnContext(ctx, "Panel image is missing; leaving the cell blank", "page", page, "panel", j+1)
+			continue
+		}
+		data, err := w.readAll(ctx, p.ReferenceURL)
+		if err != nil {
+			return "", fmt.Errorf("page %d panel %d: %w", page, j+1, err)
+		}
+		if images[j], err = imaging.DecodeRGBA(bytes.NewReader(data)); err != nil {
+			return "", fmt.Errorf("page %d panel %d: %w", page, j+1, err)
+		}
+	}
+
+	layout := imagePrompt.PageLayout(portsPanelsOf(panels))
+	rects := layout.Cells(image.Rectangle{Max: composedPageSize})
+	cells := make([]imaging.PageCell, len(rects))
+	for j, rect := range rects {
+		cells[j] = imaging.PageCell{Rect: rect, Impact: j+1 == layout.ImpactPanel}
+	}
+
+	pagePath, err := asset.GenerateIndexedPath(basePath, page)
+	if err != nil {
+		return "", fmt.Errorf("ページ %d の出力パス生成に失敗しました: %w", page, err)
+	}
+	slog.InfoContext(ctx, "合成したページ画像を保存しています", "index", page, "layout", layout.Name, "path", pagePath)
+	if err := w.writePNG(ctx, pagePath, imaging.ComposePage(composedPageSize, cells, images)); err != nil {
+		return "", fmt.Errorf("page %d: %w", page, err)
+	}
+	return pagePath, nil
+}
+
+// pageChunks は go-manga-kit のページ生成と同じく、MaxPanelsPerPage 枚ずつパネルをページに割り当てます。
+func (w *WorkflowsAdapter) pageChunks(plot *domain.MangaPlot) [][]domain.PlotPanel {
+	perPage := w.args.Config.MaxPanelsPerPage
+	if perPage <= 0 {
+		perPage = domain.DefaultPanelsPerPage
+	}
+
+	var pages [][]domain.PlotPanel
+	for start := 0; start < len(plot.Panels); start += perPage {
+		pages = append(pages, plot.Panels[start:min(start+perPage, len(plot.Panels))])
+	}
+	return pages
+}
+
+// portsPanelsOf はレイアウト選択用に、台本上のパネルを ports.Panel に変換します。
+func portsPanelsOf(panels []domain.PlotPanel) []ports.Panel {
+	out := make([]ports.Panel, len(panels))
+	for i, p := range panels {
+		out[i] = p.Panel
+	}
+	return out
+}
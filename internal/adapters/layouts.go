@@ -0,0 +1,48 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/shouni/go-remote-io/remoteio"
+
+	"ap-manga-web/assets"
+	"ap-manga-web/internal/prompts"
+)
+
+// loadLayoutCatalog は埋め込みのコマ割りテンプレートを読み込み、url が指定されていれば追加定義を重ねてカタログを構築します。
+// 追加定義に同名のテンプレートがある場合は、そちらが優先されます。
+func loadLayoutCatalog(ctx context.Context, r remoteio.InputReader, url string) (*prompts.LayoutCatalog, error) {
+	templates, err := prompts.ParseLayoutTemplates(assets.LayoutTemplates)
+	if err != nil {
+		return nil, fmt.Errorf("埋め込みレイアウトテンプレートの読み込みに失敗しました: %w", err)
+	}
+
+	if url != "" {
+		extra, err := readLayoutTemplates(ctx, r, url)
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("Loaded external layout templates", "url", url, "count", len(extra))
+		templates = append(templates, extra...)
+	}
+
+	return prompts.NewLayoutCatalog(templates)
+}
+
+// readLayoutTemplates は指定された URL からテンプレート定義を読み込みます。
+func readLayoutTemplates(ctx context.Context, r remoteio.InputReader, url string) ([]prompts.LayoutTemplate, error) {
+	rc, err := r.Open(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("レイアウトテンプレートを開けませんでした (%s): %w", url, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("レイアウトテンプレートの読み込みに失敗しました (%s): %w", url, err)
+	}
+	return prompts.ParseLayoutTemplates(data)
+}
@@ -0,0 +1,237 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/shouni/go-manga-kit/ports"
+	"github.com/shouni/go-remote-io/remoteio"
+
+	"ap-manga-web/assets"
+	"ap-manga-web/internal/config"
+	"ap-manga-web/internal/domain"
+	"ap-manga-web/internal/imaging"
+	"ap-manga-web/internal/prompts"
+)
+
+const (
+	// rawPanelDir は写植前のパネル画像を退避するサブディレクトリ名です。
+	// ページ生成の参照画像には、文字の入っていないこちらの画像を使用します。
+	rawPanelDir       = "raw"
+	imageCacheControl = "public, max-age=1800"
+)
+
+// newLetterer は設定に応じて写植用の Letterer を生成します。ローカル写植が無効な場合は nil を返します。
+func newLetterer(ctx context.Context, cfg *config.Config, r remoteio.InputReader) (*imaging.Letterer, error) {
+	if !cfg.LocalLettering {
+		return nil, nil
+	}
+
+	var fontData []byte
+	if cfg.LetteringFontURL != "" {
+		rc, err := r.Open(ctx, cfg.LetteringFontURL)
+		if err != nil {
+			return nil, fmt.Errorf("写植用フォントを開けませんでした (%s): %w", cfg.LetteringFontURL, err)
+		}
+		defer rc.Close()
+		if fontData, err = io.ReadAll(rc); err != nil {
+			return nil, fmt.Errorf("写植用フォントの読み込みに失敗しました (%s): %w", cfg.LetteringFontURL, err)
+		}
+	} else {
+		var err error
+		if fontData, err = assets.LoadLetteringFont(); err != nil {
+			return nil, fmt.Errorf("埋め込みフォントの読み込みに失敗しました: %w", err)
+		}
+		if fontData == nil {
+			return nil, fmt.Errorf("LOCAL_LETTERING が有効ですが、LETTERING_FONT_URL が未設定です。日本語フォント (例: Noto Sans JP) を GCS に置いて指定してください")
+		}
+	}
+
+	return imaging.NewLetterer(fontData)
+}
+
+// letterPanels はパネル画像に写植を行います。写植前の画像は raw/ 以下に退避します。
+func (w *WorkflowsAdapter) letterPanels(ctx context.Context, plot *domain.MangaPlot) error {
+	for i, panel := range plot.Panels {
+		if err := w.letterPanel(ctx, i+1, panel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// letterPanel は number 番目のパネル画像に写植を行います。画像や文字要素のないパネルは何もしません。
+func (w *WorkflowsAdapter) letterPanel(ctx context.Context, number int, panel domain.PlotPanel) error {
+	bubbles := panelBubbles(panel)
+	if panel.ReferenceURL == "" || len(bubbles) == 0 {
+		return nil
+	}
+
+	data, err := w.readAll(ctx, panel.ReferenceURL)
+	if err != nil {
+		return fmt.Errorf("panel %d: %w", number, err)
+	}
+	if err := w.writer.Write(ctx, rawPanelPath(panel.ReferenceURL), bytes.NewReader(data),
+		remoteio.WithContentType(http.DetectContentType(data)),
+		remoteio.WithCacheControl(imageCacheControl)); err != nil {
+		return fmt.Errorf("panel %d: 写植前画像の保存に失敗しました: %w", number, err)
+	}
+
+	img, err := imaging.DecodeRGBA(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("panel %d: %w", number, err)
+	}
+	if err := w.letterer.Draw(img, img.Bounds(), bubbles); err != nil {
+		return fmt.Errorf("panel %d: %w", number, err)
+	}
+	if err := w.writePNG(ctx, panel.ReferenceURL, img); err != nil {
+		return fmt.Errorf("panel %d: %w", number, err)
+	}
+	return nil
+}
+
+// letterPages はページ画像に写植を行います。
+// ページへのパネルの割り当ては pageChunks に従い、レイアウトテンプレートから各コマの位置を求めます。
+func (w *WorkflowsAdapter) letterPages(ctx context.Context, plot *domain.MangaPlot, imagePrompt *prompts.ImageBuilder, pagePaths []string) error {
+	pages := w.pageChunks(plot)
+	for i, pagePath := range pagePaths {
+		if i >= len(pages) {
+			break
+		}
+		if err := w.letterPage(ctx, i+1, pages[i], imagePrompt, pagePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// letterPage はページ番号 page のページ画像に、そのページのパネル panels の文字要素を写植します。
+func (w *WorkflowsAdapter) letterPage(ctx context.Context, page int, panels []domain.PlotPanel, imagePrompt *prompts.ImageBuilder, pagePath string) error {
+	data, err := w.readAll(ctx, pagePath)
+	if err != nil {
+		return fmt.Errorf("page %d: %w", page, err)
+	}
+	img, err := imaging.DecodeRGBA(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("page %d: %w", page, err)
+	}
+
+	cells := imagePrompt.PageLayout(portsPanelsOf(panels)).Cells(img.Bounds())
+	for j, p := range panels {
+		if j >= len(cells) {
+			break
+		}
+		if err := w.letterer.Draw(img, cells[j], panelBubbles(p)); err != nil {
+			return fmt.Errorf("page %d panel %d: %w", page, j+1, err)
+		}
+	}
+
+	if err := w.writePNG(ctx, pagePath, img); err != nil {
+		return fmt.Errorf("page %d: %w", page, err)
+	}
+	return nil
+}
+
+// withRawPanels はページ生成の参照画像として、写植前のパネル画像が存在すればそれを使うよう差し替えます。
+func (w *WorkflowsAdapter) withRawPanels(ctx context.Context, manga *ports.MangaResponse) *ports.MangaResponse {
+	for i, panel := range manga.Panels {
+		if panel.ReferenceURL == "" {
+			continue
+		}
+		raw := rawPanelPath(panel.ReferenceURL)
+		exists, err := w.reader.Exists(ctx, raw)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to check raw panel image", "path", raw, "error", err)
+			continue
+		}
+		if exists {
+			manga.Panels[i].ReferenceURL = raw
+		}
+	}
+	return manga
+}
+
+// readAll は指定されたパスのファイルをすべて読み込みます。
+func (w *WorkflowsAdapter) readAll(ctx context.Context, path string) ([]byte, error) {
+	rc, err := w.reader.Open(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("画像を開けませんでした (%s): %w", path, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("画像の読み込みに失敗しました (%s): %w", path, err)
+	}
+	return data, nil
+}
+
+// writePNG は画像を PNG として保存します。
+func (w *WorkflowsAdapter) writePNG(ctx context.Context, path string, img image.Image) error {
+	buf, err := imaging.EncodePNG(img)
+	if err != nil {
+		return err
+	}
+	if err := w.writer.Write(ctx, path, buf,
+		remoteio.WithContentType("image/png"),
+		remoteio.WithCacheControl(imageCacheControl)); err != nil {
+		return fmt.Errorf("画像の保存に失敗しました (%s): %w", path, err)
+	}
+	return nil
+}
+
+// panelBubbles はパネルのナレーション・セリフ・効果音を写植用の文字要素に変換します。
+func panelBubbles(p domain.PlotPanel) []imaging.Bubble {
+	var bubbles []imaging.Bubble
+	if narration := strings.TrimSpace(p.Narration); narration != "" {
+		bubbles = append(bubbles, imaging.Bubble{Kind: imaging.BubbleCaption, Text: narration, Vertical: isVerticalText("", narration)})
+	}
+	for _, line := range p.DialogueLines() {
+		bubbles = append(bubbles, imaging.Bubble{
+			Kind:     imaging.BubbleSpeech,
+			Text:     line.Text,
+			Region:   imaging.Region(line.Region),
+			Vertical: isVerticalText(line.Direction, line.Text),
+		})
+	}
+	for _, sfx := range p.SFX {
+		bubbles = append(bubbles, imaging.Bubble{Kind: imaging.BubbleSFX, Text: sfx})
+	}
+	return bubbles
+}
+
+// isVerticalText は書字方向を決定します。指定がない場合、英数字が主体の文は横書き、それ以外は縦書きとします。
+func isVerticalText(direction, text string) bool {
+	switch direction {
+	case "vertical":
+		return true
+	case "horizontal":
+		return false
+	}
+
+	ascii, total := 0, 0
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		total++
+		if r < unicode.MaxASCII {
+			ascii++
+		}
+	}
+	return total == 0 || ascii*2 < total
+}
+
+// rawPanelPath はパネル画像のパスから、写植前画像の退避先パスを返します。
+// gs:// のスキームを壊さないよう、path.Dir ではなく最後の区切りで分割します。
+func rawPanelPath(ref string) string {
+	i := strings.LastIndex(ref, "/")
+	return ref[:i+1] + rawPanelDir + "/" + ref[i+1:]
+}
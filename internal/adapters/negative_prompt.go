@@ -0,0 +1,58 @@
+package adapters
+
+import (
+	"context"
+	"strings"
+
+	"github.com/shouni/go-gemini-client/gemini"
+	"google.golang.org/genai"
+
+	"ap-manga-web/internal/domain"
+	"ap-manga-web/internal/prompts"
+)
+
+// negativePromptSeparator は gemini-image-kit がプロンプトとネガティブプロンプトを連結する区切りです。
+const negativePromptSeparator = "\n\n[Negative Prompt]\n"
+
+// colorModeModel は画像生成リクエストのネガティブプロンプトを色表現モードに合わせて書き換える GenerativeModel です。
+// go-manga-kit はネガティブプロンプトをフルカラー前提で固定しているため、Gemini への送信直前に差し替えます。
+type colorModeModel struct {
+	gemini.GenerativeModel
+	mode domain.ColorMode
+}
+
+// withColorMode は mode に合わせてネガティブプロンプトを書き換える model を返します。フルカラーでは model をそのまま返します。
+func withColorMode(model gemini.GenerativeModel, mode domain.ColorMode) gemini.GenerativeModel {
+	if model == nil || mode == "" || mode == domain.ColorModeFullColor {
+		return model
+	}
+	return &colorModeModel{GenerativeModel: model, mode: mode}
+}
+
+// GenerateWithParts は最後のテキストパートに含まれるネガティブプロンプトを書き換えてから生成します。
+// 呼び出し元の parts は変更しません。
+func (m *colorModeModel) GenerateWithParts(ctx context.Context, modelName string, parts []*genai.Part, opts gemini.GenerateOptions) (*gemini.Response, error) {
+	return m.GenerativeModel.GenerateWithParts(ctx, modelName, rewriteNegativePrompt(parts, m.mode), opts)
+}
+
+// rewriteNegativePrompt は最後のテキストパートのネガティブプロンプトを mode に合わせて書き換えた parts を返します。
+// ネガティブプロンプトを含まない場合は parts をそのまま返します。
+func rewriteNegativePrompt(parts []*genai.Part, mode domain.ColorMode) []*genai.Part {
+	for i := len(parts) - 1; i >= 0; i-- {
+		if parts[i] == nil || parts[i].Text == "" {
+			continue
+		}
+		prompt, negative, ok := strings.Cut(parts[i].Text, negativePromptSeparator)
+		if !ok {
+			return parts
+		}
+
+		part := *parts[i]
+		part.Text = prompt + negativePromptSeparator + prompts.NegativePrompt(mode, negative)
+		rewritten := make([]*genai.Part, len(parts))
+		copy(rewritten, parts)
+		rewritten[i] = &part
+		return rewritten
+	}
+	return parts
+}
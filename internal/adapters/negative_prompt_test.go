@@ -0,0 +1,37 @@
+package adapters
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+
+	"ap-manga-web/internal/domain"
+)
+
+func TestRewriteNegativePrompt(t *testing.T) {
+	text := "draw a panel" + negativePromptSeparator + "text, watermark, monochrome, black and white, greyscale"
+	image := &genai.Part{InlineData: &genai.Blob{MIMEType: "image/png", Data: []byte{1}}}
+	parts := []*genai.Part{image, {Text: text}}
+
+	got := rewriteNegativePrompt(parts, domain.ColorModeMonochrome)
+	if got[0] != image {
+		t.Error("image part was replaced")
+	}
+	prompt, negative, ok := strings.Cut(got[1].Text, negativePromptSeparator)
+	if !ok || prompt != "draw a panel" {
+		t.Fatalf("text = %q", got[1].Text)
+	}
+	if strings.Contains(negative, "monochrome") || !strings.Contains(negative, "watermark") || !strings.Contains(negative, "full color") {
+		t.Errorf("negative prompt = %q", negative)
+	}
+	// 呼び出し元の parts は変更しません
+	if parts[1].Text != text {
+		t.Errorf("original part was modified: %q", parts[1].Text)
+	}
+
+	plain := []*genai.Part{{Text: "no negative prompt"}}
+	if got := rewriteNegativePrompt(plain, domain.ColorModeMonochrome); got[0] != plain[0] {
+		t.Error("part without negative prompt was replaced")
+	}
+}
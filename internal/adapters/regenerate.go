@@ -0,0 +1,209 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/shouni/go-manga-kit/asset"
+	"github.com/shouni/go-manga-kit/ports"
+	"github.com/shouni/go-remote-io/remoteio"
+
+	"ap-manga-web/internal/domain"
+	"ap-manga-web/internal/imaging"
+)
+
+// RegeneratePanels は台本のうち indices (0 始まり) のパネル画像だけを生成し直し、元と同じファイル名 (panel_N.png) で上書き保存します。
+// 画像パスを反映した台本を保存し、ローカル写植と縮小画像の生成も作り直したパネルに対してのみ行います。
+func (w *WorkflowsAdapter) RegeneratePanels(ctx context.Context, plot *domain.MangaPlot, outputPath string, indices []int, opts domain.ImageOptions) (*domain.MangaPlot, error) {
+	if len(indices) == 0 {
+		return plot, nil
+	}
+	basePath, err := asset.ResolveOutputPath(asset.ResolveBaseURL(outputPath), asset.DefaultPanelImagePath())
+	if err != nil {
+		return nil, fmt.Errorf("出力パスの解決に失敗しました: %w", err)
+	}
+
+	p, err := w.scopedPrompts(ctx, plot, opts)
+	if err != nil {
+		return nil, err
+	}
+	workflows, err := w.scopedWorkflows(p)
+	if err != nil {
+		return nil, err
+	}
+	defer workflows.Close()
+
+	targets := &ports.MangaResponse{Title: plot.Title, Description: plot.Description}
+	for _, i := range indices {
+		targets.Panels = append(targets.Panels, plot.Panels[i].Panel)
+	}
+	images, err := workflows.PanelImage.Run(ctx, targets)
+	if err != nil {
+		return nil, err
+	}
+	if len(images) != len(indices) {
+		return nil, fmt.Errorf("生成された画像の数(%d)と対象パネルの数(%d)が一致しません", len(images), len(indices))
+	}
+
+	panelPaths := make([]string, len(indices))
+	for k, i := range indices {
+		panelPath, err := asset.GenerateIndexedPath(basePath, i+1)
+		if err != nil {
+			return nil, fmt.Errorf("パネル %d の出力パス生成に失敗しました: %w", i+1, err)
+		}
+		slog.InfoContext(ctx, "作り直したパネル画像を保存しています", "index", i+1, "path", panelPath)
+		if err := w.writer.Write(ctx, panelPath, bytes.NewReader(images[k].Data),
+			remoteio.WithContentType(images[k].MimeType),
+			remoteio.WithCacheControl(imageCacheControl)); err != nil {
+			return nil, fmt.Errorf("第 %d パネルの保存に失敗しました (path: %s): %w", i+1, panelPath, err)
+		}
+		plot.Panels[i].ReferenceURL = panelPath
+		panelPaths[k] = panelPath
+	}
+	if err := w.saveJSON(ctx, outputPath, plot); err != nil {
+		return plot, err
+	}
+
+	if w.letterer != nil {
+		for _, i := range indices {
+			if err := w.letterPanel(ctx, i+1, plot.Panels[i]); err != nil {
+				return plot, fmt.Errorf("panel lettering failed: %w", err)
+			}
+		}
+	}
+
+	w.writeVariants(ctx, panelPaths)
+	return plot, nil
+}
+
+// ReletterPanels は indices (0 始まり) のパネル画像に、写植前の画像 (raw/) から写植し直し、縮小画像も作り直します。
+// セリフだけを変更したパネルに使用し、画像は生成し直しません。ローカル写植を使わない場合は何もしません。
+func (w *WorkflowsAdapter) ReletterPanels(ctx context.Context, plot *domain.MangaPlot, indices []int) error {
+	if w.letterer == nil || len(indices) == 0 {
+		return nil
+	}
+	var panelPaths []string
+	for _, i := range indices {
+		panel := plot.Panels[i]
+		if panel.ReferenceURL == "" {
+			continue
+		}
+		if err := w.reletterPanel(ctx, i+1, panel); err != nil {
+			return fmt.Errorf("panel lettering failed: %w", err)
+		}
+		panelPaths = append(panelPaths, panel.ReferenceURL)
+	}
+	w.writeVariants(ctx, panelPaths)
+	return nil
+}
+
+// reletterPanel は number 番目のパネルの写植前の画像に写植し、パネル画像を上書きします。
+// 写植前の画像がない (これまで文字要素がなかった) パネルは、letterPanel と同じく現在の画像を写植前の画像として退避してから写植します。
+// 文字要素がなくなったパネルは、写植前の画像に戻します。
+func (w *WorkflowsAdapter) reletterPanel(ctx context.Context, number int, panel domain.PlotPanel) error {
+	raw := rawPanelPath(panel.ReferenceURL)
+	exists, err := w.reader.Exists(ctx, raw)
+	if err != nil {
+		return fmt.Errorf("panel %d: 写植前画像の確認に失敗しました: %w", number, err)
+	}
+	if !exists {
+		return w.letterPanel(ctx, number, panel)
+	}
+
+	data, err := w.readAll(ctx, raw)
+	if err != nil {
+		return fmt.Errorf("panel %d: %w", number, err)
+	}
+	img, err := imaging.DecodeRGBA(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("panel %d: %w", number, err)
+	}
+	if bubbles := panelBubbles(panel); len(bubbles) > 0 {
+		if err := w.letterer.Draw(img, img.Bounds(), bubbles); err != nil {
+			return fmt.Errorf("panel %d: %w", number, err)
+		}
+	}
+	if err := w.writePNG(ctx, panel.ReferenceURL, img); err != nil {
+		return fmt.Errorf("panel %d: %w", number, err)
+	}
+	return nil
+}
+
+// RegeneratePages はページ番号 pages (1 始まり) のページ画像だけを作り直し、元と同じファイル名 (manga_page_N.png) で上書き保存します。
+// ページへのパネルの割り当ては Page と同じく MaxPanelsPerPage 枚ずつで、ページごとに描画方式に応じて生成します。
+func (w *WorkflowsAdapter) RegeneratePages(ctx context.Context, plot *domain.MangaPlot, outputPath string, pages []int, opts domain.ImageOptions) ([]string, error) {
+	if len(pages) == 0 {
+		return nil, nil
+	}
+	basePath, err := asset.ResolveOutputPath(asset.ResolveBaseURL(outputPath), asset.DefaultPageImagePath())
+	if err != nil {
+		return nil, fmt.Errorf("出力パスの解決に失敗しました: %w", err)
+	}
+	chunks := w.pageChunks(plot)
+	p, err := w.scopedPrompts(ctx, plot, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var pagePaths []string
+	for _, page := range pages {
+		if page < 1 || page > len(chunks) {
+			return pagePaths, fmt.Errorf("ページ %d は台本にありません (全 %d ページ)", page, len(chunks))
+		}
+		var pagePath string
+		if opts.PageRenderer == domain.PageRendererCompose {
+			pagePath, err = w.composePage(ctx, page, chunks[page-1], p.imagePrompt, basePath)
+		} else {
+			pagePath, err = w.renderPage(ctx, plot, page, chunks[page-1], p, basePath)
+		}
+		if err != nil {
+			return pagePaths, err
+		}
+		pagePaths = append(pagePaths, pagePath)
+	}
+
+	w.writeVariants(ctx, pagePaths)
+	return pagePaths, nil
+}
+
+// renderPage は1ページ分のパネルだけを渡して画像モデルでページ画像を生成し、ページ番号 page の連番を付けて保存します。
+// 渡すパネルは MaxPanelsPerPage 枚以下のため、go-manga-kit は1ページとして生成します。
+func (w *WorkflowsAdapter) renderPage(ctx context.Context, plot *domain.MangaPlot, page int, panels []domain.PlotPanel, p *promptSet, basePath string) (string, error) {
+	workflows, err := w.scopedWorkflows(p)
+	if err != nil {
+		return "", err
+	}
+	defer workflows.Close()
+
+	manga := &ports.MangaResponse{Title: plot.Title, Description: plot.Description, Panels: portsPanelsOf(panels)}
+	if w.letterer != nil {
+		manga = w.withRawPanels(ctx, manga)
+	}
+	images, err := workflows.PageImage.Run(ctx, manga)
+	if err != nil {
+		return "", fmt.Errorf("page %d: %w", page, err)
+	}
+	if len(images) != 1 {
+		return "", fmt.Errorf("page %d: 生成されたページ画像の数が %d 枚です", page, len(images))
+	}
+
+	pagePath, err := asset.GenerateIndexedPath(basePath, page)
+	if err != nil {
+		return "", fmt.Errorf("ページ %d の出力パス生成に失敗しました: %w", page, err)
+	}
+	slog.InfoContext(ctx, "作り直したページ画像を保存しています", "index", page, "path", pagePath)
+	if err := w.writer.Write(ctx, pagePath, bytes.NewReader(images[0].Data),
+		remoteio.WithContentType(images[0].MimeType),
+		remoteio.WithCacheControl(imageCacheControl)); err != nil {
+		return "", fmt.Errorf("第 %d ページの保存に失敗しました (path: %s): %w", page, pagePath, err)
+	}
+
+	if w.letterer != nil {
+		if err := w.letterPage(ctx, page, panels, p.imagePrompt, pagePath); err != nil {
+			return pagePath, fmt.Errorf("page lettering failed: %w", err)
+		}
+	}
+	return pagePath, nil
+}
@@ -84,6 +84,12 @@ func (s *SlackAdapter) NotifyError(ctx context.Context, errDetail error, req dom
 	var sb strings.Builder
 	fmt.Fprintf(&sb, "*作品タイトル:* `%s`\n", req.TargetTitle)
 	fmt.Fprintf(&sb, "*実行モード:* `%s`\n", req.ExecutionMode)
+	if req.Owner != "" {
+		fmt.Fprintf(&sb, "*依頼者:* %s\n", req.Owner)
+	}
+	if req.Changes != "" {
+		fmt.Fprintf(&sb, "*変更内容:* %s\n", req.Changes)
+	}
 	fmt.Fprintf(&sb, "*ソース:* %s\n\n", req.SourceURL)
 
 	// エラー詳細をコードブロックで囲むことで、スタックトレースなどの可読性を向上させます。
@@ -120,6 +126,15 @@ func (s *SlackAdapter) buildSlackContent(publicURL, storageURI string, req domai
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("**作品タイトル:** `%s`\n", req.TargetTitle))
 	sb.WriteString(fmt.Sprintf("**実行モード:** `%s`\n", req.ExecutionMode))
+	if req.Owner != "" {
+		sb.WriteString(fmt.Sprintf("**担当者:** %s\n", req.Owner))
+	}
+	if len(req.Tags) > 0 {
+		sb.WriteString(fmt.Sprintf("**タグ:** %s\n", strings.Join(req.Tags, ", ")))
+	}
+	if req.Changes != "" {
+		sb.WriteString(fmt.Sprintf("**変更内容:** %s\n", req.Changes))
+	}
 	sb.WriteString(fmt.Sprintf("**ソース:** %s\n\n", req.SourceURL))
 
 	// プレビューリンク（publicURLがある場合のみ）
@@ -132,7 +147,7 @@ func (s *SlackAdapter) buildSlackContent(publicURL, storageURI string, req domai
 	sb.WriteString(fmt.Sprintf("📍 **保存場所(URI):** `%s`\n\n", storageURI))
 
 	// 集成画像についての案内（Phase 4 がある generate モードのみ）
-	if strings.Contains(req.ExecutionMode, "generate") {
+	if strings.HasPrefix(req.ExecutionMode, "generate ") {
 		sb.WriteString("✨ _最終ページ画像 (final_page_n.png) も同じフォルダに生成済み様なのだ！_")
 	}
 
@@ -0,0 +1,103 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/shouni/go-remote-io/remoteio"
+
+	"ap-manga-web/internal/domain"
+)
+
+// TitleStoreAdapter は、作業ディレクトリのジョブ記録・メタデータ・台本を JSON としてストレージに読み書きするアダプタです。
+type TitleStoreAdapter struct {
+	reader remoteio.InputReader
+	writer remoteio.OutputWriter
+}
+
+// NewTitleStoreAdapter は新しいアダプターインスタンスを作成します。
+func NewTitleStoreAdapter(reader remoteio.InputReader, writer remoteio.OutputWriter) *TitleStoreAdapter {
+	return &TitleStoreAdapter{reader: reader, writer: writer}
+}
+
+// SaveJob はジョブ記録を保存します。
+func (a *TitleStoreAdapter) SaveJob(ctx context.Context, path string, rec domain.JobRecord) error {
+	return a.writeJSON(ctx, path, rec)
+}
+
+// LoadJob はジョブ記録を読み込みます。ファイルがない場合は nil を返します。
+func (a *TitleStoreAdapter) LoadJob(ctx context.Context, path string) (*domain.JobRecord, error) {
+	var rec domain.JobRecord
+	found, err := a.readJSON(ctx, path, &rec)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// LoadMeta はメタデータを読み込みます。ファイルがない場合は nil を返します。
+func (a *TitleStoreAdapter) LoadMeta(ctx context.Context, path string) (*domain.TitleMeta, error) {
+	var meta domain.TitleMeta
+	found, err := a.readJSON(ctx, path, &meta)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// SaveMeta はメタデータを保存します。
+func (a *TitleStoreAdapter) SaveMeta(ctx context.Context, path string, meta domain.TitleMeta) error {
+	return a.writeJSON(ctx, path, meta)
+}
+
+// LoadPlot は台本を読み込みます。ファイルがない場合は nil を返します。
+func (a *TitleStoreAdapter) LoadPlot(ctx context.Context, path string) (*domain.MangaPlot, error) {
+	var plot domain.MangaPlot
+	found, err := a.readJSON(ctx, path, &plot)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &plot, nil
+}
+
+// SavePlot は改訂した台本を保存します。
+func (a *TitleStoreAdapter) SavePlot(ctx context.Context, path string, plot *domain.MangaPlot) error {
+	return a.writeJSON(ctx, path, plot)
+}
+
+// readJSON は path の JSON を v にデコードします。ファイルがない場合は false を返します。
+func (a *TitleStoreAdapter) readJSON(ctx context.Context, path string, v any) (bool, error) {
+	exists, err := a.reader.Exists(ctx, path)
+	if err != nil {
+		return false, fmt.Errorf("failed to check %s: %w", path, err)
+	}
+	if !exists {
+		return false, nil
+	}
+
+	rc, err := a.reader.Open(ctx, path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer rc.Close()
+
+	if err := json.NewDecoder(rc).Decode(v); err != nil {
+		return false, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// writeJSON は v を JSON として保存します。いずれのファイルも後から更新されるため、キャッシュさせません。
+func (a *TitleStoreAdapter) writeJSON(ctx context.Context, path string, v any) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	return a.writer.Write(ctx, path, &buf,
+		remoteio.WithContentType("application/json"),
+		remoteio.WithCacheControl("no-cache"))
+}
@@ -0,0 +1,94 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"log/slog"
+
+	"github.com/shouni/go-remote-io/remoteio"
+
+	"ap-manga-web/internal/domain"
+	"ap-manga-web/internal/imaging"
+)
+
+// writeVariants は保存済みの画像から一覧表示用の縮小画像 (JPEG と WebP) を生成し、images/variants/ に保存します。
+// 縮小画像は表示を軽くするためのもので、閲覧側は存在しない場合に元画像を使用します。
+// そのため生成に失敗してもジョブは失敗させず、警告を記録して次の画像に進みます。
+func (w *WorkflowsAdapter) writeVariants(ctx context.Context, paths []string) {
+	if !w.imageVariants {
+		return
+	}
+	for _, src := range paths {
+		if err := ctx.Err(); err != nil {
+			return
+		}
+		if err := w.writeVariantsOf(ctx, src); err != nil {
+			slog.WarnContext(ctx, "Failed to write image variants", "path", src, "error", err)
+		}
+	}
+}
+
+// writeVariantsOf は1枚の画像について、元画像より小さい VariantWidths の縮小画像を保存します。
+// ファイル名に元画像の幅を含めるため、閲覧側は元画像を読み込まずに srcset を組み立てられます。
+func (w *WorkflowsAdapter) writeVariantsOf(ctx context.Context, src string) error {
+	data, err := w.readAll(ctx, src)
+	if err != nil {
+		return err
+	}
+	img, err := imaging.DecodeRGBA(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	width := img.Bounds().Dx()
+	for _, vw := range imaging.VariantWidths {
+		if vw >= width {
+			continue
+		}
+		if err := w.writeVariant(ctx, imaging.Thumbnail(img, vw), src, vw, width); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeVariant は縮小画像を JPEG と WebP の両方で保存します。
+// 閲覧画面は <picture> で WebP を優先し、JPEG は WebP に対応しないブラウザとフィードで使用します。
+// 作り直しの際に古い WebP が残らないよう、サイズに関わらず両方を上書きします。
+func (w *WorkflowsAdapter) writeVariant(ctx context.Context, img image.Image, src string, width, sourceWidth int) error {
+	jpg, err := imaging.EncodeVariant(img)
+	if err != nil {
+		return err
+	}
+	if err := w.saveVariant(ctx, imaging.VariantPath(src, width, sourceWidth, imaging.VariantJPEG), jpg, "image/jpeg"); err != nil {
+		return err
+	}
+
+	webp, err := imaging.EncodeWebP(img)
+	if err != nil {
+		return err
+	}
+	return w.saveVariant(ctx, imaging.VariantPath(src, width, sourceWidth, imaging.VariantWebP), webp, "image/webp")
+}
+
+func (w *WorkflowsAdapter) saveVariant(ctx context.Context, path string, buf *bytes.Buffer, contentType string) error {
+	if err := w.writer.Write(ctx, path, buf,
+		remoteio.WithContentType(contentType),
+		remoteio.WithCacheControl(imageCacheControl)); err != nil {
+		return fmt.Errorf("縮小画像の保存に失敗しました (%s): %w", path, err)
+	}
+	return nil
+}
+
+// panelPaths は台本から生成済みのパネル画像のパスを返します。
+func panelPaths(plot *domain.MangaPlot) []string {
+	var paths []string
+	for _, p := range plot.Panels {
+		if p.ReferenceURL != "" {
+			paths = append(paths, p.ReferenceURL)
+		}
+	}
+	return paths
+}
@@ -5,7 +5,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"sync"
 
+	"github.com/shouni/go-character-kit/character"
 	"github.com/shouni/go-gemini-client/gemini"
 	"github.com/shouni/go-http-kit/httpkit"
 	"github.com/shouni/go-manga-kit/ports"
@@ -15,21 +18,51 @@ import (
 
 	"ap-manga-web/assets"
 	"ap-manga-web/internal/app"
+	"ap-manga-web/internal/characters"
 	"ap-manga-web/internal/config"
+	"ap-manga-web/internal/domain"
+	"ap-manga-web/internal/imaging"
 	"ap-manga-web/internal/prompts"
 )
 
 // WorkflowsAdapter は、Workflows インターフェイスをラップするアダプタ構造体です。
 type WorkflowsAdapter struct {
-	workflows *ports.Workflows
-	writer    remoteio.OutputWriter
+	// args は Workflows の構築に使用する引数です。PromptDeps はキャラクター定義に合わせて currentPrompts で構築します。
+	args              workflow.ManagerArgs
+	characters        *characters.Registry
+	layouts           *prompts.LayoutCatalog
+	styleSuffix       string
+	reader            remoteio.InputReader
+	writer            remoteio.OutputWriter
+	maxDialogueLength int
+	// letterer はローカル写植用です。写植が無効な場合は nil です。
+	letterer *imaging.Letterer
+	// imageVariants が true の場合、画像の保存後に一覧表示用の縮小画像を生成します。
+	imageVariants bool
+
+	// promptMu は prompts を保護します。
+	promptMu sync.Mutex
+	// prompts は最後に構築した Prompt の依存関係です。
+	prompts *promptSet
+}
+
+// promptSet はキャラクター定義の版ごとに構築した Prompt の依存関係です。
+type promptSet struct {
+	version     string
+	deps        *workflow.PromptDeps
+	imagePrompt *prompts.ImageBuilder
 }
 
 // NewWorkflowsAdapter は Workflowsを初期化します。
-func NewWorkflowsAdapter(cfg *config.Config, httpClient httpkit.HTTPClient, rio *app.RemoteIO, geminiAI, vertexAI gemini.GenerativeModel) (*WorkflowsAdapter, error) {
-	promptDeps, err := buildPromptDeps(cfg.StyleSuffix)
+func NewWorkflowsAdapter(ctx context.Context, cfg *config.Config, httpClient httpkit.HTTPClient, rio *app.RemoteIO, geminiAI, vertexAI gemini.GenerativeModel, chars *characters.Registry) (*WorkflowsAdapter, error) {
+	layouts, err := loadLayoutCatalog(ctx, rio.Reader, cfg.LayoutTemplatesURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize prompt dependencies: %w", err)
+		return nil, fmt.Errorf("failed to load layout templates: %w", err)
+	}
+
+	letterer, err := newLetterer(ctx, cfg, rio.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize local lettering: %w", err)
 	}
 
 	contentReader, err := reader.New(
@@ -56,49 +89,219 @@ func NewWorkflowsAdapter(cfg *config.Config, httpClient httpkit.HTTPClient, rio
 		Writer:          rio.Writer,
 		AIClient:        geminiAI,
 		AIClientQuality: vertexAI,
-		PromptDeps:      promptDeps,
 	}
+
+	w := &WorkflowsAdapter{
+		args:              args,
+		characters:        chars,
+		layouts:           layouts,
+		styleSuffix:       cfg.StyleSuffix,
+		reader:            rio.Reader,
+		writer:            rio.Writer,
+		maxDialogueLength: cfg.MaxDialogueLength,
+		letterer:          letterer,
+		imageVariants:     cfg.ImageVariants,
+	}
+	// 起動時に一度構築し、キャラクター定義やプロンプトの誤りを検出します
+	if _, err := w.currentPrompts(ctx); err != nil {
+		return nil, fmt.Errorf("failed to initialize prompt dependencies: %w", err)
+	}
+	return w, nil
+}
+
+// currentPrompts は現在のキャラクター定義で構築した Prompt の依存関係を返します。
+// 定義が保存し直されていれば構築し直すため、追加・編集したキャラクターは再起動せずに次のジョブから使用されます。
+func (w *WorkflowsAdapter) currentPrompts(ctx context.Context) (*promptSet, error) {
+	snap, err := w.characters.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	w.promptMu.Lock()
+	defer w.promptMu.Unlock()
+	if w.prompts != nil && w.prompts.version == snap.Version {
+		return w.prompts, nil
+	}
+	deps, imagePrompt, err := buildPromptDeps(w.styleSuffix, w.layouts, w.letterer != nil, w.args.Config.MaxPanelsPerPage, snap.Characters())
+	if err != nil {
+		return nil, err
+	}
+	if w.prompts != nil {
+		slog.InfoContext(ctx, "キャラクター定義の更新を反映しました", "version", snap.Version)
+	}
+	w.prompts = &promptSet{version: snap.Version, deps: deps, imagePrompt: imagePrompt}
+	return w.prompts, nil
+}
+
+// scopedWorkflows は、p の Prompt を持つ Workflows をジョブ単位で構築します。
+// 描画指定と台本の演出指定を適用する場合は、p に scopedPrompts で生成したものを渡します。
+// go-manga-kit は PromptDeps を構築時に固定するため、ジョブごとの指定やキャラクター定義の更新はこの経路で反映します。
+// ImagePrompt はジョブを識別できないため、参照画像のキャッシュも含めて Workflows はジョブ間で共有しません。
+// 画像モデルは色表現モードに合わせてネガティブプロンプトを書き換えるものに差し替えます。
+// 呼び出し元は使用後に Close を呼び出す必要があります。
+func (w *WorkflowsAdapter) scopedWorkflows(p *promptSet) (*ports.Workflows, error) {
+	deps := *p.deps
+	deps.ImagePrompt = p.imagePrompt
+
+	args := w.args
+	args.PromptDeps = &deps
+	args.AIClient = withColorMode(args.AIClient, p.imagePrompt.ColorMode())
+	args.AIClientQuality = withColorMode(args.AIClientQuality, p.imagePrompt.ColorMode())
 	workflows, err := workflow.New(args)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create workflows: %w", err)
+		return nil, fmt.Errorf("failed to create scoped workflows: %w", err)
 	}
+	return workflows, nil
+}
 
-	return &WorkflowsAdapter{
-		workflows: workflows,
-		writer:    rio.Writer,
-	}, nil
+// currentWorkflows は現在のキャラクター定義で Workflows を構築します。呼び出し元は使用後に Close を呼び出す必要があります。
+func (w *WorkflowsAdapter) currentWorkflows(ctx context.Context) (*ports.Workflows, error) {
+	p, err := w.currentPrompts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return w.scopedWorkflows(p)
 }
 
 // Design は指定されたキャラクターIDのキャラクターを生成します。
 func (w *WorkflowsAdapter) Design(ctx context.Context, charIDs []string, seed int64, outputDir string) (string, int64, error) {
-	return w.workflows.Design.Run(ctx, charIDs, seed, outputDir)
+	workflows, err := w.currentWorkflows(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+	defer workflows.Close()
+
+	return workflows.Design.Run(ctx, charIDs, seed, outputDir)
 }
 
 // Script は指定されたURLから台本を作成し、JSON を保存します。
-func (w *WorkflowsAdapter) Script(ctx context.Context, sourceURL, mode, outputPath string) (*ports.MangaResponse, error) {
-	manga, err := w.workflows.Script.Run(ctx, sourceURL, mode)
+// 保存前に、吹き出しに収まらない長いセリフを追加パネルへ分割します。
+func (w *WorkflowsAdapter) Script(ctx context.Context, sourceURL, mode, outputPath string) (*domain.MangaPlot, error) {
+	workflows, err := w.currentWorkflows(ctx)
 	if err != nil {
 		return nil, err
 	}
-	if err := w.saveJSON(ctx, outputPath, manga); err != nil {
-		return manga, err
+	defer workflows.Close()
+
+	manga, err := workflows.Script.Run(ctx, sourceURL, mode)
+	if err != nil {
+		return nil, err
+	}
+	plot := domain.NewMangaPlot(manga)
+	if added := plot.SplitLongDialogue(w.maxDialogueLength, w.args.Config.MaxPanelsPerPage); added > 0 {
+		slog.InfoContext(ctx, "Split overlong dialogue into additional panels",
+			"added_panels", added,
+			"max_dialogue_length", w.maxDialogueLength,
+		)
+	}
+	if err := w.saveJSON(ctx, outputPath, plot); err != nil {
+		return plot, err
 	}
-	return manga, nil
+	return plot, nil
 }
 
-// Panel は指定された漫画のページを保存します。
-func (w *WorkflowsAdapter) Panel(ctx context.Context, manga *ports.MangaResponse, outputPath string) (*ports.MangaResponse, error) {
-	return w.workflows.PanelImage.RunAndSave(ctx, manga, outputPath)
+// scopedPrompts は現在のキャラクター定義の Prompt に、ジョブの描画指定と台本を適用した ImageBuilder を組み合わせて返します。
+func (w *WorkflowsAdapter) scopedPrompts(ctx context.Context, plot *domain.MangaPlot, opts domain.ImageOptions) (*promptSet, error) {
+	p, err := w.currentPrompts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scoped := *p
+	scoped.imagePrompt = p.imagePrompt.WithOptions(opts).WithPlot(plot)
+	return &scoped, nil
 }
 
-// Page は指定された漫画のページを保存します。
-func (w *WorkflowsAdapter) Page(ctx context.Context, manga *ports.MangaResponse, outputPath string) ([]string, error) {
-	return w.workflows.PageImage.RunAndSave(ctx, manga, outputPath)
+// Panel は指定された描画指定でパネル画像を生成し、保存します。
+// go-manga-kit は台本を ports.MangaResponse として保存し直すため、演出指定を含む台本で上書き保存します。
+// ローカル写植が有効な場合は、保存後のパネル画像に写植を行います。
+// 最後に、一覧表示用の縮小画像を生成します。
+func (w *WorkflowsAdapter) Panel(ctx context.Context, plot *domain.MangaPlot, outputPath string, opts domain.ImageOptions) (*domain.MangaPlot, error) {
+	p, err := w.scopedPrompts(ctx, plot, opts)
+	if err != nil {
+		return nil, err
+	}
+	workflows, err := w.scopedWorkflows(p)
+	if err != nil {
+		return nil, err
+	}
+	defer workflows.Close()
+
+	updated, err := workflows.PanelImage.RunAndSave(ctx, plot.Response(), outputPath)
+	if err != nil {
+		return nil, err
+	}
+	plot.ApplyResponse(updated)
+	if err := w.saveJSON(ctx, outputPath, plot); err != nil {
+		return plot, err
+	}
+
+	if w.letterer != nil {
+		if err := w.letterPanels(ctx, plot); err != nil {
+			return plot, fmt.Errorf("panel lettering failed: %w", err)
+		}
+	}
+
+	w.writeVariants(ctx, panelPaths(plot))
+	return plot, nil
+}
+
+// Page は指定された描画指定でページ画像を生成し、保存します。
+// PageRendererCompose の場合は、画像モデルを使わずにパネル画像を合成します。
+// ローカル写植が有効な場合は、写植前のパネル画像を参照して生成し、保存後のページ画像に写植を行います。
+// 最後に、一覧表示用の縮小画像を生成します。
+func (w *WorkflowsAdapter) Page(ctx context.Context, plot *domain.MangaPlot, outputPath string, opts domain.ImageOptions) ([]string, error) {
+	pagePaths, err := w.renderPages(ctx, plot, outputPath, opts)
+	if err != nil {
+		return pagePaths, err
+	}
+
+	w.writeVariants(ctx, pagePaths)
+	return pagePaths, nil
+}
+
+// renderPages は描画方式に応じてページ画像を生成し、保存したパスを返します。
+func (w *WorkflowsAdapter) renderPages(ctx context.Context, plot *domain.MangaPlot, outputPath string, opts domain.ImageOptions) ([]string, error) {
+	p, err := w.scopedPrompts(ctx, plot, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.PageRenderer == domain.PageRendererCompose {
+		return w.composePages(ctx, plot, p.imagePrompt, outputPath)
+	}
+
+	workflows, err := w.scopedWorkflows(p)
+	if err != nil {
+		return nil, err
+	}
+	defer workflows.Close()
+
+	manga := plot.Response()
+	if w.letterer != nil {
+		manga = w.withRawPanels(ctx, manga)
+	}
+
+	pagePaths, err := workflows.PageImage.RunAndSave(ctx, manga, outputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if w.letterer != nil {
+		if err := w.letterPages(ctx, plot, p.imagePrompt, pagePaths); err != nil {
+			return pagePaths, fmt.Errorf("page lettering failed: %w", err)
+		}
+	}
+	return pagePaths, nil
 }
 
 // Publish は指定された漫画を公開します。
-func (w *WorkflowsAdapter) Publish(ctx context.Context, manga *ports.MangaResponse, outputDir string) (*ports.PublishResult, error) {
-	return w.workflows.Publish.Run(ctx, manga, outputDir)
+func (w *WorkflowsAdapter) Publish(ctx context.Context, plot *domain.MangaPlot, outputDir string) (*ports.PublishResult, error) {
+	workflows, err := w.currentWorkflows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer workflows.Close()
+
+	return workflows.Publish.Run(ctx, plot.Response(), outputDir)
 }
 
 // saveJSON は指定されたデータを JSON 形式で保存します。
@@ -114,27 +317,26 @@ func (w *WorkflowsAdapter) saveJSON(ctx context.Context, path string, data any)
 		remoteio.WithCacheControl("public, max-age=1800"))
 }
 
-// buildPromptDeps は Prompt ビルダーを初期化します。
-func buildPromptDeps(styleSuffix string) (*workflow.PromptDeps, error) {
+// buildPromptDeps はキャラクター定義 charMap を使用する Prompt ビルダーを初期化します。
+// ジョブ単位で描画指定を適用できるよう、ImageBuilder 自体も併せて返します。
+func buildPromptDeps(styleSuffix string, layouts *prompts.LayoutCatalog, localLettering bool, panelsPerPage int, charMap *character.Characters) (*workflow.PromptDeps, *prompts.ImageBuilder, error) {
 	templates, err := assets.LoadPrompts()
 	if err != nil {
-		return nil, fmt.Errorf("プロンプトテンプレートの読み込みに失敗しました: %w", err)
+		return nil, nil, fmt.Errorf("プロンプトテンプレートの読み込みに失敗しました: %w", err)
 	}
 
 	textPrompt, err := prompts.NewBuilder(templates)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create text prompt builder: %w", err)
+		return nil, nil, fmt.Errorf("failed to create text prompt builder: %w", err)
 	}
 
-	charMap, err := assets.LoadCharacters()
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate character map: %w", err)
-	}
-	imagePrompt := prompts.NewImageBuilder(charMap, styleSuffix)
+	imagePrompt := prompts.NewImageBuilder(charMap, layouts, styleSuffix).
+		WithLocalLettering(localLettering).
+		WithPanelsPerPage(panelsPerPage)
 
 	return &workflow.PromptDeps{
 		Characters:   charMap,
 		ScriptPrompt: textPrompt,
 		ImagePrompt:  imagePrompt,
-	}, nil
+	}, imagePrompt, nil
 }
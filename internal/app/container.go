@@ -7,8 +7,11 @@ import (
 	"github.com/shouni/go-http-kit/httpkit"
 	"github.com/shouni/go-remote-io/remoteio"
 
+	"ap-manga-web/internal/characters"
 	"ap-manga-web/internal/config"
 	"ap-manga-web/internal/domain"
+	"ap-manga-web/internal/search"
+	"ap-manga-web/internal/share"
 )
 
 // Container はアプリケーションの依存関係（DIコンテナ）を保持します。
@@ -20,6 +23,14 @@ type Container struct {
 	TaskEnqueuer *tasks.Enqueuer[domain.GenerateTaskPayload]
 	// Business Logic
 	Pipeline domain.Pipeline
+	// Search は全文検索の索引です。ワーカーが更新し、Web 画面から検索します。
+	Search *search.Store
+	// Titles は作業ディレクトリのジョブ記録とメタデータ (job.json, meta.json) を読み書きします。
+	Titles domain.TitleStore
+	// Shares は共有リンクの発行と検証を行います。共有リンクを無効にしている場合は nil です。
+	Shares *share.Store
+	// Characters は埋め込みの定義にバケットの定義を重ねたキャラクター定義です。管理画面から追加・編集します。
+	Characters *characters.Registry
 	// External Adapters
 	HTTPClient httpkit.HTTPClient
 	Notifier   domain.Notifier
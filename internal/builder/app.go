@@ -66,12 +66,26 @@ func BuildContainer(ctx context.Context, cfg *config.Config) (container *app.Con
 		vertexAI = geminiAI
 	}
 
-	workflows, err := adapters.NewWorkflowsAdapter(cfg, httpClient, rio, geminiAI, vertexAI)
+	chars, err := BuildCharacterRegistry(cfg, rio)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize character registry: %w", err)
+	}
+
+	workflows, err := adapters.NewWorkflowsAdapter(ctx, cfg, httpClient, rio, geminiAI, vertexAI, chars)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize manga workflow: %w", err)
 	}
+	searchIndex := BuildSearchIndex(cfg, rio, chars)
+
+	shares, err := BuildShareStore(cfg, rio)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize share links: %w", err)
+	}
+
+	titles := adapters.NewTitleStoreAdapter(rio.Reader, rio.Writer)
+
 	// 3. Pipeline (Core Logic)
-	mangaPipeline, err := buildPipeline(cfg, workflows, slack)
+	mangaPipeline, err := buildPipeline(cfg, workflows, slack, titles, searchIndex)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize manga pipeline: %w", err)
 	}
@@ -81,6 +95,10 @@ func BuildContainer(ctx context.Context, cfg *config.Config) (container *app.Con
 		RemoteIO:     rio,
 		TaskEnqueuer: enqueuer,
 		Pipeline:     mangaPipeline,
+		Search:       searchIndex,
+		Characters:   chars,
+		Titles:       titles,
+		Shares:       shares,
 		HTTPClient:   httpClient,
 		Notifier:     slack,
 	}
@@ -2,8 +2,10 @@ package builder
 
 import (
 	"fmt"
+	"net/http"
 	"net/url"
 
+	"github.com/gorilla/sessions"
 	"github.com/shouni/gcp-kit/auth"
 	"github.com/shouni/gcp-kit/worker"
 
@@ -21,6 +23,8 @@ type AppHandlers struct {
 	Auth   *auth.Handler
 	Web    *handlers.Handler
 	Worker *worker.Handler[domain.GenerateTaskPayload]
+	// CurrentUser はセッションからログイン中の利用者のメールアドレスを返します。
+	CurrentUser func(r *http.Request) string
 }
 
 // BuildHandlers は各ハンドラーの依存関係をすべて組み立て、AppHandlers 構造体を返します。
@@ -38,7 +42,7 @@ func BuildHandlers(
 	}
 
 	// 2. Web UI 用Handlerの初期化
-	webHandler, err := handlers.NewHandler(appCtx.Config, appCtx.TaskEnqueuer, appCtx.RemoteIO)
+	webHandler, err := handlers.NewHandler(appCtx.Config, appCtx.TaskEnqueuer, appCtx.RemoteIO, appCtx.Search, appCtx.Titles, appCtx.Shares, appCtx.Characters)
 	if err != nil {
 		return nil, fmt.Errorf("WebHandlerの初期化に失敗しました: %w", err)
 	}
@@ -47,9 +51,10 @@ func BuildHandlers(
 	workerHandler := worker.NewHandler[domain.GenerateTaskPayload](appCtx.Pipeline)
 
 	return &AppHandlers{
-		Auth:   authHandler,
-		Web:    webHandler,
-		Worker: workerHandler,
+		Auth:        authHandler,
+		Web:         webHandler,
+		Worker:      workerHandler,
+		CurrentUser: newCurrentUserFunc(appCtx.Config),
 	}, nil
 }
 
@@ -73,3 +78,17 @@ func createAuthHandler(cfg *config.Config) (*auth.Handler, error) {
 		TaskAudienceURL:   cfg.ServiceURL,
 	})
 }
+
+// newCurrentUserFunc は、認証Handlerと同じ鍵でセッション Cookie を読み、ログイン中の利用者のメールアドレスを返す関数を作成します。
+// gcp-kit の auth.Handler はメールアドレスを取得する API を公開していないため、セッションを直接参照します。
+func newCurrentUserFunc(cfg *config.Config) func(r *http.Request) string {
+	store := sessions.NewCookieStore([]byte(cfg.SessionSecret), []byte(cfg.SessionEncryptKey))
+	return func(r *http.Request) string {
+		session, err := store.Get(r, defaultSessionName)
+		if err != nil {
+			return ""
+		}
+		email, _ := session.Values[auth.DefaultUserSessionKey].(string)
+		return email
+	}
+}
@@ -1,13 +1,35 @@
 package builder
 
 import (
+	"context"
 	"fmt"
 
+	"ap-manga-web/assets"
 	"ap-manga-web/internal/app"
+	"ap-manga-web/internal/characters"
+	"ap-manga-web/internal/config"
+	"ap-manga-web/internal/search"
+	"ap-manga-web/internal/share"
 
 	"github.com/shouni/go-remote-io/remoteio"
+	"github.com/shouni/go-remote-io/remoteio/gcs"
 )
 
+// BuildStorage は GCS に接続し、I/O コンポーネントのみを初期化します。
+// CLI など、Web サーバーの依存関係を必要としない用途で使用します。呼び出し側で Close する必要があります。
+func BuildStorage(ctx context.Context) (*app.RemoteIO, error) {
+	storage, err := gcs.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS factory: %w", err)
+	}
+	rio, err := buildRemoteIO(storage)
+	if err != nil {
+		_ = storage.Close()
+		return nil, fmt.Errorf("failed to initialize IO components: %w", err)
+	}
+	return rio, nil
+}
+
 // buildRemoteIO は、GCS ベースの I/O コンポーネントを初期化します。
 func buildRemoteIO(storage remoteio.IOFactory) (*app.RemoteIO, error) {
 	if storage == nil {
@@ -33,3 +55,30 @@ func buildRemoteIO(storage remoteio.IOFactory) (*app.RemoteIO, error) {
 		Signer:  s,
 	}, nil
 }
+
+// BuildCharacterRegistry は埋め込みのキャラクター定義に CHARACTER_REGISTRY_PATH の定義を重ねる Registry を初期化します。
+func BuildCharacterRegistry(cfg *config.Config, rio *app.RemoteIO) (*characters.Registry, error) {
+	registry, err := characters.NewRegistry(rio.Reader, rio.Writer, cfg.GetGCSObjectURL(cfg.CharacterRegistryPath), assets.DefaultCharacters())
+	if err != nil {
+		return nil, fmt.Errorf("キャラクター定義の読み込みに失敗しました: %w", err)
+	}
+	return registry, nil
+}
+
+// BuildSearchIndex は BaseOutputDir 直下の検索索引を扱う Store を初期化します。
+// キャラクターの表示名は chars の現在の定義で解決します。
+func BuildSearchIndex(cfg *config.Config, rio *app.RemoteIO, chars *characters.Registry) *search.Store {
+	return search.NewStore(rio.Reader, rio.Writer, cfg.GetGCSObjectURL(cfg.BaseOutputDir), chars)
+}
+
+// BuildShareStore は共有リンクを扱う Store を初期化します。SHARE_TOKEN_SECRET が空の場合は nil を返します。
+func BuildShareStore(cfg *config.Config, rio *app.RemoteIO) (*share.Store, error) {
+	if cfg.ShareTokenSecret == "" {
+		return nil, nil
+	}
+	signer, err := share.NewSigner(cfg.ShareTokenSecret)
+	if err != nil {
+		return nil, err
+	}
+	return share.NewStore(signer, rio.Reader, rio.Writer, cfg.GetGCSObjectURL(cfg.BaseOutputDir)), nil
+}
@@ -7,8 +7,8 @@ import (
 )
 
 // buildPipeline は、提供された設定と各コンポーネントを使用して新しいパイプラインを初期化して返します。
-func buildPipeline(cfg *config.Config, workflows domain.Workflows, slack domain.Notifier) (domain.Pipeline, error) {
-	p, err := pipeline.NewMangaPipeline(cfg, workflows, slack)
+func buildPipeline(cfg *config.Config, workflows domain.Workflows, slack domain.Notifier, titles domain.TitleStore, indexer domain.SearchIndexer) (domain.Pipeline, error) {
+	p, err := pipeline.NewMangaPipeline(cfg, workflows, slack, titles, indexer)
 	if err != nil {
 		return nil, err
 	}
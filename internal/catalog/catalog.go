@@ -0,0 +1,333 @@
+// Package catalog は BaseOutputDir 以下に生成された作品の一覧を組み立てます。
+// ストレージを1回だけ走査し、作品ごとの台本・ジョブ記録・メタデータの要約はキャッシュして再利用します。
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shouni/go-manga-kit/asset"
+
+	"ap-manga-web/internal/domain"
+	"ap-manga-web/internal/export"
+	"ap-manga-web/internal/imaging"
+)
+
+const (
+	// summaryTTL は台本・ジョブ記録・メタデータの要約をキャッシュする期間です。
+	summaryTTL = 10 * time.Minute
+	// loadConcurrency は要約を読み込む際の同時実行数です。
+	loadConcurrency = 8
+	// titleTimeLayout は作業ディレクトリ名の先頭にある日時の書式です。(例: 20260113_153000_abcd1234)
+	titleTimeLayout = "20060102_150405"
+)
+
+var jst = time.FixedZone("Asia/Tokyo", 9*60*60)
+
+// Entry は一覧に表示する作品1件分の情報です。
+type Entry struct {
+	// Name は作業ディレクトリ名（URL 上のタイトル）です。
+	Name        string
+	Title       string
+	Description string
+	// Job はジョブ記録です。記録のない古い作品では nil です。
+	Job *domain.JobRecord
+	// Meta は利用者が編集したメタデータです。未編集の作品では nil です。
+	Meta *domain.TitleMeta
+	// CreatedAt はジョブの開始時刻です。ジョブ記録がない場合は作業ディレクトリ名から求めます。
+	CreatedAt time.Time
+	// Pages と Panels は画像のストレージ上のパスを連番順に並べたものです。
+	Pages  []string
+	Panels []string
+	// Variants は縮小画像のストレージ上のパスです。
+	Variants []string
+
+	hasJob  bool
+	hasMeta bool
+}
+
+// Thumbnail は一覧に表示する画像のパスを返します。1ページ目、なければ最初のパネルを使用します。
+func (e *Entry) Thumbnail() string {
+	if len(e.Pages) > 0 {
+		return e.Pages[0]
+	}
+	if len(e.Panels) > 0 {
+		return e.Panels[0]
+	}
+	return ""
+}
+
+// DisplayTitle はメタデータの表示タイトル、なければ台本のタイトル、それもなければ作業ディレクトリ名を返します。
+func (e *Entry) DisplayTitle() string {
+	if e.Meta != nil && e.Meta.DisplayTitle != "" {
+		return e.Meta.DisplayTitle
+	}
+	if e.Title != "" {
+		return e.Title
+	}
+	return e.Name
+}
+
+// Owner はメタデータの担当者、なければジョブを投入した利用者を返します。
+func (e *Entry) Owner() string {
+	if e.Meta != nil && e.Meta.Owner != "" {
+		return e.Meta.Owner
+	}
+	if e.Job != nil {
+		return e.Job.Owner
+	}
+	return ""
+}
+
+// Tags はメタデータのタグを返します。
+func (e *Entry) Tags() []string {
+	if e.Meta == nil {
+		return nil
+	}
+	return e.Meta.Tags
+}
+
+// Pinned はギャラリーで先頭に固定表示する作品かを返します。
+func (e *Entry) Pinned() bool {
+	return e.Meta != nil && e.Meta.Pinned
+}
+
+// Favorite はお気に入りの作品かを返します。
+func (e *Entry) Favorite() bool {
+	return e.Meta != nil && e.Meta.Favorite
+}
+
+// VariantsOf は src の縮小画像のパスを返します。
+func (e *Entry) VariantsOf(src string) []string {
+	name := path.Base(src)
+	stem := strings.TrimSuffix(name, path.Ext(name))
+	var out []string
+	for _, v := range e.Variants {
+		if pv, ok := imaging.ParseVariantName(path.Base(v)); ok && pv.Stem == stem {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// summary は manga_plot.json、job.json、meta.json から読み込んだ内容です。
+type summary struct {
+	title       string
+	description string
+	job         *domain.JobRecord
+	meta        *domain.TitleMeta
+	expires     time.Time
+}
+
+// Catalog は作品一覧を提供します。
+type Catalog struct {
+	store export.Storage
+	root  string
+
+	mu        sync.Mutex
+	summaries map[string]summary
+}
+
+// New は root (例: gs://bucket/output) 以下の作品を一覧する Catalog を作成します。
+func New(store export.Storage, root string) *Catalog {
+	return &Catalog{
+		store:     store,
+		root:      strings.TrimSuffix(root, "/"),
+		summaries: make(map[string]summary),
+	}
+}
+
+// Entries は台本 (manga_plot.json) のあるすべての作品を返します。並び順は作業ディレクトリ名の順です。
+// ストレージの一覧取得は再帰的な前方一致検索 (GCS) を前提としています。
+func (c *Catalog) Entries(ctx context.Context) ([]*Entry, error) {
+	entries, err := c.Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.loadSummaries(ctx, entries)
+	return entries, nil
+}
+
+// Invalidate は作品の要約のキャッシュを破棄します。台本やメタデータを更新した後に呼び出します。
+func (c *Catalog) Invalidate(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.summaries, name)
+}
+
+// Scan はストレージを1回だけ走査し、作業ディレクトリごとにファイルを振り分けます。
+// 台本・ジョブ記録は読み込まないため、Title などの要約は空のままです。
+func (c *Catalog) Scan(ctx context.Context) ([]*Entry, error) {
+	byName := make(map[string]*Entry)
+	var names []string
+	hasPlot := make(map[string]bool)
+	variantDir := path.Join(asset.DefaultImageDir, imaging.VariantDir)
+
+	err := c.store.List(ctx, c.root+"/", func(p string) error {
+		rel, ok := strings.CutPrefix(p, c.root+"/")
+		if !ok {
+			return nil
+		}
+		name, file, ok := strings.Cut(rel, "/")
+		if !ok || name == "" {
+			return nil
+		}
+		e, ok := byName[name]
+		if !ok {
+			e = &Entry{Name: name}
+			byName[name] = e
+			names = append(names, name)
+		}
+
+		switch {
+		case file == asset.DefaultMangaPlotJson:
+			hasPlot[name] = true
+		case file == domain.JobRecordFile:
+			e.hasJob = true
+		case file == domain.MetaFile:
+			e.hasMeta = true
+		case path.Dir(file) == asset.DefaultImageDir && asset.PageFileRegex.MatchString(path.Base(file)):
+			e.Pages = append(e.Pages, p)
+		case path.Dir(file) == asset.DefaultImageDir && asset.PanelFileRegex.MatchString(path.Base(file)):
+			e.Panels = append(e.Panels, p)
+		case path.Dir(file) == variantDir && imaging.VariantFileRegex.MatchString(path.Base(file)):
+			e.Variants = append(e.Variants, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("作品一覧の取得に失敗しました: %w", err)
+	}
+
+	// キャラクターデザインなど、台本のないディレクトリは作品として扱いません
+	entries := make([]*Entry, 0, len(names))
+	for _, name := range names {
+		if !hasPlot[name] {
+			continue
+		}
+		e := byName[name]
+		export.SortIndexedPaths(e.Pages)
+		export.SortIndexedPaths(e.Panels)
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// loadSummaries は各作品の要約をキャッシュから、なければストレージから並行して読み込みます。
+// 読み込みに失敗した作品も、作業ディレクトリ名だけで一覧に残します。
+func (c *Catalog) loadSummaries(ctx context.Context, entries []*Entry) {
+	now := time.Now()
+	sem := make(chan struct{}, loadConcurrency)
+	var wg sync.WaitGroup
+
+	for _, e := range entries {
+		s, ok := c.cached(e.Name, now)
+		if !ok {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				s := c.readSummary(ctx, e)
+				s.expires = now.Add(summaryTTL)
+				c.remember(e.Name, s)
+				e.apply(s)
+			}()
+			continue
+		}
+		e.apply(s)
+	}
+	wg.Wait()
+}
+
+func (c *Catalog) cached(name string, now time.Time) (summary, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.summaries[name]
+	if !ok || now.After(s.expires) {
+		return summary{}, false
+	}
+	return s, true
+}
+
+func (c *Catalog) remember(name string, s summary) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.summaries[name] = s
+}
+
+// readSummary は manga_plot.json と job.json、meta.json を読み込みます。
+func (c *Catalog) readSummary(ctx context.Context, e *Entry) summary {
+	var s summary
+	workDir := c.root + "/" + e.Name
+
+	var plot domain.MangaPlot
+	if err := c.readJSON(ctx, workDir+"/"+asset.DefaultMangaPlotJson, &plot); err != nil {
+		slog.WarnContext(ctx, "台本の読み込みに失敗しました", "title", e.Name, "error", err)
+	} else {
+		s.title = plot.Title
+		s.description = plot.Description
+	}
+
+	if e.hasJob {
+		var job domain.JobRecord
+		if err := c.readJSON(ctx, workDir+"/"+domain.JobRecordFile, &job); err != nil {
+			slog.WarnContext(ctx, "ジョブ記録の読み込みに失敗しました", "title", e.Name, "error", err)
+		} else {
+			s.job = &job
+		}
+	}
+
+	if e.hasMeta {
+		var meta domain.TitleMeta
+		if err := c.readJSON(ctx, workDir+"/"+domain.MetaFile, &meta); err != nil {
+			slog.WarnContext(ctx, "メタデータの読み込みに失敗しました", "title", e.Name, "error", err)
+		} else {
+			s.meta = &meta
+		}
+	}
+	return s
+}
+
+func (c *Catalog) readJSON(ctx context.Context, p string, v any) error {
+	rc, err := c.store.Open(ctx, p)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := json.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("JSONの解析に失敗しました (%s): %w", p, err)
+	}
+	return nil
+}
+
+// apply は要約の内容を一覧の項目に反映します。
+func (e *Entry) apply(s summary) {
+	e.Title = s.title
+	e.Description = s.description
+	e.Job = s.job
+	e.Meta = s.meta
+	if s.job != nil && !s.job.CreatedAt.IsZero() {
+		e.CreatedAt = s.job.CreatedAt
+	} else {
+		e.CreatedAt, _ = ParseCreatedAt(e.Name)
+	}
+}
+
+// ParseCreatedAt は作業ディレクトリ名 (例: 20260113_153000_abcd1234) の先頭の日時 (JST) を返します。
+func ParseCreatedAt(name string) (time.Time, bool) {
+	if len(name) < len(titleTimeLayout) {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(titleTimeLayout, name[:len(titleTimeLayout)], jst)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,160 @@
+package catalog
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"ap-manga-web/internal/domain"
+	"ap-manga-web/internal/storagetest"
+)
+
+func TestEntries(t *testing.T) {
+	store := storagetest.Memory{
+		"gs://b/output/20260101_090000_aaaaaaaa/manga_plot.json":                          `{"title":"Go 入門","description":"desc"}`,
+		"gs://b/output/20260101_090000_aaaaaaaa/images/panel_2.png":                       "",
+		"gs://b/output/20260101_090000_aaaaaaaa/images/panel_10.png":                      "",
+		"gs://b/output/20260101_090000_aaaaaaaa/images/raw/panel_2.png":                   "",
+		"gs://b/output/20260101_090000_aaaaaaaa/images/variants/panel_2_w480_of1024.jpg":  "",
+		"gs://b/output/20260101_090000_aaaaaaaa/images/variants/panel_10_w480_of1024.jpg": "",
+		"gs://b/output/20260102_090000_bbbbbbbb/manga_plot.json":                          `{"title":"Rust"}`,
+		"gs://b/output/20260102_090000_bbbbbbbb/job.json":                                 `{"command":"generate","mode":"duet","owner":"a@example.com","created_at":"2026-01-02T00:00:05Z"}`,
+		"gs://b/output/20260102_090000_bbbbbbbb/meta.json":                                `{"display_title":"Rust 再入門","tags":["研修"]}`,
+		"gs://b/output/20260102_090000_bbbbbbbb/images/manga_page_1.png":                  "",
+		"gs://b/output/character/zundamon.png":                                            "",
+		"gs://b/output_other/x/manga_plot.json":                                           `{}`,
+	}
+
+	entries, err := New(store, "gs://b/output/").Entries(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("len(entries) = %d, want 2", len(entries))
+	}
+
+	a := entries[0]
+	if a.Title != "Go 入門" || a.Description != "desc" || a.Job != nil {
+		t.Errorf("entry a = %+v", a)
+	}
+	wantPanels := []string{
+		"gs://b/output/20260101_090000_aaaaaaaa/images/panel_2.png",
+		"gs://b/output/20260101_090000_aaaaaaaa/images/panel_10.png",
+	}
+	if !slices.Equal(a.Panels, wantPanels) {
+		t.Errorf("Panels = %v, want %v", a.Panels, wantPanels)
+	}
+	if got := a.Thumbnail(); got != wantPanels[0] {
+		t.Errorf("Thumbnail() = %q", got)
+	}
+	if got := a.VariantsOf(a.Thumbnail()); len(got) != 1 || !strings.HasSuffix(got[0], "panel_2_w480_of1024.jpg") {
+		t.Errorf("VariantsOf() = %v", got)
+	}
+	if want := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC); !a.CreatedAt.Equal(want) {
+		t.Errorf("CreatedAt = %v, want %v (from the directory name)", a.CreatedAt, want)
+	}
+
+	b := entries[1]
+	if b.Job == nil || b.Job.Command != "generate" || b.Job.Mode != "duet" {
+		t.Fatalf("Job = %+v", b.Job)
+	}
+	if want := time.Date(2026, 1, 2, 0, 0, 5, 0, time.UTC); !b.CreatedAt.Equal(want) {
+		t.Errorf("CreatedAt = %v, want %v (from the job record)", b.CreatedAt, want)
+	}
+	if b.DisplayTitle() != "Rust 再入門" || !slices.Equal(b.Tags(), []string{"研修"}) || b.Owner() != "a@example.com" {
+		t.Errorf("meta = %+v, owner = %q", b.Meta, b.Owner())
+	}
+	if got := b.Thumbnail(); !strings.HasSuffix(got, "images/manga_page_1.png") {
+		t.Errorf("Thumbnail() = %q", got)
+	}
+}
+
+func TestFilterAndSort(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 1, d, 0, 0, 0, 0, time.UTC) }
+	entries := []*Entry{
+		{Name: "t1", Title: "Go 入門", CreatedAt: day(2)},
+		{Name: "t2", Title: "rust", CreatedAt: day(3)},
+		{Name: "t3", Title: "Going further", CreatedAt: day(1)},
+	}
+
+	names := func(es []*Entry) []string {
+		var out []string
+		for _, e := range es {
+			out = append(out, e.Name)
+		}
+		return out
+	}
+
+	if got := names(Filter(entries, " go ")); !slices.Equal(got, []string{"t1", "t3"}) {
+		t.Errorf("Filter(go) = %v", got)
+	}
+	if got := names(Filter(entries, "T2")); !slices.Equal(got, []string{"t2"}) {
+		t.Errorf("Filter(T2) = %v (matches the directory name)", got)
+	}
+
+	tests := []struct {
+		key  SortKey
+		want []string
+	}{
+		{ParseSortKey(""), []string{"t2", "t1", "t3"}},
+		{ParseSortKey("old"), []string{"t3", "t1", "t2"}},
+		{ParseSortKey("title"), []string{"t1", "t3", "t2"}},
+	}
+	for _, tt := range tests {
+		Sort(entries, tt.key)
+		if got := names(entries); !slices.Equal(got, tt.want) {
+			t.Errorf("Sort(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestMetaFilterAndSort(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 1, d, 0, 0, 0, 0, time.UTC) }
+	entries := []*Entry{
+		{Name: "t1", Title: "Go 入門", CreatedAt: day(1), Meta: &domain.TitleMeta{DisplayTitle: "勉強会", Tags: []string{"研修"}, Pinned: true}},
+		{Name: "t2", Title: "rust", CreatedAt: day(2), Meta: &domain.TitleMeta{Tags: []string{"研修", "Rust"}, Favorite: true}},
+		{Name: "t3", Title: "zig", CreatedAt: day(3)},
+	}
+
+	names := func(es []*Entry) []string {
+		var out []string
+		for _, e := range es {
+			out = append(out, e.Name)
+		}
+		return out
+	}
+
+	if got := names(Filter(entries, "勉強")); !slices.Equal(got, []string{"t1"}) {
+		t.Errorf("Filter(勉強) = %v (matches the display title)", got)
+	}
+	if got := names(Filter(entries, "rust")); !slices.Equal(got, []string{"t2"}) {
+		t.Errorf("Filter(rust) = %v", got)
+	}
+	if got := names(FilterTag(entries, "研修")); !slices.Equal(got, []string{"t1", "t2"}) {
+		t.Errorf("FilterTag(研修) = %v", got)
+	}
+	if got := names(FilterFavorite(entries)); !slices.Equal(got, []string{"t2"}) {
+		t.Errorf("FilterFavorite() = %v", got)
+	}
+
+	// ピン留めした作品は並び順によらず先頭
+	Sort(entries, SortNewest)
+	if got := names(entries); !slices.Equal(got, []string{"t1", "t3", "t2"}) {
+		t.Errorf("Sort(new) = %v", got)
+	}
+	if e := entries[0]; e.DisplayTitle() != "勉強会" {
+		t.Errorf("DisplayTitle() = %q", e.DisplayTitle())
+	}
+}
+
+func TestParseCreatedAt(t *testing.T) {
+	got, ok := ParseCreatedAt("20260113_153000_abcd1234")
+	if want := time.Date(2026, 1, 13, 6, 30, 0, 0, time.UTC); !ok || !got.Equal(want) {
+		t.Errorf("ParseCreatedAt() = %v, %v, want %v", got, ok, want)
+	}
+	if _, ok := ParseCreatedAt("character"); ok {
+		t.Error("ParseCreatedAt(character) should fail")
+	}
+}
@@ -0,0 +1,92 @@
+package catalog
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// SortKey は一覧の並び順です。
+type SortKey string
+
+const (
+	SortNewest SortKey = "new"   // 作成日時の新しい順（既定）
+	SortOldest SortKey = "old"   // 作成日時の古い順
+	SortTitle  SortKey = "title" // タイトル順
+)
+
+// ParseSortKey はクエリ文字列の値を SortKey に変換します。不明な値は SortNewest とみなします。
+func ParseSortKey(s string) SortKey {
+	switch k := SortKey(s); k {
+	case SortOldest, SortTitle:
+		return k
+	default:
+		return SortNewest
+	}
+}
+
+// Filter は表示タイトル・台本のタイトル・作業ディレクトリ名・タグに q を含む作品を返します。大文字と小文字は区別しません。
+func Filter(entries []*Entry, q string) []*Entry {
+	q = strings.ToLower(strings.TrimSpace(q))
+	if q == "" {
+		return entries
+	}
+	contains := func(s string) bool { return strings.Contains(strings.ToLower(s), q) }
+	var out []*Entry
+	for _, e := range entries {
+		if contains(e.DisplayTitle()) || contains(e.Title) || contains(e.Name) || slices.ContainsFunc(e.Tags(), contains) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// FilterTag はタグ tag の付いた作品を返します。tag が空の場合はすべての作品を返します。
+func FilterTag(entries []*Entry, tag string) []*Entry {
+	if tag == "" {
+		return entries
+	}
+	var out []*Entry
+	for _, e := range entries {
+		if slices.Contains(e.Tags(), tag) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// FilterFavorite はお気に入りの作品を返します。
+func FilterFavorite(entries []*Entry) []*Entry {
+	var out []*Entry
+	for _, e := range entries {
+		if e.Favorite() {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Sort は作品を key の順に並べ替えます。ピン留めした作品は常に先頭に置き、同じ値の作品は作業ディレクトリ名の順に並べます。
+func Sort(entries []*Entry, key SortKey) {
+	slices.SortStableFunc(entries, func(a, b *Entry) int {
+		if a.Pinned() != b.Pinned() {
+			if a.Pinned() {
+				return -1
+			}
+			return 1
+		}
+		var c int
+		switch key {
+		case SortOldest:
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case SortTitle:
+			c = cmp.Compare(strings.ToLower(a.DisplayTitle()), strings.ToLower(b.DisplayTitle()))
+		default:
+			c = b.CreatedAt.Compare(a.CreatedAt)
+		}
+		if c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+}
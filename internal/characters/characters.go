@@ -0,0 +1,230 @@
+package characters
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shouni/go-character-kit/character"
+)
+
+const (
+	// MaxNameLen は表示名の最大文字数です。
+	MaxNameLen = 50
+	// MaxVisualCues は外見の特徴の最大数です。
+	MaxVisualCues = 20
+	// MaxVisualCueLen は外見の特徴1件あたりの最大文字数です。
+	MaxVisualCueLen = 200
+	// maxReferenceURLLen は参照画像の URL の最大長です。
+	maxReferenceURLLen = 1024
+)
+
+// validID はキャラクターIDの形式です。台本の speaker_id として使うため、英小文字・数字・ハイフン・アンダースコアに限ります。
+var validID = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// reservedID は管理画面の追加画面のパス (/admin/characters/new) と重なるため、キャラクターIDに使用できません。
+const reservedID = "new"
+
+// Character はキャラクター1人分の定義です。JSON の形式は埋め込みの characters.json と同じです。
+type Character struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Seed         *int64   `json:"seed,omitempty"`
+	ReferenceURL string   `json:"reference_url"`
+	VisualCues   []string `json:"visual_cues"`
+	IsDefault    bool     `json:"is_default"`
+}
+
+// Validate はキャラクター定義が登録できる内容かを検証します。誤りがある場合は ErrInvalid をラップしたエラーを返します。
+func (c Character) Validate() error {
+	if !validID.MatchString(c.ID) {
+		return fmt.Errorf("%w: キャラクターIDは英小文字・数字・ハイフン・アンダースコアの32文字以内で指定してください: %q", ErrInvalid, c.ID)
+	}
+	if c.ID == reservedID {
+		return fmt.Errorf("%w: %q はキャラクターIDに使用できません", ErrInvalid, c.ID)
+	}
+	if strings.TrimSpace(c.Name) == "" {
+		return fmt.Errorf("%w: 名前を入力してください", ErrInvalid)
+	}
+	if utf8.RuneCountInString(c.Name) > MaxNameLen {
+		return fmt.Errorf("%w: 名前は %d 文字以内で入力してください", ErrInvalid, MaxNameLen)
+	}
+	if c.Seed != nil && *c.Seed < 0 {
+		return fmt.Errorf("%w: Seed 値には 0 以上の整数を指定してください", ErrInvalid)
+	}
+	if c.ReferenceURL != "" {
+		if len(c.ReferenceURL) > maxReferenceURLLen {
+			return fmt.Errorf("%w: 参照画像の URL は %d 文字以内で指定してください", ErrInvalid, maxReferenceURLLen)
+		}
+		if !strings.HasPrefix(c.ReferenceURL, "gs://") && !strings.HasPrefix(c.ReferenceURL, "https://") {
+			return fmt.Errorf("%w: 参照画像の URL は gs:// または https:// で始まる必要があります", ErrInvalid)
+		}
+	}
+	if len(c.VisualCues) > MaxVisualCues {
+		return fmt.Errorf("%w: 外見の特徴は %d 件以内で入力してください", ErrInvalid, MaxVisualCues)
+	}
+	for _, cue := range c.VisualCues {
+		if utf8.RuneCountInString(cue) > MaxVisualCueLen {
+			return fmt.Errorf("%w: 外見の特徴は1件あたり %d 文字以内で入力してください", ErrInvalid, MaxVisualCueLen)
+		}
+	}
+	return nil
+}
+
+// Entry はバケットに保存したキャラクター定義です。埋め込みの定義と同じ ID の場合は、埋め込みの定義を上書きします。
+type Entry struct {
+	Character
+	// Retired が true のキャラクターは、台本や画像の生成に使用しません。定義は残るため、あとで戻せます。
+	Retired   bool      `json:"retired,omitempty"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Document はバケットに保存するキャラクター定義の文書です。
+type Document struct {
+	Characters []Entry `json:"characters"`
+}
+
+// entry は ID のキャラクターの保存済みの定義を返します。ない場合は nil を返します。
+func (d *Document) entry(id string) *Entry {
+	i := slices.IndexFunc(d.Characters, func(e Entry) bool { return e.ID == id })
+	if i < 0 {
+		return nil
+	}
+	return &d.Characters[i]
+}
+
+// put は ID のキャラクターの定義を追加または置き換えます。
+func (d *Document) put(e Entry) {
+	if cur := d.entry(e.ID); cur != nil {
+		*cur = e
+		return
+	}
+	d.Characters = append(d.Characters, e)
+}
+
+// Record は管理画面に表示するキャラクターです。埋め込みの定義と保存済みの定義を重ねた結果です。
+type Record struct {
+	Entry
+	// Builtin は埋め込みの定義にあるキャラクターであることを示します。
+	Builtin bool
+	// Customized はバケットに定義が保存されている (埋め込みの定義を上書きしている) ことを示します。
+	Customized bool
+}
+
+// Merge は埋め込みの定義 defaults に保存済みの定義 doc を重ねたキャラクターを返します。
+// 埋め込みの定義の順に並べ、埋め込みにないキャラクターは保存順に続けます。
+// 既定のキャラクターは使用中のキャラクターから1人だけ選びます。複数ある場合は最後に保存したものを、ない場合は先頭のキャラクターを既定にします。
+func Merge(defaults []Character, doc Document) []Record {
+	records := make([]Record, 0, len(defaults)+len(doc.Characters))
+	for _, c := range defaults {
+		r := Record{Entry: Entry{Character: c}, Builtin: true}
+		if e := doc.entry(c.ID); e != nil {
+			r.Entry = *e
+			r.Customized = true
+		}
+		records = append(records, r)
+	}
+	for _, e := range doc.Characters {
+		if slices.ContainsFunc(defaults, func(c Character) bool { return c.ID == e.ID }) {
+			continue
+		}
+		records = append(records, Record{Entry: e, Customized: true})
+	}
+
+	def := -1
+	for i, r := range records {
+		if r.Retired || !r.IsDefault {
+			continue
+		}
+		if def < 0 || r.UpdatedAt.After(records[def].UpdatedAt) {
+			def = i
+		}
+	}
+	if def < 0 {
+		def = slices.IndexFunc(records, func(r Record) bool { return !r.Retired })
+	}
+	for i := range records {
+		records[i].IsDefault = i == def
+	}
+	return records
+}
+
+// Active は records のうち使用中 (退役していない) のキャラクターを返します。
+func Active(records []Record) []Character {
+	var active []Character
+	for _, r := range records {
+		if !r.Retired {
+			active = append(active, r.Character)
+		}
+	}
+	return active
+}
+
+// Snapshot は読み込んだ時点のキャラクター定義です。
+type Snapshot struct {
+	// Version は保存済みの定義の版です。定義を保存し直すと変わります。
+	Version string
+	// Records は退役したキャラクターを含むすべてのキャラクターです。
+	Records []Record
+
+	characters *character.Characters
+}
+
+// newSnapshot は defaults と doc を重ねた Snapshot を作成します。
+func newSnapshot(version string, defaults []Character, doc Document) (*Snapshot, error) {
+	records := Merge(defaults, doc)
+	active := Active(records)
+	if len(active) == 0 {
+		return nil, errors.New("使用中のキャラクターがいません")
+	}
+	data, err := json.Marshal(active)
+	if err != nil {
+		return nil, fmt.Errorf("キャラクター定義のエンコードに失敗しました: %w", err)
+	}
+	chars, err := character.ParseCharacters(data)
+	if err != nil {
+		return nil, fmt.Errorf("キャラクター定義の解析に失敗しました: %w", err)
+	}
+	return &Snapshot{Version: version, Records: records, characters: chars}, nil
+}
+
+// Characters は使用中のキャラクターを go-character-kit の形式で返します。
+func (s *Snapshot) Characters() *character.Characters {
+	return s.characters
+}
+
+// Active は使用中のキャラクターを返します。
+func (s *Snapshot) Active() []Character {
+	return Active(s.Records)
+}
+
+// Record は ID のキャラクターを返します。ない場合は false を返します。
+func (s *Snapshot) Record(id string) (Record, bool) {
+	return findRecord(s.Records, id)
+}
+
+// ParseDefaults は埋め込みの characters.json を読み込みます。
+func ParseDefaults(data []byte) ([]Character, error) {
+	var defaults []Character
+	if err := json.Unmarshal(data, &defaults); err != nil {
+		return nil, fmt.Errorf("キャラクター定義の解析に失敗しました: %w", err)
+	}
+	return defaults, nil
+}
+
+// ParseVisualCues は1行に1件ずつ入力された外見の特徴を、空行を除いて返します。
+func ParseVisualCues(s string) []string {
+	var cues []string
+	for line := range strings.Lines(strings.ReplaceAll(s, "\r\n", "\n")) {
+		if line = strings.TrimSpace(line); line != "" {
+			cues = append(cues, line)
+		}
+	}
+	return cues
+}
@@ -0,0 +1,185 @@
+package characters
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"ap-manga-web/internal/storagetest"
+)
+
+const (
+	testPath     = "gs://b/characters/registry.json"
+	testDefaults = `[
+  {"id": "zundamon", "name": "Zundamon", "seed": 10001, "reference_url": "gs://b/zundamon.png", "visual_cues": ["green hair"], "is_default": true},
+  {"id": "metan", "name": "Metan", "seed": 20001, "reference_url": "gs://b/metan.png", "visual_cues": ["twin-tails"], "is_default": false}
+]`
+)
+
+func ids(records []Record) []string {
+	var out []string
+	for _, r := range records {
+		out = append(out, r.ID)
+	}
+	return out
+}
+
+func defaultID(records []Record) string {
+	for _, r := range records {
+		if r.IsDefault {
+			return r.ID
+		}
+	}
+	return ""
+}
+
+func TestMerge(t *testing.T) {
+	defaults, err := ParseDefaults([]byte(testDefaults))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	doc := Document{Characters: []Entry{
+		{Character: Character{ID: "tsumugi", Name: "Tsumugi"}, UpdatedAt: t0},
+		{Character: Character{ID: "metan", Name: "めたん"}, UpdatedAt: t0},
+	}}
+
+	records := Merge(defaults, doc)
+	if got, want := ids(records), []string{"zundamon", "metan", "tsumugi"}; !slices.Equal(got, want) {
+		t.Fatalf("Merge() ids = %v, want %v", got, want)
+	}
+	if r := records[1]; r.Name != "めたん" || !r.Builtin || !r.Customized {
+		t.Errorf("上書きした埋め込みのキャラクター = %+v", r)
+	}
+	if r := records[2]; r.Builtin || !r.Customized {
+		t.Errorf("追加したキャラクター = %+v", r)
+	}
+	if got := defaultID(records); got != "zundamon" {
+		t.Errorf("既定のキャラクター = %q, want zundamon", got)
+	}
+
+	// 後から既定にしたキャラクターを優先し、退役したキャラクターは既定にしない
+	doc.Characters[0].IsDefault = true
+	if got := defaultID(Merge(defaults, doc)); got != "tsumugi" {
+		t.Errorf("既定のキャラクター = %q, want tsumugi", got)
+	}
+	doc.Characters[0].Retired = true
+	doc.Characters = append(doc.Characters, Entry{Character: defaults[0], Retired: true, UpdatedAt: t0})
+	records = Merge(defaults, doc)
+	if got := defaultID(records); got != "metan" {
+		t.Errorf("既定のキャラクター = %q, want metan", got)
+	}
+	var active []string
+	for _, c := range Active(records) {
+		active = append(active, c.ID)
+	}
+	if !slices.Equal(active, []string{"metan"}) {
+		t.Errorf("Active() = %v, want [metan]", active)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	seed := int64(1)
+	negative := int64(-1)
+	valid := Character{ID: "new-chara_1", Name: "新キャラ", Seed: &seed, ReferenceURL: "https://example.com/a.png", VisualCues: []string{"red hat"}}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	tests := map[string]func(c *Character){
+		"空のID":    func(c *Character) { c.ID = "" },
+		"大文字のID":  func(c *Character) { c.ID = "Zunda" },
+		"長すぎるID":  func(c *Character) { c.ID = strings.Repeat("a", 33) },
+		"予約済みのID": func(c *Character) { c.ID = "new" },
+		"空の名前":    func(c *Character) { c.Name = " " },
+		"長すぎる名前":  func(c *Character) { c.Name = strings.Repeat("あ", MaxNameLen+1) },
+		"負のSeed":  func(c *Character) { c.Seed = &negative },
+		"未対応のURL": func(c *Character) { c.ReferenceURL = "http://example.com/a.png" },
+		"多すぎる特徴":  func(c *Character) { c.VisualCues = make([]string, MaxVisualCues+1) },
+		"長すぎる特徴":  func(c *Character) { c.VisualCues = []string{strings.Repeat("a", MaxVisualCueLen+1)} },
+	}
+	for name, mutate := range tests {
+		c := valid
+		mutate(&c)
+		if err := c.Validate(); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: Validate() = %v, want ErrInvalid", name, err)
+		}
+	}
+}
+
+func TestParseVisualCues(t *testing.T) {
+	got := ParseVisualCues(" green hair \r\n\r\nsoybean earmuffs\n")
+	if want := []string{"green hair", "soybean earmuffs"}; !slices.Equal(got, want) {
+		t.Errorf("ParseVisualCues() = %q, want %q", got, want)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	store := storagetest.Memory{}
+	reg, err := NewRegistry(store, store, testPath, []byte(testDefaults))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := reg.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.Version != BuiltinVersion || len(snap.Active()) != 2 {
+		t.Fatalf("Load() = version %q, %d characters", snap.Version, len(snap.Active()))
+	}
+
+	seed := int64(30001)
+	tsumugi := Character{ID: "tsumugi", Name: "Tsumugi", Seed: &seed, VisualCues: []string{"ponytail"}}
+	if err := reg.Create(ctx, tsumugi, snap.Version, "admin@example.com"); err != nil {
+		t.Fatalf("Create() = %v", err)
+	}
+	if err := reg.Create(ctx, tsumugi, snap.Version, "admin@example.com"); !errors.Is(err, ErrConflict) {
+		t.Errorf("古い版での Create() = %v, want ErrConflict", err)
+	}
+
+	snap, err = reg.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := snap.Characters().GetCharacter("tsumugi"); c == nil || c.Name != "Tsumugi" {
+		t.Fatalf("追加したキャラクター = %+v", c)
+	}
+	if err := reg.Create(ctx, tsumugi, snap.Version, "admin@example.com"); !errors.Is(err, ErrExists) {
+		t.Errorf("同じ ID の Create() = %v, want ErrExists", err)
+	}
+
+	if err := reg.SetRetired(ctx, "zundamon", true, snap.Version, "admin@example.com"); err != nil {
+		t.Fatalf("SetRetired() = %v", err)
+	}
+	// 別のインスタンスで保存した定義も読み込める
+	other, _ := NewRegistry(store, store, testPath, []byte(testDefaults))
+	snap, err = other.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.Characters().GetCharacter("zundamon") != nil {
+		t.Error("退役したキャラクターが使用中のままです")
+	}
+	if r, ok := snap.Record("zundamon"); !ok || !r.Retired {
+		t.Errorf("退役したキャラクターの記録 = %+v, %v", r, ok)
+	}
+	if d := snap.Characters().GetDefault(); d == nil || d.ID != "metan" {
+		t.Errorf("GetDefault() = %+v, want metan", d)
+	}
+
+	if err := other.SetRetired(ctx, "metan", true, snap.Version, ""); err != nil {
+		t.Fatalf("SetRetired(metan) = %v", err)
+	}
+	snap, _ = other.Load(ctx)
+	if err := other.SetRetired(ctx, "tsumugi", true, snap.Version, ""); !errors.Is(err, ErrInvalid) {
+		t.Error("最後のキャラクターを退役できてしまいます")
+	}
+	if err := other.Update(ctx, Character{ID: "unknown", Name: "x"}, snap.Version, ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("未登録の Update() = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,246 @@
+package characters
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/shouni/go-character-kit/character"
+	"github.com/shouni/go-remote-io/remoteio"
+)
+
+// cacheTTL は読み込んだ定義を使い回す期間です。別のインスタンスで保存した定義も、この期間が過ぎると反映されます。
+const cacheTTL = 30 * time.Second
+
+// BuiltinVersion は定義がまだ保存されていない (埋め込みの定義だけの) 場合の版です。
+const BuiltinVersion = "builtin"
+
+var (
+	// ErrNotFound は指定したキャラクターが登録されていないことを示します。
+	ErrNotFound = errors.New("キャラクターが見つかりません")
+	// ErrExists は同じ ID のキャラクターが登録済みであることを示します。
+	ErrExists = errors.New("同じ ID のキャラクターが登録されています")
+	// ErrConflict は編集を始めた後に定義が更新されたことを示します。
+	ErrConflict = errors.New("キャラクター定義が更新されています")
+	// ErrInvalid は登録できない定義や操作であることを示します。
+	ErrInvalid = errors.New("キャラクター定義を保存できません")
+)
+
+// Registry は埋め込みの定義にバケットの文書を重ねたキャラクター定義を扱います。
+// 定義の追加・編集・退役は文書に保存し、再起動せずに反映します。
+//
+// 版の確認から保存までは writeMu で直列化しますが、これはインスタンスの中に限られます。
+// remoteio の書き込みは GCS の世代 (if-generation-match) を条件にできないため、複数のインスタンスで同時に保存すると
+// 後の書き込みが先の変更を上書きします。管理画面での編集は1つのインスタンスから行う前提です。
+type Registry struct {
+	reader remoteio.InputReader
+	writer remoteio.Writer
+	// path は文書の格納先 (例: gs://bucket/characters/registry.json) です。
+	path     string
+	defaults []Character
+	now      func() time.Time
+
+	// mu は snapshot と loadedAt を保護します。
+	mu       sync.Mutex
+	snapshot *Snapshot
+	loadedAt time.Time
+
+	// writeMu は文書の読み込みから書き込みまでを直列化します。別のインスタンスの書き込みとは競合し得ます。
+	writeMu sync.Mutex
+}
+
+// NewRegistry は path の文書を埋め込みの定義 defaults (characters.json の内容) に重ねる Registry を作成します。
+func NewRegistry(reader remoteio.InputReader, writer remoteio.Writer, path string, defaults []byte) (*Registry, error) {
+	chars, err := ParseDefaults(defaults)
+	if err != nil {
+		return nil, err
+	}
+	return &Registry{reader: reader, writer: writer, path: path, defaults: chars, now: time.Now}, nil
+}
+
+// Load は現在のキャラクター定義を返します。読み込んでから cacheTTL 以内は読み込み済みの定義を返します。
+// 読み込みに失敗した場合は、読み込み済みの定義があればそれを返し、なければエラーを返します。
+func (r *Registry) Load(ctx context.Context) (*Snapshot, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.snapshot != nil && r.now().Sub(r.loadedAt) < cacheTTL {
+		return r.snapshot, nil
+	}
+	snap, err := r.read(ctx)
+	if err != nil {
+		if r.snapshot == nil {
+			return nil, err
+		}
+		slog.WarnContext(ctx, "キャラクター定義の再読み込みに失敗したため、読み込み済みの定義を使用します", "path", r.path, "error", err)
+		snap = r.snapshot
+	}
+	r.snapshot = snap
+	r.loadedAt = r.now()
+	return snap, nil
+}
+
+// Characters は使用中のキャラクターを go-character-kit の形式で返します。
+func (r *Registry) Characters(ctx context.Context) (*character.Characters, error) {
+	snap, err := r.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return snap.Characters(), nil
+}
+
+// Create はキャラクターを追加します。退役したものを含め、同じ ID のキャラクターがいる場合は ErrExists を返します。
+// version は編集を始めた時点の Snapshot.Version で、定義が更新されていた場合は ErrConflict を返します。
+func (r *Registry) Create(ctx context.Context, c Character, version, user string) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	return r.modify(ctx, version, func(doc *Document, records []Record) error {
+		for _, rec := range records {
+			if rec.ID == c.ID {
+				return ErrExists
+			}
+		}
+		doc.put(Entry{Character: c, UpdatedBy: user, UpdatedAt: r.now().UTC()})
+		return nil
+	})
+}
+
+// Update はキャラクターの定義を置き換えます。埋め込みのキャラクターは、文書に保存した定義で上書きします。
+// 退役の状態は変えません。登録されていない場合は ErrNotFound を返します。
+func (r *Registry) Update(ctx context.Context, c Character, version, user string) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	return r.modify(ctx, version, func(doc *Document, records []Record) error {
+		rec, ok := findRecord(records, c.ID)
+		if !ok {
+			return ErrNotFound
+		}
+		if rec.Retired && c.IsDefault {
+			return fmt.Errorf("%w: 退役したキャラクターは既定にできません", ErrInvalid)
+		}
+		doc.put(Entry{Character: c, Retired: rec.Retired, UpdatedBy: user, UpdatedAt: r.now().UTC()})
+		return nil
+	})
+}
+
+// SetRetired は retired が true の場合はキャラクターを退役させ、false の場合は使用中に戻します。
+// 使用中のキャラクターがいなくなる退役はできません。登録されていない場合は ErrNotFound を返します。
+func (r *Registry) SetRetired(ctx context.Context, id string, retired bool, version, user string) error {
+	return r.modify(ctx, version, func(doc *Document, records []Record) error {
+		rec, ok := findRecord(records, id)
+		if !ok {
+			return ErrNotFound
+		}
+		if retired && len(Active(records)) == 1 && !rec.Retired {
+			return fmt.Errorf("%w: 使用中のキャラクターがいなくなるため、退役できません", ErrInvalid)
+		}
+		e := rec.Entry
+		e.Retired = retired
+		if retired {
+			e.IsDefault = false
+		}
+		e.UpdatedBy = user
+		e.UpdatedAt = r.now().UTC()
+		doc.put(e)
+		return nil
+	})
+}
+
+// modify は最新の文書を読み込み、版を確認してから fn で変更し、保存します。保存した定義はすぐに Load に反映します。
+// 版の確認と保存の間に別のインスタンスが保存した場合は検出できません (Registry を参照)。
+func (r *Registry) modify(ctx context.Context, version string, fn func(doc *Document, records []Record) error) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	doc, current, err := r.readDocument(ctx)
+	if err != nil {
+		return err
+	}
+	if version != current {
+		return ErrConflict
+	}
+	if err := fn(&doc, Merge(r.defaults, doc)); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("キャラクター定義のエンコードに失敗しました: %w", err)
+	}
+	data = append(data, '\n')
+	snap, err := newSnapshot(documentVersion(data), r.defaults, doc)
+	if err != nil {
+		return err
+	}
+	if err := r.writer.Write(ctx, r.path, bytes.NewReader(data),
+		remoteio.WithContentType("application/json"),
+		remoteio.WithCacheControl("no-cache")); err != nil {
+		return fmt.Errorf("キャラクター定義の保存に失敗しました: %w", err)
+	}
+
+	r.mu.Lock()
+	r.snapshot = snap
+	r.loadedAt = r.now()
+	r.mu.Unlock()
+	return nil
+}
+
+// read は文書を読み込み、埋め込みの定義に重ねた Snapshot を作成します。
+func (r *Registry) read(ctx context.Context) (*Snapshot, error) {
+	doc, version, err := r.readDocument(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newSnapshot(version, r.defaults, doc)
+}
+
+// readDocument は文書とその版を読み込みます。文書がない場合は空の文書と BuiltinVersion を返します。
+func (r *Registry) readDocument(ctx context.Context) (Document, string, error) {
+	exists, err := r.reader.Exists(ctx, r.path)
+	if err != nil {
+		return Document{}, "", fmt.Errorf("キャラクター定義の確認に失敗しました: %w", err)
+	}
+	if !exists {
+		return Document{}, BuiltinVersion, nil
+	}
+
+	rc, err := r.reader.Open(ctx, r.path)
+	if err != nil {
+		return Document{}, "", fmt.Errorf("キャラクター定義の読み込みに失敗しました: %w", err)
+	}
+	defer rc.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(rc); err != nil {
+		return Document{}, "", fmt.Errorf("キャラクター定義の読み込みに失敗しました: %w", err)
+	}
+	var doc Document
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		return Document{}, "", fmt.Errorf("キャラクター定義の解析に失敗しました (path: %s): %w", r.path, err)
+	}
+	return doc, documentVersion(buf.Bytes()), nil
+}
+
+// documentVersion は文書の内容から版を求めます。
+func documentVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+func findRecord(records []Record, id string) (Record, bool) {
+	for _, rec := range records {
+		if rec.ID == id {
+			return rec, true
+		}
+	}
+	return Record{}, false
+}
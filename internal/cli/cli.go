@@ -0,0 +1,163 @@
+// Package cli は Web サーバーを起動せずに実行する管理用のサブコマンドを提供します。
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"ap-manga-web/internal/builder"
+	"ap-manga-web/internal/config"
+	"ap-manga-web/internal/export"
+)
+
+var validTitle = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// command はサブコマンドの定義です。
+type command struct {
+	usage string
+	run   func(ctx context.Context, cfg *config.Config, args []string) error
+}
+
+var commands = map[string]command{
+	"export-pdf":     {usage: "指定したタイトルを PDF に書き出します", run: exportPDF},
+	"rebuild-search": {usage: "すべての manga_plot.json から全文検索の索引を作り直します", run: rebuildSearch},
+}
+
+// IsCommand は引数がサブコマンド名であるかを判定します。
+func IsCommand(name string) bool {
+	_, ok := commands[name]
+	return ok || name == "help"
+}
+
+// Run は args[0] のサブコマンドを実行します。
+func Run(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] == "help" {
+		printUsage(os.Stderr)
+		return nil
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		printUsage(os.Stderr)
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+	return cmd.run(ctx, cfg, args[1:])
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ap-manga-web <command> [flags]")
+	fmt.Fprintln(w, "引数なしで実行すると Web サーバーを起動します。")
+	fmt.Fprintln(w, "\nCommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-16s %s\n", name, commands[name].usage)
+	}
+}
+
+// exportPDF は GCS の作業ディレクトリから PDF を生成し、ファイルまたは標準出力に書き出します。
+func exportPDF(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("export-pdf", flag.ContinueOnError)
+	title := fs.String("title", "", "書き出すタイトル（出力ディレクトリ名）")
+	transcript := fs.Bool("transcript", false, "末尾にセリフ一覧を追加する")
+	out := fs.String("o", "", "出力先ファイル。\"-\" で標準出力（デフォルト: <title>.pdf）")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !validTitle.MatchString(*title) {
+		return fmt.Errorf("-title に英数字・ハイフン・アンダースコアのみからなるタイトルを指定してください: %q", *title)
+	}
+	if *out == "" {
+		*out = *title + ".pdf"
+	}
+
+	rio, err := builder.BuildStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer rio.Close()
+
+	registry, err := builder.BuildCharacterRegistry(cfg, rio)
+	if err != nil {
+		return err
+	}
+	chars, err := registry.Characters(ctx)
+	if err != nil {
+		return err
+	}
+
+	workDir := cfg.GetGCSObjectURL(cfg.GetWorkDir(*title))
+	book, err := export.LoadBook(ctx, rio.Reader, workDir, chars, cfg.MaxPanelsPerPage)
+	if err != nil {
+		return err
+	}
+	if len(book.Pages) == 0 {
+		return fmt.Errorf("ページ画像が見つかりません: %s", workDir)
+	}
+
+	w, closeOut, err := openOutput(*out)
+	if err != nil {
+		return err
+	}
+	if err := export.WritePDF(ctx, w, book, rio.Reader, export.PDFOptions{Transcript: *transcript}); err != nil {
+		_ = closeOut()
+		return fmt.Errorf("PDF の書き出しに失敗しました: %w", err)
+	}
+	if err := closeOut(); err != nil {
+		return fmt.Errorf("出力ファイルのクローズに失敗しました: %w", err)
+	}
+
+	slog.InfoContext(ctx, "PDF を書き出しました", "title", *title, "pages", len(book.Pages), "output", *out)
+	return nil
+}
+
+// rebuildSearch は BaseOutputDir 以下のすべての台本を読み込み、検索索引を上書き保存します。
+func rebuildSearch(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("rebuild-search", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	rio, err := builder.BuildStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer rio.Close()
+
+	chars, err := builder.BuildCharacterRegistry(cfg, rio)
+	if err != nil {
+		return err
+	}
+	store := builder.BuildSearchIndex(cfg, rio, chars)
+	n, err := store.Rebuild(ctx)
+	if err != nil {
+		return fmt.Errorf("検索索引の作り直しに失敗しました: %w", err)
+	}
+
+	slog.InfoContext(ctx, "検索索引を作り直しました", "titles", n, "output", store.Path())
+	return nil
+}
+
+// openOutput は出力先を開きます。"-" の場合は標準出力を返します。
+func openOutput(name string) (io.Writer, func() error, error) {
+	if name == "-" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+	if strings.HasSuffix(name, "/") {
+		return nil, nil, fmt.Errorf("出力先にはファイル名を指定してください: %s", name)
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("出力ファイルを作成できませんでした: %w", err)
+	}
+	return f, f.Close, nil
+}
@@ -45,15 +45,48 @@ type Config struct {
 	// SessionEncryptKey はセッションデータのAES暗号化用シークレットキーです。 16, 24, 32 バイトのいずれかである必要があります。
 	SessionEncryptKey string `env:"SESSION_ENCRYPT_KEY"`
 
+	// ShareTokenSecret は共有リンクのトークンを HMAC 署名するためのシークレットキーです。32 バイト以上で、空の場合は共有リンクを無効にします。
+	ShareTokenSecret string `env:"SHARE_TOKEN_SECRET"`
+	// FeedToken は新着フィード (/feed.xml) の購読に使用するトークンです。空の場合はフィードを無効にします。
+	FeedToken string `env:"FEED_TOKEN"`
+
 	// Authz Settings
 	AllowedEmails  []string `env:"ALLOWED_EMAILS"`
 	AllowedDomains []string `env:"ALLOWED_DOMAINS"`
+	// AdminEmails はキャラクター管理画面 (/admin/characters) を使用できるメールアドレスです。空の場合は管理画面を無効にします。
+	AdminEmails []string `env:"ADMIN_EMAILS"`
 
 	// Generation Settings
 	MaxPanelsPerPage int           `env:"MAX_PANELS_PER_PAGE" envDefault:"6"`
 	MaxConcurrency   int           `env:"MAX_CONCURRENCY" envDefault:"2"`
 	RateInterval     time.Duration `env:"RATE_INTERVAL_SEC" envDefault:"60s"`
-	StyleSuffix      string
+	// MaxDialogueLength は1パネルあたりのセリフの最大文字数です。超過分は文の区切りで別パネルに分割されます。0 で無効になります。
+	MaxDialogueLength int `env:"MAX_DIALOGUE_LENGTH" envDefault:"35"`
+	StyleSuffix       string
+	// LayoutTemplatesURL は追加のコマ割りテンプレート定義 (JSON) の格納先です (例: gs://bucket/layouts.json)。
+	// 同名のテンプレートは埋め込みの定義を上書きします。
+	LayoutTemplatesURL string `env:"LAYOUT_TEMPLATES_URL"`
+	// CharacterRegistryPath は管理画面で追加・編集したキャラクター定義 (JSON) の格納先で、バケット内のパスまたは gs:// の URL です。
+	// 同じ ID のキャラクターは埋め込みの定義を上書きします。
+	CharacterRegistryPath string `env:"CHARACTER_REGISTRY_PATH" envDefault:"characters/registry.json"`
+
+	// Lettering Settings
+	// LocalLettering が有効な場合、セリフ・ナレーション・効果音はモデルに描かせず、生成後の画像にローカルで写植します。
+	LocalLettering bool `env:"LOCAL_LETTERING" envDefault:"false"`
+	// LetteringFontURL は写植に使用するフォントの格納先です (例: gs://bucket/fonts/NotoSansJP-Bold.ttf)。
+	// リポジトリにはフォントを同梱していないため、assets/fonts にフォントを置いてビルドした場合を除き、LocalLettering の有効時は必須です。
+	LetteringFontURL string `env:"LETTERING_FONT_URL"`
+
+	// Image Variant Settings
+	// ImageVariants が有効な場合、パネル・ページ画像の生成後に一覧表示用の縮小画像 (WebP / JPEG) を images/variants/ に保存します。
+	ImageVariants bool `env:"IMAGE_VARIANTS" envDefault:"true"`
+	// ImageProxy が有効な場合、ログインした利用者の画面では署名付きURLの代わりに /{BaseOutputDir}/{title}/img/{file} を参照し、
+	// アプリがストレージから画像を配信します。共有リンクや埋め込み (トークン付き)、フィードでは引き続き署名付きURLを使用します。
+	ImageProxy bool `env:"IMAGE_PROXY" envDefault:"false"`
+
+	// Export Settings
+	// EPUBPageDirection は EPUB のページ送り方向 (rtl / ltr) です。日本の漫画に合わせて右綴じ (rtl) が既定です。
+	EPUBPageDirection string `env:"EPUB_PAGE_DIRECTION" envDefault:"rtl"`
 }
 
 // LoadConfig は環境変数から設定を読み込み、Config 構造体を生成します。
@@ -77,6 +110,12 @@ func LoadConfig() (*Config, error) {
 		cfg.TaskAudienceURL = cfg.ServiceURL
 	}
 
+	switch cfg.EPUBPageDirection {
+	case "rtl", "ltr":
+	default:
+		return nil, fmt.Errorf("EPUB_PAGE_DIRECTION must be rtl or ltr: %q", cfg.EPUBPageDirection)
+	}
+
 	return cfg, nil
 }
 
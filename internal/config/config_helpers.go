@@ -39,6 +39,19 @@ func (c *Config) GetGCSObjectURL(path string) string {
 	return path
 }
 
+// IsAdmin は email がキャラクター管理画面を使用できる管理者のメールアドレスかを返します。大文字・小文字は区別しません。
+func (c *Config) IsAdmin(email string) bool {
+	if email == "" {
+		return false
+	}
+	for _, admin := range c.AdminEmails {
+		if strings.EqualFold(admin, email) {
+			return true
+		}
+	}
+	return false
+}
+
 // --- バリデーション ---
 
 // ValidateEssentialConfig はアプリケーション実行に不可欠な設定を検証します。
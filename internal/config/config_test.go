@@ -25,6 +25,15 @@ func TestLoadConfigDefaults(t *testing.T) {
 	if len(cfg.AllowedEmails) != 0 {
 		t.Fatalf("AllowedEmails = %v, want empty", cfg.AllowedEmails)
 	}
+	if !cfg.ImageVariants {
+		t.Fatal("ImageVariants = false, want true")
+	}
+	if cfg.CharacterRegistryPath != "characters/registry.json" {
+		t.Fatalf("CharacterRegistryPath = %q, want characters/registry.json", cfg.CharacterRegistryPath)
+	}
+	if cfg.EPUBPageDirection != "rtl" {
+		t.Fatalf("EPUBPageDirection = %q, want rtl", cfg.EPUBPageDirection)
+	}
 }
 
 func TestLoadConfigParsesEnv(t *testing.T) {
@@ -33,6 +42,7 @@ func TestLoadConfigParsesEnv(t *testing.T) {
 	t.Setenv("TASK_AUDIENCE_URL", "https://tasks.example.com")
 	t.Setenv("ALLOWED_EMAILS", "alice@example.com, bob@example.com")
 	t.Setenv("ALLOWED_DOMAINS", "example.com")
+	t.Setenv("ADMIN_EMAILS", "alice@example.com")
 	t.Setenv("MAX_CONCURRENCY", "4")
 	t.Setenv("RATE_INTERVAL_SEC", "30s")
 
@@ -50,6 +60,9 @@ func TestLoadConfigParsesEnv(t *testing.T) {
 	if got, want := cfg.AllowedDomains, []string{"example.com"}; !equalStringSlices(got, want) {
 		t.Fatalf("AllowedDomains = %v, want %v", got, want)
 	}
+	if !cfg.IsAdmin("Alice@Example.com") || cfg.IsAdmin("bob@example.com") || cfg.IsAdmin("") {
+		t.Fatalf("IsAdmin() does not match ADMIN_EMAILS %v", cfg.AdminEmails)
+	}
 	if cfg.MaxConcurrency != 4 {
 		t.Fatalf("MaxConcurrency = %d, want 4", cfg.MaxConcurrency)
 	}
@@ -81,6 +94,15 @@ func TestLoadConfigInvalidRateInterval(t *testing.T) {
 	}
 }
 
+func TestLoadConfigInvalidEPUBPageDirection(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("EPUB_PAGE_DIRECTION", "ttb")
+
+	if _, err := LoadConfig(); err == nil {
+		t.Fatal("LoadConfig() error = nil, want error")
+	}
+}
+
 func clearConfigEnv(t *testing.T) {
 	t.Helper()
 
@@ -103,11 +125,22 @@ func clearConfigEnv(t *testing.T) {
 		"GOOGLE_CLIENT_SECRET",
 		"SESSION_SECRET",
 		"SESSION_ENCRYPT_KEY",
+		"SHARE_TOKEN_SECRET",
+		"FEED_TOKEN",
 		"ALLOWED_EMAILS",
 		"ALLOWED_DOMAINS",
+		"ADMIN_EMAILS",
 		"MAX_PANELS_PER_PAGE",
 		"MAX_CONCURRENCY",
 		"RATE_INTERVAL_SEC",
+		"MAX_DIALOGUE_LENGTH",
+		"LAYOUT_TEMPLATES_URL",
+		"CHARACTER_REGISTRY_PATH",
+		"LOCAL_LETTERING",
+		"LETTERING_FONT_URL",
+		"EPUB_PAGE_DIRECTION",
+		"IMAGE_VARIANTS",
+		"IMAGE_PROXY",
 	} {
 		t.Setenv(key, "")
 	}
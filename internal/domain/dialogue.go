@@ -0,0 +1,183 @@
+package domain
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// sentenceTerminators は文末として扱う文字です。
+	sentenceTerminators = "。．！？!?…\n."
+	// sentenceClosers は文末記号の直後に続けて同じ文に含める閉じ括弧類です。
+	sentenceClosers = "」』）)】〉》\"'"
+	// clauseBreaks は1文が長すぎる場合に区切りとして使う読点類です。
+	clauseBreaks = "、，,　 "
+)
+
+// SplitLongDialogue は maxRunes を超えるセリフを文の区切りで分割し、同じ話者・作画指示を持つパネルとして後ろに挿入します。
+// 分割が発生した場合は、1ページあたり panelsPerPage 枚 (0 以下の場合は DefaultPanelsPerPage) としてページ番号を振り直し、
+// PageLayouts も各ページの先頭だったパネルが移った先のページに付け替えます。
+// 追加されたパネル数を返します。maxRunes が 0 以下の場合は何もしません。
+func (p *MangaPlot) SplitLongDialogue(maxRunes, panelsPerPage int) int {
+	if maxRunes <= 0 {
+		return 0
+	}
+
+	added := 0
+	panels := make([]PlotPanel, 0, len(p.Panels))
+	// moved は元のパネルのインデックスから、分割後の（最初の断片の）インデックスへの対応です。
+	moved := make([]int, len(p.Panels))
+	for j, panel := range p.Panels {
+		moved[j] = len(panels)
+		// 吹き出し単位で指定されたセリフは、台本の作者の意図を優先して分割しません。
+		if len(panel.Lines) > 0 {
+			panels = append(panels, panel)
+			continue
+		}
+
+		chunks := SplitDialogue(panel.Dialogue, maxRunes)
+		if len(chunks) <= 1 {
+			panels = append(panels, panel)
+			continue
+		}
+
+		for i, chunk := range chunks {
+			extra := panel
+			extra.Dialogue = chunk
+			// 演出指定・ナレーション・効果音は元のパネル（最初の断片）にのみ残します。
+			if i > 0 {
+				extra.Emphasis, extra.Size, extra.FullWidth = EmphasisNone, SizeAuto, false
+				extra.Narration, extra.SFX = "", nil
+			}
+			panels = append(panels, extra)
+		}
+		added += len(chunks) - 1
+	}
+	if added == 0 {
+		return 0
+	}
+
+	if panelsPerPage <= 0 {
+		panelsPerPage = DefaultPanelsPerPage
+	}
+	p.Panels = panels
+	for i := range p.Panels {
+		p.Panels[i].Page = i/panelsPerPage + 1
+	}
+	p.PageLayouts = remapPageLayouts(p.PageLayouts, moved, panelsPerPage)
+	return added
+}
+
+// remapPageLayouts はページごとのコマ割り指定を、各ページの先頭だったパネルが移った先のページに付け替えます。
+// 複数のページが同じページに移る場合は、前のページの指定を優先します。
+func remapPageLayouts(layouts map[int]string, moved []int, panelsPerPage int) map[int]string {
+	if len(layouts) == 0 {
+		return layouts
+	}
+	pages := slices.Sorted(maps.Keys(layouts))
+	remapped := make(map[int]string, len(layouts))
+	for _, page := range pages {
+		first := (page - 1) * panelsPerPage
+		if page < 1 || first >= len(moved) {
+			continue
+		}
+		newPage := moved[first]/panelsPerPage + 1
+		if _, ok := remapped[newPage]; !ok {
+			remapped[newPage] = layouts[page]
+		}
+	}
+	return remapped
+}
+
+// SplitDialogue はセリフを maxRunes 文字以内の断片に分割します。
+// 文末記号で区切った文をできるだけまとめ、1文が長すぎる場合は読点、それでも長い場合は文字数で区切ります。
+func SplitDialogue(dialogue string, maxRunes int) []string {
+	dialogue = strings.TrimSpace(dialogue)
+	if maxRunes <= 0 || utf8.RuneCountInString(dialogue) <= maxRunes {
+		return []string{dialogue}
+	}
+
+	var pieces []string
+	for _, sentence := range splitAfter(dialogue, sentenceTerminators) {
+		if utf8.RuneCountInString(sentence) <= maxRunes {
+			pieces = append(pieces, sentence)
+			continue
+		}
+		for _, clause := range splitAfter(sentence, clauseBreaks) {
+			pieces = append(pieces, splitRunes(clause, maxRunes)...)
+		}
+	}
+	return pack(pieces, maxRunes)
+}
+
+// splitAfter は区切り文字（と続く閉じ括弧）の直後で文字列を分割します。
+// 英文の単語間の空白を失わないよう、断片の空白はそのまま残します。
+func splitAfter(s, separators string) []string {
+	var parts []string
+	runes := []rune(s)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		if !isBreak(runes, i, separators) {
+			continue
+		}
+		// 連続する区切り文字（「！？」など）と閉じ括弧は同じ断片に含めます。
+		for i+1 < len(runes) && (strings.ContainsRune(separators, runes[i+1]) || strings.ContainsRune(sentenceClosers, runes[i+1])) {
+			i++
+		}
+		parts = append(parts, string(runes[start:i+1]))
+		start = i + 1
+	}
+	if start < len(runes) {
+		parts = append(parts, string(runes[start:]))
+	}
+	return parts
+}
+
+// isBreak は runes[i] が区切り文字かを判定します。
+// 半角ピリオドは "v1.2" のような表記を壊さないよう、直後が空白か末尾の場合のみ区切りとみなします。
+func isBreak(runes []rune, i int, separators string) bool {
+	if !strings.ContainsRune(separators, runes[i]) {
+		return false
+	}
+	if runes[i] == '.' {
+		return i+1 == len(runes) || unicode.IsSpace(runes[i+1])
+	}
+	return true
+}
+
+// splitRunes は区切りのない長い文字列を maxRunes 文字ごとに分割します。
+func splitRunes(s string, maxRunes int) []string {
+	runes := []rune(s)
+	var parts []string
+	for len(runes) > maxRunes {
+		parts = append(parts, string(runes[:maxRunes]))
+		runes = runes[maxRunes:]
+	}
+	if len(runes) > 0 {
+		parts = append(parts, string(runes))
+	}
+	return parts
+}
+
+// pack は断片を順序を保ったまま maxRunes 文字以内でできるだけ結合し、前後の空白を除いて返します。
+func pack(pieces []string, maxRunes int) []string {
+	var chunks []string
+	var current string
+	flush := func() {
+		if chunk := strings.TrimSpace(current); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current = ""
+	}
+	for _, piece := range pieces {
+		if utf8.RuneCountInString(strings.TrimSpace(current+piece)) > maxRunes {
+			flush()
+		}
+		current += piece
+	}
+	flush()
+	return chunks
+}
@@ -0,0 +1,165 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/shouni/go-manga-kit/ports"
+)
+
+func TestSplitDialogue(t *testing.T) {
+	tests := []struct {
+		name     string
+		dialogue string
+		max      int
+		want     []string
+	}{
+		{
+			name:     "short dialogue is kept",
+			dialogue: "これは短いのだ！",
+			max:      10,
+			want:     []string{"これは短いのだ！"},
+		},
+		{
+			name:     "splits at sentence boundaries",
+			dialogue: "キャッシュは速いのだ。でも古くなるのだ！だから無効化が大事なのだ。",
+			max:      13,
+			want:     []string{"キャッシュは速いのだ。", "でも古くなるのだ！", "だから無効化が大事なのだ。"},
+		},
+		{
+			name:     "packs short sentences together",
+			dialogue: "そう。なるほど。つまりこういうことなのね。",
+			max:      13,
+			want:     []string{"そう。なるほど。", "つまりこういうことなのね。"},
+		},
+		{
+			name:     "keeps closing brackets with the sentence",
+			dialogue: "「速いのだ！」「でも高いわ。」",
+			max:      8,
+			want:     []string{"「速いのだ！」", "「でも高いわ。」"},
+		},
+		{
+			name:     "falls back to clause and rune boundaries",
+			dialogue: "あいうえおかきくけこ、さしすせそたちつてとなにぬねの",
+			max:      8,
+			want:     []string{"あいうえおかきく", "けこ、", "さしすせそたちつ", "てとなにぬねの"},
+		},
+		{
+			name:     "does not split version numbers",
+			dialogue: "v1.2 is out. Upgrade now.",
+			max:      14,
+			want:     []string{"v1.2 is out.", "Upgrade now."},
+		},
+		{
+			name:     "keeps spaces between words",
+			dialogue: "Caches are fast. But they go stale. Invalidate them.",
+			max:      20,
+			want:     []string{"Caches are fast.", "But they go stale.", "Invalidate them."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitDialogue(tt.dialogue, tt.max)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("SplitDialogue() = %q, want %q", got, tt.want)
+			}
+			for _, chunk := range got {
+				if utf8.RuneCountInString(chunk) > tt.max && len(tt.want) > 1 {
+					t.Fatalf("chunk %q exceeds %d runes", chunk, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitLongDialogueRegroupsPages(t *testing.T) {
+	plot := &MangaPlot{Panels: []PlotPanel{
+		{Panel: ports.Panel{Page: 1, SpeakerID: "zundamon", VisualAnchor: "a", Dialogue: "一文目なのだ。二文目なのだ。"}, Emphasis: EmphasisImpact},
+		{Panel: ports.Panel{Page: 1, SpeakerID: "metan", VisualAnchor: "b", Dialogue: "短いわ。"}},
+		{Panel: ports.Panel{Page: 2, SpeakerID: "zundamon", VisualAnchor: "c", Dialogue: "ここも短いのだ。"}},
+	}}
+
+	if added := plot.SplitLongDialogue(8, 2); added != 1 {
+		t.Fatalf("SplitLongDialogue() added = %d, want 1", added)
+	}
+	if len(plot.Panels) != 4 {
+		t.Fatalf("len(Panels) = %d, want 4", len(plot.Panels))
+	}
+
+	first, second := plot.Panels[0], plot.Panels[1]
+	if first.Dialogue != "一文目なのだ。" || second.Dialogue != "二文目なのだ。" {
+		t.Fatalf("dialogues = %q, %q", first.Dialogue, second.Dialogue)
+	}
+	if second.SpeakerID != "zundamon" || second.VisualAnchor != "a" {
+		t.Fatalf("split panel = %+v, want same speaker and anchor", second)
+	}
+	if first.Emphasis != EmphasisImpact || second.Emphasis != EmphasisNone {
+		t.Fatalf("emphasis = %q, %q, want hint kept only on the first panel", first.Emphasis, second.Emphasis)
+	}
+	for i, panel := range plot.Panels {
+		if want := i/2 + 1; panel.Page != want {
+			t.Fatalf("panel %d page = %d, want %d", i+1, panel.Page, want)
+		}
+	}
+}
+
+func TestSplitLongDialogueKeepsCaptionOnFirstPanel(t *testing.T) {
+	plot := &MangaPlot{Panels: []PlotPanel{
+		{
+			Panel:     ports.Panel{Page: 1, SpeakerID: "zundamon", VisualAnchor: "a", Dialogue: "一文目なのだ。二文目なのだ。三文目なのだ。"},
+			Narration: "その日の夜。",
+			SFX:       []string{"ドドド"},
+		},
+	}}
+
+	if added := plot.SplitLongDialogue(8, 6); added != 2 {
+		t.Fatalf("SplitLongDialogue() added = %d, want 2", added)
+	}
+	first := plot.Panels[0]
+	if first.Narration != "その日の夜。" || !reflect.DeepEqual(first.SFX, []string{"ドドド"}) {
+		t.Fatalf("first panel = %+v, want narration and SFX kept", first)
+	}
+	for i, panel := range plot.Panels[1:] {
+		if panel.Narration != "" || len(panel.SFX) > 0 {
+			t.Fatalf("panel %d = %+v, want no narration or SFX", i+2, panel)
+		}
+	}
+}
+
+func TestSplitLongDialogueRemapsPageLayouts(t *testing.T) {
+	plot := &MangaPlot{
+		PageLayouts: map[int]string{2: "splash", 3: "grid"},
+		Panels: []PlotPanel{
+			{Panel: ports.Panel{Page: 1, SpeakerID: "zundamon", VisualAnchor: "a", Dialogue: "一文目なのだ。二文目なのだ。"}},
+			{Panel: ports.Panel{Page: 1, SpeakerID: "metan", VisualAnchor: "b", Dialogue: "短いわ。"}},
+			{Panel: ports.Panel{Page: 2, SpeakerID: "zundamon", VisualAnchor: "c", Dialogue: "ここも短いのだ。"}},
+			{Panel: ports.Panel{Page: 2, SpeakerID: "metan", VisualAnchor: "d", Dialogue: "そうね。"}},
+		},
+	}
+
+	if added := plot.SplitLongDialogue(8, 2); added != 1 {
+		t.Fatalf("SplitLongDialogue() added = %d, want 1", added)
+	}
+	// 2ページ目の先頭だったパネル c は3枚目に移り、描画上も2ページ目の先頭のままです。存在しないページの指定は捨てます。
+	if want := map[int]string{2: "splash"}; !reflect.DeepEqual(plot.PageLayouts, want) {
+		t.Fatalf("PageLayouts = %v, want %v", plot.PageLayouts, want)
+	}
+
+	plot = &MangaPlot{
+		PageLayouts: map[int]string{2: "splash"},
+		Panels: []PlotPanel{
+			{Panel: ports.Panel{Page: 1, SpeakerID: "zundamon", VisualAnchor: "a", Dialogue: "一文目なのだ。二文目なのだ。三文目なのだ。"}},
+			{Panel: ports.Panel{Page: 1, SpeakerID: "metan", VisualAnchor: "b", Dialogue: "短いわ。"}},
+			{Panel: ports.Panel{Page: 2, SpeakerID: "zundamon", VisualAnchor: "c", Dialogue: "ここも短いのだ。"}},
+		},
+	}
+	if added := plot.SplitLongDialogue(8, 2); added != 2 {
+		t.Fatalf("SplitLongDialogue() added = %d, want 2", added)
+	}
+	// パネル c は5枚目 (3ページ目) に移るため、指定も3ページ目に付け替えます。
+	if want := map[int]string{3: "splash"}; !reflect.DeepEqual(plot.PageLayouts, want) {
+		t.Fatalf("PageLayouts = %v, want %v", plot.PageLayouts, want)
+	}
+}
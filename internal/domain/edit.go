@@ -0,0 +1,232 @@
+package domain
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxEditDialogueLen は編集画面で入力できるセリフ (1つの吹き出し) の最大文字数です。
+	MaxEditDialogueLen = 500
+	// MaxEditVisualAnchorLen は編集画面で入力できる描写の最大文字数です。
+	MaxEditVisualAnchorLen = 2000
+)
+
+// PanelEdit は編集画面で変更できるパネルの項目です。
+type PanelEdit struct {
+	SpeakerID    string
+	Dialogue     string
+	VisualAnchor string
+	// Lines は吹き出し単位のセリフを持つパネルの、各吹き出しの話者とセリフです。吹き出しの数と位置の指定は変更できません。
+	Lines []DialogueLine
+}
+
+// ApplyEdits は編集画面の入力を台本の各パネルに反映し、代表の話者とセリフを補完し直します。
+// 話者は knownSpeaker が受け付けるキャラクターID、空 (話者なし)、または元の台本と同じIDのいずれかです。
+// 入力はすべて検証してから反映するため、エラーの場合は台本を変更しません。
+// 改行コードと前後の空白の違いだけでは変更とみなさないため、再生成の対象を ChangedPanels で求められます。
+func (p *MangaPlot) ApplyEdits(edits []PanelEdit, knownSpeaker func(id string) bool) error {
+	if len(edits) != len(p.Panels) {
+		return fmt.Errorf("パネルの数 (%d) が台本 (%d) と一致しません", len(edits), len(p.Panels))
+	}
+	speakerOK := func(id, original string) bool {
+		return id == "" || id == original || knownSpeaker(id)
+	}
+	for i, e := range edits {
+		panel := p.Panels[i]
+		anchor := editText(e.VisualAnchor)
+		switch {
+		case anchor == "":
+			return fmt.Errorf("第 %d パネルの描写を入力してください", i+1)
+		case utf8.RuneCountInString(anchor) > MaxEditVisualAnchorLen:
+			return fmt.Errorf("第 %d パネルの描写は %d 文字以内で指定してください", i+1, MaxEditVisualAnchorLen)
+		}
+		if len(panel.Lines) == 0 {
+			if !speakerOK(e.SpeakerID, panel.SpeakerID) {
+				return fmt.Errorf("第 %d パネルの話者が不明です: %s", i+1, e.SpeakerID)
+			}
+			if utf8.RuneCountInString(editText(e.Dialogue)) > MaxEditDialogueLen {
+				return fmt.Errorf("第 %d パネルのセリフは %d 文字以内で指定してください", i+1, MaxEditDialogueLen)
+			}
+			continue
+		}
+		if len(e.Lines) != len(panel.Lines) {
+			return fmt.Errorf("第 %d パネルの吹き出しの数 (%d) が台本 (%d) と一致しません", i+1, len(e.Lines), len(panel.Lines))
+		}
+		for j, line := range e.Lines {
+			text := editText(line.Text)
+			switch {
+			case !speakerOK(line.SpeakerID, panel.Lines[j].SpeakerID):
+				return fmt.Errorf("第 %d パネルの %d 番目の吹き出しの話者が不明です: %s", i+1, j+1, line.SpeakerID)
+			case text == "":
+				return fmt.Errorf("第 %d パネルの %d 番目のセリフを入力してください", i+1, j+1)
+			case utf8.RuneCountInString(text) > MaxEditDialogueLen:
+				return fmt.Errorf("第 %d パネルの %d 番目のセリフは %d 文字以内で指定してください", i+1, j+1, MaxEditDialogueLen)
+			}
+		}
+	}
+
+	for i, e := range edits {
+		panel := &p.Panels[i]
+		if anchor := editText(e.VisualAnchor); anchor != editText(panel.VisualAnchor) {
+			panel.VisualAnchor = anchor
+		}
+		if len(panel.Lines) == 0 {
+			panel.SpeakerID = e.SpeakerID
+			if dialogue := editText(e.Dialogue); dialogue != editText(panel.Dialogue) {
+				panel.Dialogue = dialogue
+			}
+			continue
+		}
+
+		lines := slices.Clone(panel.Lines)
+		speakersChanged := false
+		for j, line := range e.Lines {
+			if text := editText(line.Text); text != editText(lines[j].Text) {
+				lines[j].Text = text
+			}
+			if line.SpeakerID != lines[j].SpeakerID {
+				lines[j].SpeakerID = line.SpeakerID
+				speakersChanged = true
+			}
+		}
+		if slices.Equal(lines, panel.Lines) {
+			continue
+		}
+		// 代表の話者とセリフは Lines から補完し直します（話者が変わらない場合は元の代表の話者を保ちます）
+		panel.Lines = lines
+		panel.Dialogue = ""
+		if speakersChanged {
+			panel.SpeakerID = ""
+		}
+	}
+	p.Normalize()
+	return nil
+}
+
+// editText はフォームから送信された文字列の改行コードを LF に揃え、前後の空白を取り除きます。
+func editText(s string) string {
+	return strings.TrimSpace(strings.ReplaceAll(s, "\r\n", "\n"))
+}
+
+// ChangedPanels は before と after で内容の異なるパネルのインデックス (0 始まり) を返します。
+// 画像パス (ReferenceURL) は生成のたびに書き換わるため比較しません。パネル数の異なる台本は比較できないため nil を返します。
+func ChangedPanels(before, after *MangaPlot) []int {
+	if before == nil || after == nil || len(before.Panels) != len(after.Panels) {
+		return nil
+	}
+	var changed []int
+	for i := range after.Panels {
+		a, b := before.Panels[i], after.Panels[i]
+		a.ReferenceURL, b.ReferenceURL = "", ""
+		if !reflect.DeepEqual(a, b) {
+			changed = append(changed, i)
+		}
+	}
+	return changed
+}
+
+// PlotDiff は保存済みの台本と改訂した台本の差分です。パネルはインデックス (0 始まり)、ページは番号 (1 始まり) で表します。
+type PlotDiff struct {
+	// Panels は話者 (speaker_id) または描写 (visual_anchor) が変わり、画像を生成し直すパネルです。
+	Panels []int `json:"panels,omitempty"`
+	// Dialogue はセリフなど、画像の生成に使わない項目だけが変わったパネルです。
+	Dialogue []int `json:"dialogue,omitempty"`
+	// Pages は作り直すページです。変更したパネルを含むページと、コマ割りテンプレートを変更したページです。
+	Pages []int `json:"pages,omitempty"`
+}
+
+// DiffPlots は保存済みの台本 before と改訂した台本 after を比べ、作り直す対象を求めます。
+// 画像パス (ReferenceURL) は比較しません。パネル数の異なる台本は既存の画像と対応付けられないため、エラーを返します。
+func DiffPlots(before, after *MangaPlot, perPage int) (PlotDiff, error) {
+	var diff PlotDiff
+	if before == nil || after == nil {
+		return diff, fmt.Errorf("比較する台本がありません")
+	}
+	if len(before.Panels) != len(after.Panels) {
+		return diff, fmt.Errorf("パネルの数が変わっています (%d → %d)。パネル数の異なる台本は作品の更新では扱えません", len(before.Panels), len(after.Panels))
+	}
+
+	for _, i := range ChangedPanels(before, after) {
+		a, b := before.Panels[i], after.Panels[i]
+		if a.SpeakerID != b.SpeakerID || a.VisualAnchor != b.VisualAnchor {
+			diff.Panels = append(diff.Panels, i)
+		} else {
+			diff.Dialogue = append(diff.Dialogue, i)
+		}
+	}
+
+	pages := PagesOf(slices.Concat(diff.Panels, diff.Dialogue), perPage)
+	total := PageCount(len(after.Panels), perPage)
+	for page := 1; page <= total; page++ {
+		if before.LayoutFor(page) != after.LayoutFor(page) && !slices.Contains(pages, page) {
+			pages = append(pages, page)
+		}
+	}
+	slices.Sort(pages)
+	diff.Pages = pages
+	return diff, nil
+}
+
+// RedrawDialogue は Dialogue のパネルも画像を生成し直す対象 (Panels) に移した差分を返します。
+// ローカル写植を使わない場合はセリフなどもモデルが画像に描くため、写植し直すことができず、画像から作り直す必要があります。
+func (d PlotDiff) RedrawDialogue() PlotDiff {
+	if len(d.Dialogue) == 0 {
+		return d
+	}
+	d.Panels = slices.Sorted(slices.Values(slices.Concat(d.Panels, d.Dialogue)))
+	d.Dialogue = nil
+	return d
+}
+
+// Empty は作り直す対象がないかを返します。
+func (d PlotDiff) Empty() bool {
+	return len(d.Panels) == 0 && len(d.Dialogue) == 0 && len(d.Pages) == 0
+}
+
+// Summary は差分の要約です。パネルとページは画面の表示と同じく 1 から数えます。(例: "再生成: パネル 2, 5 / セリフ等の変更: パネル 3 / 再構成: ページ 1")
+func (d PlotDiff) Summary() string {
+	if d.Empty() {
+		return "変更なし"
+	}
+	var parts []string
+	if len(d.Panels) > 0 {
+		parts = append(parts, "再生成: パネル "+joinNumbers(d.Panels, 1))
+	}
+	if len(d.Dialogue) > 0 {
+		parts = append(parts, "セリフ等の変更: パネル "+joinNumbers(d.Dialogue, 1))
+	}
+	if len(d.Pages) > 0 {
+		parts = append(parts, "再構成: ページ "+joinNumbers(d.Pages, 0))
+	}
+	return strings.Join(parts, " / ")
+}
+
+// joinNumbers は各値に offset を足した数をカンマ区切りで連結します。
+func joinNumbers(values []int, offset int) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = strconv.Itoa(v + offset)
+	}
+	return strings.Join(s, ", ")
+}
+
+// PagesOf は indices (0 始まり) のパネルを含むページの番号 (1 始まり) を、重複を除いて昇順に返します。
+// ページへのパネルの割り当ては PageCount と同じく perPage 枚ずつです。
+func PagesOf(indices []int, perPage int) []int {
+	if perPage <= 0 {
+		perPage = DefaultPanelsPerPage
+	}
+	var pages []int
+	for _, i := range indices {
+		if page := i/perPage + 1; !slices.Contains(pages, page) {
+			pages = append(pages, page)
+		}
+	}
+	slices.Sort(pages)
+	return pages
+}
@@ -0,0 +1,147 @@
+package domain
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/shouni/go-manga-kit/ports"
+)
+
+func TestChangedPanels(t *testing.T) {
+	before := &MangaPlot{Panels: []PlotPanel{
+		{Panel: ports.Panel{SpeakerID: "zundamon", Dialogue: "こんにちは", ReferenceURL: "gs://b/t/images/panel_1.png"}},
+		{Panel: ports.Panel{SpeakerID: "metan", Dialogue: "やあ", ReferenceURL: "gs://b/t/images/panel_2.png"}},
+		{Panel: ports.Panel{SpeakerID: "metan", VisualAnchor: "教室"}},
+	}}
+	after := &MangaPlot{Panels: slices.Clone(before.Panels)}
+	after.Panels[0].ReferenceURL = ""
+	after.Panels[1].Dialogue = "やあ！"
+	after.Panels[2].Lines = []DialogueLine{{SpeakerID: "metan", Text: "ふむ"}}
+
+	if got, want := ChangedPanels(before, after), []int{1, 2}; !slices.Equal(got, want) {
+		t.Errorf("ChangedPanels() = %v, want %v", got, want)
+	}
+	if got := ChangedPanels(before, &MangaPlot{Panels: before.Panels[:2]}); got != nil {
+		t.Errorf("ChangedPanels(パネル数が異なる) = %v, want nil", got)
+	}
+}
+
+func TestPagesOf(t *testing.T) {
+	if got, want := PagesOf([]int{7, 0, 5, 6}, 6), []int{1, 2}; !slices.Equal(got, want) {
+		t.Errorf("PagesOf() = %v, want %v", got, want)
+	}
+	if got := PagesOf(nil, 0); got != nil {
+		t.Errorf("PagesOf(nil) = %v, want nil", got)
+	}
+}
+
+func TestMangaPlotApplyEdits(t *testing.T) {
+	known := func(id string) bool { return id == "zundamon" || id == "metan" }
+	newPlot := func() *MangaPlot {
+		return &MangaPlot{Panels: []PlotPanel{
+			{Panel: ports.Panel{SpeakerID: "zundamon", Dialogue: "こんにちは", VisualAnchor: "教室"}},
+			{
+				Panel: ports.Panel{SpeakerID: "metan", Dialogue: "やあ\nふむ", VisualAnchor: "廊下"},
+				Lines: []DialogueLine{{SpeakerID: "metan", Text: "やあ", Region: "top_right"}, {SpeakerID: "zundamon", Text: "ふむ"}},
+			},
+		}}
+	}
+
+	t.Run("改行コードと空白だけの違いは変更しない", func(t *testing.T) {
+		plot := newPlot()
+		err := plot.ApplyEdits([]PanelEdit{
+			{SpeakerID: "zundamon", Dialogue: " こんにちは\r\n", VisualAnchor: "教室"},
+			{VisualAnchor: "廊下 ", Lines: []DialogueLine{{SpeakerID: "metan", Text: "やあ"}, {SpeakerID: "zundamon", Text: "ふむ"}}},
+		}, known)
+		if err != nil {
+			t.Fatalf("ApplyEdits() error = %v", err)
+		}
+		if got := ChangedPanels(newPlot(), plot); got != nil {
+			t.Errorf("ChangedPanels() = %v, want nil", got)
+		}
+	})
+
+	t.Run("吹き出しの話者の変更", func(t *testing.T) {
+		plot := newPlot()
+		err := plot.ApplyEdits([]PanelEdit{
+			{SpeakerID: "metan", Dialogue: "こんにちは", VisualAnchor: "教室"},
+			{VisualAnchor: "廊下", Lines: []DialogueLine{{SpeakerID: "zundamon", Text: "やあ！"}, {SpeakerID: "zundamon", Text: "ふむ"}}},
+		}, known)
+		if err != nil {
+			t.Fatalf("ApplyEdits() error = %v", err)
+		}
+		p := plot.Panels[1]
+		if p.SpeakerID != "zundamon" || p.Dialogue != "やあ！\nふむ" || p.Lines[0].Region != "top_right" {
+			t.Errorf("panel 2 = %+v", p)
+		}
+		if got, want := ChangedPanels(newPlot(), plot), []int{0, 1}; !slices.Equal(got, want) {
+			t.Errorf("ChangedPanels() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("不正な入力は反映しない", func(t *testing.T) {
+		for name, edits := range map[string][]PanelEdit{
+			"パネル数":   {{VisualAnchor: "教室"}},
+			"不明な話者":  {{SpeakerID: "unknown", VisualAnchor: "教室"}, {VisualAnchor: "廊下", Lines: make([]DialogueLine, 2)}},
+			"描写なし":   {{SpeakerID: "zundamon"}, {VisualAnchor: "廊下", Lines: []DialogueLine{{Text: "a"}, {Text: "b"}}}},
+			"空のセリフ":  {{VisualAnchor: "教室"}, {VisualAnchor: "廊下", Lines: []DialogueLine{{Text: "a"}, {Text: " "}}}},
+			"吹き出しの数": {{VisualAnchor: "教室"}, {VisualAnchor: "廊下", Lines: []DialogueLine{{Text: "a"}}}},
+		} {
+			plot := newPlot()
+			if err := plot.ApplyEdits(edits, known); err == nil {
+				t.Errorf("%s: ApplyEdits() error = nil", name)
+			}
+			if !reflect.DeepEqual(plot, newPlot()) {
+				t.Errorf("%s: 台本が変更されました", name)
+			}
+		}
+	})
+}
+
+func TestDiffPlots(t *testing.T) {
+	before := &MangaPlot{Panels: []PlotPanel{
+		{Panel: ports.Panel{SpeakerID: "zundamon", Dialogue: "こんにちは", VisualAnchor: "教室", ReferenceURL: "gs://b/t/images/panel_1.png"}},
+		{Panel: ports.Panel{SpeakerID: "metan", Dialogue: "やあ", VisualAnchor: "廊下"}},
+		{Panel: ports.Panel{SpeakerID: "metan", VisualAnchor: "校庭"}},
+		{Panel: ports.Panel{SpeakerID: "zundamon", VisualAnchor: "屋上"}},
+		{Panel: ports.Panel{SpeakerID: "zundamon", VisualAnchor: "夕焼け"}},
+	}}
+	clone := func() *MangaPlot {
+		p := *before
+		p.Panels = slices.Clone(before.Panels)
+		return &p
+	}
+
+	after := clone()
+	after.Panels[0].ReferenceURL = ""
+	after.Panels[1].Dialogue = "やあ！"
+	after.Panels[2].SpeakerID = "zundamon"
+	after.PageLayouts = map[int]string{3: "splash"}
+	got, err := DiffPlots(before, after, 2)
+	if err != nil {
+		t.Fatalf("DiffPlots() error = %v", err)
+	}
+	want := PlotDiff{Panels: []int{2}, Dialogue: []int{1}, Pages: []int{1, 2, 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffPlots() = %+v, want %+v", got, want)
+	}
+	if s, want := got.Summary(), "再生成: パネル 3 / セリフ等の変更: パネル 2 / 再構成: ページ 1, 2, 3"; s != want {
+		t.Errorf("Summary() = %q, want %q", s, want)
+	}
+
+	redraw := got.RedrawDialogue()
+	if want := (PlotDiff{Panels: []int{1, 2}, Pages: []int{1, 2, 3}}); !reflect.DeepEqual(redraw, want) {
+		t.Errorf("RedrawDialogue() = %+v, want %+v", redraw, want)
+	}
+	if s, want := redraw.Summary(), "再生成: パネル 2, 3 / 再構成: ページ 1, 2, 3"; s != want {
+		t.Errorf("RedrawDialogue().Summary() = %q, want %q", s, want)
+	}
+
+	if got, err := DiffPlots(before, clone(), 2); err != nil || !got.Empty() || got.Summary() != "変更なし" {
+		t.Errorf("DiffPlots(同じ台本) = %+v, %v", got, err)
+	}
+	if _, err := DiffPlots(before, &MangaPlot{Panels: before.Panels[:4]}, 2); err == nil {
+		t.Error("DiffPlots(パネル数が異なる) error = nil")
+	}
+}
@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// JobRecordFile は作業ディレクトリに保存するジョブ記録のファイル名です。
+const JobRecordFile = "job.json"
+
+// JobStatus はジョブの進行状況です。
+type JobStatus string
+
+const (
+	// JobQueued は、受け付けたジョブをワーカーが始めるまでの状態です。作品の編集から作り直しを依頼した際に記録します。
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+// JobStep はジョブの工程です。台本 → パネル → ページの順に実行します。
+type JobStep string
+
+const (
+	JobStepScript JobStep = "script"
+	JobStepPanel  JobStep = "panel"
+	JobStepPage   JobStep = "page"
+)
+
+// order は工程の実行順です。不明な工程は -1 を返します。
+func (s JobStep) order() int {
+	switch s {
+	case JobStepScript:
+		return 0
+	case JobStepPanel:
+		return 1
+	case JobStepPage:
+		return 2
+	default:
+		return -1
+	}
+}
+
+// ImageState は、画面に表示する画像がまだない理由です。
+type ImageState string
+
+const (
+	// ImagePending は、画像を生成する工程の実行を待っている状態です。
+	ImagePending ImageState = "pending"
+	// ImageGenerating は、画像を生成する工程を実行中の状態です。
+	ImageGenerating ImageState = "generating"
+	// ImageFailed は、画像を生成する工程 (またはその前の工程) が失敗した状態です。
+	ImageFailed ImageState = "failed"
+	// ImageMissing は、ジョブが終わったにもかかわらず画像がない状態です。
+	ImageMissing ImageState = "missing"
+)
+
+// JobRecord は作品を生成したジョブの記録です。ギャラリーなど、作品一覧の表示に使用します。
+// ジョブの実行中は工程ごとに更新し、生成途中の作品を表示する画面でも使用します。
+type JobRecord struct {
+	// Command は実行したワークフローです。(例: "generate", "panel", "page")
+	Command string `json:"command"`
+	// Mode は台本生成のモードです。
+	Mode string `json:"mode,omitempty"`
+	// SourceURL は台本の元になったコンテンツのURLです。
+	SourceURL string `json:"source_url,omitempty"`
+	// Owner はジョブを投入した利用者のメールアドレスです。
+	Owner        string `json:"owner,omitempty"`
+	ColorMode    string `json:"color_mode,omitempty"`
+	PageRenderer string `json:"page_renderer,omitempty"`
+	// CreatedAt はジョブの開始時刻です。
+	CreatedAt time.Time `json:"created_at"`
+	// Status はジョブの進行状況です。進行状況を記録する前の古い記録では空で、終了したものとして扱います。
+	Status JobStatus `json:"status,omitempty"`
+	// Step は実行中の工程です。終了したジョブでは最後に実行した (失敗した場合は失敗した) 工程です。
+	Step JobStep `json:"step,omitempty"`
+	// Error は失敗したジョブのエラーメッセージです。
+	Error string `json:"error,omitempty"`
+	// Panels は台本のパネル数です。台本を保存する前の作品でも、画像の枠を表示できるよう記録します。
+	Panels int `json:"panels,omitempty"`
+	// Changes は既存の作品を改訂した台本で更新したジョブの、作り直した対象です。
+	Changes *PlotDiff `json:"changes,omitempty"`
+	// UpdatedAt は記録を最後に更新した時刻です。
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+}
+
+// Running はジョブが実行中 (または実行待ち) かを返します。
+func (r *JobRecord) Running() bool {
+	return r != nil && (r.Status == JobRunning || r.Status == JobQueued)
+}
+
+// Includes は、ジョブのワークフローが工程 step を含むかを返します。
+// generate は全工程を、script・panel・page はそれぞれの工程のみを実行します。
+// regenerate はパネルを作り直し、作り直すページ (Changes.Pages) がある場合はページも作り直します。
+func (r *JobRecord) Includes(step JobStep) bool {
+	if r == nil {
+		return false
+	}
+	switch r.Command {
+	case "generate":
+		return true
+	case "regenerate":
+		return step == JobStepPanel || (step == JobStepPage && r.Changes != nil && len(r.Changes.Pages) > 0)
+	default:
+		return r.Command == string(step)
+	}
+}
+
+// MissingImageState は、工程 step で生成する画像がない理由をジョブの進行状況から判断します。
+// 記録がない場合や、ジョブが終わっている場合は ImageMissing を返します。
+func (r *JobRecord) MissingImageState(step JobStep) ImageState {
+	if r == nil {
+		return ImageMissing
+	}
+	switch r.Status {
+	case JobQueued:
+		return ImagePending
+	case JobRunning:
+		switch {
+		case r.Step == step:
+			return ImageGenerating
+		case r.Step.order() < step.order():
+			return ImagePending
+		}
+	case JobFailed:
+		if r.Step.order() <= step.order() {
+			return ImageFailed
+		}
+	}
+	return ImageMissing
+}
+
+// JobStore は、ジョブ記録を読み書きするためのインターフェースです。
+type JobStore interface {
+	// LoadJob は、path のジョブ記録を読み込みます。ファイルがない場合は nil を返します。
+	LoadJob(ctx context.Context, path string) (*JobRecord, error)
+	// SaveJob は、ジョブ記録を path (例: gs://bucket/output/{title}/job.json) に保存します。
+	SaveJob(ctx context.Context, path string, rec JobRecord) error
+}
+
+// PlotStore は、保存済みの台本と改訂した台本を比べて更新するためのインターフェースです。
+type PlotStore interface {
+	// LoadPlot は、path (例: gs://bucket/output/{title}/manga_plot.json) の台本を読み込みます。ファイルがない場合は nil を返します。
+	LoadPlot(ctx context.Context, path string) (*MangaPlot, error)
+	// SavePlot は、台本を path に保存します。
+	SavePlot(ctx context.Context, path string, plot *MangaPlot) error
+}
+
+// TitleStore は、作業ディレクトリに置く付随ファイル (job.json, meta.json) と、改訂した台本を読み書きするためのインターフェースです。
+type TitleStore interface {
+	JobStore
+	MetaStore
+	PlotStore
+}
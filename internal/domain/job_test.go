@@ -0,0 +1,70 @@
+package domain
+
+import "testing"
+
+func TestJobRecordMissingImageState(t *testing.T) {
+	tests := []struct {
+		name string
+		rec  *JobRecord
+		step JobStep
+		want ImageState
+	}{
+		{"記録なし", nil, JobStepPanel, ImageMissing},
+		{"古い記録", &JobRecord{Command: "generate"}, JobStepPage, ImageMissing},
+		{"作り直しの受付後のページ", &JobRecord{Status: JobQueued, Step: JobStepPanel}, JobStepPage, ImagePending},
+		{"台本の作成中のパネル", &JobRecord{Status: JobRunning, Step: JobStepScript}, JobStepPanel, ImagePending},
+		{"パネルの生成中のパネル", &JobRecord{Status: JobRunning, Step: JobStepPanel}, JobStepPanel, ImageGenerating},
+		{"ページの生成中のパネル", &JobRecord{Status: JobRunning, Step: JobStepPage}, JobStepPanel, ImageMissing},
+		{"パネルで失敗したページ", &JobRecord{Status: JobFailed, Step: JobStepPanel}, JobStepPage, ImageFailed},
+		{"ページで失敗したページ", &JobRecord{Status: JobFailed, Step: JobStepPage}, JobStepPage, ImageFailed},
+		{"ページで失敗したパネル", &JobRecord{Status: JobFailed, Step: JobStepPage}, JobStepPanel, ImageMissing},
+		{"成功", &JobRecord{Status: JobSucceeded, Step: JobStepPage}, JobStepPage, ImageMissing},
+	}
+	for _, tt := range tests {
+		if got := tt.rec.MissingImageState(tt.step); got != tt.want {
+			t.Errorf("%s: MissingImageState(%s) = %s, want %s", tt.name, tt.step, got, tt.want)
+		}
+	}
+}
+
+func TestJobRecordIncludes(t *testing.T) {
+	generate := &JobRecord{Command: "generate"}
+	panel := &JobRecord{Command: "panel"}
+	if !generate.Includes(JobStepPage) || !panel.Includes(JobStepPanel) {
+		t.Error("Includes() = false, want true")
+	}
+	if panel.Includes(JobStepPage) || (*JobRecord)(nil).Includes(JobStepPanel) {
+		t.Error("Includes() = true, want false")
+	}
+
+	tests := []struct {
+		name string
+		rec  *JobRecord
+		step JobStep
+		want bool
+	}{
+		{name: "regenerate はパネルを含む", rec: &JobRecord{Command: "regenerate"}, step: JobStepPanel, want: true},
+		{name: "regenerate は台本を生成しない", rec: &JobRecord{Command: "regenerate"}, step: JobStepScript, want: false},
+		{name: "作り直すページがない regenerate", rec: &JobRecord{Command: "regenerate", Changes: &PlotDiff{Panels: []int{1}}}, step: JobStepPage, want: false},
+		{name: "作り直すページがある regenerate", rec: &JobRecord{Command: "regenerate", Changes: &PlotDiff{Panels: []int{1}, Pages: []int{1}}}, step: JobStepPage, want: true},
+	}
+	for _, tt := range tests {
+		if got := tt.rec.Includes(tt.step); got != tt.want {
+			t.Errorf("%s: Includes(%s) = %v, want %v", tt.name, tt.step, got, tt.want)
+		}
+	}
+}
+
+func TestPageCount(t *testing.T) {
+	tests := []struct{ panels, perPage, want int }{
+		{0, 6, 0},
+		{6, 6, 1},
+		{7, 6, 2},
+		{13, 0, 3},
+	}
+	for _, tt := range tests {
+		if got := PageCount(tt.panels, tt.perPage); got != tt.want {
+			t.Errorf("PageCount(%d, %d) = %d, want %d", tt.panels, tt.perPage, got, tt.want)
+		}
+	}
+}
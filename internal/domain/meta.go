@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MetaFile は作業ディレクトリに保存する作品のメタデータのファイル名です。
+const MetaFile = "meta.json"
+
+const (
+	maxDisplayTitleLen = 200
+	maxTags            = 20
+	maxTagLen          = 32
+	maxOwnerLen        = 254
+	maxNotesLen        = 4000
+)
+
+// TitleMeta は作品ごとに利用者が編集するメタデータです。
+// 台本 (manga_plot.json) とは別のファイルに保存するため、画像の再生成で上書きされることはありません。
+type TitleMeta struct {
+	// DisplayTitle は一覧などに表示するタイトルです。空の場合は台本のタイトルを使用します。
+	DisplayTitle string   `json:"display_title,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	// Owner は作品の担当者（メールアドレス）です。空の場合はジョブを投入した利用者とみなします。
+	Owner string `json:"owner,omitempty"`
+	// Pinned はギャラリーで先頭に固定表示する指定です。
+	Pinned bool `json:"pinned,omitempty"`
+	// Favorite はお気に入りの指定です。
+	Favorite  bool      `json:"favorite,omitempty"`
+	Notes     string    `json:"notes,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// MetaStore は、作品のメタデータを読み書きするためのインターフェースです。
+type MetaStore interface {
+	// LoadMeta は path (例: gs://bucket/output/{title}/meta.json) のメタデータを読み込みます。ファイルがない場合は nil を返します。
+	LoadMeta(ctx context.Context, path string) (*TitleMeta, error)
+	// SaveMeta はメタデータを path に保存します。
+	SaveMeta(ctx context.Context, path string, meta TitleMeta) error
+}
+
+// Normalize は前後の空白を除き、タグの重複を取り除きます。
+func (m *TitleMeta) Normalize() {
+	m.DisplayTitle = strings.TrimSpace(m.DisplayTitle)
+	m.Owner = strings.TrimSpace(m.Owner)
+	m.Notes = strings.TrimSpace(m.Notes)
+
+	var tags []string
+	for _, tag := range m.Tags {
+		tag = strings.Join(strings.Fields(tag), " ")
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	m.Tags = tags
+}
+
+// Validate は各項目の長さを検証します。
+func (m TitleMeta) Validate() error {
+	if utf8.RuneCountInString(m.DisplayTitle) > maxDisplayTitleLen {
+		return fmt.Errorf("表示タイトルは %d 文字以内で指定してください", maxDisplayTitleLen)
+	}
+	if len(m.Tags) > maxTags {
+		return fmt.Errorf("タグは %d 個まで指定できます", maxTags)
+	}
+	for _, tag := range m.Tags {
+		if utf8.RuneCountInString(tag) > maxTagLen {
+			return fmt.Errorf("タグは %d 文字以内で指定してください: %s", maxTagLen, tag)
+		}
+	}
+	if len(m.Owner) > maxOwnerLen {
+		return fmt.Errorf("担当者は %d 文字以内で指定してください", maxOwnerLen)
+	}
+	if utf8.RuneCountInString(m.Notes) > maxNotesLen {
+		return fmt.Errorf("メモは %d 文字以内で指定してください", maxNotesLen)
+	}
+	return nil
+}
+
+// ParseTags はカンマ（全角を含む）区切りのタグ文字列を分割します。
+func ParseTags(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '、' || r == '，' })
+}
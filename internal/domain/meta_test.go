@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTitleMetaNormalize(t *testing.T) {
+	m := TitleMeta{
+		DisplayTitle: "  社内勉強会 ",
+		Tags:         ParseTags("研修, Go 、 研修，  ,Go  言語"),
+		Owner:        " a@example.com ",
+	}
+	m.Normalize()
+	if m.DisplayTitle != "社内勉強会" || m.Owner != "a@example.com" {
+		t.Errorf("Normalize() = %+v", m)
+	}
+	if want := []string{"研修", "Go", "Go 言語"}; !slices.Equal(m.Tags, want) {
+		t.Errorf("Tags = %q, want %q", m.Tags, want)
+	}
+	if err := m.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestTitleMetaValidate(t *testing.T) {
+	tests := []TitleMeta{
+		{DisplayTitle: strings.Repeat("あ", maxDisplayTitleLen+1)},
+		{Tags: slices.Repeat([]string{"x"}, maxTags+1)},
+		{Tags: []string{strings.Repeat("x", maxTagLen+1)}},
+		{Notes: strings.Repeat("x", maxNotesLen+1)},
+	}
+	for i, m := range tests {
+		if err := m.Validate(); err == nil {
+			t.Errorf("case %d: Validate() = nil, want error", i)
+		}
+	}
+}
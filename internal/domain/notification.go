@@ -19,4 +19,13 @@ type NotificationRequest struct {
 
 	// ExecutionMode は、実行されたコマンドとモードです。(例: "generate / duet")
 	ExecutionMode string `json:"execution_mode"`
+
+	// Owner は、ジョブを投入した利用者、または作品のメタデータに設定された担当者です。
+	Owner string `json:"owner,omitempty"`
+
+	// Tags は、作品のメタデータに設定されたタグです。
+	Tags []string `json:"tags,omitempty"`
+
+	// Changes は、既存の作品を改訂した台本で更新した場合の、作り直した対象の要約です。
+	Changes string `json:"changes,omitempty"`
 }
@@ -0,0 +1,275 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shouni/go-manga-kit/ports"
+)
+
+// PanelEmphasis はパネルの演出上の強調度です。
+type PanelEmphasis string
+
+const (
+	// EmphasisNone は強調指定なしを表します。
+	EmphasisNone PanelEmphasis = ""
+	// EmphasisImpact はページ内で最も印象的に見せるべきパネルを表します。
+	EmphasisImpact PanelEmphasis = "impact"
+)
+
+// PanelSize はページ内でのパネルの相対的な大きさの指定です。
+type PanelSize string
+
+const (
+	// SizeAuto はレイアウトに大きさを任せることを表します。
+	SizeAuto PanelSize = ""
+	// SizeSmall は間や反応を見せる小さなコマです。
+	SizeSmall PanelSize = "small"
+	// SizeMedium は標準的な大きさのコマです。
+	SizeMedium PanelSize = "medium"
+	// SizeLarge は場面をじっくり見せる大きなコマです。
+	SizeLarge PanelSize = "large"
+)
+
+// DialogueLine は1つの吹き出しに入るセリフです。
+type DialogueLine struct {
+	SpeakerID string `json:"speaker_id"`
+	Text      string `json:"text"`
+	// Region はローカル写植時に吹き出しを置く位置です (top_right / top_left / bottom_right / bottom_left / top / bottom / center)。
+	Region string `json:"region,omitempty"`
+	// Direction はローカル写植時の書字方向 (vertical / horizontal) です。空の場合は本文から判定します。
+	Direction string `json:"direction,omitempty"`
+}
+
+// PlotPanel は ports.Panel に、本アプリ独自の演出指定を加えたパネルです。
+// ports.Panel を埋め込んでいるため、JSON 上は同じ階層にフィールドが並びます。
+type PlotPanel struct {
+	ports.Panel
+	// Emphasis はパネルの強調指定です。"impact" を指定すると、そのパネルが見せ場として扱われます。
+	Emphasis PanelEmphasis `json:"emphasis,omitempty"`
+	// Size はパネルの相対的な大きさ (small / medium / large) です。
+	Size PanelSize `json:"size,omitempty"`
+	// FullWidth はページの横幅いっぱいにパネルを配置する指定です。
+	FullWidth bool `json:"full_width,omitempty"`
+	// Speakers はコマ内に登場するキャラクターIDの一覧です。SpeakerID に加えて、セリフのないキャラクターも指定できます。
+	Speakers []string `json:"speakers,omitempty"`
+	// Lines はコマ内のセリフを吹き出し単位で並べたものです。指定された場合は Dialogue より優先されます。
+	Lines []DialogueLine `json:"lines,omitempty"`
+	// Narration はナレーション用の四角い枠（キャプション）に入れる文章です。
+	Narration string `json:"narration,omitempty"`
+	// SFX は描き文字として背景に描き込む効果音・擬音です。
+	SFX []string `json:"sfx,omitempty"`
+}
+
+// SpeakerIDs はコマ内に登場するキャラクターIDを、重複を除いて登場順に返します。
+func (p PlotPanel) SpeakerIDs() []string {
+	seen := make(map[string]bool)
+	var ids []string
+	add := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	add(p.SpeakerID)
+	for _, id := range p.Speakers {
+		add(id)
+	}
+	for _, line := range p.Lines {
+		add(line.SpeakerID)
+	}
+	return ids
+}
+
+// DialogueLines は吹き出し単位のセリフを返します。Lines がない場合は Dialogue を SpeakerID のセリフとして扱います。
+func (p PlotPanel) DialogueLines() []DialogueLine {
+	if len(p.Lines) > 0 {
+		return p.Lines
+	}
+	if p.Dialogue == "" {
+		return nil
+	}
+	return []DialogueLine{{SpeakerID: p.SpeakerID, Text: p.Dialogue}}
+}
+
+// normalize は Lines / Speakers から go-manga-kit が参照する SpeakerID と Dialogue を補完します。
+// go-manga-kit はパネルごとに1人の話者しか扱わないため、先頭の話者を代表とし、セリフは改行で連結します。
+func (p *PlotPanel) normalize() {
+	if p.SpeakerID == "" {
+		if ids := p.SpeakerIDs(); len(ids) > 0 {
+			p.SpeakerID = ids[0]
+		}
+	}
+	if p.Dialogue == "" && len(p.Lines) > 0 {
+		texts := make([]string, len(p.Lines))
+		for i, line := range p.Lines {
+			texts[i] = line.Text
+		}
+		p.Dialogue = strings.Join(texts, "\n")
+	}
+}
+
+// Validate は演出指定の値が既知のものであるかを検証します。
+func (p PlotPanel) Validate() error {
+	switch p.Emphasis {
+	case EmphasisNone, EmphasisImpact:
+	default:
+		return fmt.Errorf("unsupported emphasis: %s", p.Emphasis)
+	}
+	switch p.Size {
+	case SizeAuto, SizeSmall, SizeMedium, SizeLarge:
+	default:
+		return fmt.Errorf("unsupported panel size: %s", p.Size)
+	}
+	for i, line := range p.Lines {
+		if strings.TrimSpace(line.Text) == "" {
+			return fmt.Errorf("line %d has no text", i+1)
+		}
+		switch line.Region {
+		case "", "top_right", "top_left", "bottom_right", "bottom_left", "top", "bottom", "center":
+		default:
+			return fmt.Errorf("line %d: unsupported region: %s", i+1, line.Region)
+		}
+		switch line.Direction {
+		case "", "vertical", "horizontal":
+		default:
+			return fmt.Errorf("line %d: unsupported direction: %s", i+1, line.Direction)
+		}
+	}
+	return nil
+}
+
+// DefaultPanelsPerPage は go-manga-kit が MaxPanelsPerPage 未指定時に使用する1ページあたりのパネル数です。
+const DefaultPanelsPerPage = 6
+
+// PageCount は panels 枚のパネルを perPage 枚ずつページに割り当てたときのページ数です。
+// perPage が0以下の場合は DefaultPanelsPerPage を使用します。
+func PageCount(panels, perPage int) int {
+	if perPage <= 0 {
+		perPage = DefaultPanelsPerPage
+	}
+	return (panels + perPage - 1) / perPage
+}
+
+// MangaPlot は ports.MangaResponse に、本アプリ独自の演出指定を加えた台本です。
+// manga_plot.json と同じ JSON 形式を持ち、go-manga-kit が解釈しないフィールドもここで保持します。
+type MangaPlot struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// Layout は全ページに適用するコマ割りテンプレート名です。空の場合はパネル数から自動で選択します。
+	Layout string `json:"layout,omitempty"`
+	// PageLayouts はページ番号ごとのコマ割りテンプレート名です。Layout より優先されます。
+	// ページ番号は Panel.Page ではなく、パネルを先頭から MaxPanelsPerPage 枚ずつ割り当てた描画上のページ (1 始まり) です。
+	PageLayouts map[int]string `json:"page_layouts,omitempty"`
+	Panels      []PlotPanel    `json:"panels"`
+}
+
+// NewMangaPlot は go-manga-kit の台本から MangaPlot を生成します。
+func NewMangaPlot(manga *ports.MangaResponse) *MangaPlot {
+	if manga == nil {
+		return nil
+	}
+	plot := &MangaPlot{
+		Title:       manga.Title,
+		Description: manga.Description,
+	}
+	plot.ApplyResponse(manga)
+	return plot
+}
+
+// Validate は各パネルの演出指定を検証します。
+func (p *MangaPlot) Validate() error {
+	for i, panel := range p.Panels {
+		if err := panel.Validate(); err != nil {
+			return fmt.Errorf("panel %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Normalize は複数話者の指定から、go-manga-kit が参照する代表の話者とセリフを補完します。
+func (p *MangaPlot) Normalize() {
+	for i := range p.Panels {
+		p.Panels[i].normalize()
+	}
+}
+
+// Response は go-manga-kit のワークフローに渡すための ports.MangaResponse を生成します。
+func (p *MangaPlot) Response() *ports.MangaResponse {
+	panels := make([]ports.Panel, len(p.Panels))
+	for i, panel := range p.Panels {
+		panels[i] = panel.Panel
+	}
+	return &ports.MangaResponse{
+		Title:       p.Title,
+		Description: p.Description,
+		Panels:      panels,
+	}
+}
+
+// ApplyResponse はワークフローで更新された台本（画像パスなど）を MangaPlot に反映します。
+// 演出指定は同じ位置のパネルに対してそのまま維持されます。
+func (p *MangaPlot) ApplyResponse(manga *ports.MangaResponse) {
+	if manga == nil {
+		return
+	}
+	p.Title = manga.Title
+	p.Description = manga.Description
+
+	panels := make([]PlotPanel, len(manga.Panels))
+	for i, panel := range manga.Panels {
+		if i < len(p.Panels) {
+			panels[i] = p.Panels[i]
+		}
+		panels[i].Panel = panel
+	}
+	p.Panels = panels
+}
+
+// Lookup は go-manga-kit から渡されたパネルに対応する台本上のパネルを返します。
+// 画像パスは生成の途中で書き換わるため、ページ番号・話者・描写・セリフの内容で照合します。
+func (p *MangaPlot) Lookup(panel ports.Panel) (PlotPanel, bool) {
+	i := p.indexOf(panel)
+	if i < 0 {
+		return PlotPanel{}, false
+	}
+	return p.Panels[i], true
+}
+
+// PageOf は go-manga-kit から渡されたパネルが描画されるページの番号 (1 始まり) を返します。
+// ページへのパネルの割り当ては PageCount と同じく、先頭から perPage 枚ずつです。台本にないパネルの場合は 0 を返します。
+func (p *MangaPlot) PageOf(panel ports.Panel, perPage int) int {
+	if perPage <= 0 {
+		perPage = DefaultPanelsPerPage
+	}
+	i := p.indexOf(panel)
+	if i < 0 {
+		return 0
+	}
+	return i/perPage + 1
+}
+
+// indexOf は Lookup と同じ規則で照合したパネルのインデックスを返します。見つからない場合は -1 を返します。
+func (p *MangaPlot) indexOf(panel ports.Panel) int {
+	if p == nil {
+		return -1
+	}
+	for i, candidate := range p.Panels {
+		if candidate.Page == panel.Page &&
+			candidate.SpeakerID == panel.SpeakerID &&
+			candidate.VisualAnchor == panel.VisualAnchor &&
+			candidate.Dialogue == panel.Dialogue {
+			return i
+		}
+	}
+	return -1
+}
+
+// LayoutFor は指定された描画上のページ番号 (PageOf) に明示されたコマ割りテンプレート名を返します。
+func (p *MangaPlot) LayoutFor(page int) string {
+	if name, ok := p.PageLayouts[page]; ok && name != "" {
+		return name
+	}
+	return p.Layout
+}
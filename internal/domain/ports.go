@@ -17,13 +17,19 @@ type Workflows interface {
 	// Design は指定されたキャラクターIDのキャラクターを生成します。
 	Design(ctx context.Context, charIDs []string, seed int64, outputDir string) (string, int64, error)
 	// Script は指定されたURLから台本を作成し、指定先へ保存します。
-	Script(ctx context.Context, sourceURL, mode, outputPath string) (*ports.MangaResponse, error)
-	// Panel は指定された漫画のページを保存します。
-	Panel(ctx context.Context, manga *ports.MangaResponse, outputPath string) (*ports.MangaResponse, error)
-	// Page は指定された漫画のページを保存します。
-	Page(ctx context.Context, manga *ports.MangaResponse, outputPath string) ([]string, error)
+	Script(ctx context.Context, sourceURL, mode, outputPath string) (*MangaPlot, error)
+	// Panel は指定された描画指定でパネル画像を生成し、画像パスを反映した台本を保存します。
+	Panel(ctx context.Context, plot *MangaPlot, outputPath string, opts ImageOptions) (*MangaPlot, error)
+	// Page は指定された描画指定でページ画像を生成し、保存します。
+	Page(ctx context.Context, plot *MangaPlot, outputPath string, opts ImageOptions) ([]string, error)
+	// RegeneratePanels は indices (0 始まり) のパネル画像だけを生成し直して上書きし、画像パスを反映した台本を保存します。
+	RegeneratePanels(ctx context.Context, plot *MangaPlot, outputPath string, indices []int, opts ImageOptions) (*MangaPlot, error)
+	// ReletterPanels は indices (0 始まり) のパネル画像に、写植前の画像から写植し直します。ローカル写植を使わない場合は何もしません。
+	ReletterPanels(ctx context.Context, plot *MangaPlot, indices []int) error
+	// RegeneratePages はページ番号 pages (1 始まり) のページ画像だけを作り直して上書きします。
+	RegeneratePages(ctx context.Context, plot *MangaPlot, outputPath string, pages []int, opts ImageOptions) ([]string, error)
 	// Publish は指定された漫画を公開します。
-	Publish(ctx context.Context, manga *ports.MangaResponse, outputDir string) (*ports.PublishResult, error)
+	Publish(ctx context.Context, plot *MangaPlot, outputDir string) (*ports.PublishResult, error)
 }
 
 // Notifier は、生成されたコンテンツまたはエラーに関する通知を指定されたターゲットまたはチャネルに送信するためのインターフェイスです。
@@ -33,3 +39,9 @@ type Notifier interface {
 	// NotifyError は、関連メタデータを含むエラー通知をターゲットに送信します。
 	NotifyError(ctx context.Context, err error, req NotificationRequest) error
 }
+
+// SearchIndexer は、作品の台本を全文検索用の索引に登録するためのインターフェースです。
+type SearchIndexer interface {
+	// IndexTitle は、作業ディレクトリ名 name の作品の台本を索引に追加（または更新）します。
+	IndexTitle(ctx context.Context, name string, plot *MangaPlot) error
+}
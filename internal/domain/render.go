@@ -0,0 +1,57 @@
+package domain
+
+import "fmt"
+
+// ColorMode は画像生成時の色表現モードです。
+type ColorMode string
+
+const (
+	// ColorModeFullColor は鮮やかなフルカラーで描画します。(デフォルト)
+	ColorModeFullColor ColorMode = "full_color"
+	// ColorModeMonochrome はスクリーントーンを用いた白黒漫画として描画します。
+	ColorModeMonochrome ColorMode = "monochrome"
+	// ColorModeLimitedPalette は黒とアクセントカラー1色の限定パレットで描画します。
+	ColorModeLimitedPalette ColorMode = "limited_palette"
+)
+
+// ParseColorMode は文字列を ColorMode に変換します。空文字の場合はフルカラーを返します。
+func ParseColorMode(s string) (ColorMode, error) {
+	switch mode := ColorMode(s); mode {
+	case "":
+		return ColorModeFullColor, nil
+	case ColorModeFullColor, ColorModeMonochrome, ColorModeLimitedPalette:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported color mode: %s", s)
+	}
+}
+
+// PageRenderer はページ画像の作成方法です。
+type PageRenderer string
+
+const (
+	// PageRendererAI は高品質画像モデルにパネル画像を参照させてページ全体を描き直します。(デフォルト)
+	PageRendererAI PageRenderer = "ai"
+	// PageRendererCompose は生成済みのパネル画像をコマ割りに従ってローカルで合成します。
+	PageRendererCompose PageRenderer = "compose"
+)
+
+// ParsePageRenderer は文字列を PageRenderer に変換します。空文字の場合は PageRendererAI を返します。
+func ParsePageRenderer(s string) (PageRenderer, error) {
+	switch renderer := PageRenderer(s); renderer {
+	case "":
+		return PageRendererAI, nil
+	case PageRendererAI, PageRendererCompose:
+		return renderer, nil
+	default:
+		return "", fmt.Errorf("unsupported page renderer: %s", s)
+	}
+}
+
+// ImageOptions は画像生成プロンプトに適用する、ジョブ単位の描画指定です。
+type ImageOptions struct {
+	// ColorMode はパネル・ページ共通の色表現モードです。
+	ColorMode ColorMode
+	// PageRenderer はページ画像の作成方法です。
+	PageRenderer PageRenderer
+}
@@ -12,6 +12,29 @@ type GenerateTaskPayload struct {
 	Mode string `json:"mode"`
 	// TargetPanels は生成したいパネルのインデックスをカンマ区切りで指定します（例: "0,2"）。
 	TargetPanels string `json:"target_panels"`
+	// ComposePages は、変更したパネルを含むページの画像も作り直すかを指定します。ページ画像のある作品で指定します。(Regenerateモードで使用)
+	ComposePages bool `json:"compose_pages,omitempty"`
+	// Title は更新する既存の作品の作業ディレクトリ名です。(Regenerateモードで使用)
+	Title string `json:"title,omitempty"`
 	// Seed は乱数生成のためのシード値です。
 	Seed int64 `json:"seed"`
+	// ColorMode は画像生成時の色表現モードです。(例: "full_color", "monochrome", "limited_palette")
+	ColorMode string `json:"color_mode"`
+	// PageRenderer はページ画像の作成方法です。(例: "ai", "compose")
+	PageRenderer string `json:"page_renderer,omitempty"`
+	// Owner はジョブを投入した利用者のメールアドレスです。
+	Owner string `json:"owner,omitempty"`
+}
+
+// ImageOptions はペイロードから画像生成用の描画指定を組み立てます。
+func (p GenerateTaskPayload) ImageOptions() (ImageOptions, error) {
+	colorMode, err := ParseColorMode(p.ColorMode)
+	if err != nil {
+		return ImageOptions{}, err
+	}
+	pageRenderer, err := ParsePageRenderer(p.PageRenderer)
+	if err != nil {
+		return ImageOptions{}, err
+	}
+	return ImageOptions{ColorMode: colorMode, PageRenderer: pageRenderer}, nil
 }
@@ -0,0 +1,187 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shouni/go-character-kit/character"
+	"github.com/shouni/go-manga-kit/asset"
+
+	"ap-manga-web/internal/domain"
+)
+
+// fileIndex は panel_1.png や manga_page_12.png などの連番部分に一致します。
+var fileIndex = regexp.MustCompile(`_(\d+)\.[^.]+$`)
+
+// Storage は作品の読み込みに必要なストレージ操作です。remoteio.InputReader が満たします。
+type Storage interface {
+	Opener
+	List(ctx context.Context, path string, fn func(path string) error) error
+}
+
+// LoadBook は作業ディレクトリ (例: gs://bucket/output/{title}) の manga_plot.json とページ画像から作品を組み立てます。
+// chars はキャラクターIDを表示名に変換するために使用します。nil の場合は ID をそのまま使用します。
+// perPage は1ページあたりのパネル数 (MAX_PANELS_PER_PAGE) で、セリフ一覧のページ番号を描画上のページに合わせるために使用します。
+func LoadBook(ctx context.Context, store Storage, workDir string, chars *character.Characters, perPage int) (Book, error) {
+	workDir = strings.TrimSuffix(workDir, "/")
+
+	plot, err := loadPlot(ctx, store, workDir+"/"+asset.DefaultMangaPlotJson)
+	if err != nil {
+		return Book{}, err
+	}
+
+	pages, err := listImages(ctx, store, workDir, asset.PageFileRegex)
+	if err != nil {
+		return Book{}, fmt.Errorf("ページ画像のリスト取得に失敗しました: %w", err)
+	}
+	panels, err := listImages(ctx, store, workDir, asset.PanelFileRegex)
+	if err != nil {
+		return Book{}, fmt.Errorf("パネル画像のリスト取得に失敗しました: %w", err)
+	}
+
+	title := plot.Title
+	if title == "" {
+		title = path.Base(workDir)
+	}
+	names := speakerNames(plot, chars)
+	book := Book{
+		Name:        path.Base(workDir),
+		Title:       title,
+		Description: plot.Description,
+		Pages:       pages,
+		Panels:      panels,
+		Plot:        plot,
+		names:       names,
+	}
+	book.Characters = characterNames(plot, book.SpeakerName)
+	book.Transcript = transcript(plot, book.SpeakerName, perPage)
+	return book, nil
+}
+
+// listImages は作業ディレクトリの images/ 以下で re に一致する画像を連番順に返します。
+func listImages(ctx context.Context, store Storage, workDir string, re *regexp.Regexp) ([]string, error) {
+	dir := workDir + "/" + asset.DefaultImageDir
+	var paths []string
+	err := store.List(ctx, dir, func(p string) error {
+		if IsDirectChild(dir, p) && re.MatchString(path.Base(p)) {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	SortIndexedPaths(paths)
+	return paths, nil
+}
+
+// IsDirectChild は p が dir 直下のファイルであるかを返します。
+// GCS のリストはサブディレクトリ (写植前の raw/ や縮小画像の variants/) も含むため、同名のファイルを除外するために使用します。
+func IsDirectChild(dir, p string) bool {
+	rel, ok := strings.CutPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+	return ok && rel != "" && !strings.Contains(rel, "/")
+}
+
+// loadPlot は manga_plot.json を読み込みます。
+func loadPlot(ctx context.Context, open Opener, plotPath string) (*domain.MangaPlot, error) {
+	rc, err := open.Open(ctx, plotPath)
+	if err != nil {
+		return nil, fmt.Errorf("プロットJSONが見つかりません (%s): %w", plotPath, err)
+	}
+	defer rc.Close()
+
+	var plot domain.MangaPlot
+	if err := json.NewDecoder(rc).Decode(&plot); err != nil {
+		return nil, fmt.Errorf("プロットJSONの解析に失敗しました: %w", err)
+	}
+	return &plot, nil
+}
+
+// speakerNames は台本に登場するキャラクターIDと表示名の対応を返します。
+// キャラクター定義にない ID は含めません（Book.SpeakerName が ID をそのまま返します）。
+func speakerNames(plot *domain.MangaPlot, chars *character.Characters) map[string]string {
+	names := make(map[string]string)
+	if chars == nil {
+		return names
+	}
+	for _, panel := range plot.Panels {
+		for _, id := range panel.SpeakerIDs() {
+			if char := chars.GetCharacter(id); char != nil && char.Name != "" {
+				names[id] = char.Name
+			}
+		}
+	}
+	return names
+}
+
+// characterNames は台本に登場するキャラクターの表示名を登場順に返します。
+func characterNames(plot *domain.MangaPlot, nameOf func(string) string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, panel := range plot.Panels {
+		for _, id := range panel.SpeakerIDs() {
+			if !seen[id] {
+				seen[id] = true
+				names = append(names, nameOf(id))
+			}
+		}
+	}
+	return names
+}
+
+// transcript は台本のナレーション・セリフ・効果音をパネル順に並べます。
+// ページ番号は台本の page ではなく、パネルが描画されるページ (先頭から perPage 枚ずつ) です。
+func transcript(plot *domain.MangaPlot, nameOf func(string) string, perPage int) []TranscriptEntry {
+	if perPage <= 0 {
+		perPage = domain.DefaultPanelsPerPage
+	}
+	var entries []TranscriptEntry
+	for i, panel := range plot.Panels {
+		page := i/perPage + 1
+		if text := strings.TrimSpace(panel.Narration); text != "" {
+			entries = append(entries, TranscriptEntry{Page: page, Kind: TranscriptNarration, Text: text})
+		}
+		for _, line := range panel.DialogueLines() {
+			entries = append(entries, TranscriptEntry{Page: page, Kind: TranscriptDialogue, Speaker: nameOf(line.SpeakerID), Text: line.Text})
+		}
+		for _, sfx := range panel.SFX {
+			entries = append(entries, TranscriptEntry{Page: page, Kind: TranscriptSFX, Text: sfx})
+		}
+	}
+	return entries
+}
+
+// SortIndexedPaths はファイル名末尾の連番の数値順にパスを並べ替えます（panel_2.png は panel_10.png より前）。
+// 連番のないパスは末尾に辞書順で並べます。
+func SortIndexedPaths(paths []string) {
+	index := func(p string) int {
+		m := fileIndex.FindStringSubmatch(path.Base(p))
+		if m == nil {
+			return -1
+		}
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return -1
+		}
+		return n
+	}
+	sort.SliceStable(paths, func(i, j int) bool {
+		a, b := index(paths[i]), index(paths[j])
+		switch {
+		case a < 0 && b < 0:
+			return paths[i] < paths[j]
+		case a < 0 || b < 0:
+			return b < 0
+		case a != b:
+			return a < b
+		default:
+			return paths[i] < paths[j]
+		}
+	})
+}
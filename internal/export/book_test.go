@@ -0,0 +1,48 @@
+package export
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/shouni/go-manga-kit/ports"
+
+	"ap-manga-web/internal/domain"
+)
+
+func TestIsDirectChild(t *testing.T) {
+	tests := []struct {
+		dir, path string
+		want      bool
+	}{
+		{"gs://b/out/t/images", "gs://b/out/t/images/panel_1.png", true},
+		{"gs://b/out/t/images/", "gs://b/out/t/images/panel_1.png", true},
+		{"gs://b/out/t/images", "gs://b/out/t/images/raw/panel_1.png", false},
+		{"gs://b/out/t/images", "gs://b/out/t/images/variants/panel_1_w480_of1024.jpg", false},
+		{"gs://b/out/t/images", "gs://b/out/t/images2/panel_1.png", false},
+		{"gs://b/out/t/images", "gs://b/out/t/images/", false},
+	}
+	for _, tt := range tests {
+		if got := IsDirectChild(tt.dir, tt.path); got != tt.want {
+			t.Errorf("IsDirectChild(%q, %q) = %v, want %v", tt.dir, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestTranscriptUsesRenderedPage(t *testing.T) {
+	// 台本の page は描画上のページと一致しないことがあります (分割で追加したパネルなど)
+	plot := &domain.MangaPlot{}
+	for i := range 5 {
+		plot.Panels = append(plot.Panels, domain.PlotPanel{Panel: ports.Panel{Page: 1}, Narration: fmt.Sprintf("n%d", i+1)})
+	}
+
+	got := transcript(plot, func(id string) string { return id }, 2)
+	want := []int{1, 1, 2, 2, 3}
+	if len(got) != len(want) {
+		t.Fatalf("len(transcript) = %d, want %d", len(got), len(want))
+	}
+	for i, e := range got {
+		if e.Page != want[i] {
+			t.Errorf("entry %d (%s): Page = %d, want %d", i, e.Text, e.Page, want[i])
+		}
+	}
+}
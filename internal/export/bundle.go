@@ -0,0 +1,144 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"ap-manga-web/assets"
+	"ap-manga-web/internal/domain"
+)
+
+// bundleImageDir はバンドル内で画像を置くディレクトリです。index.html からの相対パスで参照します。
+const bundleImageDir = "images"
+
+// bundleTemplate はオフライン閲覧用の index.html です。CSS はインラインで持ち、外部リソースを参照しません。
+var bundleTemplate = template.Must(template.New("index.html").Funcs(template.FuncMap{
+	"add": func(a, b int) int { return a + b },
+}).Parse(assets.BundleTemplate))
+
+// bundleData は index.html のテンプレートに渡すデータです。
+type bundleData struct {
+	Title       string
+	Description string
+	Pages       []string
+	Panels      []bundlePanel
+}
+
+// bundlePanel は台本上の1パネル分の表示内容です。
+type bundlePanel struct {
+	Image        string
+	Narration    string
+	Lines        []bundleLine
+	SFX          []string
+	VisualAnchor string
+}
+
+type bundleLine struct {
+	Speaker string
+	Text    string
+}
+
+// WriteBundle は静的な index.html、ページ・パネル画像、manga_plot.json をまとめた ZIP を w に書き込みます。
+// 画像は index.html からの相対パスで参照するため、署名付きURLの有効期限に関係なくオフラインで閲覧できます。
+// アーカイブ内のファイルは作品名のディレクトリ以下にまとめます。
+func WriteBundle(ctx context.Context, w io.Writer, book Book, open Opener) error {
+	zw := zip.NewWriter(w)
+	modified := time.Now().UTC()
+
+	root := ""
+	if book.Name != "" {
+		root = book.Name + "/"
+	}
+
+	// 画像はストレージ上のファイル名のまま images/ に置きます
+	local := make(map[string]string, len(book.Pages)+len(book.Panels))
+	for _, src := range append(append([]string{}, book.Pages...), book.Panels...) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		name := bundleImageDir + "/" + path.Base(src)
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: root + name, Method: zip.Store, Modified: modified})
+		if err != nil {
+			return fmt.Errorf("アーカイブへの画像追加に失敗しました: %w", err)
+		}
+		if err := copyFile(ctx, open, fw, src); err != nil {
+			return err
+		}
+		local[path.Base(src)] = name
+	}
+
+	plot := bundlePlot(book.Plot, local)
+	plotJSON, err := json.MarshalIndent(plot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("プロットJSONの生成に失敗しました: %w", err)
+	}
+	if err := writeZipFile(zw, root+"manga_plot.json", zip.Deflate, modified, plotJSON); err != nil {
+		return err
+	}
+
+	data := bundleData{
+		Title:       book.Title,
+		Description: book.Description,
+		Panels:      bundlePanels(plot, book.SpeakerName),
+	}
+	for _, src := range book.Pages {
+		data.Pages = append(data.Pages, local[path.Base(src)])
+	}
+
+	var sb strings.Builder
+	if err := bundleTemplate.Execute(&sb, data); err != nil {
+		return fmt.Errorf("index.html の生成に失敗しました: %w", err)
+	}
+	if err := writeZipFile(zw, root+"index.html", zip.Deflate, modified, []byte(sb.String())); err != nil {
+		return err
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("アーカイブの書き込みに失敗しました: %w", err)
+	}
+	return nil
+}
+
+// bundlePlot はパネルの ReferenceURL をバンドル内の相対パスに置き換えたプロットのコピーを返します。
+// バンドルに含まれない画像の参照は、ストレージのパスを残さないよう空にします。
+func bundlePlot(plot *domain.MangaPlot, local map[string]string) *domain.MangaPlot {
+	if plot == nil {
+		return &domain.MangaPlot{}
+	}
+	out := *plot
+	out.Panels = make([]domain.PlotPanel, len(plot.Panels))
+	for i, p := range plot.Panels {
+		if p.ReferenceURL != "" {
+			// 署名付きURLが保存されている場合もあるため、クエリを除いたファイル名で照合します
+			ref, _, _ := strings.Cut(p.ReferenceURL, "?")
+			p.ReferenceURL = local[path.Base(ref)]
+		}
+		out.Panels[i] = p
+	}
+	return &out
+}
+
+// bundlePanels は台本のパネルを index.html の表示用に変換します。
+func bundlePanels(plot *domain.MangaPlot, nameOf func(string) string) []bundlePanel {
+	panels := make([]bundlePanel, len(plot.Panels))
+	for i, p := range plot.Panels {
+		panel := bundlePanel{
+			Image:        p.ReferenceURL,
+			Narration:    strings.TrimSpace(p.Narration),
+			SFX:          p.SFX,
+			VisualAnchor: p.VisualAnchor,
+		}
+		for _, line := range p.DialogueLines() {
+			panel.Lines = append(panel.Lines, bundleLine{Speaker: nameOf(line.SpeakerID), Text: line.Text})
+		}
+		panels[i] = panel
+	}
+	return panels
+}
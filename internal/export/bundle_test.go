@@ -0,0 +1,78 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/shouni/go-manga-kit/ports"
+
+	"ap-manga-web/internal/domain"
+)
+
+func TestWriteBundle(t *testing.T) {
+	store := memOpener{
+		"gs://b/t/images/manga_page_1.png": "page1",
+		"gs://b/t/images/panel_1.png":      "panel1",
+	}
+	book := Book{
+		Name:   "t",
+		Title:  "Go <入門>",
+		Pages:  []string{"gs://b/t/images/manga_page_1.png"},
+		Panels: []string{"gs://b/t/images/panel_1.png"},
+		Plot: &domain.MangaPlot{
+			Title: "Go <入門>",
+			Panels: []domain.PlotPanel{
+				{Panel: ports.Panel{SpeakerID: "zundamon", Dialogue: "こんにちは", ReferenceURL: "https://signed.example/t/images/panel_1.png?X-Goog-Signature=abc"}},
+				{Panel: ports.Panel{ReferenceURL: "gs://b/t/images/panel_2.png"}},
+			},
+		},
+		names: map[string]string{"zundamon": "ずんだもん"},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteBundle(context.Background(), &buf, book, store); err != nil {
+		t.Fatalf("WriteBundle() error = %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	for _, name := range []string{"t/index.html", "t/manga_plot.json", "t/images/manga_page_1.png", "t/images/panel_1.png"} {
+		if files[name] == nil {
+			t.Fatalf("archive is missing %s", name)
+		}
+	}
+	if got := readZipFile(t, files["t/images/panel_1.png"]); got != "panel1" {
+		t.Errorf("panel_1.png = %q, want panel1", got)
+	}
+
+	index := readZipFile(t, files["t/index.html"])
+	for _, want := range []string{`src="images/manga_page_1.png"`, `src="images/panel_1.png"`, "Go &lt;入門&gt;", "ずんだもん", "こんにちは"} {
+		if !strings.Contains(index, want) {
+			t.Errorf("index.html does not contain %q", want)
+		}
+	}
+	if strings.Contains(index, "X-Goog-Signature") || strings.Contains(index, "gs://") {
+		t.Errorf("index.html must not reference remote images:\n%s", index)
+	}
+
+	var plot domain.MangaPlot
+	if err := json.Unmarshal([]byte(readZipFile(t, files["t/manga_plot.json"])), &plot); err != nil {
+		t.Fatalf("manga_plot.json is invalid: %v", err)
+	}
+	if got := plot.Panels[0].ReferenceURL; got != "images/panel_1.png" {
+		t.Errorf("panel 1 reference = %q, want images/panel_1.png", got)
+	}
+	if got := plot.Panels[1].ReferenceURL; got != "" {
+		t.Errorf("panel 2 reference = %q, want empty (not bundled)", got)
+	}
+}
@@ -0,0 +1,97 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// comicInfo は ComicRack 形式のメタデータ (ComicInfo.xml) です。
+type comicInfo struct {
+	XMLName    xml.Name        `xml:"ComicInfo"`
+	XSI        string          `xml:"xmlns:xsi,attr"`
+	XSD        string          `xml:"xmlns:xsd,attr"`
+	Title      string          `xml:"Title"`
+	Summary    string          `xml:"Summary,omitempty"`
+	Characters string          `xml:"Characters,omitempty"`
+	PageCount  int             `xml:"PageCount"`
+	Manga      string          `xml:"Manga"`
+	Pages      []comicInfoPage `xml:"Pages>Page"`
+}
+
+type comicInfoPage struct {
+	Image int    `xml:"Image,attr"`
+	Type  string `xml:"Type,attr,omitempty"`
+}
+
+// ComicInfo は作品情報から ComicInfo.xml の内容を生成します。
+// 日本の漫画として右から左に読む指定 (YesAndRightToLeft) を付与し、1ページ目を表紙として扱います。
+func ComicInfo(book Book) ([]byte, error) {
+	info := comicInfo{
+		XSI:        "http://www.w3.org/2001/XMLSchema-instance",
+		XSD:        "http://www.w3.org/2001/XMLSchema",
+		Title:      book.Title,
+		Summary:    book.Description,
+		Characters: strings.Join(book.Characters, ", "),
+		PageCount:  len(book.Pages),
+		Manga:      "YesAndRightToLeft",
+	}
+	for i := range book.Pages {
+		page := comicInfoPage{Image: i}
+		if i == 0 {
+			page.Type = "FrontCover"
+		}
+		info.Pages = append(info.Pages, page)
+	}
+
+	data, err := xml.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("ComicInfo.xml の生成に失敗しました: %w", err)
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// WriteCBZ はページ画像と ComicInfo.xml を CBZ (ZIP) として w に書き込みます。
+// 画像は既に圧縮済みのため、無圧縮 (Store) で格納します。
+func WriteCBZ(ctx context.Context, w io.Writer, book Book, open Opener) error {
+	zw := zip.NewWriter(w)
+	modified := time.Now()
+
+	for i, src := range book.Pages {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     pageFileName(i, src),
+			Method:   zip.Store,
+			Modified: modified,
+		})
+		if err != nil {
+			return fmt.Errorf("アーカイブへのページ追加に失敗しました: %w", err)
+		}
+		if err := copyFile(ctx, open, fw, src); err != nil {
+			return err
+		}
+	}
+
+	info, err := ComicInfo(book)
+	if err != nil {
+		return err
+	}
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: "ComicInfo.xml", Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return fmt.Errorf("アーカイブへの ComicInfo.xml 追加に失敗しました: %w", err)
+	}
+	if _, err := fw.Write(info); err != nil {
+		return fmt.Errorf("ComicInfo.xml の書き込みに失敗しました: %w", err)
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("アーカイブの書き込みに失敗しました: %w", err)
+	}
+	return nil
+}
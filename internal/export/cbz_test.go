@@ -0,0 +1,96 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// memOpener はテスト用のインメモリストレージです。
+type memOpener map[string]string
+
+func (m memOpener) Open(_ context.Context, path string) (io.ReadCloser, error) {
+	data, ok := m[path]
+	if !ok {
+		return nil, fmt.Errorf("not found: %s", path)
+	}
+	return io.NopCloser(strings.NewReader(data)), nil
+}
+
+func TestWriteCBZ(t *testing.T) {
+	store := memOpener{
+		"gs://b/t/images/manga_page_1.png":  "page1",
+		"gs://b/t/images/manga_page_2.png":  "page2",
+		"gs://b/t/images/manga_page_10.png": "page10",
+	}
+	book := Book{
+		Title:       "Go <入門>",
+		Description: "説明",
+		Characters:  []string{"Zundamon", "Metan"},
+		Pages: []string{
+			"gs://b/t/images/manga_page_1.png",
+			"gs://b/t/images/manga_page_2.png",
+			"gs://b/t/images/manga_page_10.png",
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCBZ(context.Background(), &buf, book, store); err != nil {
+		t.Fatalf("WriteCBZ() error = %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+	wantNames := []string{"page_001.png", "page_002.png", "page_003.png", "ComicInfo.xml"}
+	if len(zr.File) != len(wantNames) {
+		t.Fatalf("archive has %d files, want %d", len(zr.File), len(wantNames))
+	}
+	for i, f := range zr.File {
+		if f.Name != wantNames[i] {
+			t.Errorf("file %d = %q, want %q", i, f.Name, wantNames[i])
+		}
+	}
+	if got := readZipFile(t, zr.File[2]); got != "page10" {
+		t.Errorf("page_003.png = %q, want page10", got)
+	}
+
+	info := readZipFile(t, zr.File[3])
+	for _, want := range []string{
+		"<Title>Go &lt;入門&gt;</Title>",
+		"<Characters>Zundamon, Metan</Characters>",
+		"<PageCount>3</PageCount>",
+		"<Manga>YesAndRightToLeft</Manga>",
+		`<Page Image="0" Type="FrontCover"></Page>`,
+	} {
+		if !strings.Contains(info, want) {
+			t.Errorf("ComicInfo.xml does not contain %q:\n%s", want, info)
+		}
+	}
+}
+
+func TestWriteCBZMissingPage(t *testing.T) {
+	book := Book{Title: "t", Pages: []string{"gs://b/missing.png"}}
+	if err := WriteCBZ(context.Background(), io.Discard, book, memOpener{}); err == nil {
+		t.Fatal("WriteCBZ() error = nil, want error for missing page")
+	}
+}
+
+func readZipFile(t *testing.T, f *zip.File) string {
+	t.Helper()
+	rc, err := f.Open()
+	if err != nil {
+		t.Fatalf("Open(%s) error = %v", f.Name, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("ReadAll(%s) error = %v", f.Name, err)
+	}
+	return string(data)
+}
@@ -0,0 +1,256 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha1"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"io"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	_ "image/jpeg" // JPEG のページ画像のサイズ取得用
+	_ "image/png"
+)
+
+// EPUBOptions は EPUB 書き出しのオプションです。
+type EPUBOptions struct {
+	// LeftToRight が true の場合は左から右へ、false の場合は日本の漫画と同じく右から左へページを送ります。
+	LeftToRight bool
+}
+
+// epubPage は EPUB 内の1ページ分の情報です。
+type epubPage struct {
+	ID        string
+	Image     string // OEBPS からの相対パス
+	MediaType string
+	XHTML     string // OEBPS からの相対パス
+	Width     int
+	Height    int
+}
+
+// WriteEPUB はページ画像を1ページ1画像で並べた EPUB 3 固定レイアウトを w に書き込みます。
+// 画像はストレージから読み込みながらアーカイブに書き出し、その際に取得した画像サイズを各ページの viewport に使用します。
+func WriteEPUB(ctx context.Context, w io.Writer, book Book, open Opener, opts EPUBOptions) error {
+	zw := zip.NewWriter(w)
+	modified := time.Now().UTC()
+
+	// mimetype は無圧縮で先頭に置く必要があります
+	if err := writeZipFile(zw, "mimetype", zip.Store, modified, []byte("application/epub+zip")); err != nil {
+		return err
+	}
+	if err := writeZipFile(zw, "META-INF/container.xml", zip.Deflate, modified, []byte(epubContainer)); err != nil {
+		return err
+	}
+
+	pages := make([]epubPage, 0, len(book.Pages))
+	for i, src := range book.Pages {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		name := pageFileName(i, src)
+		page := epubPage{
+			ID:        strings.TrimSuffix(name, path.Ext(name)),
+			Image:     "images/" + name,
+			MediaType: imageMediaType(name),
+			XHTML:     "pages/" + strings.TrimSuffix(name, path.Ext(name)) + ".xhtml",
+		}
+
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: "OEBPS/" + page.Image, Method: zip.Store, Modified: modified})
+		if err != nil {
+			return fmt.Errorf("アーカイブへのページ追加に失敗しました: %w", err)
+		}
+		if page.Width, page.Height, err = copyImage(ctx, open, fw, src); err != nil {
+			return err
+		}
+		pages = append(pages, page)
+	}
+
+	data := epubData{
+		Book:       book,
+		Identifier: bookIdentifier(book),
+		Modified:   modified.Format("2006-01-02T15:04:05Z"),
+		Direction:  "rtl",
+		Pages:      pages,
+	}
+	if opts.LeftToRight {
+		data.Direction = "ltr"
+	}
+
+	for _, page := range pages {
+		if err := renderZipFile(zw, "OEBPS/"+page.XHTML, modified, epubPageTemplate, struct {
+			epubPage
+			Title string
+		}{page, book.Title}); err != nil {
+			return err
+		}
+	}
+	if err := renderZipFile(zw, "OEBPS/nav.xhtml", modified, epubNavTemplate, data); err != nil {
+		return err
+	}
+	if err := renderZipFile(zw, "OEBPS/content.opf", modified, epubPackageTemplate, data); err != nil {
+		return err
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("アーカイブの書き込みに失敗しました: %w", err)
+	}
+	return nil
+}
+
+// copyImage は画像を w に書き込みながら、先頭部分からサイズを取得します。
+func copyImage(ctx context.Context, open Opener, w io.Writer, src string) (int, int, error) {
+	rc, err := open.Open(ctx, src)
+	if err != nil {
+		return 0, 0, fmt.Errorf("ファイルを開けませんでした (%s): %w", src, err)
+	}
+	defer rc.Close()
+
+	// DecodeConfig が読み込んだ分も TeeReader 経由で書き込まれるため、残りをそのままコピーすれば全体が書き込まれます
+	cfg, _, err := image.DecodeConfig(io.TeeReader(rc, w))
+	if err != nil {
+		return 0, 0, fmt.Errorf("画像サイズの取得に失敗しました (%s): %w", src, err)
+	}
+	if _, err := io.Copy(w, rc); err != nil {
+		return 0, 0, fmt.Errorf("ファイルの書き込みに失敗しました (%s): %w", src, err)
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+func imageMediaType(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".webp":
+		return "image/webp"
+	default:
+		return "image/png"
+	}
+}
+
+// bookIdentifier はタイトルと概要から決定的な urn:uuid 形式の識別子を生成します。
+// 同じ作品を書き出し直しても、電子書籍リーダー上で同一の本として扱われます。
+func bookIdentifier(book Book) string {
+	sum := sha1.Sum([]byte(book.Title + "\x00" + book.Description))
+	sum[6] = (sum[6] & 0x0f) | 0x50 // version 5
+	sum[8] = (sum[8] & 0x3f) | 0x80 // variant RFC 4122
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+func writeZipFile(zw *zip.Writer, name string, method uint16, modified time.Time, data []byte) error {
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: modified})
+	if err != nil {
+		return fmt.Errorf("アーカイブへの %s の追加に失敗しました: %w", name, err)
+	}
+	if _, err := fw.Write(data); err != nil {
+		return fmt.Errorf("%s の書き込みに失敗しました: %w", name, err)
+	}
+	return nil
+}
+
+func renderZipFile(zw *zip.Writer, name string, modified time.Time, tmpl *template.Template, data any) error {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return fmt.Errorf("%s の生成に失敗しました: %w", name, err)
+	}
+	return writeZipFile(zw, name, zip.Deflate, modified, []byte(sb.String()))
+}
+
+// epubData は content.opf と nav.xhtml のテンプレートに渡すデータです。
+type epubData struct {
+	Book
+	Identifier string
+	Modified   string
+	Direction  string
+	Pages      []epubPage
+}
+
+const epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+// epubFuncs はテンプレート用の関数です。作品由来の文字列は必ず xml でエスケープします。
+var epubFuncs = template.FuncMap{
+	"xml": escapeXML,
+	"add": func(a, b int) int { return a + b },
+}
+
+var (
+	epubPackageTemplate = template.Must(template.New("content.opf").Funcs(epubFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="ja" prefix="rendition: http://www.idpf.org/vocab/rendition/#">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">{{.Identifier}}</dc:identifier>
+    <dc:title>{{xml .Title}}</dc:title>
+    <dc:language>ja</dc:language>
+    {{- if .Description}}
+    <dc:description>{{xml .Description}}</dc:description>
+    {{- end}}
+    {{- range .Characters}}
+    <dc:subject>{{xml .}}</dc:subject>
+    {{- end}}
+    <meta property="dcterms:modified">{{.Modified}}</meta>
+    <meta property="rendition:layout">pre-paginated</meta>
+    <meta property="rendition:spread">none</meta>
+    <meta property="rendition:orientation">portrait</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    {{- range $i, $p := .Pages}}
+    <item id="img-{{$p.ID}}" href="{{$p.Image}}" media-type="{{$p.MediaType}}"{{if eq $i 0}} properties="cover-image"{{end}}/>
+    <item id="{{$p.ID}}" href="{{$p.XHTML}}" media-type="application/xhtml+xml"/>
+    {{- end}}
+  </manifest>
+  <spine page-progression-direction="{{.Direction}}">
+    {{- range .Pages}}
+    <itemref idref="{{.ID}}"/>
+    {{- end}}
+  </spine>
+</package>
+`))
+
+	epubNavTemplate = template.Must(template.New("nav.xhtml").Funcs(epubFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="ja" lang="ja">
+<head><title>{{xml .Title}}</title></head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <h1>{{xml .Title}}</h1>
+    <ol>
+      {{- range $i, $p := .Pages}}
+      <li><a href="{{$p.XHTML}}">ページ {{add $i 1}}</a></li>
+      {{- end}}
+    </ol>
+  </nav>
+</body>
+</html>
+`))
+
+	epubPageTemplate = template.Must(template.New("page.xhtml").Funcs(epubFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="ja" lang="ja">
+<head>
+  <title>{{xml .Title}}</title>
+  <meta name="viewport" content="width={{.Width}}, height={{.Height}}"/>
+  <style>html, body { margin: 0; padding: 0; } img { display: block; width: {{.Width}}px; height: {{.Height}}px; }</style>
+</head>
+<body>
+  <img src="../{{.Image}}" alt="{{.ID}}"/>
+</body>
+</html>
+`))
+)
+
+// escapeXML は XML のテキスト・属性値として安全な文字列にエスケープします。
+func escapeXML(s string) string {
+	var sb strings.Builder
+	_ = xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
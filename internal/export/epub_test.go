@@ -0,0 +1,77 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestWriteEPUB(t *testing.T) {
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 40, 60))); err != nil {
+		t.Fatal(err)
+	}
+	store := memOpener{
+		"gs://b/t/images/manga_page_1.png": img.String(),
+		"gs://b/t/images/manga_page_2.png": img.String(),
+	}
+	book := Book{
+		Title:      "A & B",
+		Characters: []string{"Zundamon"},
+		Pages:      []string{"gs://b/t/images/manga_page_1.png", "gs://b/t/images/manga_page_2.png"},
+	}
+
+	for _, tt := range []struct {
+		opts EPUBOptions
+		want string
+	}{
+		{EPUBOptions{}, `page-progression-direction="rtl"`},
+		{EPUBOptions{LeftToRight: true}, `page-progression-direction="ltr"`},
+	} {
+		var buf bytes.Buffer
+		if err := WriteEPUB(context.Background(), &buf, book, store, tt.opts); err != nil {
+			t.Fatalf("WriteEPUB() error = %v", err)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatalf("zip.NewReader() error = %v", err)
+		}
+
+		// mimetype は無圧縮で先頭に置かれていること
+		if first := zr.File[0]; first.Name != "mimetype" || first.Method != zip.Store {
+			t.Fatalf("first entry = %s (method %d), want stored mimetype", first.Name, first.Method)
+		}
+
+		files := make(map[string]*zip.File)
+		for _, f := range zr.File {
+			files[f.Name] = f
+		}
+		if got := readZipFile(t, files["OEBPS/images/page_002.png"]); got != img.String() {
+			t.Error("page image was not copied intact")
+		}
+
+		opf := readZipFile(t, files["OEBPS/content.opf"])
+		for _, want := range []string{
+			tt.want,
+			"<dc:title>A &amp; B</dc:title>",
+			"<dc:subject>Zundamon</dc:subject>",
+			"pre-paginated",
+			`properties="cover-image"`,
+			`<itemref idref="page_002"/>`,
+		} {
+			if !strings.Contains(opf, want) {
+				t.Errorf("content.opf does not contain %q", want)
+			}
+		}
+		if page := readZipFile(t, files["OEBPS/pages/page_001.xhtml"]); !strings.Contains(page, `width=40, height=60`) {
+			t.Errorf("page viewport is missing:\n%s", page)
+		}
+		if nav := readZipFile(t, files["OEBPS/nav.xhtml"]); !strings.Contains(nav, `href="pages/page_002.xhtml"`) {
+			t.Errorf("nav does not list page 2:\n%s", nav)
+		}
+	}
+}
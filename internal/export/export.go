@@ -0,0 +1,88 @@
+// Package export は生成済みの漫画を CBZ などの配布用フォーマットに書き出します。
+// 画像はストレージから逐次読み込み、書き出し先へそのままストリーミングします。
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"ap-manga-web/internal/domain"
+)
+
+// Opener はストレージ上のファイルを開くためのインターフェースです。remoteio.InputReader が満たします。
+type Opener interface {
+	Open(ctx context.Context, path string) (io.ReadCloser, error)
+}
+
+// Book は書き出し対象の作品です。
+type Book struct {
+	// Name は作業ディレクトリ名（URL 上のタイトル）です。
+	Name        string
+	Title       string
+	Description string
+	// Characters は登場キャラクターの表示名です。
+	Characters []string
+	// Pages はページ画像のストレージ上のパスを読み順に並べたものです。
+	Pages []string
+	// Panels はパネル画像のストレージ上のパスを連番順に並べたものです。
+	Panels []string
+	// Transcript は台本上のすべての文字要素を登場順に並べたものです。
+	Transcript []TranscriptEntry
+	// Plot は manga_plot.json の内容です。
+	Plot *domain.MangaPlot
+
+	// names はキャラクターIDから表示名への対応です。
+	names map[string]string
+}
+
+// SpeakerName はキャラクターIDの表示名を返します。不明な ID はそのまま返します。
+func (b Book) SpeakerName(id string) string {
+	if name, ok := b.names[id]; ok {
+		return name
+	}
+	return id
+}
+
+// TranscriptKind は台本上の文字要素の種類です。
+type TranscriptKind string
+
+const (
+	TranscriptDialogue  TranscriptKind = "dialogue"
+	TranscriptNarration TranscriptKind = "narration"
+	TranscriptSFX       TranscriptKind = "sfx"
+)
+
+// TranscriptEntry はセリフ・ナレーション・効果音の1件分です。
+type TranscriptEntry struct {
+	// Page はパネルが描画されるページの番号 (1 始まり) です。
+	Page    int
+	Kind    TranscriptKind
+	Speaker string
+	Text    string
+}
+
+// pageFileName はアーカイブ内でのページ画像のファイル名を返します。連番はゼロ埋めし、辞書順で並ぶようにします。
+func pageFileName(index int, src string) string {
+	ext := strings.ToLower(path.Ext(src))
+	if ext == "" {
+		ext = ".png"
+	}
+	return fmt.Sprintf("page_%03d%s", index+1, ext)
+}
+
+// copyFile はストレージ上のファイルを w に書き込みます。
+func copyFile(ctx context.Context, open Opener, w io.Writer, src string) error {
+	rc, err := open.Open(ctx, src)
+	if err != nil {
+		return fmt.Errorf("ファイルを開けませんでした (%s): %w", src, err)
+	}
+	defer rc.Close()
+
+	if _, err := io.Copy(w, rc); err != nil {
+		return fmt.Errorf("ファイルの書き込みに失敗しました (%s): %w", src, err)
+	}
+	return nil
+}
@@ -0,0 +1,412 @@
+package export
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"ap-manga-web/internal/imaging"
+)
+
+const (
+	// A4 縦 (pt)。タイトルページとセリフ一覧に使用します。
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+	pdfMargin     = 56.0
+	pdfLineHeight = 1.6
+
+	// pdfFontName は日本語用の非埋め込み CID フォントです。フォントを同梱しないため埋め込まず、表示はビューアの代替フォントに任せます。
+	// 日本語フォントを代替できないビューアでは、タイトルページとセリフ一覧が文字化けします (README の制限事項を参照)。
+	pdfFontName = "HeiseiKakuGo-W5"
+)
+
+// PDFOptions は PDF 書き出しのオプションです。
+type PDFOptions struct {
+	// Transcript が true の場合、末尾にセリフ一覧のページを追加します。
+	Transcript bool
+}
+
+// WritePDF はタイトルページ、ページ画像（1画像1ページ）、任意のセリフ一覧からなる PDF を w に書き込みます。
+// ページ画像は1枚ずつストレージから読み込んで書き出すため、全ページをメモリに保持しません。
+func WritePDF(ctx context.Context, w io.Writer, book Book, open Opener, opts PDFOptions) error {
+	pw := &pdfWriter{w: w}
+	pw.printf("%%PDF-1.7\n%%\xe2\xe3\xcf\xd3\n")
+
+	catalog := pw.alloc()
+	pagesRoot := pw.alloc()
+	font := pw.writeFont()
+
+	var kids []int
+	for _, content := range titlePage(book) {
+		kids = append(kids, pw.textPage(pagesRoot, font, content))
+	}
+	for i, src := range book.Pages {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		id, err := pw.imagePage(ctx, pagesRoot, open, src)
+		if err != nil {
+			return fmt.Errorf("page %d: %w", i+1, err)
+		}
+		kids = append(kids, id)
+	}
+	if opts.Transcript {
+		for _, content := range transcriptPages(book) {
+			kids = append(kids, pw.textPage(pagesRoot, font, content))
+		}
+	}
+
+	refs := make([]string, len(kids))
+	for i, id := range kids {
+		refs[i] = fmt.Sprintf("%d 0 R", id)
+	}
+	pw.object(pagesRoot, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(refs, " "), len(kids)))
+	// 見開き表示でも右から左に読めるよう、綴じ方向を右綴じにします
+	pw.object(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R /ViewerPreferences << /Direction /R2L >> >>", pagesRoot))
+
+	info := pw.alloc()
+	pw.object(info, fmt.Sprintf("<< /Title %s /Subject %s /Producer (ap-manga-web) /CreationDate (D:%s) >>",
+		pdfString(book.Title), pdfString(book.Description), time.Now().UTC().Format("20060102150405Z")))
+
+	pw.trailer(catalog, info)
+	return pw.err
+}
+
+// --- 低レベルの PDF 出力 ---
+
+// pdfWriter はオブジェクトのオフセットを記録しながら PDF を逐次書き出します。
+type pdfWriter struct {
+	w       io.Writer
+	offset  int64
+	offsets []int64
+	err     error
+}
+
+func (p *pdfWriter) write(b []byte) {
+	if p.err != nil {
+		return
+	}
+	n, err := p.w.Write(b)
+	p.offset += int64(n)
+	p.err = err
+}
+
+func (p *pdfWriter) printf(format string, args ...any) {
+	p.write([]byte(fmt.Sprintf(format, args...)))
+}
+
+// alloc はオブジェクト番号を予約します。
+func (p *pdfWriter) alloc() int {
+	p.offsets = append(p.offsets, 0)
+	return len(p.offsets)
+}
+
+func (p *pdfWriter) begin(id int) {
+	p.offsets[id-1] = p.offset
+	p.printf("%d 0 obj\n", id)
+}
+
+// object は辞書などの単純なオブジェクトを書き出します。
+func (p *pdfWriter) object(id int, body string) {
+	p.begin(id)
+	p.printf("%s\nendobj\n", body)
+}
+
+// stream はストリームオブジェクトを書き出します。dict には << >> を除いた辞書の中身を指定します。
+func (p *pdfWriter) stream(id int, dict string, data []byte) {
+	p.begin(id)
+	p.printf("<< %s /Length %d >>\nstream\n", dict, len(data))
+	p.write(data)
+	p.printf("\nendstream\nendobj\n")
+}
+
+// trailer はクロスリファレンステーブルとトレーラーを書き出します。
+func (p *pdfWriter) trailer(catalog, info int) {
+	xref := p.offset
+	p.printf("xref\n0 %d\n0000000000 65535 f \n", len(p.offsets)+1)
+	for _, off := range p.offsets {
+		p.printf("%010d 00000 n \n", off)
+	}
+	p.printf("trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(p.offsets)+1, catalog, info, xref)
+}
+
+// writeFont は日本語テキスト用の Type0 フォントを書き出し、そのオブジェクト番号を返します。
+// UniJIS-UCS2-HW-H により、ASCII と半角カナは半角幅 (CID 231-632)、それ以外は全角幅で表示されます。
+func (p *pdfWriter) writeFont() int {
+	font, cidFont, descriptor := p.alloc(), p.alloc(), p.alloc()
+	p.object(font, fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /UniJIS-UCS2-HW-H /DescendantFonts [%d 0 R] >>", pdfFontName, cidFont))
+	p.object(cidFont, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /%s "+
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (Japan1) /Supplement 2 >> "+
+		"/FontDescriptor %d 0 R /DW 1000 /W [231 632 500] >>", pdfFontName, descriptor))
+	p.object(descriptor, fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 4 /FontBBox [-92 -250 1010 922] "+
+		"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 737 /StemV 114 >>", pdfFontName))
+	return font
+}
+
+// textPage は A4 のテキストページを書き出します。
+func (p *pdfWriter) textPage(parent, font int, content []byte) int {
+	page, contents := p.alloc(), p.alloc()
+	p.stream(contents, "", content)
+	p.object(page, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+		parent, pdfPageWidth, pdfPageHeight, font, contents))
+	return page
+}
+
+// imagePage はページ画像を、画像と同じ縦横比のページいっぱいに配置して書き出します。
+// RGB とグレースケールの JPEG はそのまま埋め込み、それ以外 (CMYK の JPEG を含む) はデコードして Flate 圧縮した RGB として埋め込みます。
+func (p *pdfWriter) imagePage(ctx context.Context, parent int, open Opener, src string) (int, error) {
+	var buf bytes.Buffer
+	if err := copyFile(ctx, open, &buf, src); err != nil {
+		return 0, err
+	}
+	data := buf.Bytes()
+
+	var (
+		bounds     image.Rectangle
+		dict       string
+		body       []byte
+		colorSpace string
+	)
+	if http.DetectContentType(data) == "image/jpeg" {
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return 0, fmt.Errorf("画像の読み込みに失敗しました (%s): %w", src, err)
+		}
+		bounds = image.Rect(0, 0, cfg.Width, cfg.Height)
+		colorSpace = dctColorSpace(cfg.ColorModel)
+	}
+	if colorSpace != "" {
+		dict, body = "/Filter /DCTDecode /ColorSpace "+colorSpace, data
+	} else {
+		img, err := imaging.DecodeRGBA(bytes.NewReader(data))
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", src, err)
+		}
+		bounds = img.Bounds()
+		if body, err = flateRGB(img); err != nil {
+			return 0, err
+		}
+		dict = "/Filter /FlateDecode /ColorSpace /DeviceRGB"
+	}
+	if bounds.Empty() {
+		return 0, fmt.Errorf("画像のサイズが不正です (%s)", src)
+	}
+
+	width := pdfPageWidth
+	height := width * float64(bounds.Dy()) / float64(bounds.Dx())
+
+	page, contents, xobject := p.alloc(), p.alloc(), p.alloc()
+	p.stream(xobject, fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /BitsPerComponent 8 %s",
+		bounds.Dx(), bounds.Dy(), dict), body)
+	p.stream(contents, "", []byte(fmt.Sprintf("q %.2f 0 0 %.2f 0 0 cm /Im1 Do Q", width, height)))
+	p.object(page, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /XObject << /Im1 %d 0 R >> >> /Contents %d 0 R >>",
+		parent, width, height, xobject, contents))
+	return page, p.err
+}
+
+// dctColorSpace は JPEG をそのまま埋め込む場合の色空間を返します。そのまま埋め込めない色形式の場合は空文字を返します。
+// CMYK (4成分) の JPEG は Adobe 形式の反転の有無をビューアが判断できず色が崩れるため、デコードして RGB に変換します。
+func dctColorSpace(model color.Model) string {
+	switch model {
+	case color.YCbCrModel:
+		return "/DeviceRGB"
+	case color.GrayModel:
+		return "/DeviceGray"
+	default:
+		return ""
+	}
+}
+
+// flateRGB は画像のアルファを除いた RGB 値を zlib で圧縮します。
+func flateRGB(img *image.RGBA) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	b := img.Bounds()
+	row := make([]byte, b.Dx()*3)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		pix := img.Pix[img.PixOffset(b.Min.X, y):]
+		for x := 0; x < b.Dx(); x++ {
+			copy(row[x*3:x*3+3], pix[x*4:x*4+3])
+		}
+		if _, err := zw.Write(row); err != nil {
+			return nil, fmt.Errorf("画像の圧縮に失敗しました: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("画像の圧縮に失敗しました: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// pdfString は文字列を UTF-16BE (BOM 付き) の16進文字列として返します。文書情報辞書で使用します。
+func pdfString(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// --- テキストのレイアウト ---
+
+// textLayout は A4 ページに上から順にテキストを配置し、ページのコンテンツストリームを組み立てます。
+type textLayout struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+func (t *textLayout) newPage() {
+	t.pages = append(t.pages, &bytes.Buffer{})
+	t.y = pdfPageHeight - pdfMargin
+}
+
+// paragraph は文章を本文幅で折り返して配置します。centered が true の場合は各行を中央揃えにします。
+func (t *textLayout) paragraph(text string, size float64, centered bool) {
+	if len(t.pages) == 0 {
+		t.newPage()
+	}
+	maxWidth := pdfPageWidth - pdfMargin*2
+	for _, para := range strings.Split(text, "\n") {
+		for _, line := range wrapText(para, size, maxWidth) {
+			if t.y-size*pdfLineHeight < pdfMargin {
+				t.newPage()
+			}
+			t.y -= size * pdfLineHeight
+			x := pdfMargin
+			if centered {
+				x = (pdfPageWidth - textWidth(line, size)) / 2
+			}
+			fmt.Fprintf(t.pages[len(t.pages)-1], "BT /F1 %.1f Tf %.2f %.2f Td %s Tj ET\n", size, x, t.y, cidString(line))
+		}
+	}
+}
+
+// space は指定した高さの余白を空けます。
+func (t *textLayout) space(height float64) {
+	t.y -= height
+}
+
+func (t *textLayout) contents() [][]byte {
+	out := make([][]byte, len(t.pages))
+	for i, p := range t.pages {
+		out[i] = p.Bytes()
+	}
+	return out
+}
+
+// titlePage はタイトル・概要・登場キャラクターからなるタイトルページを組み立てます。
+func titlePage(book Book) [][]byte {
+	var t textLayout
+	t.newPage()
+	t.space(pdfPageHeight / 4)
+	t.paragraph(book.Title, 28, true)
+	if book.Description != "" {
+		t.space(36)
+		t.paragraph(book.Description, 12, false)
+	}
+	if len(book.Characters) > 0 {
+		t.space(24)
+		t.paragraph("登場キャラクター: "+strings.Join(book.Characters, "、"), 11, false)
+	}
+	return t.contents()
+}
+
+// transcriptPages は台本上のページごとにセリフ・ナレーション・効果音を並べたセリフ一覧を組み立てます。
+func transcriptPages(book Book) [][]byte {
+	var t textLayout
+	t.newPage()
+	t.paragraph("セリフ一覧", 18, false)
+
+	page := -1
+	for _, e := range book.Transcript {
+		if e.Page != page {
+			page = e.Page
+			t.space(12)
+			t.paragraph(fmt.Sprintf("ページ %d", page), 13, false)
+		}
+		switch e.Kind {
+		case TranscriptNarration:
+			t.paragraph("［ナレーション］"+e.Text, 10.5, false)
+		case TranscriptSFX:
+			t.paragraph("［効果音］"+e.Text, 10.5, false)
+		default:
+			t.paragraph(e.Speaker+"「"+e.Text+"」", 10.5, false)
+		}
+	}
+	return t.contents()
+}
+
+// runeWidth は1文字の幅を 1/1000 em 単位で返します。ASCII と半角カナは半角、それ以外は全角とします。
+func runeWidth(r rune) float64 {
+	if r < 0x80 || (r >= 0xFF61 && r <= 0xFF9F) {
+		return 500
+	}
+	return 1000
+}
+
+func textWidth(s string, size float64) float64 {
+	w := 0.0
+	for _, r := range s {
+		w += runeWidth(r)
+	}
+	return w * size / 1000
+}
+
+// wrapText は文章を maxWidth に収まるように折り返します。
+// 英単語の途中で折り返す場合は、直前の空白で改行します。
+func wrapText(s string, size, maxWidth float64) []string {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return []string{""}
+	}
+
+	var lines []string
+	start, width, lastSpace := 0, 0.0, -1
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		w := runeWidth(r) * size / 1000
+		if width+w > maxWidth && i > start {
+			end := i
+			if r < 0x80 && r != ' ' && lastSpace > start {
+				end = lastSpace + 1
+			}
+			lines = append(lines, strings.TrimRight(string(runes[start:end]), " "))
+			for end < len(runes) && runes[end] == ' ' {
+				end++
+			}
+			start, width, lastSpace = end, 0, -1
+			i = end - 1
+			continue
+		}
+		if r == ' ' {
+			lastSpace = i
+		}
+		width += w
+	}
+	return append(lines, string(runes[start:]))
+}
+
+// cidString はテキストを UniJIS-UCS2 エンコーディングの16進文字列に変換します。
+// UCS-2 で表せない文字は「〓」に置き換えます。
+func cidString(s string) string {
+	var b strings.Builder
+	b.WriteString("<")
+	for _, r := range s {
+		if r > 0xFFFF || (r >= 0xD800 && r <= 0xDFFF) {
+			r = '〓'
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	b.WriteString(">")
+	return b.String()
+}
@@ -0,0 +1,119 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWritePDF(t *testing.T) {
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 20, 30))); err != nil {
+		t.Fatal(err)
+	}
+	store := memOpener{"gs://b/t/images/manga_page_1.png": img.String()}
+	book := Book{
+		Title:       "テスト",
+		Description: "概要",
+		Pages:       []string{"gs://b/t/images/manga_page_1.png"},
+		Transcript: []TranscriptEntry{
+			{Page: 1, Kind: TranscriptNarration, Text: "その頃"},
+			{Page: 1, Kind: TranscriptDialogue, Speaker: "Zundamon", Text: "なのだ"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WritePDF(context.Background(), &buf, book, store, PDFOptions{Transcript: true}); err != nil {
+		t.Fatalf("WritePDF() error = %v", err)
+	}
+	pdf := buf.String()
+
+	if !strings.HasPrefix(pdf, "%PDF-1.7") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Fatal("output is not a complete PDF")
+	}
+	// タイトルページ + 画像1枚 + セリフ一覧
+	if !strings.Contains(pdf, "/Count 3") {
+		t.Error("page count should be 3")
+	}
+	if !strings.Contains(pdf, "/Width 20 /Height 30") {
+		t.Error("image XObject is missing")
+	}
+
+	// クロスリファレンスの各オフセットがオブジェクトの先頭を指していること
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(pdf)
+	if m == nil {
+		t.Fatal("startxref not found")
+	}
+	xref, _ := strconv.Atoi(m[1])
+	lines := strings.Split(pdf[xref:], "\n")
+	count, _ := strconv.Atoi(strings.Fields(lines[1])[1])
+	for id := 1; id < count; id++ {
+		offset, _ := strconv.Atoi(strings.Fields(lines[2+id])[0])
+		if want := fmt.Sprintf("%d 0 obj", id); !strings.HasPrefix(pdf[offset:], want) {
+			t.Fatalf("xref entry %d points to %q", id, pdf[offset:offset+10])
+		}
+	}
+}
+
+func TestWritePDFJPEG(t *testing.T) {
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewRGBA(image.Rect(0, 0, 20, 30)), nil); err != nil {
+		t.Fatal(err)
+	}
+	store := memOpener{"gs://b/t/images/manga_page_1.jpg": img.String()}
+	book := Book{Title: "テスト", Pages: []string{"gs://b/t/images/manga_page_1.jpg"}}
+
+	var buf bytes.Buffer
+	if err := WritePDF(context.Background(), &buf, book, store, PDFOptions{}); err != nil {
+		t.Fatalf("WritePDF() error = %v", err)
+	}
+	// RGB の JPEG は再圧縮せずにそのまま埋め込みます
+	if pdf := buf.String(); !strings.Contains(pdf, "/Filter /DCTDecode /ColorSpace /DeviceRGB") || !strings.Contains(pdf, img.String()) {
+		t.Error("JPEG page was not embedded as is")
+	}
+}
+
+func TestDCTColorSpace(t *testing.T) {
+	tests := []struct {
+		name  string
+		model color.Model
+		want  string
+	}{
+		{"ycbcr", color.YCbCrModel, "/DeviceRGB"},
+		{"gray", color.GrayModel, "/DeviceGray"},
+		// CMYK の JPEG はそのまま埋め込まず、RGB に変換します
+		{"cmyk", color.CMYKModel, ""},
+	}
+	for _, tt := range tests {
+		if got := dctColorSpace(tt.model); got != tt.want {
+			t.Errorf("dctColorSpace(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	// 10pt で全角は 10、半角は 5 の幅
+	tests := []struct {
+		text  string
+		width float64
+		want  []string
+	}{
+		{"あいうえお", 30, []string{"あいう", "えお"}},
+		{"hello world", 40, []string{"hello", "world"}},
+		{"", 40, []string{""}},
+	}
+	for _, tt := range tests {
+		got := wrapText(tt.text, 10, tt.width)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("wrapText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
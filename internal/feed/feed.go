@@ -0,0 +1,214 @@
+// Package feed は作品の新着情報を Atom 1.0 と RSS 2.0 のフィードとして書き出します。
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"time"
+)
+
+// mediaNS はサムネイルの記述に使用する Media RSS の名前空間です。
+const mediaNS = "http://search.yahoo.com/mrss/"
+
+// Feed はフィード全体の情報です。
+type Feed struct {
+	// ID はフィードを識別する URL です。
+	ID    string
+	Title string
+	// Link はフィードの内容に対応する Web ページ（ギャラリーなど）の URL です。
+	Link string
+	// Self はフィード自身の URL です。
+	Self    string
+	Author  string
+	Updated time.Time
+	Entries []Entry
+}
+
+// Entry はフィードの作品1件分の情報です。
+type Entry struct {
+	// ID は作品を識別する URL です。表示タイトルを変えても同じ値を使います。
+	ID          string
+	Title       string
+	Description string
+	// Link はプレビュー画面の URL です。
+	Link string
+	// Thumbnail は1ページ目の画像の URL です。空の場合は画像を載せません。
+	Thumbnail  string
+	Published  time.Time
+	Updated    time.Time
+	Categories []string
+}
+
+// content は説明文とサムネイルを HTML にした本文を返します。
+func (e Entry) content() string {
+	var s string
+	if e.Thumbnail != "" {
+		s = fmt.Sprintf(`<p><a href="%s"><img src="%s" alt="%s"></a></p>`,
+			html.EscapeString(e.Link), html.EscapeString(e.Thumbnail), html.EscapeString(e.Title))
+	}
+	if e.Description != "" {
+		s += "<p>" + html.EscapeString(e.Description) + "</p>"
+	}
+	return s
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Media   string      `xml:"xmlns:media,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Link       atomLink       `xml:"link"`
+	Summary    string         `xml:"summary,omitempty"`
+	Content    *atomContent   `xml:"content"`
+	Categories []atomCategory `xml:"category"`
+	Thumbnail  *mediaThumb    `xml:"media:thumbnail"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type mediaThumb struct {
+	URL string `xml:"url,attr"`
+}
+
+// WriteAtom は f を Atom 1.0 として w に書き込みます。
+func WriteAtom(w io.Writer, f Feed) error {
+	af := atomFeed{
+		Media:   mediaNS,
+		ID:      f.ID,
+		Title:   f.Title,
+		Updated: f.Updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: f.Self},
+			{Rel: "alternate", Type: "text/html", Href: f.Link},
+		},
+		Author: atomAuthor{Name: f.Author},
+	}
+	for _, e := range f.Entries {
+		ae := atomEntry{
+			ID:        e.ID,
+			Title:     e.Title,
+			Published: e.Published.UTC().Format(time.RFC3339),
+			Updated:   e.Updated.UTC().Format(time.RFC3339),
+			Link:      atomLink{Rel: "alternate", Type: "text/html", Href: e.Link},
+			Summary:   e.Description,
+		}
+		if c := e.content(); c != "" {
+			ae.Content = &atomContent{Type: "html", Body: c}
+		}
+		for _, c := range e.Categories {
+			ae.Categories = append(ae.Categories, atomCategory{Term: c})
+		}
+		ae.Thumbnail = thumbnail(e.Thumbnail)
+		af.Entries = append(af.Entries, ae)
+	}
+	return encode(w, af)
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Media   string     `xml:"xmlns:media,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string      `xml:"title"`
+	Link        string      `xml:"link"`
+	GUID        rssGUID     `xml:"guid"`
+	PubDate     string      `xml:"pubDate"`
+	Description string      `xml:"description,omitempty"`
+	Categories  []string    `xml:"category"`
+	Thumbnail   *mediaThumb `xml:"media:thumbnail"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// WriteRSS は f を RSS 2.0 として w に書き込みます。RSS には更新日時がないため、公開日時のみを載せます。
+func WriteRSS(w io.Writer, f Feed) error {
+	rf := rssFeed{
+		Version: "2.0",
+		Media:   mediaNS,
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Title,
+			Self:          atomLink{Rel: "self", Type: "application/rss+xml", Href: f.Self},
+			LastBuildDate: f.Updated.UTC().Format(time.RFC1123Z),
+		},
+	}
+	for _, e := range f.Entries {
+		rf.Channel.Items = append(rf.Channel.Items, rssItem{
+			Title:       e.Title,
+			Link:        e.Link,
+			GUID:        rssGUID{Value: e.ID},
+			PubDate:     e.Published.UTC().Format(time.RFC1123Z),
+			Description: e.content(),
+			Categories:  e.Categories,
+			Thumbnail:   thumbnail(e.Thumbnail),
+		})
+	}
+	return encode(w, rf)
+}
+
+func thumbnail(u string) *mediaThumb {
+	if u == "" {
+		return nil
+	}
+	return &mediaThumb{URL: u}
+}
+
+func encode(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("フィードの生成に失敗しました: %w", err)
+	}
+	return enc.Close()
+}
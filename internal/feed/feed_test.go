@@ -0,0 +1,95 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() Feed {
+	published := time.Date(2026, 1, 13, 6, 30, 0, 0, time.UTC)
+	return Feed{
+		ID:      "https://example.com/feed.xml",
+		Title:   "新着",
+		Link:    "https://example.com/gallery",
+		Self:    "https://example.com/feed.xml",
+		Author:  "AP Manga Web",
+		Updated: published.Add(time.Hour),
+		Entries: []Entry{
+			{
+				ID:          "https://example.com/output/t1",
+				Title:       "ずんだ <餅>",
+				Description: "説明 & 補足",
+				Link:        "https://example.com/output/t1",
+				Thumbnail:   "https://storage.example.com/page_1.png?a=1&b=2",
+				Published:   published,
+				Updated:     published.Add(time.Hour),
+				Categories:  []string{"zunda"},
+			},
+			{ID: "https://example.com/output/t2", Title: "t2", Link: "https://example.com/output/t2", Published: published, Updated: published},
+		},
+	}
+}
+
+func TestWriteAtom(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteAtom(&buf, testFeed()); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`<feed xmlns="http://www.w3.org/2005/Atom" xmlns:media="http://search.yahoo.com/mrss/">`,
+		`<title>ずんだ &lt;餅&gt;</title>`,
+		`<updated>2026-01-13T07:30:00Z</updated>`,
+		`<link rel="alternate" type="text/html" href="https://example.com/output/t1"></link>`,
+		`<media:thumbnail url="https://storage.example.com/page_1.png?a=1&amp;b=2"></media:thumbnail>`,
+		`<category term="zunda"></category>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Atom に %q が含まれていません:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "<media:thumbnail"); n != 1 {
+		t.Errorf("media:thumbnail が %d 件あります, want 1", n)
+	}
+	assertWellFormed(t, out)
+}
+
+func TestWriteRSS(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteRSS(&buf, testFeed()); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`<rss version="2.0"`,
+		`<guid isPermaLink="false">https://example.com/output/t1</guid>`,
+		`<pubDate>Tue, 13 Jan 2026 06:30:00 +0000</pubDate>`,
+		`<atom:link rel="self" type="application/rss+xml" href="https://example.com/feed.xml"></atom:link>`,
+		// 本文の HTML はエスケープして埋め込みます
+		`&lt;p&gt;説明 &amp;amp; 補足&lt;/p&gt;`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("RSS に %q が含まれていません:\n%s", want, out)
+		}
+	}
+	assertWellFormed(t, out)
+}
+
+func assertWellFormed(t *testing.T, s string) {
+	t.Helper()
+	dec := xml.NewDecoder(strings.NewReader(s))
+	for {
+		_, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			t.Fatalf("XML として解析できません: %v", err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+var placeholderColor = color.RGBA{R: 0xe0, G: 0xe0, B: 0xe0, A: 0xff}
+
+// PageCell はページ上の1コマ分の配置です。
+type PageCell struct {
+	Rect image.Rectangle
+	// Impact が true のコマは、見せ場として枠線を太く描画します。
+	Impact bool
+}
+
+// ComposePage はパネル画像をコマの矩形に合わせて配置し、1枚のページ画像を合成します。
+// パネル画像はコマを覆うように拡大縮小し、はみ出した部分は中央を基準に切り取ります。
+// panels[i] が nil の場合、そのコマは灰色で塗りつぶします。
+func ComposePage(size image.Point, cells []PageCell, panels []image.Image) *image.RGBA {
+	page := image.NewRGBA(image.Rectangle{Max: size})
+	draw.Draw(page, page.Bounds(), image.NewUniform(paperColor), image.Point{}, draw.Src)
+
+	border := max(2, size.X/300)
+	for i, cell := range cells {
+		if i < len(panels) && panels[i] != nil {
+			drawCover(page, cell.Rect, panels[i])
+		} else {
+			draw.Draw(page, cell.Rect, image.NewUniform(placeholderColor), image.Point{}, draw.Src)
+		}
+
+		width := border
+		if cell.Impact {
+			width = border * 2
+		}
+		strokeRect(page, cell.Rect, width)
+	}
+	return page
+}
+
+// drawCover は src の縦横比を保ったまま rect 全体を覆うように描画します。
+func drawCover(dst draw.Image, rect image.Rectangle, src image.Image) {
+	sb := src.Bounds()
+	if sb.Empty() || rect.Empty() {
+		return
+	}
+
+	// rect と同じ縦横比になるよう、src の中央から切り出す範囲を決める
+	crop := sb
+	if sb.Dx()*rect.Dy() > rect.Dx()*sb.Dy() {
+		w := sb.Dy() * rect.Dx() / rect.Dy()
+		crop.Min.X += (sb.Dx() - w) / 2
+		crop.Max.X = crop.Min.X + w
+	} else {
+		h := sb.Dx() * rect.Dy() / rect.Dx()
+		crop.Min.Y += (sb.Dy() - h) / 2
+		crop.Max.Y = crop.Min.Y + h
+	}
+	xdraw.CatmullRom.Scale(dst, rect, src, crop, xdraw.Src, nil)
+}
+
+// strokeRect は rect の内側に指定幅の黒い枠線を描画します。
+func strokeRect(dst draw.Image, rect image.Rectangle, width int) {
+	ink := image.NewUniform(inkColor)
+	edges := []image.Rectangle{
+		image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Min.Y+width),
+		image.Rect(rect.Min.X, rect.Max.Y-width, rect.Max.X, rect.Max.Y),
+		image.Rect(rect.Min.X, rect.Min.Y, rect.Min.X+width, rect.Max.Y),
+		image.Rect(rect.Max.X-width, rect.Min.Y, rect.Max.X, rect.Max.Y),
+	}
+	for _, e := range edges {
+		draw.Draw(dst, e.Intersect(rect), ink, image.Point{}, draw.Src)
+	}
+}
@@ -0,0 +1,36 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestComposePage(t *testing.T) {
+	red := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	draw.Draw(red, red.Bounds(), image.NewUniform(color.RGBA{R: 0xff, A: 0xff}), image.Point{}, draw.Src)
+
+	cells := []PageCell{
+		{Rect: image.Rect(50, 10, 90, 90)},
+		{Rect: image.Rect(10, 10, 40, 90), Impact: true},
+	}
+	page := ComposePage(image.Pt(100, 100), cells, []image.Image{red, nil})
+
+	if got := page.RGBAAt(70, 50); got != (color.RGBA{R: 0xff, A: 0xff}) {
+		t.Errorf("panel 1 center = %v, want red", got)
+	}
+	if got := page.RGBAAt(25, 50); got != placeholderColor {
+		t.Errorf("missing panel center = %v, want placeholder", got)
+	}
+	if got := page.RGBAAt(50, 50); got != inkColor {
+		t.Errorf("panel 1 border = %v, want ink", got)
+	}
+	if got := page.RGBAAt(45, 50); got != paperColor {
+		t.Errorf("gutter = %v, want paper", got)
+	}
+	// 強調パネルは枠線が太い
+	if got, normal := page.RGBAAt(13, 50), page.RGBAAt(53, 50); got != inkColor || normal == inkColor {
+		t.Errorf("impact border = %v, normal = %v; want thicker impact border", got, normal)
+	}
+}
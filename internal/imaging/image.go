@@ -0,0 +1,36 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg" // 生成モデルが JPEG を返す場合に備えてデコーダを登録
+	"image/png"
+	"io"
+)
+
+// DecodeRGBA は PNG / JPEG 画像を描画可能な RGBA 画像としてデコードします。
+func DecodeRGBA(r io.Reader) (*image.RGBA, error) {
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("画像のデコードに失敗しました: %w", err)
+	}
+	if rgba, ok := src.(*image.RGBA); ok {
+		return rgba, nil
+	}
+
+	bounds := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	return rgba, nil
+}
+
+// EncodePNG は画像を PNG としてエンコードします。
+func EncodePNG(img image.Image) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("PNG のエンコードに失敗しました: %w", err)
+	}
+	return &buf, nil
+}
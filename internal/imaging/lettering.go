@@ -0,0 +1,364 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// BubbleKind は写植する文字要素の種類です。
+type BubbleKind int
+
+const (
+	// BubbleSpeech は楕円の吹き出しに入るセリフです。
+	BubbleSpeech BubbleKind = iota
+	// BubbleCaption は四角い枠に入るナレーションです。
+	BubbleCaption
+	// BubbleSFX は枠を持たない描き文字（効果音）です。
+	BubbleSFX
+)
+
+// Region はコマ内で文字要素を配置する領域の名前です。
+type Region string
+
+const (
+	// RegionAuto は読み順に基づいて自動で配置することを表します。
+	RegionAuto        Region = ""
+	RegionTopRight    Region = "top_right"
+	RegionTopLeft     Region = "top_left"
+	RegionBottomRight Region = "bottom_right"
+	RegionBottomLeft  Region = "bottom_left"
+	RegionTop         Region = "top"
+	RegionBottom      Region = "bottom"
+	RegionCenter      Region = "center"
+)
+
+// autoRegions は領域が指定されていない吹き出しを配置する順序です（右上から読み順）。
+var autoRegions = []Region{RegionTopRight, RegionTopLeft, RegionBottomLeft, RegionBottomRight}
+
+// Bubble は1つの文字要素（吹き出し・キャプション・描き文字）です。
+type Bubble struct {
+	Kind     BubbleKind
+	Text     string
+	Region   Region
+	Vertical bool // true の場合は縦書き（右から左へ列を並べる）
+}
+
+var (
+	inkColor   = color.RGBA{A: 0xff}
+	paperColor = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+)
+
+// verticalForms は縦書き時に置き換える約物です。フォントにグリフがない場合は元の文字を使います。
+var verticalForms = map[rune]rune{
+	'、': '︑', '。': '︒', '「': '﹁', '」': '﹂', '『': '﹃', '』': '﹄',
+	'（': '︵', '）': '︶', '(': '︵', ')': '︶', '…': '︙', '‥': '︰',
+	'ー': '丨', '－': '丨', '―': '丨', '〜': '≀', '～': '≀',
+}
+
+// Letterer は埋め込みフォントを用いて画像に写植（吹き出しと文字の描画）を行います。
+type Letterer struct {
+	font *opentype.Font
+}
+
+// NewLetterer は TrueType / OpenType フォント（.ttc の場合は先頭のフォント）から Letterer を生成します。
+func NewLetterer(fontData []byte) (*Letterer, error) {
+	if bytes.HasPrefix(fontData, []byte("ttcf")) {
+		collection, err := opentype.ParseCollection(fontData)
+		if err != nil {
+			return nil, fmt.Errorf("フォントコレクションの解析に失敗しました: %w", err)
+		}
+		f, err := collection.Font(0)
+		if err != nil {
+			return nil, fmt.Errorf("フォントコレクションからの読み込みに失敗しました: %w", err)
+		}
+		return &Letterer{font: f}, nil
+	}
+
+	f, err := opentype.Parse(fontData)
+	if err != nil {
+		return nil, fmt.Errorf("フォントの解析に失敗しました: %w", err)
+	}
+	return &Letterer{font: f}, nil
+}
+
+// Draw は area（コマの矩形）の中に文字要素を描画します。
+// 領域が指定されていない要素は、右上から読み順に空いている領域へ割り当てられます。
+func (l *Letterer) Draw(dst draw.Image, area image.Rectangle, bubbles []Bubble) error {
+	for _, b := range assignRegions(bubbles) {
+		if strings.TrimSpace(b.Text) == "" {
+			continue
+		}
+		if err := l.drawBubble(dst, area, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assignRegions は領域未指定の要素に領域を割り当てたコピーを返します。
+func assignRegions(bubbles []Bubble) []Bubble {
+	used := make(map[Region]bool)
+	for _, b := range bubbles {
+		used[b.Region] = true
+	}
+
+	assigned := make([]Bubble, len(bubbles))
+	next := 0
+	for i, b := range bubbles {
+		if b.Region == RegionAuto {
+			if b.Kind == BubbleSFX {
+				b.Region = RegionCenter
+			} else {
+				for next < len(autoRegions) && used[autoRegions[next]] {
+					next++
+				}
+				b.Region = autoRegions[next%len(autoRegions)]
+				used[b.Region] = true
+				next++
+			}
+		}
+		assigned[i] = b
+	}
+	return assigned
+}
+
+// regionRect は領域名に対応する、area 内の配置可能な矩形を返します。
+func regionRect(area image.Rectangle, region Region) image.Rectangle {
+	w, h := area.Dx(), area.Dy()
+	pad := min(w, h) / 25
+	halfW, halfH := w*46/100, h*46/100
+
+	left, right := area.Min.X+pad, area.Max.X-pad-halfW
+	top, bottom := area.Min.Y+pad, area.Max.Y-pad-halfH
+
+	switch region {
+	case RegionTopLeft:
+		return image.Rect(left, top, left+halfW, top+halfH)
+	case RegionBottomRight:
+		return image.Rect(right, bottom, right+halfW, bottom+halfH)
+	case RegionBottomLeft:
+		return image.Rect(left, bottom, left+halfW, bottom+halfH)
+	case RegionTop:
+		return image.Rect(area.Min.X+w/10, top, area.Max.X-w/10, top+h*32/100)
+	case RegionBottom:
+		return image.Rect(area.Min.X+w/10, area.Max.Y-pad-h*32/100, area.Max.X-w/10, area.Max.Y-pad)
+	case RegionCenter:
+		return image.Rect(area.Min.X+w/5, area.Min.Y+h*3/10, area.Max.X-w/5, area.Max.Y-h*3/10)
+	default: // RegionTopRight および未知の値
+		return image.Rect(right, top, right+halfW, top+halfH)
+	}
+}
+
+// textBlock はフォントサイズを決定済みの文字列レイアウトです。
+type textBlock struct {
+	face     font.Face
+	columns  [][]rune // 横書きでは行、縦書きでは列
+	vertical bool
+	pitch    int // 行または列の間隔
+	advance  int // 縦書き時の1文字あたりの送り
+	width    int
+	height   int
+}
+
+// drawBubble は1つの文字要素を、その種類に応じた枠とともに描画します。
+func (l *Letterer) drawBubble(dst draw.Image, area image.Rectangle, b Bubble) error {
+	rect := regionRect(area, b.Region)
+
+	// 楕円に内接させるため、セリフは領域の約7割に収めます。
+	maxW, maxH := rect.Dx(), rect.Dy()
+	if b.Kind == BubbleSpeech {
+		maxW, maxH = maxW*68/100, maxH*68/100
+	}
+	maxSize := float64(min(area.Dx(), area.Dy())) / 9
+	if b.Kind == BubbleSFX {
+		maxSize *= 2
+	}
+
+	block, err := l.layout(b.Text, maxW, maxH, b.Vertical, maxSize)
+	if err != nil {
+		return err
+	}
+	defer block.face.Close()
+
+	// 領域の角（右寄せ・上寄せなど）に合わせて文字ブロックを配置します。
+	pad := block.pitch / 2
+	x := rect.Min.X + (rect.Dx()-block.width)/2
+	switch b.Region {
+	case RegionTopRight, RegionBottomRight:
+		x = rect.Max.X - block.width - pad
+	case RegionTopLeft, RegionBottomLeft:
+		x = rect.Min.X + pad
+	}
+	y := rect.Min.Y + (rect.Dy()-block.height)/2
+	switch b.Region {
+	case RegionTopRight, RegionTopLeft, RegionTop:
+		y = rect.Min.Y + pad
+	case RegionBottomRight, RegionBottomLeft, RegionBottom:
+		y = rect.Max.Y - block.height - pad
+	}
+	textRect := image.Rect(x, y, x+block.width, y+block.height)
+
+	switch b.Kind {
+	case BubbleSpeech:
+		// 文字ブロックが内接する楕円（半径は矩形の 1/√2 倍 + 余白）
+		rx := float64(block.width)/math.Sqrt2 + float64(pad)
+		ry := float64(block.height)/math.Sqrt2 + float64(pad)
+		fillEllipse(dst, area, textRect, rx, ry, max(2, block.pitch/12))
+		l.drawText(dst, block, textRect, image.NewUniform(inkColor))
+	case BubbleCaption:
+		box := textRect.Inset(-pad)
+		draw.Draw(dst, box, image.NewUniform(inkColor), image.Point{}, draw.Src)
+		draw.Draw(dst, box.Inset(max(2, block.pitch/16)), image.NewUniform(paperColor), image.Point{}, draw.Src)
+		l.drawText(dst, block, textRect, image.NewUniform(inkColor))
+	case BubbleSFX:
+		// 白フチ付きの描き文字
+		outline := max(2, block.pitch/10)
+		for dx := -outline; dx <= outline; dx += outline {
+			for dy := -outline; dy <= outline; dy += outline {
+				if dx != 0 || dy != 0 {
+					l.drawText(dst, block, textRect.Add(image.Pt(dx, dy)), image.NewUniform(paperColor))
+				}
+			}
+		}
+		l.drawText(dst, block, textRect, image.NewUniform(inkColor))
+	}
+	return nil
+}
+
+// layout は maxW × maxH に収まる最大のフォントサイズで文字列を配置します。
+func (l *Letterer) layout(text string, maxW, maxH int, vertical bool, maxSize float64) (*textBlock, error) {
+	const minSize = 10
+	if vertical {
+		text = l.toVertical(text)
+	}
+
+	for size := math.Max(maxSize, minSize); ; size-- {
+		face, err := opentype.NewFace(l.font, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+		if err != nil {
+			return nil, fmt.Errorf("フォントフェイスの生成に失敗しました: %w", err)
+		}
+
+		var block *textBlock
+		if vertical {
+			block = layoutVertical(face, text, size, maxH)
+		} else {
+			block = layoutHorizontal(face, text, size, maxW)
+		}
+		if (block.width <= maxW && block.height <= maxH) || size <= minSize {
+			return block, nil
+		}
+		face.Close()
+	}
+}
+
+// layoutHorizontal は横書きで maxW ごとに折り返します。
+func layoutHorizontal(face font.Face, text string, size float64, maxW int) *textBlock {
+	block := &textBlock{face: face, pitch: int(size * 1.3)}
+	for _, paragraph := range strings.Split(text, "\n") {
+		var line []rune
+		for _, r := range strings.TrimSpace(paragraph) {
+			if len(line) > 0 && font.MeasureString(face, string(append(line, r))).Ceil() > maxW {
+				block.columns = append(block.columns, line)
+				line = nil
+			}
+			line = append(line, r)
+		}
+		if len(line) > 0 {
+			block.columns = append(block.columns, line)
+		}
+	}
+	for _, line := range block.columns {
+		block.width = max(block.width, font.MeasureString(face, string(line)).Ceil())
+	}
+	block.height = len(block.columns) * block.pitch
+	return block
+}
+
+// layoutVertical は縦書きで maxH ごとに改列します。
+func layoutVertical(face font.Face, text string, size float64, maxH int) *textBlock {
+	block := &textBlock{face: face, vertical: true, pitch: int(size * 1.3), advance: int(size * 1.05)}
+	perColumn := max(1, maxH/max(1, block.advance))
+	for _, paragraph := range strings.Split(text, "\n") {
+		runes := []rune(strings.TrimSpace(paragraph))
+		for len(runes) > perColumn {
+			block.columns = append(block.columns, runes[:perColumn])
+			runes = runes[perColumn:]
+		}
+		if len(runes) > 0 {
+			block.columns = append(block.columns, runes)
+		}
+	}
+	longest := 0
+	for _, column := range block.columns {
+		longest = max(longest, len(column))
+	}
+	block.width = len(block.columns) * block.pitch
+	block.height = longest * block.advance
+	return block
+}
+
+// drawText は配置済みの文字ブロックを rect に描画します。
+func (l *Letterer) drawText(dst draw.Image, block *textBlock, rect image.Rectangle, src image.Image) {
+	drawer := &font.Drawer{Dst: dst, Src: src, Face: block.face}
+	ascent := block.face.Metrics().Ascent.Ceil()
+
+	for i, column := range block.columns {
+		if !block.vertical {
+			lineW := font.MeasureString(block.face, string(column)).Ceil()
+			drawer.Dot = fixed.P(rect.Min.X+(rect.Dx()-lineW)/2, rect.Min.Y+i*block.pitch+ascent)
+			drawer.DrawString(string(column))
+			continue
+		}
+
+		// 縦書きは右端の列から左へ並べ、各文字を列の中央に揃えます。
+		center := rect.Max.X - i*block.pitch - block.pitch/2
+		for j, r := range column {
+			adv := font.MeasureString(block.face, string(r)).Ceil()
+			drawer.Dot = fixed.P(center-adv/2, rect.Min.Y+j*block.advance+ascent)
+			drawer.DrawString(string(r))
+		}
+	}
+}
+
+// toVertical は縦書き用の約物に置き換えます。フォントに縦書き用のグリフがない文字はそのまま残します。
+func (l *Letterer) toVertical(text string) string {
+	var buf sfnt.Buffer
+	return strings.Map(func(r rune) rune {
+		if v, ok := verticalForms[r]; ok {
+			if idx, err := l.font.GlyphIndex(&buf, v); err == nil && idx != 0 {
+				return v
+			}
+		}
+		return r
+	}, text)
+}
+
+// fillEllipse は textRect を中心とする白地・黒フチの楕円を、コマ (area) からはみ出さない範囲で描画します。
+func fillEllipse(dst draw.Image, area, textRect image.Rectangle, rx, ry float64, stroke int) {
+	cx := float64(textRect.Min.X+textRect.Max.X) / 2
+	cy := float64(textRect.Min.Y+textRect.Max.Y) / 2
+	outerX, outerY := rx+float64(stroke), ry+float64(stroke)
+	bounds := image.Rect(int(cx-outerX), int(cy-outerY), int(cx+outerX)+1, int(cy+outerY)+1).Intersect(area).Intersect(dst.Bounds())
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+			switch {
+			case (dx*dx)/(rx*rx)+(dy*dy)/(ry*ry) <= 1:
+				dst.Set(x, y, paperColor)
+			case (dx*dx)/(outerX*outerX)+(dy*dy)/(outerY*outerY) <= 1:
+				dst.Set(x, y, inkColor)
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func newTestLetterer(t *testing.T) *Letterer {
+	t.Helper()
+	l, err := NewLetterer(goregular.TTF)
+	if err != nil {
+		t.Fatalf("NewLetterer() error = %v", err)
+	}
+	return l
+}
+
+// countColor は rect 内で c と一致する画素の数を返します。
+func countColor(img *image.RGBA, rect image.Rectangle, c color.RGBA) int {
+	n := 0
+	rect = rect.Intersect(img.Bounds())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if img.RGBAAt(x, y) == c {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestAssignRegions(t *testing.T) {
+	bubbles := []Bubble{
+		{Kind: BubbleSpeech, Text: "a"},
+		{Kind: BubbleSpeech, Text: "b", Region: RegionTopLeft},
+		{Kind: BubbleSpeech, Text: "c"},
+		{Kind: BubbleSFX, Text: "d"},
+		{Kind: BubbleCaption, Text: "e"},
+	}
+
+	var got []Region
+	for _, b := range assignRegions(bubbles) {
+		got = append(got, b.Region)
+	}
+	// 指定済みの左上を飛ばして右上から読み順に割り当て、効果音は中央に置きます。
+	want := []Region{RegionTopRight, RegionTopLeft, RegionBottomLeft, RegionCenter, RegionBottomRight}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("assignRegions() = %v, want %v", got, want)
+	}
+	if bubbles[0].Region != RegionAuto {
+		t.Fatal("assignRegions() modified its input")
+	}
+}
+
+func TestLayoutFits(t *testing.T) {
+	l := newTestLetterer(t)
+	text := strings.Repeat("lettering ", 6)
+
+	tests := []struct {
+		name     string
+		vertical bool
+	}{
+		{name: "horizontal"},
+		{name: "vertical", vertical: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block, err := l.layout(text, 120, 120, tt.vertical, 40)
+			if err != nil {
+				t.Fatalf("layout() error = %v", err)
+			}
+			defer block.face.Close()
+
+			if block.width > 120 || block.height > 120 {
+				t.Fatalf("block = %dx%d, want within 120x120", block.width, block.height)
+			}
+			if len(block.columns) < 2 {
+				t.Fatalf("columns = %d, want the text to wrap", len(block.columns))
+			}
+			if block.vertical != tt.vertical {
+				t.Fatalf("vertical = %v, want %v", block.vertical, tt.vertical)
+			}
+		})
+	}
+}
+
+func TestLayoutStopsAtMinimumSize(t *testing.T) {
+	l := newTestLetterer(t)
+	// 収まらない場合も最小サイズで配置を返します。
+	block, err := l.layout(strings.Repeat("overflow ", 50), 30, 30, false, 40)
+	if err != nil {
+		t.Fatalf("layout() error = %v", err)
+	}
+	defer block.face.Close()
+	if block.height <= 30 {
+		t.Fatalf("block height = %d, want overflow at the minimum size", block.height)
+	}
+}
+
+func TestLettererDraw(t *testing.T) {
+	l := newTestLetterer(t)
+	gray := color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}
+	img := image.NewRGBA(image.Rect(0, 0, 200, 200))
+	draw.Draw(img, img.Bounds(), image.NewUniform(gray), image.Point{}, draw.Src)
+
+	// コマ (area) はページの一部で、吹き出しはその外に描かれません。
+	area := image.Rect(0, 0, 200, 160)
+	err := l.Draw(img, area, []Bubble{
+		{Kind: BubbleSpeech, Text: "Hello there"},
+		{Kind: BubbleCaption, Text: "Meanwhile", Region: RegionBottomLeft},
+		{Kind: BubbleSpeech, Text: "   "},
+	})
+	if err != nil {
+		t.Fatalf("Draw() error = %v", err)
+	}
+
+	topRight := regionRect(area, RegionTopRight)
+	if countColor(img, topRight, paperColor) == 0 || countColor(img, topRight, inkColor) == 0 {
+		t.Error("speech bubble was not drawn in the top right region")
+	}
+	bottomLeft := regionRect(area, RegionBottomLeft)
+	if countColor(img, bottomLeft, paperColor) == 0 || countColor(img, bottomLeft, inkColor) == 0 {
+		t.Error("caption was not drawn in the bottom left region")
+	}
+	// 空白だけの要素は描画せず、割り当てられた左上は元の画像のままです。
+	if topLeft := regionRect(area, RegionTopLeft); countColor(img, topLeft, gray) != topLeft.Dx()*topLeft.Dy() {
+		t.Error("blank bubble was drawn in the top left region")
+	}
+	if countColor(img, image.Rect(0, 160, 200, 200), gray) != 200*40 {
+		t.Error("bubbles were drawn outside the panel area")
+	}
+}
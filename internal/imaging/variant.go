@@ -0,0 +1,97 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"regexp"
+	"strconv"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+const (
+	// VariantDir は縮小画像を保存するサブディレクトリ名です。元画像と同じ images/ の下に置きます。
+	VariantDir = "variants"
+
+	variantJPEGQuality = 82
+)
+
+// 縮小画像の形式です。値はファイルの拡張子です。
+const (
+	VariantJPEG = "jpg"
+	// VariantWebP は対応するブラウザに <picture> で優先して配信します。
+	VariantWebP = "webp"
+)
+
+// VariantWidths は生成する縮小画像の幅です。スマートフォンの一覧表示と、高密度ディスプレイ向けの2段階です。
+// 元画像より小さい幅のみ生成します。
+var VariantWidths = []int{480, 960}
+
+// VariantFileRegex は panel_1_w480_of1024.jpg や panel_1_w480_of1024.webp などの縮小画像のファイル名に一致します。
+// 閲覧側が元画像を読み込まずに srcset を組み立てられるよう、縮小後の幅に続けて元画像の幅を含めます。
+var VariantFileRegex = regexp.MustCompile(`^(.+)_w(\d+)_of(\d+)\.(jpg|webp)$`)
+
+// Variant は縮小画像のファイル名から読み取れる情報です。
+type Variant struct {
+	// Stem は元画像のファイル名 (拡張子なし) です。
+	Stem string
+	// Width は縮小画像の幅です。
+	Width int
+	// SourceWidth は元画像の幅です。
+	SourceWidth int
+	// Format は VariantJPEG または VariantWebP です。
+	Format string
+}
+
+// VariantPath は元画像のパスと幅、形式から縮小画像の保存先を返します。
+// 例: gs://bucket/output/t/images/panel_1.png, 480, 1024, "jpg" -> gs://bucket/output/t/images/variants/panel_1_w480_of1024.jpg
+// gs:// のスキームを壊さないよう、path.Dir ではなく最後の区切りで分割します。
+func VariantPath(src string, width, sourceWidth int, format string) string {
+	i := strings.LastIndex(src, "/")
+	name := src[i+1:]
+	if j := strings.LastIndex(name, "."); j > 0 {
+		name = name[:j]
+	}
+	return fmt.Sprintf("%s%s/%s_w%d_of%d.%s", src[:i+1], VariantDir, name, width, sourceWidth, format)
+}
+
+// ParseVariantName は縮小画像のファイル名から、元画像のファイル名・縮小後の幅・元画像の幅・形式を取り出します。
+func ParseVariantName(name string) (Variant, bool) {
+	m := VariantFileRegex.FindStringSubmatch(name)
+	if m == nil {
+		return Variant{}, false
+	}
+	width, err := strconv.Atoi(m[2])
+	if err != nil || width <= 0 {
+		return Variant{}, false
+	}
+	sourceWidth, err := strconv.Atoi(m[3])
+	if err != nil || sourceWidth < width {
+		return Variant{}, false
+	}
+	return Variant{Stem: m[1], Width: width, SourceWidth: sourceWidth, Format: m[4]}, true
+}
+
+// Thumbnail は縦横比を保ったまま幅 width に縮小した画像を返します。元画像の幅が width 以下の場合は元画像をそのまま返します。
+func Thumbnail(src image.Image, width int) image.Image {
+	b := src.Bounds()
+	if b.Dx() <= width || b.Dx() == 0 {
+		return src
+	}
+	height := max(1, b.Dy()*width/b.Dx())
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, b, xdraw.Src, nil)
+	return dst
+}
+
+// EncodeVariant は縮小画像を JPEG でエンコードします。
+func EncodeVariant(img image.Image) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: variantJPEGQuality}); err != nil {
+		return nil, fmt.Errorf("JPEG のエンコードに失敗しました: %w", err)
+	}
+	return &buf, nil
+}
@@ -0,0 +1,37 @@
+package imaging
+
+import (
+	"image"
+	"testing"
+)
+
+func TestVariantPathRoundTrip(t *testing.T) {
+	got := VariantPath("gs://bucket/output/t/images/panel_12.png", 480, 1024, VariantJPEG)
+	if want := "gs://bucket/output/t/images/variants/panel_12_w480_of1024.jpg"; got != want {
+		t.Fatalf("VariantPath() = %q, want %q", got, want)
+	}
+
+	for name, want := range map[string]Variant{
+		"panel_12_w480_of1024.jpg":  {Stem: "panel_12", Width: 480, SourceWidth: 1024, Format: VariantJPEG},
+		"panel_12_w480_of1024.webp": {Stem: "panel_12", Width: 480, SourceWidth: 1024, Format: VariantWebP},
+	} {
+		if v, ok := ParseVariantName(name); !ok || v != want {
+			t.Fatalf("ParseVariantName(%q) = %+v, %v, want %+v", name, v, ok, want)
+		}
+	}
+	for _, name := range []string{"panel_12.png", "panel_12_w480.webp", "panel_12_w960_of480.jpg", "panel_12_w480_of1024.png"} {
+		if _, ok := ParseVariantName(name); ok {
+			t.Fatalf("ParseVariantName(%q) should be rejected", name)
+		}
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 1600, 2263))
+	if got := Thumbnail(src, 480).Bounds(); got != image.Rect(0, 0, 480, 678) {
+		t.Fatalf("Thumbnail() bounds = %v", got)
+	}
+	if got := Thumbnail(src, 2000); got != image.Image(src) {
+		t.Fatal("Thumbnail() should not upscale")
+	}
+}
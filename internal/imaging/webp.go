@@ -0,0 +1,857 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+)
+
+// golang.org/x/image/webp はデコードのみに対応しているため、縮小画像用に非可逆圧縮 (VP8) のエンコーダを実装しています。
+// 予測は 16x16 の輝度・8x8 の色差単位 (DC / 垂直 / 水平 / TrueMotion) のみで、4x4 単位の予測やセグメントは使用しません。
+// 係数の確率は画像ごとに集計して更新します。ビットストリームの仕様は RFC 6386 (VP8) と RFC 9649 (WebP) に従います。
+
+const (
+	webpMaxSize = 1<<14 - 1
+	// webpQuantIndex は量子化インデックス (0〜127) です。縮小画像の JPEG (品質 82) と同程度の画質になる値です。
+	webpQuantIndex = 24
+	// webpFilterLevel はブロック境界を滑らかにするループフィルタの強さ (0〜63) です。
+	webpFilterLevel = 12
+)
+
+// 予測モード。値は RFC 6386 の列挙順です。
+const (
+	vp8PredDC = iota
+	vp8PredTM
+	vp8PredVE
+	vp8PredHE
+	vp8PredModes
+)
+
+// EncodeWebP は画像を非可逆圧縮の WebP としてエンコードします。アルファチャンネルは使用しません。
+func EncodeWebP(img image.Image) (*bytes.Buffer, error) {
+	b := img.Bounds()
+	if b.Dx() <= 0 || b.Dy() <= 0 || b.Dx() > webpMaxSize || b.Dy() > webpMaxSize {
+		return nil, fmt.Errorf("WebP にエンコードできない画像サイズです: %dx%d", b.Dx(), b.Dy())
+	}
+
+	e := newVP8Encoder(img, webpQuantIndex)
+	e.analyze()
+	frame := e.encodeFrame()
+
+	var buf bytes.Buffer
+	pad := len(frame) & 1
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(4+8+len(frame)+pad))
+	buf.WriteString("WEBPVP8 ")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(frame)))
+	buf.Write(frame)
+	if pad != 0 {
+		buf.WriteByte(0)
+	}
+	return &buf, nil
+}
+
+// vp8Macroblock は 16x16 画素のマクロブロックの予測モードと量子化した係数です。
+type vp8Macroblock struct {
+	yMode, uvMode uint8
+	// y2 は輝度の各 4x4 ブロックの DC 成分を Walsh-Hadamard 変換した係数です。
+	y2 [16]int16
+	// y は輝度の 4x4 ブロック (ラスター順) の AC 係数です。DC 成分 (添字 0) は y2 で符号化します。
+	y [16][16]int16
+	// uv は色差の 4x4 ブロックの係数です。0〜3 が Cb、4〜7 が Cr です。
+	uv [8][16]int16
+	// skip は係数がすべて 0 であることを表します。
+	skip bool
+}
+
+// vp8Quant は係数の種類ごとの DC / AC の量子化幅です。
+type vp8Quant struct {
+	y1, y2, uv [2]int32
+}
+
+func newVP8Quant(q int) vp8Quant {
+	y2ac := vp8ACQuant[q] * 155 / 100
+	if y2ac < 8 {
+		y2ac = 8
+	}
+	return vp8Quant{
+		y1: [2]int32{vp8DCQuant[q], vp8ACQuant[q]},
+		y2: [2]int32{vp8DCQuant[q] * 2, y2ac},
+		uv: [2]int32{vp8DCQuant[min(q, 117)], vp8ACQuant[q]},
+	}
+}
+
+// vp8Plane はマクロブロック単位に拡張した画像の1面です。
+type vp8Plane struct {
+	pix    []uint8
+	stride int
+}
+
+func newVP8Plane(w, h int) vp8Plane {
+	return vp8Plane{pix: make([]uint8, w*h), stride: w}
+}
+
+type vp8Encoder struct {
+	width, height int
+	mbw, mbh      int
+	quantIndex    int
+	quant         vp8Quant
+	// src は入力、rec はデコーダと同じ手順で復元した画像です。予測には rec を使用します。
+	src, rec [3]vp8Plane
+	mbs      []vp8Macroblock
+}
+
+// newVP8Encoder は画像を BT.601 (limited range) の YCbCr 4:2:0 に変換し、マクロブロックの倍数まで端の画素で拡張します。
+func newVP8Encoder(img image.Image, quantIndex int) *vp8Encoder {
+	b := img.Bounds()
+	rgba, ok := img.(*image.RGBA)
+	if !ok || rgba.Bounds().Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	}
+
+	e := &vp8Encoder{
+		width:      b.Dx(),
+		height:     b.Dy(),
+		mbw:        (b.Dx() + 15) / 16,
+		mbh:        (b.Dy() + 15) / 16,
+		quantIndex: quantIndex,
+		quant:      newVP8Quant(quantIndex),
+	}
+	e.mbs = make([]vp8Macroblock, e.mbw*e.mbh)
+	yw, yh := e.mbw*16, e.mbh*16
+	for i := range e.src {
+		w, h := yw, yh
+		if i > 0 {
+			w, h = yw/2, yh/2
+		}
+		e.src[i] = newVP8Plane(w, h)
+		e.rec[i] = newVP8Plane(w, h)
+	}
+
+	rgb := func(x, y int) (int32, int32, int32) {
+		o := rgba.PixOffset(min(x, e.width-1), min(y, e.height-1))
+		return int32(rgba.Pix[o]), int32(rgba.Pix[o+1]), int32(rgba.Pix[o+2])
+	}
+	for y := 0; y < yh; y++ {
+		for x := 0; x < yw; x++ {
+			r, g, b := rgb(x, y)
+			e.src[0].pix[y*yw+x] = uint8((16839*r + 33059*g + 6420*b + 1<<15 + 16<<16) >> 16)
+		}
+	}
+	for y := 0; y < yh/2; y++ {
+		for x := 0; x < yw/2; x++ {
+			var r, g, b int32
+			for _, d := range [4][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+				pr, pg, pb := rgb(2*x+d[0], 2*y+d[1])
+				r, g, b = r+pr, g+pg, b+pb
+			}
+			e.src[1].pix[y*yw/2+x] = clipUV(-9719*r - 19081*g + 28800*b)
+			e.src[2].pix[y*yw/2+x] = clipUV(28800*r - 24116*g - 4684*b)
+		}
+	}
+	return e
+}
+
+// clipUV は 2x2 画素の合計から求めた色差を 8 ビットに丸めます。
+func clipUV(v int32) uint8 {
+	v = (v + 1<<17 + 128<<18) >> 18
+	return uint8(max(0, min(255, v)))
+}
+
+// analyze はマクロブロックごとに予測モードを選び、残差を変換・量子化して、デコーダと同じ画像を復元します。
+func (e *vp8Encoder) analyze() {
+	for mby := 0; mby < e.mbh; mby++ {
+		for mbx := 0; mbx < e.mbw; mbx++ {
+			mb := &e.mbs[mby*e.mbw+mbx]
+			e.analyzeLuma(mb, mbx, mby)
+			e.analyzeChroma(mb, mbx, mby)
+			mb.skip = mb.isZero()
+		}
+	}
+}
+
+func (e *vp8Encoder) analyzeLuma(mb *vp8Macroblock, mbx, mby int) {
+	src, rec := &e.src[0], &e.rec[0]
+	x0, y0 := mbx*16, mby*16
+
+	var pred [16 * 16]uint8
+	mb.yMode = bestPrediction(src, rec, x0, y0, 16, mbx > 0, mby > 0, pred[:])
+	predict(rec, x0, y0, 16, mb.yMode, mbx > 0, mby > 0, pred[:])
+
+	var coeffs [16][16]int32
+	var dc [16]int32
+	for n := range 16 {
+		bx, by := n%4*4, n/4*4
+		var res [16]int32
+		for j := range 4 {
+			for i := range 4 {
+				res[j*4+i] = int32(src.pix[(y0+by+j)*src.stride+x0+bx+i]) - int32(pred[(by+j)*16+bx+i])
+			}
+		}
+		fdct4(&res, &coeffs[n])
+		dc[n] = coeffs[n][0]
+	}
+
+	var wht [16]int32
+	fwht4(&dc, &wht)
+	var y2 [16]int16
+	for k := range 16 {
+		mb.y2[k] = quantize(wht[k], e.quant.y2[min(k, 1)], true)
+		y2[k] = int16(int32(mb.y2[k]) * e.quant.y2[min(k, 1)])
+	}
+	dcs := iwht4(&y2)
+
+	for n := range 16 {
+		var deq [16]int16
+		deq[0] = dcs[n]
+		for k := 1; k < 16; k++ {
+			mb.y[n][k] = quantize(coeffs[n][k], e.quant.y1[1], false)
+			deq[k] = int16(int32(mb.y[n][k]) * e.quant.y1[1])
+		}
+		bx, by := n%4*4, n/4*4
+		for j := range 4 {
+			copy(rec.pix[(y0+by+j)*rec.stride+x0+bx:][:4], pred[(by+j)*16+bx:][:4])
+		}
+		idct4Add(&deq, rec.pix[(y0+by)*rec.stride+x0+bx:], rec.stride)
+	}
+}
+
+func (e *vp8Encoder) analyzeChroma(mb *vp8Macroblock, mbx, mby int) {
+	x0, y0 := mbx*8, mby*8
+
+	// Cb と Cr は同じ予測モードを使うため、両方の誤差の合計で選びます
+	best, bestErr := uint8(0), int64(math.MaxInt64)
+	var pred [8 * 8]uint8
+	for mode := uint8(0); mode < vp8PredModes; mode++ {
+		var sum int64
+		for c := 1; c <= 2; c++ {
+			predict(&e.rec[c], x0, y0, 8, mode, mbx > 0, mby > 0, pred[:])
+			sum += blockError(&e.src[c], x0, y0, 8, pred[:])
+		}
+		if sum < bestErr {
+			best, bestErr = mode, sum
+		}
+	}
+	mb.uvMode = best
+
+	for c := 1; c <= 2; c++ {
+		src, rec := &e.src[c], &e.rec[c]
+		predict(rec, x0, y0, 8, best, mbx > 0, mby > 0, pred[:])
+		for n := range 4 {
+			bx, by := n%2*4, n/2*4
+			var res, coeffs [16]int32
+			for j := range 4 {
+				for i := range 4 {
+					res[j*4+i] = int32(src.pix[(y0+by+j)*src.stride+x0+bx+i]) - int32(pred[(by+j)*8+bx+i])
+				}
+			}
+			fdct4(&res, &coeffs)
+
+			levels := &mb.uv[(c-1)*4+n]
+			var deq [16]int16
+			for k := range 16 {
+				q := e.quant.uv[min(k, 1)]
+				levels[k] = quantize(coeffs[k], q, k == 0)
+				deq[k] = int16(int32(levels[k]) * q)
+			}
+			for j := range 4 {
+				copy(rec.pix[(y0+by+j)*rec.stride+x0+bx:][:4], pred[(by+j)*8+bx:][:4])
+			}
+			idct4Add(&deq, rec.pix[(y0+by)*rec.stride+x0+bx:], rec.stride)
+		}
+	}
+}
+
+func (mb *vp8Macroblock) isZero() bool {
+	for _, v := range mb.y2 {
+		if v != 0 {
+			return false
+		}
+	}
+	for _, blk := range mb.y {
+		for _, v := range blk[1:] {
+			if v != 0 {
+				return false
+			}
+		}
+	}
+	for _, blk := range mb.uv {
+		for _, v := range blk {
+			if v != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// bestPrediction は n×n のブロックで、入力との二乗誤差が最も小さい予測モードを返します。pred は作業領域です。
+func bestPrediction(src, rec *vp8Plane, x0, y0, n int, hasLeft, hasTop bool, pred []uint8) uint8 {
+	best, bestErr := uint8(0), int64(math.MaxInt64)
+	for mode := uint8(0); mode < vp8PredModes; mode++ {
+		predict(rec, x0, y0, n, mode, hasLeft, hasTop, pred)
+		if err := blockError(src, x0, y0, n, pred); err < bestErr {
+			best, bestErr = mode, err
+		}
+	}
+	return best
+}
+
+func blockError(src *vp8Plane, x0, y0, n int, pred []uint8) int64 {
+	var sum int64
+	for j := range n {
+		for i := range n {
+			d := int64(src.pix[(y0+j)*src.stride+x0+i]) - int64(pred[j*n+i])
+			sum += d * d
+		}
+	}
+	return sum
+}
+
+// predict は復元済みの上端・左端の画素から n×n のブロックを予測します。
+// 画像の上端と左端では、デコーダと同じく上を 127、左を 129 とみなします (RFC 6386 12.2)。
+func predict(rec *vp8Plane, x0, y0, n int, mode uint8, hasLeft, hasTop bool, pred []uint8) {
+	var top, left [16]int32
+	for i := range n {
+		top[i], left[i] = 127, 129
+		if hasTop {
+			top[i] = int32(rec.pix[(y0-1)*rec.stride+x0+i])
+		}
+		if hasLeft {
+			left[i] = int32(rec.pix[(y0+i)*rec.stride+x0-1])
+		}
+	}
+	corner := int32(127)
+	if hasTop {
+		corner = 129
+		if hasLeft {
+			corner = int32(rec.pix[(y0-1)*rec.stride+x0-1])
+		}
+	}
+
+	switch mode {
+	case vp8PredDC:
+		shift := 3
+		if n == 16 {
+			shift = 4
+		}
+		var sum int32
+		v := int32(0x80)
+		switch {
+		case hasTop && hasLeft:
+			for i := range n {
+				sum += top[i] + left[i]
+			}
+			v = (sum + int32(n)) >> (shift + 1)
+		case hasTop:
+			for i := range n {
+				sum += top[i]
+			}
+			v = (sum + int32(n/2)) >> shift
+		case hasLeft:
+			for i := range n {
+				sum += left[i]
+			}
+			v = (sum + int32(n/2)) >> shift
+		}
+		for i := range n * n {
+			pred[i] = uint8(v)
+		}
+	case vp8PredTM:
+		for j := range n {
+			for i := range n {
+				pred[j*n+i] = clip8(left[j] + top[i] - corner)
+			}
+		}
+	case vp8PredVE:
+		for j := range n {
+			for i := range n {
+				pred[j*n+i] = uint8(top[i])
+			}
+		}
+	case vp8PredHE:
+		for j := range n {
+			for i := range n {
+				pred[j*n+i] = uint8(left[j])
+			}
+		}
+	}
+}
+
+func clip8(v int32) uint8 {
+	return uint8(max(0, min(255, v)))
+}
+
+// quantize は係数を量子化します。AC 成分は 0 に寄せて丸め、符号量を抑えます。
+func quantize(c, q int32, dc bool) int16 {
+	bias := q * 3 / 8
+	if dc {
+		bias = q / 2
+	}
+	neg := c < 0
+	if neg {
+		c = -c
+	}
+	level := min((c+bias)/q, 2048)
+	if neg {
+		level = -level
+	}
+	return int16(level)
+}
+
+// fdct4 は 4x4 の残差を DCT 変換します。libvpx の vp8_short_fdct4x4_c と同じ整数演算です。
+func fdct4(in, out *[16]int32) {
+	var tmp [16]int32
+	for i := range 4 {
+		ip := in[i*4:]
+		a1 := (ip[0] + ip[3]) * 8
+		b1 := (ip[1] + ip[2]) * 8
+		c1 := (ip[1] - ip[2]) * 8
+		d1 := (ip[0] - ip[3]) * 8
+		tmp[i*4+0] = a1 + b1
+		tmp[i*4+2] = a1 - b1
+		tmp[i*4+1] = (c1*2217 + d1*5352 + 14500) >> 12
+		tmp[i*4+3] = (d1*2217 - c1*5352 + 7500) >> 12
+	}
+	for i := range 4 {
+		a1 := tmp[i] + tmp[12+i]
+		b1 := tmp[4+i] + tmp[8+i]
+		c1 := tmp[4+i] - tmp[8+i]
+		d1 := tmp[i] - tmp[12+i]
+		out[i] = (a1 + b1 + 7) >> 4
+		out[8+i] = (a1 - b1 + 7) >> 4
+		out[4+i] = (c1*2217+d1*5352+12000)>>16 + btoi(d1 != 0)
+		out[12+i] = (d1*2217 - c1*5352 + 51000) >> 16
+	}
+}
+
+// fwht4 は輝度の 16 ブロックの DC 成分を Walsh-Hadamard 変換します。libvpx の vp8_short_walsh4x4_c と同じ整数演算です。
+func fwht4(in, out *[16]int32) {
+	var tmp [16]int32
+	for i := range 4 {
+		ip := in[i*4:]
+		a1 := (ip[0] + ip[2]) * 4
+		d1 := (ip[1] + ip[3]) * 4
+		c1 := (ip[1] - ip[3]) * 4
+		b1 := (ip[0] - ip[2]) * 4
+		tmp[i*4+0] = a1 + d1 + btoi(a1 != 0)
+		tmp[i*4+1] = b1 + c1
+		tmp[i*4+2] = b1 - c1
+		tmp[i*4+3] = a1 - d1
+	}
+	for i := range 4 {
+		a1 := tmp[i] + tmp[8+i]
+		d1 := tmp[4+i] + tmp[12+i]
+		c1 := tmp[4+i] - tmp[12+i]
+		b1 := tmp[i] - tmp[8+i]
+		for k, v := range [4]int32{a1 + d1, b1 + c1, b1 - c1, a1 - d1} {
+			if v < 0 {
+				v++
+			}
+			out[k*4+i] = (v + 3) >> 3
+		}
+	}
+}
+
+// iwht4 は逆 Walsh-Hadamard 変換で、輝度の各ブロックの DC 成分を復元します (RFC 6386 14.3)。
+func iwht4(in *[16]int16) [16]int16 {
+	var m [16]int32
+	for i := range 4 {
+		a0 := int32(in[i]) + int32(in[12+i])
+		a1 := int32(in[4+i]) + int32(in[8+i])
+		a2 := int32(in[4+i]) - int32(in[8+i])
+		a3 := int32(in[i]) - int32(in[12+i])
+		m[i] = a0 + a1
+		m[8+i] = a0 - a1
+		m[4+i] = a3 + a2
+		m[12+i] = a3 - a2
+	}
+	var out [16]int16
+	for i := range 4 {
+		dc := m[i*4] + 3
+		a0 := dc + m[i*4+3]
+		a1 := m[i*4+1] + m[i*4+2]
+		a2 := m[i*4+1] - m[i*4+2]
+		a3 := dc - m[i*4+3]
+		out[i*4+0] = int16((a0 + a1) >> 3)
+		out[i*4+1] = int16((a3 + a2) >> 3)
+		out[i*4+2] = int16((a0 - a1) >> 3)
+		out[i*4+3] = int16((a3 - a2) >> 3)
+	}
+	return out
+}
+
+// idct4Add は逆 DCT 変換した残差を dst の 4x4 画素に加えます (RFC 6386 14.3)。
+func idct4Add(in *[16]int16, dst []uint8, stride int) {
+	const (
+		c1 = 85627 // 65536 * cos(pi/8) * sqrt(2)
+		c2 = 35468 // 65536 * sin(pi/8) * sqrt(2)
+	)
+	var m [4][4]int32
+	for i := range 4 {
+		a := int32(in[i]) + int32(in[8+i])
+		b := int32(in[i]) - int32(in[8+i])
+		c := (int32(in[4+i])*c2)>>16 - (int32(in[12+i])*c1)>>16
+		d := (int32(in[4+i])*c1)>>16 + (int32(in[12+i])*c2)>>16
+		m[i] = [4]int32{a + d, b + c, b - c, a - d}
+	}
+	for j := range 4 {
+		dc := m[0][j] + 4
+		a := dc + m[2][j]
+		b := dc - m[2][j]
+		c := (m[1][j]*c2)>>16 - (m[3][j]*c1)>>16
+		d := (m[1][j]*c1)>>16 + (m[3][j]*c2)>>16
+		row := dst[j*stride:]
+		for i, v := range [4]int32{a + d, b + c, b - c, a - d} {
+			row[i] = clip8(int32(row[i]) + v>>3)
+		}
+	}
+}
+
+func btoi(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// encodeFrame はキーフレームを符号化します。係数の出現回数を数えてから確率を決め、
+// ヘッダーと予測モードの第1パーティション、係数のパーティションの順に書き出します。
+func (e *vp8Encoder) encodeFrame() []byte {
+	probs := vp8DefaultTokenProbs
+	var stats vp8TokenStats
+	e.writeTokens(&vp8TokenWriter{probs: &probs, stats: &stats})
+	updates := stats.updates(&probs)
+
+	skipped := 0
+	for i := range e.mbs {
+		if e.mbs[i].skip {
+			skipped++
+		}
+	}
+	skipProb := uint8(max(1, min(255, 255*(len(e.mbs)-skipped)/len(e.mbs))))
+
+	first := newVP8BoolEncoder()
+	first.putLiteral(0, 1) // color_space
+	first.putLiteral(0, 1) // clamping_type
+	first.putLiteral(0, 1) // segmentation_enabled
+	first.putLiteral(0, 1) // filter_type (normal)
+	first.putLiteral(webpFilterLevel, 6)
+	first.putLiteral(0, 3) // sharpness_level
+	first.putLiteral(0, 1) // loop_filter_adj_enable
+	first.putLiteral(0, 2) // log2_nbr_of_dct_partitions
+	first.putLiteral(uint32(e.quantIndex), 7)
+	first.putLiteral(0, 5) // 量子化インデックスの差分なし
+	first.putLiteral(0, 1) // refresh_entropy_probs
+	for i := range probs {
+		for j := range probs[i] {
+			for k := range probs[i][j] {
+				for l := range probs[i][j][k] {
+					update := updates[i][j][k][l]
+					first.putBit(update, vp8TokenUpdateProbs[i][j][k][l])
+					if update {
+						first.putLiteral(uint32(probs[i][j][k][l]), 8)
+					}
+				}
+			}
+		}
+	}
+	first.putLiteral(1, 1) // mb_no_coeff_skip
+	first.putLiteral(uint32(skipProb), 8)
+
+	for i := range e.mbs {
+		mb := &e.mbs[i]
+		first.putBit(mb.skip, skipProb)
+		// 16x16 予測のキーフレーム用の符号木 (RFC 6386 11.2)
+		first.putBit(true, 145)
+		switch mb.yMode {
+		case vp8PredDC, vp8PredVE:
+			first.putBit(false, 156)
+			first.putBit(mb.yMode == vp8PredVE, 163)
+		default:
+			first.putBit(true, 156)
+			first.putBit(mb.yMode == vp8PredTM, 128)
+		}
+		first.putBit(mb.uvMode != vp8PredDC, 142)
+		if mb.uvMode != vp8PredDC {
+			first.putBit(mb.uvMode != vp8PredVE, 114)
+			if mb.uvMode != vp8PredVE {
+				first.putBit(mb.uvMode == vp8PredTM, 183)
+			}
+		}
+	}
+	firstPart := first.flush()
+
+	tokens := newVP8BoolEncoder()
+	e.writeTokens(&vp8TokenWriter{enc: tokens, probs: &probs})
+	tokenPart := tokens.flush()
+
+	frame := make([]byte, 10, 10+len(firstPart)+len(tokenPart))
+	tag := uint32(len(firstPart))<<5 | 1<<4 // キーフレーム、version 0、show_frame
+	frame[0], frame[1], frame[2] = byte(tag), byte(tag>>8), byte(tag>>16)
+	frame[3], frame[4], frame[5] = 0x9d, 0x01, 0x2a
+	binary.LittleEndian.PutUint16(frame[6:], uint16(e.width))
+	binary.LittleEndian.PutUint16(frame[8:], uint16(e.height))
+	frame = append(frame, firstPart...)
+	return append(frame, tokenPart...)
+}
+
+// writeTokens は全マクロブロックの係数を書き出します。
+// 各ブロックの確率の文脈には、左と上のブロックに 0 でない係数があるか (RFC 6386 13.3) を使用します。
+func (e *vp8Encoder) writeTokens(w *vp8TokenWriter) {
+	type nz struct {
+		y16 uint8
+		y   [4]uint8
+		uv  [4]uint8 // 0〜1 が Cb、2〜3 が Cr
+	}
+	up := make([]nz, e.mbw)
+	for mby := 0; mby < e.mbh; mby++ {
+		var left nz
+		for mbx := 0; mbx < e.mbw; mbx++ {
+			mb := &e.mbs[mby*e.mbw+mbx]
+			u := &up[mbx]
+			if mb.skip {
+				left, *u = nz{}, nz{}
+				continue
+			}
+
+			v := w.writeBlock(vp8PlaneY2, left.y16+u.y16, &mb.y2, 0)
+			left.y16, u.y16 = v, v
+			for y := range 4 {
+				for x := range 4 {
+					v := w.writeBlock(vp8PlaneY1WithY2, left.y[y]+u.y[x], &mb.y[y*4+x], 1)
+					left.y[y], u.y[x] = v, v
+				}
+			}
+			for c := 0; c < 4; c += 2 {
+				for y := range 2 {
+					for x := range 2 {
+						v := w.writeBlock(vp8PlaneUV, left.uv[c+y]+u.uv[c+x], &mb.uv[c*2+y*2+x], 0)
+						left.uv[c+y], u.uv[c+x] = v, v
+					}
+				}
+			}
+		}
+	}
+}
+
+// vp8TokenStats は係数の確率ごとに、0 と 1 を書いた回数です。
+type vp8TokenStats [vp8Planes][vp8Bands][vp8Contexts][vp8Probs][2]uint32
+
+// updates は、更新の符号量を含めても符号量が減る確率を probs に反映し、更新したものを返します。
+func (s *vp8TokenStats) updates(probs *vp8TokenProbs) (updated [vp8Planes][vp8Bands][vp8Contexts][vp8Probs]bool) {
+	for i := range s {
+		for j := range s[i] {
+			for k := range s[i][j] {
+				for l, n := range s[i][j][k] {
+					total := n[0] + n[1]
+					if total == 0 {
+						continue
+					}
+					old := probs[i][j][k][l]
+					p := uint8(max(1, min(255, (uint64(n[0])*256+uint64(total)/2)/uint64(total))))
+					upd := vp8TokenUpdateProbs[i][j][k][l]
+					saving := bitCost(n, old) - bitCost(n, p) - (bitCost([2]uint32{0, 1}, upd) + 8 - bitCost([2]uint32{1, 0}, upd))
+					if saving > 0 {
+						probs[i][j][k][l] = p
+						updated[i][j][k][l] = true
+					}
+				}
+			}
+		}
+	}
+	return updated
+}
+
+// bitCost は確率 prob (0 の確率 / 256) で 0 を n[0] 回、1 を n[1] 回書くときのおおよそのビット数です。
+func bitCost(n [2]uint32, prob uint8) float64 {
+	p0 := float64(prob) / 256
+	return -float64(n[0])*math.Log2(p0) - float64(n[1])*math.Log2(1-p0)
+}
+
+// vp8TokenWriter は係数のトークンを書き出します。enc が nil の場合は stats に回数を数えるだけです。
+type vp8TokenWriter struct {
+	enc   *vp8BoolEncoder
+	probs *vp8TokenProbs
+	stats *vp8TokenStats
+}
+
+func (w *vp8TokenWriter) put(plane, band, ctx, i int, bit bool) {
+	if w.stats != nil {
+		w.stats[plane][band][ctx][i][btoi(bit)]++
+	}
+	if w.enc != nil {
+		w.enc.putBit(bit, w.probs[plane][band][ctx][i])
+	}
+}
+
+func (w *vp8TokenWriter) putFixed(bit bool, prob uint8) {
+	if w.enc != nil {
+		w.enc.putBit(bit, prob)
+	}
+}
+
+// writeBlock は 4x4 ブロックの係数をジグザグ順に書き出し、0 でない係数があったかを返します (RFC 6386 13.2)。
+// first が 1 の場合は DC 成分 (Y2 で符号化済み) を飛ばします。
+func (w *vp8TokenWriter) writeBlock(plane int, ctx uint8, levels *[16]int16, first int) uint8 {
+	last := -1
+	for n := 15; n >= first; n-- {
+		if levels[vp8Zigzag[n]] != 0 {
+			last = n
+			break
+		}
+	}
+
+	n := first
+	band, c := int(vp8CoeffBands[n]), int(ctx)
+	if last < 0 {
+		w.put(plane, band, c, 0, false) // EOB
+		return 0
+	}
+	w.put(plane, band, c, 0, true)
+	for n < 16 {
+		level := int32(levels[vp8Zigzag[n]])
+		n++
+		v := max(level, -level)
+		if v == 0 {
+			w.put(plane, band, c, 1, false)
+			band, c = int(vp8CoeffBands[n]), 0
+			continue
+		}
+		w.put(plane, band, c, 1, true)
+		if v == 1 {
+			w.put(plane, band, c, 2, false)
+			band, c = int(vp8CoeffBands[n]), 1
+		} else {
+			w.put(plane, band, c, 2, true)
+			w.writeLargeToken(plane, band, c, v)
+			band, c = int(vp8CoeffBands[n]), 2
+		}
+		w.putFixed(level < 0, 128)
+		if n == 16 {
+			break
+		}
+		if n > last {
+			w.put(plane, band, c, 0, false) // EOB
+			break
+		}
+		w.put(plane, band, c, 0, true)
+	}
+	return 1
+}
+
+// writeLargeToken は 2 以上の係数の大きさを書き出します。
+func (w *vp8TokenWriter) writeLargeToken(plane, band, c int, v int32) {
+	switch {
+	case v <= 4:
+		w.put(plane, band, c, 3, false)
+		w.put(plane, band, c, 4, v != 2)
+		if v != 2 {
+			w.put(plane, band, c, 5, v == 4)
+		}
+	case v <= 10:
+		w.put(plane, band, c, 3, true)
+		w.put(plane, band, c, 6, false)
+		if v <= 6 {
+			w.put(plane, band, c, 7, false)
+			w.putFixed(v == 6, 159)
+		} else {
+			w.put(plane, band, c, 7, true)
+			w.putFixed(v-7 >= 2, 165)
+			w.putFixed((v-7)&1 == 1, 145)
+		}
+	default:
+		w.put(plane, band, c, 3, true)
+		w.put(plane, band, c, 6, true)
+		cat := 3
+		for i := range 3 {
+			if v < 3+(8<<(i+1)) {
+				cat = i
+				break
+			}
+		}
+		w.put(plane, band, c, 8, cat >= 2)
+		w.put(plane, band, c, 9+cat/2, cat%2 == 1)
+		extra := v - (3 + 8<<cat)
+		probs := vp8CatProbs[cat]
+		for i, p := range probs {
+			w.putFixed(extra>>(len(probs)-1-i)&1 == 1, p)
+		}
+	}
+}
+
+// vp8BoolEncoder は VP8 の算術符号器です (RFC 6386 7.3)。
+type vp8BoolEncoder struct {
+	buf      []byte
+	rng      uint32
+	bottom   uint32
+	bitCount int
+}
+
+func newVP8BoolEncoder() *vp8BoolEncoder {
+	return &vp8BoolEncoder{rng: 255, bitCount: 24}
+}
+
+// putBit は 0 の確率が prob/256 のビットを書き出します。
+func (e *vp8BoolEncoder) putBit(bit bool, prob uint8) {
+	split := 1 + (e.rng-1)*uint32(prob)>>8
+	if bit {
+		e.bottom += split
+		e.rng -= split
+	} else {
+		e.rng = split
+	}
+	for e.rng < 128 {
+		e.rng <<= 1
+		if e.bottom&(1<<31) != 0 {
+			e.carry()
+		}
+		e.bottom <<= 1
+		e.bitCount--
+		if e.bitCount == 0 {
+			e.buf = append(e.buf, byte(e.bottom>>24))
+			e.bottom &= 1<<24 - 1
+			e.bitCount = 8
+		}
+	}
+}
+
+// putLiteral は n ビットの値を上位ビットから確率 1/2 で書き出します。
+func (e *vp8BoolEncoder) putLiteral(v uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		e.putBit(v>>i&1 == 1, 128)
+	}
+}
+
+// carry は書き出し済みのバイトに桁上がりを加えます。
+func (e *vp8BoolEncoder) carry() {
+	for i := len(e.buf) - 1; i >= 0; i-- {
+		if e.buf[i] != 0xff {
+			e.buf[i]++
+			return
+		}
+		e.buf[i] = 0
+	}
+}
+
+// flush は残りのビットを書き出し、符号化したバイト列を返します。
+func (e *vp8BoolEncoder) flush() []byte {
+	c := e.bitCount
+	v := e.bottom
+	if v&(1<<(32-c)) != 0 {
+		e.carry()
+	}
+	v <<= c & 7
+	for c >>= 3; c > 0; c-- {
+		v <<= 8
+	}
+	for range 4 {
+		e.buf = append(e.buf, byte(v>>24))
+		v <<= 8
+	}
+	return e.buf
+}
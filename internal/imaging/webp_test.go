@@ -0,0 +1,90 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func TestEncodeWebPRoundTrip(t *testing.T) {
+	gradient := func(w, h int) image.Image {
+		img := image.NewRGBA(image.Rect(0, 0, w, h))
+		for y := range h {
+			for x := range w {
+				img.Set(x, y, color.RGBA{uint8(x * 255 / w), uint8(y * 255 / h), uint8((x + y) * 4), 255})
+			}
+		}
+		return img
+	}
+
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{"1x1", image.NewRGBA(image.Rect(0, 0, 1, 1))},
+		{"flat", uniformImage(color.RGBA{200, 40, 90, 255}, 33, 20)},
+		{"gradient", gradient(64, 48)},
+		{"odd size", gradient(37, 23)},
+		{"offset bounds", gradient(50, 50).(*image.RGBA).SubImage(image.Rect(5, 7, 45, 30))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := tt.img
+			buf, err := EncodeWebP(img)
+			if err != nil {
+				t.Fatalf("EncodeWebP() error = %v", err)
+			}
+			got, err := webp.Decode(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("webp.Decode() error = %v", err)
+			}
+			if got.Bounds().Size() != img.Bounds().Size() {
+				t.Fatalf("decoded size = %v, want %v", got.Bounds().Size(), img.Bounds().Size())
+			}
+			ycc, ok := got.(*image.YCbCr)
+			if !ok {
+				t.Fatalf("decoded image type = %T", got)
+			}
+			want := newVP8Encoder(img, webpQuantIndex).src[0]
+			if p := lumaPSNR(ycc, want); p < 35 {
+				t.Fatalf("luma PSNR = %.2f dB, want >= 35", p)
+			}
+		})
+	}
+}
+
+func TestEncodeWebPRejectsEmpty(t *testing.T) {
+	if _, err := EncodeWebP(image.NewRGBA(image.Rect(0, 0, 0, 10))); err == nil {
+		t.Fatal("EncodeWebP() should reject an empty image")
+	}
+}
+
+func uniformImage(c color.Color, w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// lumaPSNR はデコードした輝度と、エンコーダが変換した輝度の PSNR を返します。
+func lumaPSNR(got *image.YCbCr, want vp8Plane) float64 {
+	b := got.Bounds()
+	var sse float64
+	for y := range b.Dy() {
+		for x := range b.Dx() {
+			d := float64(got.Y[y*got.YStride+x]) - float64(want.pix[y*want.stride+x])
+			sse += d * d
+		}
+	}
+	if sse == 0 {
+		return math.Inf(1)
+	}
+	return 10 * math.Log10(255*255*float64(b.Dx()*b.Dy())/sse)
+}
@@ -5,10 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"path"
 	"time"
 
-	"github.com/shouni/go-manga-kit/ports"
-
 	"ap-manga-web/internal/config"
 	"ap-manga-web/internal/domain"
 )
@@ -17,18 +16,27 @@ import (
 type mangaExecution struct {
 	// 実行状態
 	payload           domain.GenerateTaskPayload
+	imageOptions      domain.ImageOptions
 	startTime         time.Time
 	resolvedSafeTitle string
+	// step は実行中の工程です。作業ディレクトリを使う工程を始めるまでは空です。
+	step domain.JobStep
+	// baseJob は既存の作品を更新する場合の、作品を作成したジョブの記録です。
+	baseJob *domain.JobRecord
+	// diff は既存の作品を改訂した台本で更新する場合の、作り直す対象です。
+	diff *domain.PlotDiff
 
 	// 依存関係
 	cfg       *config.Config
 	workflows domain.Workflows
 	notifier  domain.Notifier
+	titles    domain.TitleStore
+	indexer   domain.SearchIndexer
 }
 
 // run はメインのエントリーポイントとして各コマンドにディスパッチします。
 func (e *mangaExecution) run(ctx context.Context) (err error) {
-	var manga *ports.MangaResponse
+	var manga *domain.MangaPlot
 
 	// 失敗時の通知を defer で一括管理
 	defer func() {
@@ -40,8 +48,14 @@ func (e *mangaExecution) run(ctx context.Context) (err error) {
 	slog.Info("Pipeline execution started",
 		"command", e.payload.Command,
 		"mode", e.payload.Mode,
+		"color_mode", e.payload.ColorMode,
+		"page_renderer", e.payload.PageRenderer,
 	)
 
+	if e.imageOptions, err = e.payload.ImageOptions(); err != nil {
+		return fmt.Errorf("invalid image options: %w", err)
+	}
+
 	var req *domain.NotificationRequest
 	var publicURL, storageURI string
 
@@ -57,14 +71,25 @@ func (e *mangaExecution) run(ctx context.Context) (err error) {
 		req, publicURL, storageURI, manga, err = e.handlePanel(ctx)
 	case "page":
 		req, publicURL, storageURI, manga, err = e.handlePage(ctx)
+	case "regenerate":
+		req, publicURL, storageURI, manga, err = e.handleRegenerate(ctx)
 	default:
 		return fmt.Errorf("unsupported command: %s", e.payload.Command)
 	}
 
+	// 作業ディレクトリが作られた場合は、成否にかかわらずジョブ記録を残します
+	e.recordJob(ctx, manga, err)
+
 	if err != nil {
 		return err // defer により handleFailure が呼ばれる
 	}
 
+	// 台本のある作品は全文検索の索引に登録します
+	e.indexTitle(ctx, manga)
+
+	// 利用者が編集したメタデータがあれば通知に反映します
+	e.applyMeta(ctx, req, manga)
+
 	// 成功時の共通通知
 	e.notifySuccess(ctx, req, publicURL, storageURI)
 	return nil
@@ -73,7 +98,7 @@ func (e *mangaExecution) run(ctx context.Context) (err error) {
 // --- Command Handlers ---
 
 // handleGenerate は スクリプト解析 -> パネル生成 -> ページ構成 のフルパイプラインを実行します。
-func (e *mangaExecution) handleGenerate(ctx context.Context) (*domain.NotificationRequest, string, string, *ports.MangaResponse, error) {
+func (e *mangaExecution) handleGenerate(ctx context.Context) (*domain.NotificationRequest, string, string, *domain.MangaPlot, error) {
 	manga, _, err := e.runScriptStep(ctx)
 	if err != nil {
 		return nil, "", "", nil, fmt.Errorf("script step failed: %w", err)
@@ -102,7 +127,7 @@ func (e *mangaExecution) handleDesign(ctx context.Context) (*domain.Notification
 }
 
 // handleScript は スクリプトの解析と保存のみを実行します。
-func (e *mangaExecution) handleScript(ctx context.Context) (*domain.NotificationRequest, string, string, *ports.MangaResponse, error) {
+func (e *mangaExecution) handleScript(ctx context.Context) (*domain.NotificationRequest, string, string, *domain.MangaPlot, error) {
 	manga, scriptPath, err := e.runScriptStep(ctx)
 	if err != nil {
 		return nil, "", "", nil, fmt.Errorf("script step failed: %w", err)
@@ -112,11 +137,18 @@ func (e *mangaExecution) handleScript(ctx context.Context) (*domain.Notification
 }
 
 // handlePanel は 既存のJSONデータからパネル画像を生成します。
-func (e *mangaExecution) handlePanel(ctx context.Context) (*domain.NotificationRequest, string, string, *ports.MangaResponse, error) {
-	var manga *ports.MangaResponse
+func (e *mangaExecution) handlePanel(ctx context.Context) (*domain.NotificationRequest, string, string, *domain.MangaPlot, error) {
+	var manga *domain.MangaPlot
 	if err := json.Unmarshal([]byte(e.payload.InputText), &manga); err != nil {
 		return nil, "", "", nil, fmt.Errorf("panel mode input JSON unmarshal failed: %w", err)
 	}
+	if manga == nil {
+		return nil, "", "", nil, fmt.Errorf("panel mode requires manga data in InputText")
+	}
+	if err := manga.Validate(); err != nil {
+		return nil, "", "", nil, fmt.Errorf("panel mode input has invalid panel settings: %w", err)
+	}
+	manga.Normalize()
 
 	if _, err := e.runPanelAndPublishSteps(ctx, manga); err != nil {
 		return nil, "", "", manga, err
@@ -127,8 +159,8 @@ func (e *mangaExecution) handlePanel(ctx context.Context) (*domain.NotificationR
 }
 
 // handlePage は 既存のパネルデータから最終ページ画像を構成します。
-func (e *mangaExecution) handlePage(ctx context.Context) (*domain.NotificationRequest, string, string, *ports.MangaResponse, error) {
-	var manga *ports.MangaResponse
+func (e *mangaExecution) handlePage(ctx context.Context) (*domain.NotificationRequest, string, string, *domain.MangaPlot, error) {
+	var manga *domain.MangaPlot
 	if e.payload.InputText != "" {
 		if err := json.Unmarshal([]byte(e.payload.InputText), &manga); err != nil {
 			return nil, "", "", nil, fmt.Errorf("page mode input JSON unmarshal failed: %w", err)
@@ -137,6 +169,10 @@ func (e *mangaExecution) handlePage(ctx context.Context) (*domain.NotificationRe
 	if manga == nil {
 		return nil, "", "", nil, fmt.Errorf("page mode requires manga data in InputText")
 	}
+	if err := manga.Validate(); err != nil {
+		return nil, "", "", nil, fmt.Errorf("page mode input has invalid panel settings: %w", err)
+	}
+	manga.Normalize()
 
 	if _, err := e.runPageStep(ctx, manga); err != nil {
 		return nil, "", "", manga, fmt.Errorf("page step failed: %w", err)
@@ -150,10 +186,95 @@ func (e *mangaExecution) handlePage(ctx context.Context) (*domain.NotificationRe
 	return req, url, uri, manga, nil
 }
 
+// handleRegenerate は 既存の作品を改訂した台本で更新します。
+// 保存済みの台本と比べ、話者か描写が変わったパネルの画像だけを生成し直し、セリフなどだけが変わったパネルは写植し直します。
+// ローカル写植を使わない場合は、セリフなどだけが変わったパネルも画像を生成し直します。
+// ページ画像のある作品 (ComposePages) では、変更したパネルを含むページとコマ割りを変更したページだけを作り直します。
+// 作業ディレクトリは新しく作らず、Title の作品を上書きで更新します。
+func (e *mangaExecution) handleRegenerate(ctx context.Context) (*domain.NotificationRequest, string, string, *domain.MangaPlot, error) {
+	if !validTitleName.MatchString(e.payload.Title) {
+		return nil, "", "", nil, fmt.Errorf("regenerate mode requires a valid title: %q", e.payload.Title)
+	}
+	// 入力の検証に失敗した場合も作品のジョブ記録に結果を残すため、先に作業ディレクトリを確定します
+	e.resolvedSafeTitle = e.payload.Title
+	e.loadBaseJob(ctx, nil)
+
+	var manga *domain.MangaPlot
+	if err := json.Unmarshal([]byte(e.payload.InputText), &manga); err != nil {
+		return nil, "", "", nil, fmt.Errorf("regenerate mode input JSON unmarshal failed: %w", err)
+	}
+	if manga == nil {
+		return nil, "", "", nil, fmt.Errorf("regenerate mode requires manga data in InputText")
+	}
+	if err := manga.Validate(); err != nil {
+		return nil, "", "", nil, fmt.Errorf("regenerate mode input has invalid panel settings: %w", err)
+	}
+	manga.Normalize()
+
+	// 1. 保存済みの台本との差分
+	plotFile := e.resolvePlotFileURL(manga)
+	stored, err := e.titles.LoadPlot(ctx, plotFile)
+	if err != nil {
+		return nil, "", "", nil, fmt.Errorf("failed to load stored plot: %w", err)
+	}
+	if stored == nil {
+		return nil, "", "", nil, fmt.Errorf("stored plot not found: %s", plotFile)
+	}
+	diff, err := domain.DiffPlots(stored, manga, e.cfg.MaxPanelsPerPage)
+	if err != nil {
+		return nil, "", "", nil, fmt.Errorf("failed to diff plots: %w", err)
+	}
+	if !e.payload.ComposePages {
+		diff.Pages = nil
+	}
+	// ローカル写植を使わない場合、セリフはモデルが画像に描いているため、写植し直さずにパネルごと生成し直します
+	if !e.cfg.LocalLettering {
+		diff = diff.RedrawDialogue()
+	}
+	e.diff = &diff
+	slog.InfoContext(ctx, "Plot diff", "title", e.resolvedSafeTitle, "changes", diff.Summary())
+
+	// 2. 改訂した台本の保存（画像の作り直しに失敗しても編集内容は残します）
+	// 画像パスは生成のたびに書き換わるため、保存済みの台本のものを引き継ぎます
+	for i := range manga.Panels {
+		manga.Panels[i].ReferenceURL = stored.Panels[i].ReferenceURL
+	}
+	if err := e.titles.SavePlot(ctx, plotFile, manga); err != nil {
+		return nil, "", "", manga, fmt.Errorf("failed to save plot: %w", err)
+	}
+
+	// 3. パネルの再生成・写植し直しと公開用 HTML の更新
+	if len(diff.Panels) > 0 {
+		if _, err := e.runRegeneratePanelsStep(ctx, manga, diff.Panels); err != nil {
+			return nil, "", "", manga, fmt.Errorf("panel regeneration step failed: %w", err)
+		}
+	}
+	if len(diff.Dialogue) > 0 {
+		if err := e.runReletterPanelsStep(ctx, manga, diff.Dialogue); err != nil {
+			return nil, "", "", manga, fmt.Errorf("panel lettering step failed: %w", err)
+		}
+	}
+	if len(diff.Panels) > 0 || len(diff.Dialogue) > 0 {
+		if _, err := e.runPublishStep(ctx, manga); err != nil {
+			return nil, "", "", manga, fmt.Errorf("publish step failed: %w", err)
+		}
+	}
+
+	// 4. ページの作り直し
+	if len(diff.Pages) > 0 {
+		if _, err := e.runRegeneratePagesStep(ctx, manga, diff.Pages); err != nil {
+			return nil, "", "", manga, fmt.Errorf("page regeneration step failed: %w", err)
+		}
+	}
+
+	req, url, uri := e.buildMangaNotification(manga)
+	return req, url, uri, manga, nil
+}
+
 // --- Helper Methods ---
 
 // handleFailure はエラー発生時の通知ロジックをカプセル化します。
-func (e *mangaExecution) handleFailure(ctx context.Context, manga *ports.MangaResponse, err error) {
+func (e *mangaExecution) handleFailure(ctx context.Context, manga *domain.MangaPlot, err error) {
 	titleHint := ""
 	if manga != nil {
 		titleHint = manga.Title
@@ -164,6 +285,118 @@ func (e *mangaExecution) handleFailure(ctx context.Context, manga *ports.MangaRe
 	e.notifyError(ctx, e.payload, err, titleHint)
 }
 
+// markStep はジョブ記録を、工程 step の実行中として更新します。
+// 画面で生成途中の作品を表示するための記録のため、保存に失敗しても処理は継続します。
+func (e *mangaExecution) markStep(ctx context.Context, step domain.JobStep, manga *domain.MangaPlot) {
+	e.step = step
+	rec := e.jobRecord(manga)
+	rec.Status = domain.JobRunning
+	e.saveJob(ctx, manga, rec)
+}
+
+// recordJob は作品の作業ディレクトリに、ジョブの結果を記録します。
+// 一覧表示のための付随情報のため、保存に失敗しても処理は継続します。
+// 既存の作品を更新するジョブは、受付時の記録 (queued) を残さないよう、工程を始める前の失敗も記録します。
+func (e *mangaExecution) recordJob(ctx context.Context, manga *domain.MangaPlot, err error) {
+	if (e.step == "" && e.baseJob == nil) || e.resolvedSafeTitle == "" {
+		return
+	}
+
+	rec := e.jobRecord(manga)
+	rec.Status = domain.JobSucceeded
+	if err != nil {
+		rec.Status = domain.JobFailed
+		rec.Error = truncateRunes(err.Error(), maxJobErrorLength)
+	}
+	e.saveJob(ctx, manga, rec)
+}
+
+// jobRecord は現在の実行状態からジョブ記録を組み立てます。
+// 既存の作品を更新するジョブは、一覧表示に使う作成日時などを保つため、作品を作成したジョブの記録を引き継ぎます。
+func (e *mangaExecution) jobRecord(manga *domain.MangaPlot) domain.JobRecord {
+	rec := domain.JobRecord{
+		Command:      e.payload.Command,
+		Mode:         e.payload.Mode,
+		SourceURL:    e.payload.ScriptURL,
+		Owner:        e.payload.Owner,
+		ColorMode:    e.payload.ColorMode,
+		PageRenderer: e.payload.PageRenderer,
+		CreatedAt:    e.startTime,
+	}
+	if e.baseJob != nil {
+		rec = *e.baseJob
+		rec.Error = ""
+	}
+	rec.Changes = e.diff
+	rec.Step = e.step
+	rec.UpdatedAt = time.Now()
+	if manga != nil {
+		rec.Panels = len(manga.Panels)
+	}
+	return rec
+}
+
+// loadBaseJob は更新する作品のジョブ記録を読み込みます。記録がない (古い作品) 場合や読み込めない場合は、このジョブの記録を新たに作ります。
+func (e *mangaExecution) loadBaseJob(ctx context.Context, manga *domain.MangaPlot) {
+	rec, err := e.titles.LoadJob(ctx, e.jobFile(manga))
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to load job record", "title", e.resolvedSafeTitle, "error", err)
+		return
+	}
+	e.baseJob = rec
+}
+
+func (e *mangaExecution) saveJob(ctx context.Context, manga *domain.MangaPlot, rec domain.JobRecord) {
+	jobFile := e.jobFile(manga)
+	if err := e.titles.SaveJob(ctx, jobFile, rec); err != nil {
+		slog.WarnContext(ctx, "Failed to save job record", "path", jobFile, "status", rec.Status, "error", err)
+	}
+}
+
+// jobFile は作業ディレクトリのジョブ記録 (job.json) のフルパスです。
+func (e *mangaExecution) jobFile(manga *domain.MangaPlot) string {
+	return e.cfg.GetGCSObjectURL(path.Join(e.resolveWorkDir(manga), domain.JobRecordFile))
+}
+
+// indexTitle は作品の台本を全文検索の索引に登録します。
+// 索引は CLI で作り直せるため、登録に失敗しても処理は継続します。
+func (e *mangaExecution) indexTitle(ctx context.Context, manga *domain.MangaPlot) {
+	if manga == nil || e.resolvedSafeTitle == "" {
+		return
+	}
+	if err := e.indexer.IndexTitle(ctx, e.resolvedSafeTitle, manga); err != nil {
+		slog.WarnContext(ctx, "Failed to update search index", "title", e.resolvedSafeTitle, "error", err)
+	}
+}
+
+// applyMeta は通知に依頼者と、作品のメタデータ (meta.json) の表示タイトル・タグ・担当者を反映します。
+func (e *mangaExecution) applyMeta(ctx context.Context, req *domain.NotificationRequest, manga *domain.MangaPlot) {
+	if req == nil {
+		return
+	}
+	req.Owner = e.payload.Owner
+	if manga == nil || e.resolvedSafeTitle == "" {
+		return
+	}
+
+	metaFile := e.cfg.GetGCSObjectURL(path.Join(e.resolveWorkDir(manga), domain.MetaFile))
+	meta, err := e.titles.LoadMeta(ctx, metaFile)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to load title meta", "path", metaFile, "error", err)
+		return
+	}
+	if meta == nil {
+		return
+	}
+	if meta.DisplayTitle != "" {
+		req.TargetTitle = meta.DisplayTitle
+	}
+	if meta.Owner != "" {
+		req.Owner = meta.Owner
+	}
+	req.Tags = meta.Tags
+}
+
 // notifySuccess は成功時の通知を実行します。
 func (e *mangaExecution) notifySuccess(ctx context.Context, req *domain.NotificationRequest, url, uri string) {
 	if req == nil {
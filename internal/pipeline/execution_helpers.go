@@ -4,20 +4,28 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"path"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/shouni/go-manga-kit/asset"
-	"github.com/shouni/go-manga-kit/ports"
+
+	"ap-manga-web/internal/domain"
 )
 
 var jst = time.FixedZone("Asia/Tokyo", 9*60*60)
 
+// maxJobErrorLength はジョブ記録に残すエラーメッセージの最大文字数です。
+const maxJobErrorLength = 500
+
+// validTitleName は作業ディレクトリ名として受け付ける文字列です。
+var validTitleName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
 // --- Path Resolvers ---
 
 // resolveWorkDir は、漫画のワークディレクトリパスを解決します。
-func (e *mangaExecution) resolveWorkDir(manga *ports.MangaResponse) string {
+func (e *mangaExecution) resolveWorkDir(manga *domain.MangaPlot) string {
 	title := ""
 	if manga != nil {
 		title = manga.Title
@@ -27,12 +35,12 @@ func (e *mangaExecution) resolveWorkDir(manga *ports.MangaResponse) string {
 }
 
 // resolveOutputURL は、出力先ディレクトリのフルURLを取得します。
-func (e *mangaExecution) resolveOutputURL(manga *ports.MangaResponse) string {
+func (e *mangaExecution) resolveOutputURL(manga *domain.MangaPlot) string {
 	return e.cfg.GetGCSObjectURL(e.resolveWorkDir(manga))
 }
 
 // resolvePlotFileURL は、プロットファイル（JSON）のフルパスを解決します。
-func (e *mangaExecution) resolvePlotFileURL(manga *ports.MangaResponse) string {
+func (e *mangaExecution) resolvePlotFileURL(manga *domain.MangaPlot) string {
 	filePath := path.Join(e.resolveWorkDir(manga), asset.DefaultMangaPlotJson)
 	return e.cfg.GetGCSObjectURL(filePath)
 }
@@ -102,3 +110,12 @@ func parseCSV(input string) []string {
 	}
 	return res
 }
+
+// truncateRunes は s が n 文字を超える場合に、先頭 n 文字に切り詰めて「…」を付けます。
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
@@ -5,11 +5,14 @@ import (
 	"fmt"
 
 	"github.com/shouni/go-manga-kit/ports"
+
+	"ap-manga-web/internal/domain"
 )
 
 // runScriptStep はスクリプト生成フェーズを実行し、生成された台本をJSONとしてGCSに保存します。
-func (e *mangaExecution) runScriptStep(ctx context.Context) (*ports.MangaResponse, string, error) {
+func (e *mangaExecution) runScriptStep(ctx context.Context) (*domain.MangaPlot, string, error) {
 	plotFile := e.resolvePlotFileURL(nil)
+	e.markStep(ctx, domain.JobStepScript, nil)
 	manga, err := e.workflows.Script(ctx, e.payload.ScriptURL, e.payload.Mode, plotFile)
 	if err != nil {
 		return nil, "", fmt.Errorf("ScriptRunnerの実行に失敗しました: %w", err)
@@ -18,19 +21,20 @@ func (e *mangaExecution) runScriptStep(ctx context.Context) (*ports.MangaRespons
 }
 
 // runPanelStep は台本に基づき画像を生成・保存し、更新された台本を返します。
-func (e *mangaExecution) runPanelStep(ctx context.Context, manga *ports.MangaResponse) (*ports.MangaResponse, error) {
+func (e *mangaExecution) runPanelStep(ctx context.Context, manga *domain.MangaPlot) (*domain.MangaPlot, error) {
 	plotFile := e.resolvePlotFileURL(manga)
+	e.markStep(ctx, domain.JobStepPanel, manga)
 
-	return e.workflows.Panel(ctx, manga, plotFile)
+	return e.workflows.Panel(ctx, manga, plotFile, e.imageOptions)
 }
 
 // runPublishStep は漫画データを統合し、HTML等を出力します。
-func (e *mangaExecution) runPublishStep(ctx context.Context, manga *ports.MangaResponse) (*ports.PublishResult, error) {
+func (e *mangaExecution) runPublishStep(ctx context.Context, manga *domain.MangaPlot) (*ports.PublishResult, error) {
 	return e.workflows.Publish(ctx, manga, e.resolveOutputURL(manga))
 }
 
 // runPanelAndPublishSteps は一連の流れを管理します。
-func (e *mangaExecution) runPanelAndPublishSteps(ctx context.Context, manga *ports.MangaResponse) (*ports.PublishResult, error) {
+func (e *mangaExecution) runPanelAndPublishSteps(ctx context.Context, manga *domain.MangaPlot) (*ports.PublishResult, error) {
 	// 1. パネル生成＆保存（画像パスが書き込まれた新しい台本を受け取る）
 	updatedManga, err := e.runPanelStep(ctx, manga)
 	if err != nil {
@@ -46,9 +50,10 @@ func (e *mangaExecution) runPanelAndPublishSteps(ctx context.Context, manga *por
 }
 
 // runPageStep はMangaResponseからページ画像を生成します。
-func (e *mangaExecution) runPageStep(ctx context.Context, manga *ports.MangaResponse) ([]string, error) {
+func (e *mangaExecution) runPageStep(ctx context.Context, manga *domain.MangaPlot) ([]string, error) {
 	plotFile := e.resolvePlotFileURL(manga)
-	pagePaths, err := e.workflows.Page(ctx, manga, plotFile)
+	e.markStep(ctx, domain.JobStepPage, manga)
+	pagePaths, err := e.workflows.Page(ctx, manga, plotFile, e.imageOptions)
 	if err != nil {
 		return nil, fmt.Errorf("PageImageRunner による生成と保存に失敗しました: %w", err)
 	}
@@ -56,6 +61,26 @@ func (e *mangaExecution) runPageStep(ctx context.Context, manga *ports.MangaResp
 	return pagePaths, nil
 }
 
+// runRegeneratePanelsStep は indices のパネル画像だけを生成し直し、更新された台本を返します。
+func (e *mangaExecution) runRegeneratePanelsStep(ctx context.Context, manga *domain.MangaPlot, indices []int) (*domain.MangaPlot, error) {
+	plotFile := e.resolvePlotFileURL(manga)
+	e.markStep(ctx, domain.JobStepPanel, manga)
+	return e.workflows.RegeneratePanels(ctx, manga, plotFile, indices, e.imageOptions)
+}
+
+// runReletterPanelsStep は indices のパネル画像に写植し直します。
+func (e *mangaExecution) runReletterPanelsStep(ctx context.Context, manga *domain.MangaPlot, indices []int) error {
+	e.markStep(ctx, domain.JobStepPanel, manga)
+	return e.workflows.ReletterPanels(ctx, manga, indices)
+}
+
+// runRegeneratePagesStep はページ番号 pages のページ画像だけを作り直します。
+func (e *mangaExecution) runRegeneratePagesStep(ctx context.Context, manga *domain.MangaPlot, pages []int) ([]string, error) {
+	plotFile := e.resolvePlotFileURL(manga)
+	e.markStep(ctx, domain.JobStepPage, manga)
+	return e.workflows.RegeneratePages(ctx, manga, plotFile, pages, e.imageOptions)
+}
+
 // runDesignStep はデザインシート生成します。
 func (e *mangaExecution) runDesignStep(ctx context.Context) (string, int64, error) {
 	charIDs := parseCSV(e.payload.InputText)
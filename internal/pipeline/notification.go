@@ -6,8 +6,6 @@ import (
 	"log/slog"
 	"net/url"
 
-	"github.com/shouni/go-manga-kit/ports"
-
 	"ap-manga-web/internal/domain"
 )
 
@@ -28,6 +26,10 @@ func (e *mangaExecution) notifyError(ctx context.Context, payload domain.Generat
 		OutputCategory: errorReportCategory,
 		TargetTitle:    reqTitle,
 		ExecutionMode:  payload.Command,
+		Owner:          payload.Owner,
+	}
+	if e.diff != nil {
+		req.Changes = e.diff.Summary()
 	}
 
 	if err := e.notifier.NotifyError(ctx, opErr, req); err != nil {
@@ -37,7 +39,7 @@ func (e *mangaExecution) notifyError(ctx context.Context, payload domain.Generat
 
 // buildMangaNotification は漫画生成の結果に基づいてSlack通知用リクエストを構築します。
 func (e *mangaExecution) buildMangaNotification(
-	manga *ports.MangaResponse,
+	manga *domain.MangaPlot,
 ) (*domain.NotificationRequest, string, string) {
 	safeTitle := e.resolveSafeTitle(manga.Title)
 	publicURL, err := url.JoinPath(
@@ -53,16 +55,20 @@ func (e *mangaExecution) buildMangaNotification(
 	workDir := e.resolveWorkDir(manga)
 	storageURI := e.cfg.GetGCSObjectURL(workDir)
 
-	return &domain.NotificationRequest{
+	req := &domain.NotificationRequest{
 		SourceURL:      e.payload.ScriptURL,
 		OutputCategory: "manga-output",
 		TargetTitle:    manga.Title,
 		ExecutionMode:  e.payload.Command + " / " + e.payload.Mode,
-	}, publicURL, storageURI
+	}
+	if e.diff != nil {
+		req.Changes = e.diff.Summary()
+	}
+	return req, publicURL, storageURI
 }
 
 // buildScriptNotification はスクリプト生成の結果に基づいてSlack通知用リクエストを構築します。
-func (e *mangaExecution) buildScriptNotification(manga *ports.MangaResponse, gcsPath string) (*domain.NotificationRequest, string, string) {
+func (e *mangaExecution) buildScriptNotification(manga *domain.MangaPlot, gcsPath string) (*domain.NotificationRequest, string, string) {
 	return &domain.NotificationRequest{
 		SourceURL:      e.payload.ScriptURL,
 		OutputCategory: "script-json",
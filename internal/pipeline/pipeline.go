@@ -14,10 +14,12 @@ type MangaPipeline struct {
 	config    *config.Config
 	workflows domain.Workflows
 	notifier  domain.Notifier
+	titles    domain.TitleStore
+	indexer   domain.SearchIndexer
 }
 
 // NewMangaPipeline は、Container から必要な依存関係のみを抽出して MangaPipeline を生成します。
-func NewMangaPipeline(config *config.Config, workflows domain.Workflows, notifier domain.Notifier) (*MangaPipeline, error) {
+func NewMangaPipeline(config *config.Config, workflows domain.Workflows, notifier domain.Notifier, titles domain.TitleStore, indexer domain.SearchIndexer) (*MangaPipeline, error) {
 	if workflows == nil {
 		return nil, fmt.Errorf("MangaPipelineの初期化に失敗しました: 漫画生成ワークフロー (WorkflowsAdapter) が初期化されていません")
 	}
@@ -26,10 +28,20 @@ func NewMangaPipeline(config *config.Config, workflows domain.Workflows, notifie
 		return nil, fmt.Errorf("MangaPipelineの初期化に失敗しました: 通知コンポーネント (Notifier) が設定されていません")
 	}
 
+	if titles == nil {
+		return nil, fmt.Errorf("MangaPipelineの初期化に失敗しました: 作品の付随ファイルの保存先 (TitleStore) が設定されていません")
+	}
+
+	if indexer == nil {
+		return nil, fmt.Errorf("MangaPipelineの初期化に失敗しました: 検索索引 (SearchIndexer) が設定されていません")
+	}
+
 	return &MangaPipeline{
 		config:    config,
 		workflows: workflows,
 		notifier:  notifier,
+		titles:    titles,
+		indexer:   indexer,
 	}, nil
 }
 
@@ -44,6 +56,8 @@ func (p *MangaPipeline) Execute(ctx context.Context, payload domain.GenerateTask
 		cfg:       p.config,
 		workflows: p.workflows,
 		notifier:  p.notifier,
+		titles:    p.titles,
+		indexer:   p.indexer,
 	}
 
 	return exec.run(ctx)
@@ -0,0 +1,86 @@
+package prompts
+
+import (
+	"slices"
+
+	imagePorts "github.com/shouni/gemini-image-kit/ports"
+	"github.com/shouni/go-manga-kit/ports"
+
+	"ap-manga-web/internal/domain"
+)
+
+// castMember はコマに登場する1人のキャラクターの表示情報です。
+type castMember struct {
+	id         string
+	name       string
+	visualCues []string
+	fileIdx    int // ページ生成時の参照画像インデックス。参照画像がない場合は -1
+}
+
+// resolveCast はコマに登場するキャラクターを表示名・外見・参照画像と対応付けます。
+// rm が nil の場合（単体パネル生成時など）は、参照画像インデックスを解決しません。
+func (pb *ImageBuilder) resolveCast(panel domain.PlotPanel, rm *ports.ResourceMap) []castMember {
+	ids := panel.SpeakerIDs()
+	if len(ids) == 0 {
+		ids = []string{panel.SpeakerID}
+	}
+
+	cast := make([]castMember, 0, len(ids))
+	for _, id := range ids {
+		member := castMember{id: id, name: id, fileIdx: -1}
+		if char := pb.characterMap.GetCharacter(id); char != nil {
+			member.name = char.Name
+			member.visualCues = char.VisualCues
+			if rm != nil {
+				if idx, ok := rm.CharacterFiles[char.ID]; ok {
+					member.fileIdx = idx
+				}
+			}
+		}
+		cast = append(cast, member)
+	}
+	return cast
+}
+
+// addCastReferences はページ内のパネルに登場するキャラクターのうち、rm に参照画像が登録されていないものを登録します。
+// go-manga-kit は代表の話者 (SpeakerID) の参照画像しか登録しないため、同じコマに登場する他のキャラクターの参照画像をここで加えます。
+// rm.OrderedAssets はプロンプトの構築後にそのまま画像生成へ渡されるため、File API の URI がない画像も ReferenceURL から読み込まれます。
+func (pb *ImageBuilder) addCastReferences(panels []ports.Panel, rm *ports.ResourceMap) {
+	if rm == nil || pb.characterMap == nil {
+		return
+	}
+	if rm.CharacterFiles == nil {
+		rm.CharacterFiles = make(map[string]int)
+	}
+	for _, panel := range panels {
+		plotPanel, ok := pb.plot.Lookup(panel)
+		if !ok {
+			continue
+		}
+		for _, id := range plotPanel.SpeakerIDs() {
+			if _, ok := rm.CharacterFiles[id]; ok {
+				continue
+			}
+			char := pb.characterMap.GetCharacter(id)
+			if char == nil || char.ReferenceURL == "" {
+				continue
+			}
+			idx := slices.IndexFunc(rm.OrderedAssets, func(a imagePorts.ImageURI) bool { return a.ReferenceURL == char.ReferenceURL })
+			if idx < 0 {
+				idx = len(rm.OrderedAssets)
+				rm.OrderedAssets = append(rm.OrderedAssets, imagePorts.ImageURI{ReferenceURL: char.ReferenceURL})
+			}
+			rm.CharacterFiles[id] = idx
+		}
+	}
+}
+
+// castName は話者IDに対応する表示名を返します。登場人物に含まれない場合は ID をそのまま返します。
+func castName(cast []castMember, id string) string {
+	for _, c := range cast {
+		if c.id == id {
+			return c.name
+		}
+	}
+	return id
+}
@@ -0,0 +1,51 @@
+package prompts
+
+import (
+	"strings"
+	"testing"
+
+	imagePorts "github.com/shouni/gemini-image-kit/ports"
+	"github.com/shouni/go-character-kit/character"
+	"github.com/shouni/go-manga-kit/ports"
+
+	"ap-manga-web/internal/domain"
+)
+
+func TestBuildPageRegistersSecondarySpeakers(t *testing.T) {
+	chars, err := character.ParseCharacters([]byte(`[
+  {"id": "zundamon", "name": "ずんだもん", "reference_url": "gs://b/zundamon.png", "visual_cues": ["green hair"], "is_default": true},
+  {"id": "metan", "name": "四国めたん", "reference_url": "gs://b/metan.png", "visual_cues": ["twin-tails"]},
+  {"id": "tsumugi", "name": "春日部つむぎ", "visual_cues": ["ponytail"]}
+]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plot := &domain.MangaPlot{Panels: []domain.PlotPanel{
+		{Panel: ports.Panel{Page: 1, SpeakerID: "zundamon", VisualAnchor: "zundamon and metan", Dialogue: "やあ"}, Speakers: []string{"metan", "tsumugi"}},
+	}}
+	pb := NewImageBuilder(chars, nil, "").WithPlot(plot)
+
+	// go-manga-kit は代表の話者の参照画像だけを登録します
+	rm := &ports.ResourceMap{
+		CharacterFiles: map[string]int{"zundamon": 0},
+		PanelFiles:     map[string]int{},
+		OrderedAssets:  []imagePorts.ImageURI{{ReferenceURL: "gs://b/zundamon.png", FileAPIURI: "https://files/zundamon"}},
+	}
+	prompt, _ := pb.BuildPage(plot.Response().Panels, rm)
+
+	if idx, ok := rm.CharacterFiles["metan"]; !ok || idx != 1 || rm.OrderedAssets[1].ReferenceURL != "gs://b/metan.png" {
+		t.Fatalf("metan reference = %d, %v, assets = %+v", idx, ok, rm.OrderedAssets)
+	}
+	if _, ok := rm.CharacterFiles["tsumugi"]; ok || len(rm.OrderedAssets) != 2 {
+		t.Fatalf("character without a reference image was registered: %+v", rm.OrderedAssets)
+	}
+	for _, want := range []string{
+		"- SUBJECT [四国めたん]: Match input_file_1.",
+		"- CHARACTER_IDENTITY: [ 四国めたん ] from input_file_1.",
+		"- SUBJECT: 春日部つむぎ. Traits: {ponytail}.",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt does not contain %q:\n%s", want, prompt)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package prompts
+
+import (
+	"strings"
+
+	"ap-manga-web/internal/domain"
+)
+
+// colorProfile は色表現モードごとに切り替えるプロンプト断片を保持します。
+type colorProfile struct {
+	formatTitle    string   // MangaStructureHeader の見出し
+	formatStyle    string   // 漫画構造ルールの STYLE 行
+	formatRender   string   // 漫画構造ルールの RENDERING 行
+	globalRender   string   // RenderingStyle の RENDERING 行
+	requestTitle   string   // ページ生成リクエストの見出し
+	colorRule      string   // ページ生成リクエストの COLOR 行
+	panelSystem    string   // 単体パネル用システム指示
+	panelTags      string   // 単体パネル用ユーザープロンプトの末尾タグ
+	fallbackTraits string   // キャラクターの外見指定がない場合の既定値
+	negativeDrop   []string // go-manga-kit 固定のネガティブプロンプトから外す語
+	negativeAdd    string   // ネガティブプロンプトに加える語
+}
+
+var colorProfiles = map[domain.ColorMode]colorProfile{
+	domain.ColorModeFullColor: {
+		formatTitle:    "FULL COLOR ANIME MANGA",
+		formatStyle:    "Vibrant Full Color Digital Anime Style. High saturation, cinematic lighting.",
+		formatRender:   "Sharp clean lineart with professional digital coloring. NO screentones.",
+		globalRender:   "Sharp clean lineart, vibrant colors, no blurring, high contrast, cinematic manga lighting.",
+		requestTitle:   "FULL COLOR PAGE PRODUCTION REQUEST",
+		colorRule:      "STRICTLY VIBRANT FULL COLOR. NO monochrome, NO screentones.",
+		panelSystem:    "Create a single high-quality cinematic scene with vibrant digital coloring.",
+		panelTags:      "vibrant full color",
+		fallbackTraits: "vivid anime color palette",
+	},
+	domain.ColorModeMonochrome: {
+		formatTitle:    "MONOCHROME PRINT MANGA",
+		formatStyle:    "Traditional Japanese black-and-white manga. Pure black ink on white paper.",
+		formatRender:   "Sharp inked lineart with halftone screentones for shading. NO color.",
+		globalRender:   "Sharp clean inked lineart, screentone shading, solid blacks, high contrast, no blurring.",
+		requestTitle:   "MONOCHROME PAGE PRODUCTION REQUEST",
+		colorRule:      "STRICTLY BLACK AND WHITE with screentones. NO color, NO grey gradients.",
+		panelSystem:    "Create a single high-quality cinematic scene in black-and-white manga ink style with screentones.",
+		panelTags:      "monochrome manga, black and white, screentone shading",
+		fallbackTraits: "distinctive silhouette and hair shape",
+		negativeDrop:   []string{"monochrome", "black and white", "greyscale", "screentone", "hatching", "dot shades", "ink sketch", "line art only"},
+		negativeAdd:    "full color, colored, vibrant colors, color gradients",
+	},
+	domain.ColorModeLimitedPalette: {
+		formatTitle:    "LIMITED PALETTE MANGA",
+		formatStyle:    "Black ink manga with a single accent color. Flat, print-friendly tones.",
+		formatRender:   "Sharp inked lineart, flat shading, one accent color used sparingly for emphasis.",
+		globalRender:   "Sharp clean lineart, flat two-tone shading, limited palette, high contrast, no blurring.",
+		requestTitle:   "LIMITED PALETTE PAGE PRODUCTION REQUEST",
+		colorRule:      "LIMITED PALETTE: black, white and ONE accent color only. NO full color, NO gradients.",
+		panelSystem:    "Create a single high-quality cinematic scene in ink style with a limited palette of black, white and one accent color.",
+		panelTags:      "limited palette, black ink with one accent color, flat tones",
+		fallbackTraits: "one signature accent color",
+		negativeDrop:   []string{"monochrome", "black and white", "greyscale", "ink sketch", "line art only"},
+		negativeAdd:    "full color, multiple colors, rainbow colors, color gradients",
+	},
+}
+
+// profileFor は指定された色表現モードのプロファイルを返します。未知のモードはフルカラーとして扱います。
+func profileFor(mode domain.ColorMode) colorProfile {
+	if p, ok := colorProfiles[mode]; ok {
+		return p
+	}
+	return colorProfiles[domain.ColorModeFullColor]
+}
+
+// NegativePrompt は go-manga-kit 固定のネガティブプロンプト negative を、色表現モード mode に合わせて書き換えます。
+// 固定の指定はフルカラー前提のため、フルカラー以外ではモードと矛盾する語を外し、モードに応じた除外指定を加えます。
+func NegativePrompt(mode domain.ColorMode, negative string) string {
+	p := profileFor(mode)
+	if len(p.negativeDrop) == 0 && p.negativeAdd == "" {
+		return negative
+	}
+
+	terms := make([]string, 0, strings.Count(negative, ",")+2)
+	for _, term := range strings.Split(negative, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" || containsFold(p.negativeDrop, term) {
+			continue
+		}
+		terms = append(terms, term)
+	}
+	if p.negativeAdd != "" {
+		terms = append(terms, p.negativeAdd)
+	}
+	return strings.Join(terms, ", ")
+}
+
+// containsFold は list に大文字小文字を区別せず s と一致する要素があるかを返します。
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package prompts
+
+import (
+	"strings"
+	"testing"
+
+	"ap-manga-web/internal/domain"
+)
+
+func TestNegativePromptFollowsColorMode(t *testing.T) {
+	// go-manga-kit v1.10.0 のページ用ネガティブプロンプト
+	const pageNegative = "monochrome, black and white, greyscale, screentone, hatching, dot shades, ink sketch, line art only, realistic photos, 3d render, watermark"
+
+	if got := NegativePrompt(domain.ColorModeFullColor, pageNegative); got != pageNegative {
+		t.Errorf("full_color: got %q, want unchanged", got)
+	}
+
+	tests := []struct {
+		mode    domain.ColorMode
+		dropped []string
+		kept    []string
+		added   string
+	}{
+		{
+			mode:    domain.ColorModeMonochrome,
+			dropped: []string{"monochrome", "black and white", "greyscale", "screentone", "hatching", "dot shades"},
+			kept:    []string{"realistic photos", "3d render", "watermark"},
+			added:   "full color",
+		},
+		{
+			mode:    domain.ColorModeLimitedPalette,
+			dropped: []string{"monochrome", "black and white", "greyscale"},
+			kept:    []string{"screentone", "realistic photos", "watermark"},
+			added:   "color gradients",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			got := NegativePrompt(tt.mode, pageNegative)
+			terms := map[string]bool{}
+			for _, term := range strings.Split(got, ",") {
+				terms[strings.TrimSpace(term)] = true
+			}
+			for _, term := range tt.dropped {
+				if terms[term] {
+					t.Errorf("%q contradicts %s: %s", term, tt.mode, got)
+				}
+			}
+			for _, term := range tt.kept {
+				if !terms[term] {
+					t.Errorf("%q was removed: %s", term, got)
+				}
+			}
+			if !terms[tt.added] {
+				t.Errorf("%q was not added: %s", tt.added, got)
+			}
+		})
+	}
+}
@@ -1,32 +1,104 @@
 package prompts
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/shouni/go-manga-kit/ports"
+
+	"ap-manga-web/internal/domain"
 )
 
 const (
 	// CinematicTags クオリティ向上のための共通タグ
 	CinematicTags = "cinematic composition, high resolution, sharp focus, 2k"
 
-	// RenderingStyle は共通の画風を定義します。
-	RenderingStyle = `### GLOBAL VISUAL STYLE ###
-- RENDERING: Sharp clean lineart, vibrant colors, no blurring, high contrast, cinematic manga lighting.`
+	// renderingStyleFormat は共通の画風を定義します。%s には色表現モードごとの RENDERING 指定が入ります。
+	renderingStyleFormat = `### GLOBAL VISUAL STYLE ###
+- RENDERING: %s`
 )
 
 // ImageBuilder は、キャラクター情報を考慮してAIプロンプトを構築します。
 type ImageBuilder struct {
 	characterMap  *ports.Characters
+	layouts       *LayoutCatalog
 	defaultSuffix string // 例: "anime style, high quality"
+	colorMode     domain.ColorMode
+	plot          *domain.MangaPlot
+	// localLettering が true の場合、文字要素はモデルに描かせず、生成後にローカルで写植します。
+	localLettering bool
+	// panelsPerPage は1ページあたりのパネル数です。台本のページごとのレイアウト指定を、描画上のページと対応付けるために使います。
+	panelsPerPage int
 }
 
 // NewImageBuilder は新しい PromptBuilder を生成します。
-func NewImageBuilder(characterMap *ports.Characters, suffix string) *ImageBuilder {
+// layouts が nil の場合、ページは従来の2列グリッドで構成されます。
+func NewImageBuilder(characterMap *ports.Characters, layouts *LayoutCatalog, suffix string) *ImageBuilder {
 	return &ImageBuilder{
 		characterMap:  characterMap,
+		layouts:       layouts,
 		defaultSuffix: suffix,
+		colorMode:     domain.ColorModeFullColor,
+	}
+}
+
+// WithOptions は描画指定を適用した ImageBuilder のコピーを返します。
+// 元のインスタンスは変更されないため、ジョブごとに安全に使い分けることができます。
+func (pb *ImageBuilder) WithOptions(opts domain.ImageOptions) *ImageBuilder {
+	scoped := *pb
+	if opts.ColorMode != "" {
+		scoped.colorMode = opts.ColorMode
+	}
+	return &scoped
+}
+
+// WithPlot は台本の演出指定（レイアウト指定など）を参照する ImageBuilder のコピーを返します。
+func (pb *ImageBuilder) WithPlot(plot *domain.MangaPlot) *ImageBuilder {
+	scoped := *pb
+	scoped.plot = plot
+	return &scoped
+}
+
+// WithLocalLettering は文字要素をモデルに描かせない ImageBuilder のコピーを返します。
+func (pb *ImageBuilder) WithLocalLettering(enabled bool) *ImageBuilder {
+	scoped := *pb
+	scoped.localLettering = enabled
+	return &scoped
+}
+
+// WithPanelsPerPage は1ページあたりのパネル数を n とした ImageBuilder のコピーを返します。0 以下の場合は既定値を使います。
+func (pb *ImageBuilder) WithPanelsPerPage(n int) *ImageBuilder {
+	scoped := *pb
+	scoped.panelsPerPage = n
+	return &scoped
+}
+
+// profile は現在の色表現モードに対応するプロンプト断片を返します。
+func (pb *ImageBuilder) profile() colorProfile {
+	return profileFor(pb.colorMode)
+}
+
+// ColorMode はこの ImageBuilder に適用された色表現モードを返します。
+func (pb *ImageBuilder) ColorMode() domain.ColorMode {
+	return pb.colorMode
+}
+
+// renderingStyle は色表現モードに応じた共通画風セクションを返します。
+func (pb *ImageBuilder) renderingStyle() string {
+	return fmt.Sprintf(renderingStyleFormat, pb.profile().globalRender)
+}
+
+// artisticStyle は defaultSuffix を ARTISTIC STYLE セクションとして整形します。
+// フルカラー以外では、サフィックス内の色指定よりも色表現モードを優先するよう明記します。
+func (pb *ImageBuilder) artisticStyle() string {
+	if pb.defaultSuffix == "" {
+		return ""
+	}
+	section := fmt.Sprintf("### ARTISTIC STYLE ###\n%s", pb.defaultSuffix)
+	if pb.colorMode != domain.ColorModeFullColor {
+		section += fmt.Sprintf("\n- COLOR OVERRIDE: Ignore any color words above. %s", pb.profile().colorRule)
 	}
+	return section
 }
 
 // sanitizeInline は文字列をプロンプトに埋め込む前の最低限の正規化を行います。
@@ -6,6 +6,8 @@ import (
 	"strings"
 
 	"github.com/shouni/go-manga-kit/ports"
+
+	"ap-manga-web/internal/domain"
 )
 
 // --- Constants & Types ---
@@ -13,14 +15,20 @@ const (
 	LabelStandard     = "Standard"
 	LabelFullPage     = "FULL-PAGE"
 	LabelImpact       = "FULL-WIDTH IMPACT"
+	LabelImpactCell   = "IMPACT"
 	PosFullPage       = "Entire page area"
 	PosFullWidth      = "Bottom row, covering the entire width of the page"
 	CompositionImpact = "- COMPOSITION: Cinematic wide shot, high impact focus.\n"
+	SizeSmallNote     = "- SIZE: Small panel. Keep the framing tight and simple (reaction shot or quick beat).\n"
+	SizeLargeNote     = "- SIZE: Large panel. Give this scene generous space and background detail.\n"
+	// NoLetteringNote はローカル写植時に、文字要素の代わりに書き込む指示です。
+	NoLetteringNote = "- TEXT: NONE. Do NOT draw speech bubbles, caption boxes, sound effects or any lettering. Keep the upper corners of this panel as calm background so text can be added later.\n"
 
-	// MangaStructureHeader は漫画の構造に関する基本ルールを定義します。
-	MangaStructureHeader = `### FORMAT RULES: FULL COLOR ANIME MANGA ###
-- STYLE: Vibrant Full Color Digital Anime Style. High saturation, cinematic lighting.
-- RENDERING: Sharp clean lineart with professional digital coloring. NO screentones.
+	// mangaStructureFormat は漫画の構造に関する基本ルールを定義します。
+	// 見出し・STYLE・RENDERING は色表現モードに応じて埋め込まれます。
+	mangaStructureFormat = `### FORMAT RULES: %s ###
+- STYLE: %s
+- RENDERING: %s
 - LAYOUT: Strict multi-panel composition. Use ONLY the specified number of panels.
 - NO FILLER: Do not add extra panels or decorative small frames. Fill the page with the given count.
 - BORDERS: Deep black, crisp frame borders for EVERY panel.
@@ -31,59 +39,71 @@ const (
 // BuildPage はメインのプロンプト構築フローを管理します。
 func (pb *ImageBuilder) BuildPage(panels []ports.Panel, rm *ports.ResourceMap) (string, string) {
 	numPanels := len(panels)
-	bigPanelIdx := pb.calculateBigPanelIndex(numPanels)
+	layout := pb.selectLayout(panels, hintsFromPlot(pb.plot, panels)).plan()
 
 	// 1. システムプロンプトの構築
 	systemPrompt := pb.buildSystemPrompt()
 
 	// 2. ユーザープロンプトの構築
+	pb.addCastReferences(panels, rm)
 	var us strings.Builder
 	pb.writeBasicRequirements(&us, numPanels)
-	pb.writeLayoutStructure(&us, numPanels)
+	pb.writeLayoutStructure(&us, layout)
 	pb.writeCharacterReferences(&us, rm)
-	pb.writePanelBreakdown(&us, panels, rm, bigPanelIdx)
+	pb.writePanelBreakdown(&us, panels, rm, layout)
 
 	return us.String(), systemPrompt
 }
 
+// PageLayout は BuildPage と同じ規則で、ページに適用されるレイアウトテンプレートを返します。
+// 生成後の画像に対してコマ単位の処理（写植など）を行う際に使用します。
+func (pb *ImageBuilder) PageLayout(panels []ports.Panel) LayoutTemplate {
+	return pb.selectLayout(panels, hintsFromPlot(pb.plot, panels))
+}
+
+// selectLayout は台本の明示指定・パネルごとの演出指定・パネル数に基づいて、ページのレイアウトテンプレートを選択します。
+// ページごとの明示指定は、先頭のパネルが描画されるページ (MangaPlot.PageOf) で引きます。
+func (pb *ImageBuilder) selectLayout(panels []ports.Panel, hints []panelHint) LayoutTemplate {
+	name := ""
+	if pb.plot != nil && len(panels) > 0 {
+		name = pb.plot.LayoutFor(pb.plot.PageOf(panels[0], pb.panelsPerPage))
+	}
+	return pb.layouts.Select(name, panels, hints)
+}
+
 // buildSystemPrompt 一貫性を保つために、定義済みの指示、スタイル、タグを組み込んだシステム プロンプト文字列を構築します。
 func (pb *ImageBuilder) buildSystemPrompt() string {
 	const instr = "You are a master digital artist. You MUST follow the exact panel count and layout rules. Character identity MUST match the character master reference files."
-	parts := []string{instr, MangaStructureHeader, RenderingStyle, CinematicTags}
-	if pb.defaultSuffix != "" {
-		parts = append(parts, fmt.Sprintf("### ARTISTIC STYLE ###\n%s", pb.defaultSuffix))
+	profile := pb.profile()
+	header := fmt.Sprintf(mangaStructureFormat, profile.formatTitle, profile.formatStyle, profile.formatRender)
+	parts := []string{instr, header, pb.renderingStyle(), CinematicTags}
+	if style := pb.artisticStyle(); style != "" {
+		parts = append(parts, style)
 	}
 	return strings.Join(parts, "\n\n")
 }
 
 // writeBasicRequirements フォーマットされた基本要件セクションを生成し、提供された文字列ビルダーに追加します。
 func (pb *ImageBuilder) writeBasicRequirements(w *strings.Builder, num int) {
-	w.WriteString("# FULL COLOR PAGE PRODUCTION REQUEST\n")
+	profile := pb.profile()
+	fmt.Fprintf(w, "# %s\n", profile.requestTitle)
 	w.WriteString("- OUTPUT: ONE single portrait manga page image.\n")
-	w.WriteString("- COLOR: STRICTLY VIBRANT FULL COLOR. NO monochrome, NO screentones.\n")
+	fmt.Fprintf(w, "- COLOR: %s\n", profile.colorRule)
+	if pb.localLettering {
+		w.WriteString("- LETTERING: NO text, NO speech bubbles, NO sound effects anywhere on the page. Lettering is added in post-production.\n")
+	}
 	fmt.Fprintf(w, "- PANEL COUNT: [ %d ] (STRICTLY ONLY %d PANELS. DO NOT ADD ANY MORE).\n\n", num, num)
 }
 
 // writeLayoutStructure フォーマットされたレイアウト構造を生成し、提供された文字列ビルダーに追加します。
-func (pb *ImageBuilder) writeLayoutStructure(w *strings.Builder, num int) {
+func (pb *ImageBuilder) writeLayoutStructure(w *strings.Builder, layout pageLayout) {
 	w.WriteString("## MANDATORY PAGE STRUCTURE\n")
 	w.WriteString("- READING ORDER: Japanese Style (Right-to-Left, then Top-to-Bottom).\n")
 	w.WriteString("- PANEL PLACEMENT MAP:\n")
 
-	if num == 1 {
-		w.WriteString("  * PANEL 1: SINGLE FULL-PAGE PANEL (covers entire image area).\n")
-	} else {
-		for i := 0; i < num; i++ {
-			if num%2 == 1 && i == num-1 {
-				fmt.Fprintf(w, "  * PANEL %d: BOTTOM ROW, FULL-WIDTH.\n", i+1)
-			} else {
-				row, side := (i/2)+1, "RIGHT"
-				if i%2 == 1 {
-					side = "LEFT"
-				}
-				fmt.Fprintf(w, "  * PANEL %d: ROW %d, %s column.\n", i+1, row, side)
-			}
-		}
+	single := len(layout.slots) == 1
+	for i, slot := range layout.slots {
+		fmt.Fprintf(w, "  * PANEL %d: %s.\n", i+1, slot.placement(single))
 	}
 	w.WriteString("- FRAME STYLE: Deep black borders. GUTTERS: Pure white.\n\n")
 }
@@ -104,7 +124,7 @@ func (pb *ImageBuilder) writeCharacterReferences(w *strings.Builder, rm *ports.R
 	sort.Slice(refs, func(i, j int) bool { return refs[i].idx < refs[j].idx })
 
 	for _, r := range refs {
-		name, cues := r.id, "vivid anime color palette"
+		name, cues := r.id, pb.profile().fallbackTraits
 		if char := pb.characterMap.GetCharacter(r.id); char != nil {
 			name = char.Name
 			if len(char.VisualCues) > 0 {
@@ -117,26 +137,23 @@ func (pb *ImageBuilder) writeCharacterReferences(w *strings.Builder, rm *ports.R
 }
 
 // writePanelBreakdown 個々のパネルのフォーマットされた内訳を生成し、提供された文字列ビルダーに追加します。
-func (pb *ImageBuilder) writePanelBreakdown(w *strings.Builder, panels []ports.Panel, rm *ports.ResourceMap, bigIdx int) {
-	num := len(panels)
+func (pb *ImageBuilder) writePanelBreakdown(w *strings.Builder, panels []ports.Panel, rm *ports.ResourceMap, layout pageLayout) {
+	single := len(panels) == 1
 	w.WriteString("## PANEL BREAKDOWN\n")
 	for i, panel := range panels {
 		panelNum := i + 1
+		slot := layout.slots[i]
 
 		// メタデータ準備
-		var label, pos, extraInstruction string
-		if i == bigIdx {
-			label, pos = (map[bool]string{true: LabelFullPage, false: LabelImpact})[num == 1], (map[bool]string{true: PosFullPage, false: PosFullWidth})[num == 1]
-			if num > 1 {
-				extraInstruction = CompositionImpact
-			}
-		} else {
-			label = LabelStandard
-			side := "RIGHT"
-			if i%2 == 1 {
-				side = "LEFT"
-			}
-			pos = fmt.Sprintf("Row %d, %s column", (i/2)+1, side)
+		var extraInstruction string
+		label, pos := LabelStandard, slot.position(single)
+		switch {
+		case single:
+			label = LabelFullPage
+		case slot.impact && slot.fullWidth:
+			label, extraInstruction = LabelImpact, CompositionImpact
+		case slot.impact:
+			label, extraInstruction = LabelImpactCell, CompositionImpact
 		}
 
 		// 出力処理 (構造を維持するために順序を制御)
@@ -146,69 +163,123 @@ func (pb *ImageBuilder) writePanelBreakdown(w *strings.Builder, panels []ports.P
 			w.WriteString(extraInstruction)
 		}
 
-		// キャラクターIDと表示名の取得
-		displayName := panel.SpeakerID
-		charFileIdx := -1
-		if char := pb.characterMap.GetCharacter(panel.SpeakerID); char != nil {
-			displayName = char.Name
-			if idx, ok := rm.CharacterFiles[char.ID]; ok {
-				charFileIdx = idx
+		plotPanel, ok := pb.plot.Lookup(panel)
+		if !ok {
+			plotPanel = domain.PlotPanel{Panel: panel}
+		}
+		if !single {
+			switch plotPanel.Size {
+			case domain.SizeSmall:
+				w.WriteString(SizeSmallNote)
+			case domain.SizeLarge:
+				w.WriteString(SizeLargeNote)
 			}
 		}
 
-		if charFileIdx != -1 {
-			fmt.Fprintf(w, "- CHARACTER_IDENTITY: [ %s ] from input_file_%d. (Face, hair, and outfit MUST match input_file_%d exactly).\n", displayName, charFileIdx, charFileIdx)
-		} else {
-			fmt.Fprintf(w, "- SUBJECT: %s\n", displayName)
+		// コマ内の登場人物ごとに、表示名と参照画像を解決
+		cast := pb.resolveCast(plotPanel, rm)
+		for _, c := range cast {
+			if c.fileIdx != -1 {
+				fmt.Fprintf(w, "- CHARACTER_IDENTITY: [ %s ] from input_file_%d. (Face, hair, and outfit MUST match input_file_%d exactly).\n", c.name, c.fileIdx, c.fileIdx)
+			} else if len(c.visualCues) > 0 {
+				fmt.Fprintf(w, "- SUBJECT: %s. Traits: {%s}.\n", c.name, strings.Join(c.visualCues, ", "))
+			} else {
+				fmt.Fprintf(w, "- SUBJECT: %s\n", c.name)
+			}
 		}
 
 		// アクションとポーズ参照
 		sceneDescription := sanitizeInline(panel.VisualAnchor)
-		sceneDescription = strings.ReplaceAll(sceneDescription, panel.SpeakerID, displayName)
+		for _, c := range cast {
+			if c.id != "" {
+				sceneDescription = strings.ReplaceAll(sceneDescription, c.id, c.name)
+			}
+		}
 		fmt.Fprintf(w, "- ACTION: %s\n", sceneDescription)
 
 		if panel.ReferenceURL != "" {
 			if fileIdx, ok := rm.PanelFiles[panel.ReferenceURL]; ok {
 				fmt.Fprintf(w, "- POSE_GUIDE: Use body posture from input_file_%d. IGNORE the character in it.\n", fileIdx)
-				if charFileIdx != -1 {
-					fmt.Fprintf(w, "- MANDATORY: Face and hair MUST be %s from input_file_%d.\n", displayName, charFileIdx)
+				for _, c := range cast {
+					if c.fileIdx != -1 {
+						fmt.Fprintf(w, "- MANDATORY: Face and hair MUST be %s from input_file_%d.\n", c.name, c.fileIdx)
+					}
 				}
 			}
 		}
 
-		// セリフ指示
-		if panel.Dialogue != "" {
-			fmt.Fprintf(w, "- SPEECH: Speech bubble for [%s].\n", displayName)
-			fmt.Fprintf(w, "  - TEXT_TO_RENDER: \"%s\"\n", formatDialogue(panel.Dialogue))
-
-			direction := "Vertical (Tategaki)"
-			layoutDesc := "traditional Japanese manga style layout"
+		// 写植をローカルで行う場合は、文字要素を描かせずに余白だけを確保させる
+		if pb.localLettering {
+			if hasLettering(plotPanel) {
+				w.WriteString(NoLetteringNote)
+			}
+			w.WriteString("\n")
+			continue
+		}
 
-			// 10文字以下の短いセリフや、感嘆符(!?)が多い場合は横書きも検討する指示
-			if len([]rune(panel.Dialogue)) <= 10 && strings.ContainsAny(panel.Dialogue, "!?！？") {
-				// 短い叫びなどはインパクト重視で「横書き」を許可する指示を混ぜる
-				direction = "Horizontal (Yokogaki) or Vertical"
-				layoutDesc = "bold and high impact placement"
+		// セリフ指示（吹き出しごと）
+		lines := plotPanel.DialogueLines()
+		for n, line := range lines {
+			name := castName(cast, line.SpeakerID)
+			if len(lines) == 1 {
+				fmt.Fprintf(w, "- SPEECH: Speech bubble for [%s].\n", name)
+			} else {
+				fmt.Fprintf(w, "- SPEECH %d of %d: Speech bubble for [%s].\n", n+1, len(lines), name)
 			}
+			writeSpeechText(w, line.Text)
+		}
+		if len(lines) > 1 {
+			w.WriteString("- BUBBLE ORDER: One separate bubble per SPEECH above, placed in reading order (right-to-left, top-to-bottom). Each tail MUST point to its own speaker.\n")
+		}
 
-			fmt.Fprintf(w, "  - TEXT_DIRECTION: %s\n", direction)
-			fmt.Fprintf(w, "  - TYPOGRAPHY: Use professional Japanese manga font (Gothic/Mincho). %s.\n", layoutDesc)
-			w.WriteString("  - LANGUAGE: Japanese characters. Ensure accurate rendering of Kanji/Kana.\n")
+		// ナレーションと効果音は吹き出しと区別して指示
+		if narration := strings.TrimSpace(plotPanel.Narration); narration != "" {
+			writeCaption(w, narration)
+		}
+		for _, sfx := range plotPanel.SFX {
+			if sfx = strings.TrimSpace(sfx); sfx != "" {
+				writeSFX(w, sfx)
+			}
 		}
 		w.WriteString("\n")
 	}
 }
 
-// calculateBigPanelIndex は拡大表示するパネルのインデックスを計算して返します。
-// パネル数が1の場合は0を返します。
-// パネル数が1より大きく奇数の場合は、最後のパネルのインデックス (num - 1) を返します。
-// それ以外の場合（0、偶数）は、拡大パネルなしを示す-1を返します。
-func (pb *ImageBuilder) calculateBigPanelIndex(num int) int {
-	if num == 1 {
-		return 0
-	}
-	if num > 1 && num%2 == 1 {
-		return num - 1
+// writeSpeechText は1つの吹き出しに描画するセリフと、その書字方向・書体の指示を書き込みます。
+func writeSpeechText(w *strings.Builder, text string) {
+	fmt.Fprintf(w, "  - TEXT_TO_RENDER: \"%s\"\n", formatDialogue(text))
+
+	direction := "Vertical (Tategaki)"
+	layoutDesc := "traditional Japanese manga style layout"
+
+	// 10文字以下の短いセリフや、感嘆符(!?)が多い場合は横書きも検討する指示
+	if len([]rune(text)) <= 10 && strings.ContainsAny(text, "!?！？") {
+		// 短い叫びなどはインパクト重視で「横書き」を許可する指示を混ぜる
+		direction = "Horizontal (Yokogaki) or Vertical"
+		layoutDesc = "bold and high impact placement"
 	}
-	return -1
+
+	fmt.Fprintf(w, "  - TEXT_DIRECTION: %s\n", direction)
+	fmt.Fprintf(w, "  - TYPOGRAPHY: Use professional Japanese manga font (Gothic/Mincho). %s.\n", layoutDesc)
+	w.WriteString("  - LANGUAGE: Japanese characters. Ensure accurate rendering of Kanji/Kana.\n")
+}
+
+// writeCaption はナレーション用キャプションの指示を書き込みます。吹き出しとは異なる書体・枠で描くよう明記します。
+func writeCaption(w *strings.Builder, text string) {
+	w.WriteString("- CAPTION: Narration box (NOT a speech bubble). Rectangular box with a thin black border, NO tail.\n")
+	fmt.Fprintf(w, "  - TEXT_TO_RENDER: \"%s\"\n", formatDialogue(text))
+	w.WriteString("  - PLACEMENT: Top corner of the panel (top-right for vertical text), not covering faces.\n")
+	w.WriteString("  - TYPOGRAPHY: Mincho (serif) typeface, smaller than speech text, calm and neutral tone.\n")
+}
+
+// writeSFX は描き文字（効果音）の指示を書き込みます。枠や吹き出しを使わず、作画の一部として描くよう明記します。
+func writeSFX(w *strings.Builder, text string) {
+	w.WriteString("- SFX: Hand-drawn sound effect lettering (onomatopoeia) integrated into the artwork. NO bubble, NO box.\n")
+	fmt.Fprintf(w, "  - TEXT_TO_RENDER: \"%s\"\n", formatDialogue(text))
+	w.WriteString("  - TYPOGRAPHY: Bold, dynamic brush lettering with motion and outline, sized to match the intensity of the sound.\n")
+}
+
+// hasLettering はパネルにセリフ・ナレーション・効果音のいずれかがあるかを返します。
+func hasLettering(p domain.PlotPanel) bool {
+	return len(p.DialogueLines()) > 0 || strings.TrimSpace(p.Narration) != "" || len(p.SFX) > 0
 }
@@ -5,25 +5,32 @@ import (
 	"strings"
 
 	"github.com/shouni/go-manga-kit/ports"
+
+	"ap-manga-web/internal/domain"
 )
 
 // BuildPanel は、単体パネル用の UserPrompt と SystemPrompt を生成します。
 func (pb *ImageBuilder) BuildPanel(panel ports.Panel, char *ports.Character) (userPrompt string, systemPrompt string) {
 	// --- 1. System Prompt の構築 ---
-	const mangaSystemInstruction = "You are a professional anime illustrator. Create a single high-quality cinematic scene with vibrant digital coloring."
+	profile := pb.profile()
+	mangaSystemInstruction := "You are a professional anime illustrator. " + profile.panelSystem
 
 	systemParts := []string{
 		mangaSystemInstruction,
-		RenderingStyle,
+		pb.renderingStyle(),
 		CinematicTags,
 	}
-	if pb.defaultSuffix != "" {
-		styleDNA := fmt.Sprintf("### ARTISTIC STYLE ###\n%s", pb.defaultSuffix)
+	if styleDNA := pb.artisticStyle(); styleDNA != "" {
 		systemParts = append(systemParts, styleDNA)
 	}
 	systemPrompt = strings.Join(systemParts, "\n\n")
 
 	// --- 2. User Prompt の構築 ---
+	plotPanel, ok := pb.plot.Lookup(panel)
+	if !ok {
+		plotPanel = domain.PlotPanel{Panel: panel}
+	}
+
 	var visualParts []string
 	speakerID := panel.SpeakerID
 	displayName := speakerID // デフォルトはIDを使用
@@ -41,12 +48,35 @@ func (pb *ImageBuilder) BuildPanel(panel ports.Panel, char *ports.Character) (us
 	}
 	visualParts = append(visualParts, identityBase)
 
+	// 同じコマに登場する他のキャラクター。参照画像は代表の話者のみのため、外見は文章で指定します。
+	cast := pb.resolveCast(plotPanel, nil)
+	names := []string{displayName}
+	for _, c := range cast {
+		if c.id == speakerID || (char != nil && c.id == char.ID) {
+			continue
+		}
+		identity := fmt.Sprintf("%s character", c.name)
+		if len(c.visualCues) > 0 {
+			identity = fmt.Sprintf("%s, %s", identity, strings.Join(c.visualCues, ", "))
+		}
+		visualParts = append(visualParts, identity)
+		names = append(names, c.name)
+	}
+	if len(names) > 1 {
+		visualParts = append(visualParts, fmt.Sprintf("%d characters in the same frame (%s)", len(names), strings.Join(names, " and ")))
+	}
+
 	// 編集者AIが生成した VisualAnchor (アクション・構図・背景)
 	// IDを表示名に置換して結合します。
 	if panel.VisualAnchor != "" {
 		anchor := panel.VisualAnchor
 		if speakerID != "" {
-			anchor = strings.ReplaceAll(panel.VisualAnchor, speakerID, displayName)
+			anchor = strings.ReplaceAll(anchor, speakerID, displayName)
+		}
+		for _, c := range cast {
+			if c.id != "" && c.id != speakerID {
+				anchor = strings.ReplaceAll(anchor, c.id, c.name)
+			}
 		}
 		visualParts = append(visualParts, anchor)
 	} else {
@@ -54,7 +84,16 @@ func (pb *ImageBuilder) BuildPanel(panel ports.Panel, char *ports.Character) (us
 		visualParts = append(visualParts, "character focus, cinematic scene")
 	}
 
-	visualParts = append(visualParts, "vibrant full color", "cinematic lighting", "high quality")
+	// 見せ場として指定されたパネルは、単体画像の段階から迫力のある構図にします。
+	if plotPanel.Emphasis == domain.EmphasisImpact {
+		visualParts = append(visualParts, "dramatic high impact composition", "dynamic angle")
+	}
+
+	if pb.localLettering {
+		visualParts = append(visualParts, "no text", "no speech bubbles")
+	}
+
+	visualParts = append(visualParts, profile.panelTags, "cinematic lighting", "high quality")
 
 	// --- 3. プロンプトのクリーンな結合 ---
 	var cleanParts []string
@@ -0,0 +1,365 @@
+package prompts
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"log/slog"
+	"sort"
+
+	"github.com/shouni/go-manga-kit/ports"
+
+	"ap-manga-web/internal/domain"
+)
+
+// LayoutTemplate はページのコマ割りを定義する名前付きテンプレートです。
+// Rows は各行のセル幅（列数単位）を読み順（右から左）で並べたもので、パネルは先頭のセルから順に割り当てられます。
+type LayoutTemplate struct {
+	Name       string  `json:"name"`
+	PanelCount int     `json:"panel_count"`
+	Columns    int     `json:"columns"`
+	Rows       [][]int `json:"rows"`
+	// ImpactPanel は強調表示するパネル番号 (1始まり) です。0 の場合は強調パネルなしを表します。
+	ImpactPanel int `json:"impact_panel"`
+}
+
+// Validate はテンプレートの行・列・パネル数の整合性を検証します。
+func (t LayoutTemplate) Validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("layout template name is empty")
+	}
+	if t.Columns <= 0 {
+		return fmt.Errorf("layout %q: columns must be positive", t.Name)
+	}
+
+	cells := 0
+	for i, row := range t.Rows {
+		width := 0
+		for _, span := range row {
+			if span <= 0 {
+				return fmt.Errorf("layout %q: row %d has a non-positive span", t.Name, i+1)
+			}
+			width += span
+		}
+		if width != t.Columns {
+			return fmt.Errorf("layout %q: row %d spans %d columns, want %d", t.Name, i+1, width, t.Columns)
+		}
+		cells += len(row)
+	}
+	if cells != t.PanelCount {
+		return fmt.Errorf("layout %q: has %d cells, want panel_count %d", t.Name, cells, t.PanelCount)
+	}
+	if t.ImpactPanel < 0 || t.ImpactPanel > t.PanelCount {
+		return fmt.Errorf("layout %q: impact_panel %d is out of range", t.Name, t.ImpactPanel)
+	}
+	return nil
+}
+
+// ParseLayoutTemplates は JSON 配列形式のテンプレート定義を解析します。
+func ParseLayoutTemplates(data []byte) ([]LayoutTemplate, error) {
+	var templates []LayoutTemplate
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("レイアウトテンプレートの解析に失敗しました: %w", err)
+	}
+	return templates, nil
+}
+
+// LayoutCatalog は名前およびパネル数からレイアウトテンプレートを引くためのカタログです。
+type LayoutCatalog struct {
+	byName  map[string]LayoutTemplate
+	byCount map[int][]LayoutTemplate
+}
+
+// NewLayoutCatalog はテンプレートを検証してカタログを構築します。
+// 同名のテンプレートが複数ある場合は、後に指定されたもの（例: GCS 上の定義）が優先されます。
+func NewLayoutCatalog(templates []LayoutTemplate) (*LayoutCatalog, error) {
+	c := &LayoutCatalog{
+		byName:  make(map[string]LayoutTemplate, len(templates)),
+		byCount: make(map[int][]LayoutTemplate),
+	}
+	for _, t := range templates {
+		if err := t.Validate(); err != nil {
+			return nil, err
+		}
+		c.byName[t.Name] = t
+	}
+
+	names := make([]string, 0, len(c.byName))
+	for name := range c.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := c.byName[name]
+		c.byCount[t.PanelCount] = append(c.byCount[t.PanelCount], t)
+	}
+	return c, nil
+}
+
+// panelHint はレイアウト選択に用いるパネルごとの演出指定です。
+type panelHint struct {
+	impact bool // 見せ場として強調する
+	wide   bool // 全幅、または大きなコマを希望する
+	small  bool // 小さなコマを希望する
+}
+
+// hintsFromPlot は台本上の演出指定をレイアウト選択用のヒントに変換します。
+func hintsFromPlot(plot *domain.MangaPlot, panels []ports.Panel) []panelHint {
+	hints := make([]panelHint, len(panels))
+	for i, panel := range panels {
+		p, ok := plot.Lookup(panel)
+		if !ok {
+			continue
+		}
+		hints[i] = panelHint{
+			impact: p.Emphasis == domain.EmphasisImpact,
+			wide:   p.FullWidth || p.Size == domain.SizeLarge,
+			small:  p.Size == domain.SizeSmall,
+		}
+	}
+	return hints
+}
+
+// Select はページに適用するテンプレートを決定します。
+// 明示された名前がパネル数と一致すればそれを使い、なければ同じパネル数の候補のうち演出指定に最も合うものを選びます。
+// 候補が存在しない場合は、演出指定を反映した2列グリッドにフォールバックします。
+// いずれの場合も、強調指定のあるパネルがテンプレートの強調パネルより優先されます。
+func (c *LayoutCatalog) Select(name string, panels []ports.Panel, hints []panelHint) LayoutTemplate {
+	num := len(panels)
+	if len(hints) != num {
+		hints = make([]panelHint, num)
+	}
+	if c == nil {
+		return defaultLayout(hints)
+	}
+
+	if name != "" {
+		if t, ok := c.byName[name]; ok && t.PanelCount == num {
+			return t.withImpact(hints)
+		}
+		slog.Warn("指定されたレイアウトテンプレートが使用できないため自動選択します", "layout", name, "panels", num)
+	}
+
+	candidates := bestMatches(c.byCount[num], hints)
+	if len(candidates) == 0 {
+		return defaultLayout(hints)
+	}
+	return candidates[pageHash(panels)%uint32(len(candidates))].withImpact(hints)
+}
+
+// bestMatches は演出指定との適合度が最も高いテンプレートを返します。
+// 演出指定がない場合はすべての候補が同点となります。
+func bestMatches(candidates []LayoutTemplate, hints []panelHint) []LayoutTemplate {
+	var best []LayoutTemplate
+	bestScore := 0
+	for _, t := range candidates {
+		score := t.score(hints)
+		switch {
+		case len(best) == 0 || score > bestScore:
+			best, bestScore = []LayoutTemplate{t}, score
+		case score == bestScore:
+			best = append(best, t)
+		}
+	}
+	return best
+}
+
+// score はテンプレートの配置が演出指定にどれだけ合っているかを数値化します。
+func (t LayoutTemplate) score(hints []panelHint) int {
+	score := 0
+	for i, slot := range t.plan().slots {
+		h := hints[i]
+		if h.impact && slot.impact {
+			score += 2
+		}
+		if h.wide {
+			if slot.fullWidth || slot.span > 1 {
+				score++
+			} else {
+				score--
+			}
+		}
+		if h.small && slot.fullWidth {
+			score--
+		}
+	}
+	return score
+}
+
+// withImpact は強調指定のあるパネルを強調パネルとしたテンプレートのコピーを返します。
+func (t LayoutTemplate) withImpact(hints []panelHint) LayoutTemplate {
+	for i, h := range hints {
+		if h.impact {
+			t.ImpactPanel = i + 1
+			break
+		}
+	}
+	return t
+}
+
+// pageHash はページ内容から決定的なハッシュ値を計算します。
+// 同じ台本からは同じレイアウトが選ばれ、ページごとには異なるレイアウトが選ばれやすくなります。
+func pageHash(panels []ports.Panel) uint32 {
+	h := fnv.New32a()
+	for _, p := range panels {
+		_, _ = h.Write([]byte(p.VisualAnchor))
+		_, _ = h.Write([]byte(p.Dialogue))
+	}
+	return h.Sum32()
+}
+
+// defaultLayout は従来のヒューリスティックに基づくレイアウトを生成します。
+// 1行2列で並べ、全幅・大サイズ指定のパネルと相方のいないパネルは1行を占有します。
+// 強調指定がない場合は、最後のパネルが1行を占有していればそれを強調パネルとします（奇数枚のときの従来の挙動）。
+func defaultLayout(hints []panelHint) LayoutTemplate {
+	num := len(hints)
+	if num == 1 {
+		return LayoutTemplate{Name: "default", PanelCount: 1, Columns: 1, Rows: [][]int{{1}}, ImpactPanel: 1}
+	}
+
+	t := LayoutTemplate{Name: "default", PanelCount: num, Columns: 2}
+	lastAlone := false
+	for i := 0; i < num; {
+		if hints[i].wide || i == num-1 || hints[i+1].wide {
+			t.Rows = append(t.Rows, []int{2})
+			lastAlone = i == num-1
+			i++
+			continue
+		}
+		t.Rows = append(t.Rows, []int{1, 1})
+		i += 2
+	}
+	if lastAlone {
+		t.ImpactPanel = num
+	}
+	return t.withImpact(hints)
+}
+
+// Cells はテンプレートの各セルを、bounds 内の矩形としてパネル順に返します。
+// 強調パネルを含む行は他の行の1.5倍の高さとし、セルは読み順（右から左）に並べます。
+// 生成モデルはコマ割りを厳密には再現しないため、AI 生成のページに対しては外枠の余白とコマ間の間隔を見込んだ概算値となります。
+func (t LayoutTemplate) Cells(bounds image.Rectangle) []image.Rectangle {
+	margin := bounds.Dx() * 4 / 100
+	gutter := bounds.Dx() * 2 / 100
+	inner := bounds.Inset(margin)
+	if len(t.Rows) == 0 || t.Columns <= 0 || inner.Empty() {
+		return nil
+	}
+
+	// 行の高さの重み（通常の行 2、強調パネルを含む行 3）
+	weights := make([]int, len(t.Rows))
+	total, panel := 0, 0
+	for r, row := range t.Rows {
+		weights[r] = 2
+		if t.ImpactPanel > panel && t.ImpactPanel <= panel+len(row) {
+			weights[r] = 3
+		}
+		panel += len(row)
+		total += weights[r]
+	}
+
+	height := inner.Dy() - gutter*(len(t.Rows)-1)
+	colWidth := (inner.Dx() - gutter*(t.Columns-1)) / t.Columns
+
+	var cells []image.Rectangle
+	top := inner.Min.Y
+	for r, row := range t.Rows {
+		rowHeight := height * weights[r] / total
+		right := inner.Max.X
+		for _, span := range row {
+			width := colWidth*span + gutter*(span-1)
+			cells = append(cells, image.Rect(right-width, top, right, top+rowHeight))
+			right -= width + gutter
+		}
+		top += rowHeight + gutter
+	}
+	return cells
+}
+
+// panelSlot はレイアウト上の1パネル分の配置情報です。
+type panelSlot struct {
+	row       int
+	lastRow   bool
+	fullWidth bool
+	side      string // RIGHT / LEFT / CENTER
+	span      int
+	columns   int
+	impact    bool
+}
+
+// pageLayout はテンプレートをパネルごとの配置に展開したものです。
+type pageLayout struct {
+	name  string
+	slots []panelSlot
+}
+
+// plan はテンプレートをパネル単位の配置情報に展開します。
+func (t LayoutTemplate) plan() pageLayout {
+	layout := pageLayout{name: t.Name}
+	for r, row := range t.Rows {
+		col := 0
+		for _, span := range row {
+			slot := panelSlot{
+				row:       r + 1,
+				lastRow:   r == len(t.Rows)-1,
+				fullWidth: span == t.Columns,
+				span:      span,
+				columns:   t.Columns,
+				side:      columnSide(col, span, t.Columns),
+			}
+			slot.impact = len(layout.slots)+1 == t.ImpactPanel
+			layout.slots = append(layout.slots, slot)
+			col += span
+		}
+	}
+	return layout
+}
+
+// columnSide は右から数えた列位置を RIGHT / LEFT / CENTER で表します。
+func columnSide(start, span, columns int) string {
+	switch {
+	case start == 0:
+		return "RIGHT"
+	case start+span == columns:
+		return "LEFT"
+	default:
+		return "CENTER"
+	}
+}
+
+// placement は PANEL PLACEMENT MAP 用の配置説明を返します。
+func (s panelSlot) placement(single bool) string {
+	switch {
+	case single:
+		return "SINGLE FULL-PAGE PANEL (covers entire image area)"
+	case s.fullWidth && s.lastRow:
+		return "BOTTOM ROW, FULL-WIDTH"
+	case s.fullWidth:
+		return fmt.Sprintf("ROW %d, FULL-WIDTH", s.row)
+	default:
+		return fmt.Sprintf("ROW %d, %s", s.row, s.column())
+	}
+}
+
+// position は PANEL BREAKDOWN 用の位置説明を返します。
+func (s panelSlot) position(single bool) string {
+	switch {
+	case single:
+		return PosFullPage
+	case s.fullWidth && s.lastRow:
+		return PosFullWidth
+	case s.fullWidth:
+		return fmt.Sprintf("Row %d, covering the entire width of the page", s.row)
+	default:
+		return fmt.Sprintf("Row %d, %s", s.row, s.column())
+	}
+}
+
+// column は列の説明を返します。複数列にまたがる場合はその幅も併記します。
+func (s panelSlot) column() string {
+	if s.span > 1 {
+		return fmt.Sprintf("%s column (spanning %d of %d columns)", s.side, s.span, s.columns)
+	}
+	return fmt.Sprintf("%s column", s.side)
+}
@@ -0,0 +1,156 @@
+package prompts
+
+import (
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/shouni/go-manga-kit/ports"
+
+	"ap-manga-web/assets"
+	"ap-manga-web/internal/domain"
+)
+
+func TestDefaultLayoutMatchesLegacyGrid(t *testing.T) {
+	for num := 1; num <= 7; num++ {
+		layout := defaultLayout(make([]panelHint, num))
+		if err := layout.Validate(); err != nil {
+			t.Fatalf("defaultLayout(%d).Validate() error = %v", num, err)
+		}
+
+		// 従来の挙動: 1枚なら全面、奇数枚なら最後のパネルを全幅で強調、偶数枚なら強調なし
+		want := -1
+		if num == 1 || num%2 == 1 {
+			want = num - 1
+		}
+		for i, slot := range layout.plan().slots {
+			if got := slot.impact; got != (i == want) {
+				t.Fatalf("defaultLayout(%d) panel %d impact = %v, want %v", num, i+1, got, i == want)
+			}
+			if num > 1 && i != num-1 && slot.fullWidth {
+				t.Fatalf("defaultLayout(%d) panel %d is full width, want half width", num, i+1)
+			}
+		}
+	}
+}
+
+func TestDefaultLayoutUsesHints(t *testing.T) {
+	hints := []panelHint{{}, {wide: true}, {impact: true}, {}}
+	layout := defaultLayout(hints)
+	if err := layout.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	slots := layout.plan().slots
+	if !slots[1].fullWidth {
+		t.Fatal("panel 2 should be full width")
+	}
+	if !slots[2].impact || slots[2].fullWidth {
+		t.Fatalf("panel 3 = %+v, want a half-width impact panel", slots[2])
+	}
+}
+
+func TestEmbeddedLayoutTemplatesAreValid(t *testing.T) {
+	templates, err := ParseLayoutTemplates(assets.LayoutTemplates)
+	if err != nil {
+		t.Fatalf("ParseLayoutTemplates() error = %v", err)
+	}
+	if _, err := NewLayoutCatalog(templates); err != nil {
+		t.Fatalf("NewLayoutCatalog() error = %v", err)
+	}
+}
+
+func TestLayoutCatalogSelect(t *testing.T) {
+	catalog, err := NewLayoutCatalog([]LayoutTemplate{
+		{Name: "grid", PanelCount: 2, Columns: 2, Rows: [][]int{{1, 1}}},
+		{Name: "stack", PanelCount: 2, Columns: 1, Rows: [][]int{{1}, {1}}},
+		{Name: "trio", PanelCount: 3, Columns: 1, Rows: [][]int{{1}, {1}, {1}}},
+	})
+	if err != nil {
+		t.Fatalf("NewLayoutCatalog() error = %v", err)
+	}
+	panels := []ports.Panel{{VisualAnchor: "a"}, {VisualAnchor: "b"}}
+
+	if got := catalog.Select("stack", panels, nil).Name; got != "stack" {
+		t.Fatalf("Select(stack) = %q, want stack", got)
+	}
+	// パネル数が合わない指定は無視され、同じパネル数の候補から選ばれる
+	if got := catalog.Select("trio", panels, nil).Name; got != "grid" && got != "stack" {
+		t.Fatalf("Select(trio) = %q, want a 2-panel template", got)
+	}
+	if first, second := catalog.Select("", panels, nil).Name, catalog.Select("", panels, nil).Name; first != second {
+		t.Fatalf("Select() is not deterministic: %q, %q", first, second)
+	}
+	// 全幅指定のあるパネルは全幅のテンプレートが優先される
+	if got := catalog.Select("", panels, []panelHint{{wide: true}, {}}).Name; got != "stack" {
+		t.Fatalf("Select() with wide hint = %q, want stack", got)
+	}
+	if got := catalog.Select("", make([]ports.Panel, 5), nil).Name; got != "default" {
+		t.Fatalf("Select() without candidates = %q, want default", got)
+	}
+}
+
+func TestLayoutTemplateValidate(t *testing.T) {
+	err := LayoutTemplate{Name: "broken", PanelCount: 2, Columns: 2, Rows: [][]int{{1}, {1}}}.Validate()
+	if err == nil || !strings.Contains(err.Error(), "spans 1 columns") {
+		t.Fatalf("Validate() error = %v, want row width error", err)
+	}
+}
+
+func TestLayoutTemplateCells(t *testing.T) {
+	layout := LayoutTemplate{Name: "t", PanelCount: 3, Columns: 2, Rows: [][]int{{1, 1}, {2}}}
+	bounds := image.Rect(0, 0, 1000, 1400)
+	cells := layout.Cells(bounds)
+	if len(cells) != 3 {
+		t.Fatalf("len(Cells()) = %d, want 3", len(cells))
+	}
+
+	// 読み順は右から左のため、1枚目のパネルが右側に来る
+	if cells[0].Min.X <= cells[1].Min.X {
+		t.Fatalf("panel 1 %v should be right of panel 2 %v", cells[0], cells[1])
+	}
+	if cells[2].Dx() <= cells[0].Dx() || cells[2].Min.Y <= cells[0].Max.Y {
+		t.Fatalf("panel 3 %v should be a full-width row below %v", cells[2], cells[0])
+	}
+
+	// 強調パネルを含む行は高くなる
+	impact := layout
+	impact.ImpactPanel = 3
+	if got := impact.Cells(bounds); got[2].Dy() <= got[0].Dy() {
+		t.Fatalf("impact row height = %d, want taller than %d", got[2].Dy(), got[0].Dy())
+	}
+	for i, c := range cells {
+		if !c.In(bounds) || c.Overlaps(cells[(i+1)%len(cells)]) {
+			t.Fatalf("cell %d %v is out of bounds or overlaps", i+1, c)
+		}
+	}
+}
+
+func TestPageLayoutUsesRenderedPage(t *testing.T) {
+	catalog, err := NewLayoutCatalog([]LayoutTemplate{
+		{Name: "grid", PanelCount: 2, Columns: 2, Rows: [][]int{{1, 1}}},
+		{Name: "stack", PanelCount: 2, Columns: 1, Rows: [][]int{{1}, {1}}},
+	})
+	if err != nil {
+		t.Fatalf("NewLayoutCatalog() error = %v", err)
+	}
+	// モデルが付けた page は描画上のページ (2枚ずつ) と一致しない
+	plot := &domain.MangaPlot{
+		PageLayouts: map[int]string{1: "grid", 2: "stack"},
+		Panels: []domain.PlotPanel{
+			{Panel: ports.Panel{Page: 1, VisualAnchor: "a"}},
+			{Panel: ports.Panel{Page: 1, VisualAnchor: "b"}},
+			{Panel: ports.Panel{Page: 1, VisualAnchor: "c"}},
+			{Panel: ports.Panel{Page: 2, VisualAnchor: "d"}},
+		},
+	}
+	pb := NewImageBuilder(nil, catalog, "").WithPlot(plot).WithPanelsPerPage(2)
+	panels := plot.Response().Panels
+
+	if got := pb.PageLayout(panels[:2]).Name; got != "grid" {
+		t.Errorf("page 1 layout = %q, want grid", got)
+	}
+	if got := pb.PageLayout(panels[2:]).Name; got != "stack" {
+		t.Errorf("page 2 layout = %q, want stack", got)
+	}
+}
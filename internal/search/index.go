@@ -0,0 +1,272 @@
+// Package search は manga_plot.json から作った全文検索用の索引を提供します。
+// 索引は作品数百件程度を想定した1つの JSON ファイルで、検索は読み込んだ索引に対する部分一致で行います。
+package search
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/shouni/go-character-kit/character"
+
+	"ap-manga-web/internal/domain"
+)
+
+// IndexFile は BaseOutputDir 直下に保存する索引のファイル名です。
+const IndexFile = "search_index.json"
+
+const (
+	indexVersion = 1
+	// maxHitsPerResult は1作品あたりに返す該当箇所の最大数です。
+	maxHitsPerResult = 5
+	// snippetRadius は該当箇所の前後に表示する文字数です。
+	snippetRadius = 30
+)
+
+// Field は該当箇所の種類です。
+type Field string
+
+const (
+	FieldTitle        Field = "title"
+	FieldDescription  Field = "description"
+	FieldTag          Field = "tag"
+	FieldSpeaker      Field = "speaker"
+	FieldDialogue     Field = "dialogue"
+	FieldNarration    Field = "narration"
+	FieldVisualAnchor Field = "visual_anchor"
+)
+
+// fieldWeights は該当箇所の種類ごとの得点です。タイトルの一致を最も重視します。
+var fieldWeights = map[Field]int{
+	FieldTitle:        10,
+	FieldTag:          5,
+	FieldDescription:  4,
+	FieldDialogue:     3,
+	FieldSpeaker:      2,
+	FieldNarration:    2,
+	FieldVisualAnchor: 1,
+}
+
+// Index は全作品の検索用文書です。
+type Index struct {
+	Version   int        `json:"version"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Documents []Document `json:"documents"`
+}
+
+// Document は1作品分の検索対象のテキストです。
+type Document struct {
+	// Name は作業ディレクトリ名（URL 上のタイトル）です。
+	Name  string `json:"name"`
+	Title string `json:"title"`
+	// DisplayTitle と Tags は meta.json で利用者が設定した値です。
+	DisplayTitle string   `json:"display_title,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	Panels       []Panel  `json:"panels,omitempty"`
+}
+
+// Panel は1パネル分の検索対象のテキストです。
+type Panel struct {
+	// Speakers は登場キャラクターの ID と表示名です。
+	Speakers     []string `json:"speakers,omitempty"`
+	Dialogue     []string `json:"dialogue,omitempty"`
+	Narration    string   `json:"narration,omitempty"`
+	VisualAnchor string   `json:"visual_anchor,omitempty"`
+}
+
+// NewDocument は台本とメタデータから検索用文書を作成します。
+// meta はない場合 nil です。chars はキャラクターの表示名の解決に使用し、nil でも構いません。
+func NewDocument(name string, plot *domain.MangaPlot, meta *domain.TitleMeta, chars *character.Characters) Document {
+	doc := Document{Name: name, Title: plot.Title, Description: plot.Description}
+	if meta != nil {
+		doc.DisplayTitle, doc.Tags = meta.DisplayTitle, meta.Tags
+	}
+	for _, p := range plot.Panels {
+		panel := Panel{Narration: p.Narration, VisualAnchor: p.VisualAnchor}
+		for _, id := range p.SpeakerIDs() {
+			speaker := id
+			if chars != nil {
+				if c := chars.GetCharacter(id); c != nil && c.Name != "" && c.Name != id {
+					speaker = id + " " + c.Name
+				}
+			}
+			panel.Speakers = append(panel.Speakers, speaker)
+		}
+		for _, line := range p.DialogueLines() {
+			panel.Dialogue = append(panel.Dialogue, line.Text)
+		}
+		doc.Panels = append(doc.Panels, panel)
+	}
+	return doc
+}
+
+// NewIndex は空の索引を作成します。
+func NewIndex() *Index {
+	return &Index{Version: indexVersion}
+}
+
+// Put は文書を追加します。同じ作品の文書がある場合は置き換えます。文書は作業ディレクトリ名の順に保ちます。
+func (ix *Index) Put(doc Document) {
+	i, found := slices.BinarySearchFunc(ix.Documents, doc.Name, func(d Document, name string) int {
+		return cmp.Compare(d.Name, name)
+	})
+	if found {
+		ix.Documents[i] = doc
+		return
+	}
+	ix.Documents = slices.Insert(ix.Documents, i, doc)
+}
+
+// Result は検索に一致した作品です。
+type Result struct {
+	Name string
+	// Title は表示タイトルがあればそれを、なければ台本のタイトルです。
+	Title       string
+	Tags        []string
+	Description string
+	Score       int
+	Hits        []Hit
+}
+
+// Hit は作品内の該当箇所です。
+type Hit struct {
+	// Panel はパネル番号 (1始まり) です。タイトル・タグ・概要の場合は 0 です。
+	Panel int
+	Field Field
+	// Snippet は該当箇所の前後を切り出したテキストです。
+	Snippet string
+}
+
+// Search は空白区切りのすべての語を含む作品を、得点の高い順に返します。
+// 大文字と小文字、全角と半角の英数字、カタカナとひらがなは区別しません。
+func (ix *Index) Search(q string) []Result {
+	terms := strings.Fields(fold(q))
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var results []Result
+	for _, doc := range ix.Documents {
+		if r, ok := match(doc, terms); ok {
+			results = append(results, r)
+		}
+	}
+	// 同点の場合は新しい作品（作業ディレクトリ名が大きいもの）を先にします
+	slices.SortStableFunc(results, func(a, b Result) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.Name, a.Name)
+	})
+	return results
+}
+
+// match は文書がすべての語を含むかを判定し、該当箇所を集めます。
+func match(doc Document, terms []string) (Result, bool) {
+	r := Result{Name: doc.Name, Title: cmp.Or(doc.DisplayTitle, doc.Title), Tags: doc.Tags, Description: doc.Description}
+	found := make([]bool, len(terms))
+
+	check := func(panel int, field Field, text string) {
+		if text == "" {
+			return
+		}
+		folded := []rune(fold(text))
+		hit := -1
+		for i, term := range terms {
+			if pos := runeIndex(folded, []rune(term)); pos >= 0 {
+				found[i] = true
+				if hit < 0 {
+					hit = pos
+				}
+			}
+		}
+		if hit < 0 {
+			return
+		}
+		r.Score += fieldWeights[field]
+		if len(r.Hits) < maxHitsPerResult {
+			r.Hits = append(r.Hits, Hit{Panel: panel, Field: field, Snippet: snippet([]rune(text), hit)})
+		}
+	}
+
+	check(0, FieldTitle, doc.DisplayTitle)
+	check(0, FieldTitle, doc.Title)
+	for _, tag := range doc.Tags {
+		check(0, FieldTag, tag)
+	}
+	check(0, FieldDescription, doc.Description)
+	for i, p := range doc.Panels {
+		for _, line := range p.Dialogue {
+			check(i+1, FieldDialogue, line)
+		}
+		check(i+1, FieldNarration, p.Narration)
+		for _, s := range p.Speakers {
+			check(i+1, FieldSpeaker, s)
+		}
+		check(i+1, FieldVisualAnchor, p.VisualAnchor)
+	}
+
+	for _, ok := range found {
+		if !ok {
+			return Result{}, false
+		}
+	}
+	return r, true
+}
+
+// FirstPanel は最初に該当したパネルの番号を返します。パネルに該当箇所がない場合は 0 を返します。
+func (r Result) FirstPanel() int {
+	for _, h := range r.Hits {
+		if h.Panel > 0 {
+			return h.Panel
+		}
+	}
+	return 0
+}
+
+// fold は比較用に文字を正規化します。1文字を1文字に変換するため、変換後の位置は元の文字列の位置と一致します。
+func fold(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '！' && r <= '～':
+			// 全角英数字・記号を半角に
+			r -= '！' - '!'
+		case r >= 'ァ' && r <= 'ヶ':
+			// カタカナをひらがなに（ヴ・ヵ・ヶ を含む）
+			r -= 'ァ' - 'ぁ'
+		case r == '　':
+			r = ' '
+		}
+		return unicode.ToLower(r)
+	}, s)
+}
+
+// runeIndex は s の中で最初に sub が現れる位置（文字単位）を返します。
+func runeIndex(s, sub []rune) int {
+	if len(sub) == 0 || len(sub) > len(s) {
+		return -1
+	}
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if slices.Equal(s[i:i+len(sub)], sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// snippet は pos の前後 snippetRadius 文字を切り出します。改行は空白にします。
+func snippet(text []rune, pos int) string {
+	start := max(0, pos-snippetRadius)
+	end := min(len(text), pos+snippetRadius)
+	s := strings.Join(strings.Fields(string(text[start:end])), " ")
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(text) {
+		s += "…"
+	}
+	return s
+}
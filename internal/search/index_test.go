@@ -0,0 +1,106 @@
+package search
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/shouni/go-manga-kit/ports"
+
+	"ap-manga-web/internal/domain"
+)
+
+func testPlot(title string, panels ...domain.PlotPanel) *domain.MangaPlot {
+	return &domain.MangaPlot{Title: title, Panels: panels}
+}
+
+func panel(speaker, dialogue, anchor string) domain.PlotPanel {
+	return domain.PlotPanel{Panel: ports.Panel{SpeakerID: speaker, Dialogue: dialogue, VisualAnchor: anchor}}
+}
+
+func TestSearch(t *testing.T) {
+	ix := NewIndex()
+	ix.Put(NewDocument("20260102_000000_b", testPlot("Goroutine 入門",
+		panel("zundamon", "チャネルでデータを送るのだ", "教室"),
+		panel("metan", "select 文も便利よ", "ホワイトボードの前"),
+	), nil, nil))
+	ix.Put(NewDocument("20260101_000000_a", testPlot("Rust の所有権",
+		panel("zundamon", "借用チェッカーは厳しいのだ", "研究室"),
+	), nil, nil))
+
+	names := func(rs []Result) []string {
+		var out []string
+		for _, r := range rs {
+			out = append(out, r.Name)
+		}
+		return out
+	}
+
+	if got := names(ix.Search("のだ")); !slices.Equal(got, []string{"20260102_000000_b", "20260101_000000_a"}) {
+		t.Errorf("Search(のだ) = %v (same score, newer first)", got)
+	}
+	// カタカナとひらがな、全角英字を区別しない
+	if got := names(ix.Search("ちゃねる ＳＥＬＥＣＴ")); !slices.Equal(got, []string{"20260102_000000_b"}) {
+		t.Errorf("Search(ちゃねる SELECT) = %v", got)
+	}
+	if got := ix.Search("チャネル 所有権"); len(got) != 0 {
+		t.Errorf("Search with terms in different titles = %v, want none", names(got))
+	}
+	if got := ix.Search("  "); got != nil {
+		t.Errorf("Search(blank) = %v", got)
+	}
+
+	res := ix.Search("ホワイトボード")
+	if len(res) != 1 || res[0].FirstPanel() != 2 {
+		t.Fatalf("Search(ホワイトボード) = %+v", res)
+	}
+	if h := res[0].Hits[0]; h.Field != FieldVisualAnchor || h.Snippet != "ホワイトボードの前" {
+		t.Errorf("hit = %+v", h)
+	}
+
+	// タイトルの一致はセリフの一致より上位
+	ix.Put(NewDocument("20260103_000000_c", testPlot("借用のはなし"), nil, nil))
+	if got := names(ix.Search("借用")); !slices.Equal(got, []string{"20260103_000000_c", "20260101_000000_a"}) {
+		t.Errorf("Search(借用) = %v", got)
+	}
+}
+
+func TestSearchMeta(t *testing.T) {
+	ix := NewIndex()
+	meta := &domain.TitleMeta{DisplayTitle: "社内勉強会 第3回", Tags: []string{"Go", "研修"}}
+	ix.Put(NewDocument("20260101_000000_a", testPlot("Goroutine 入門"), meta, nil))
+
+	for _, q := range []string{"勉強会", "研修", "goroutine"} {
+		res := ix.Search(q)
+		if len(res) != 1 {
+			t.Fatalf("Search(%s) = %+v", q, res)
+		}
+		if res[0].Title != "社内勉強会 第3回" {
+			t.Errorf("Search(%s).Title = %q, want display title", q, res[0].Title)
+		}
+	}
+	if h := ix.Search("研修")[0].Hits[0]; h.Field != FieldTag {
+		t.Errorf("hit = %+v, want tag", h)
+	}
+}
+
+func TestPutReplaces(t *testing.T) {
+	ix := NewIndex()
+	ix.Put(Document{Name: "b", Title: "old"})
+	ix.Put(Document{Name: "a"})
+	ix.Put(Document{Name: "b", Title: "new"})
+	if len(ix.Documents) != 2 || ix.Documents[0].Name != "a" || ix.Documents[1].Title != "new" {
+		t.Errorf("Documents = %+v", ix.Documents)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	text := []rune("0123456789012345678901234567890123456789\nabcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyz")
+	got := snippet(text, 45)
+	want := "…5678901234567890123456789 abcdefghijklmnopqrstuvwxyzabcdefgh…"
+	if got != want {
+		t.Errorf("snippet() = %q, want %q", got, want)
+	}
+	if got := snippet([]rune("短い"), 0); got != "短い" {
+		t.Errorf("snippet(short) = %q", got)
+	}
+}
@@ -0,0 +1,247 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shouni/go-character-kit/character"
+	"github.com/shouni/go-manga-kit/asset"
+	"github.com/shouni/go-remote-io/remoteio"
+
+	"ap-manga-web/internal/catalog"
+	"ap-manga-web/internal/domain"
+)
+
+const (
+	// cacheTTL は Web から検索する際に、読み込んだ索引を再利用する期間です。
+	cacheTTL = time.Minute
+	// rebuildConcurrency は索引を作り直す際に台本を読み込む同時実行数です。
+	rebuildConcurrency = 8
+)
+
+// Store は索引ファイルの読み書きと検索を行います。
+// 索引の更新は読み込み・追加・書き込みの順に行うため、同時に完了したジョブの更新が失われることがあります。
+// その場合は CLI の rebuild-search で作り直します。
+type Store struct {
+	reader remoteio.InputReader
+	writer remoteio.Writer
+	// root は作品の作業ディレクトリを置くディレクトリ (例: gs://bucket/output) です。
+	root  string
+	chars CharacterSource
+
+	mu       sync.Mutex
+	cached   *Index
+	loadedAt time.Time
+}
+
+// CharacterSource はキャラクターの表示名の解決に使用する、現在のキャラクター定義を返します。
+type CharacterSource interface {
+	Characters(ctx context.Context) (*character.Characters, error)
+}
+
+// NewStore は root (例: gs://bucket/output) 直下の search_index.json を扱う Store を作成します。
+func NewStore(reader remoteio.InputReader, writer remoteio.Writer, root string, chars CharacterSource) *Store {
+	return &Store{reader: reader, writer: writer, root: strings.TrimSuffix(root, "/"), chars: chars}
+}
+
+// Path は索引ファイルのパスを返します。
+func (s *Store) Path() string {
+	return s.root + "/" + IndexFile
+}
+
+// Search は索引を検索します。索引は短時間キャッシュし、索引がまだない場合は結果なしとします。
+func (s *Store) Search(ctx context.Context, q string) ([]Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cached == nil || time.Since(s.loadedAt) > cacheTTL {
+		ix, err := s.load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.cached, s.loadedAt = ix, time.Now()
+	}
+	return s.cached.Search(q), nil
+}
+
+// IndexTitle は作品の台本を索引に追加（または更新）します。domain.SearchIndexer を満たします。
+// メタデータは作業ディレクトリの meta.json から読み込みます。
+func (s *Store) IndexTitle(ctx context.Context, name string, plot *domain.MangaPlot) error {
+	meta, err := s.readMeta(ctx, name)
+	if err != nil {
+		return err
+	}
+	return s.put(ctx, NewDocument(name, plot, meta, s.characters(ctx)))
+}
+
+// Reindex は作品の台本とメタデータを読み込み直して索引を更新します。メタデータを編集した後に使用します。
+func (s *Store) Reindex(ctx context.Context, name string) error {
+	plot, err := s.readPlot(ctx, name)
+	if err != nil {
+		return fmt.Errorf("台本の読み込みに失敗しました: %w", err)
+	}
+	return s.IndexTitle(ctx, name, plot)
+}
+
+func (s *Store) put(ctx context.Context, doc Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ix, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+	ix.Put(doc)
+	if err := s.save(ctx, ix); err != nil {
+		return err
+	}
+	s.cached, s.loadedAt = ix, time.Now()
+	return nil
+}
+
+// Rebuild は root 以下のすべての manga_plot.json から索引を作り直し、登録した作品数を返します。
+// 読み込めない台本は警告を記録して飛ばします。
+func (s *Store) Rebuild(ctx context.Context) (int, error) {
+	entries, err := catalog.New(s.reader, s.root).Scan(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	chars := s.characters(ctx)
+	docs := make([]*Document, len(entries))
+	sem := make(chan struct{}, rebuildConcurrency)
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			plot, err := s.readPlot(ctx, e.Name)
+			if err != nil {
+				slog.WarnContext(ctx, "台本の読み込みに失敗したため索引に含めません", "title", e.Name, "error", err)
+				return
+			}
+			meta, err := s.readMeta(ctx, e.Name)
+			if err != nil {
+				slog.WarnContext(ctx, "メタデータの読み込みに失敗したため台本のみ索引に含めます", "title", e.Name, "error", err)
+			}
+			doc := NewDocument(e.Name, plot, meta, chars)
+			docs[i] = &doc
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	ix := NewIndex()
+	for _, doc := range docs {
+		if doc != nil {
+			ix.Put(*doc)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.save(ctx, ix); err != nil {
+		return 0, err
+	}
+	s.cached, s.loadedAt = ix, time.Now()
+	return len(ix.Documents), nil
+}
+
+// load は索引ファイルを読み込みます。ファイルがない場合は空の索引を返します。
+// characters は現在のキャラクター定義を返します。読み込めない場合は警告を記録して nil を返し、表示名の代わりに ID を索引に含めます。
+func (s *Store) characters(ctx context.Context) *character.Characters {
+	chars, err := s.chars.Characters(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "キャラクター定義の読み込みに失敗したため、キャラクターIDで索引に含めます", "error", err)
+		return nil
+	}
+	return chars
+}
+
+func (s *Store) load(ctx context.Context) (*Index, error) {
+	p := s.Path()
+	exists, err := s.reader.Exists(ctx, p)
+	if err != nil {
+		return nil, fmt.Errorf("検索索引の確認に失敗しました: %w", err)
+	}
+	if !exists {
+		return NewIndex(), nil
+	}
+
+	rc, err := s.reader.Open(ctx, p)
+	if err != nil {
+		return nil, fmt.Errorf("検索索引の読み込みに失敗しました: %w", err)
+	}
+	defer rc.Close()
+
+	var ix Index
+	if err := json.NewDecoder(rc).Decode(&ix); err != nil {
+		return nil, fmt.Errorf("検索索引の解析に失敗しました: %w", err)
+	}
+	if ix.Version != indexVersion {
+		slog.WarnContext(ctx, "検索索引の形式が異なるため空の索引として扱います。rebuild-search で作り直してください", "version", ix.Version)
+		return NewIndex(), nil
+	}
+	return &ix, nil
+}
+
+func (s *Store) save(ctx context.Context, ix *Index) error {
+	ix.Version = indexVersion
+	ix.UpdatedAt = time.Now().UTC()
+
+	data, err := json.Marshal(ix)
+	if err != nil {
+		return fmt.Errorf("検索索引の生成に失敗しました: %w", err)
+	}
+	if err := s.writer.Write(ctx, s.Path(), bytes.NewReader(data),
+		remoteio.WithContentType("application/json"),
+		remoteio.WithCacheControl("no-cache")); err != nil {
+		return fmt.Errorf("検索索引の保存に失敗しました: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) readPlot(ctx context.Context, name string) (*domain.MangaPlot, error) {
+	rc, err := s.reader.Open(ctx, s.root+"/"+name+"/"+asset.DefaultMangaPlotJson)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var plot domain.MangaPlot
+	if err := json.NewDecoder(rc).Decode(&plot); err != nil {
+		return nil, fmt.Errorf("JSONの解析に失敗しました: %w", err)
+	}
+	return &plot, nil
+}
+
+// readMeta は作品の meta.json を読み込みます。ファイルがない場合は nil を返します。
+func (s *Store) readMeta(ctx context.Context, name string) (*domain.TitleMeta, error) {
+	p := s.root + "/" + name + "/" + domain.MetaFile
+	exists, err := s.reader.Exists(ctx, p)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	rc, err := s.reader.Open(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var meta domain.TitleMeta
+	if err := json.NewDecoder(rc).Decode(&meta); err != nil {
+		return nil, fmt.Errorf("メタデータの解析に失敗しました: %w", err)
+	}
+	return &meta, nil
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"ap-manga-web/internal/characters"
+)
+
+// charactersPath はキャラクター管理画面のパスです。
+const charactersPath = "/admin/characters"
+
+// characterListData はテンプレート「characters.html」に渡すデータ構造体です。
+type characterListData struct {
+	// Version は表示した時点の定義の版です。退役・復帰の際に定義が更新されていないかを確認します。
+	Version string
+	Records []characters.Record
+	// Path は定義の保存先です。
+	Path string
+}
+
+// characterFormData はテンプレート「character_form.html」に渡すデータ構造体です。
+type characterFormData struct {
+	// Version は編集を始めた時点の定義の版です。
+	Version string
+	// New はキャラクターを追加する画面であることを示します。追加する場合のみ ID を入力できます。
+	New    bool
+	Record characters.Record
+	// Seed と VisualCues は入力欄に表示する値です。外見の特徴は1行に1件です。
+	Seed       string
+	VisualCues string
+
+	MaxNameLen      int
+	MaxVisualCues   int
+	MaxVisualCueLen int
+}
+
+// RequireAdmin は ADMIN_EMAILS に含まれる利用者のみに管理画面を表示するミドルウェアです。
+// ログインの確認 (h.Auth.Middleware) と利用者のメールアドレスの設定の後に使用します。
+func (h *Handler) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		email := userEmailFromContext(r.Context())
+		if !h.cfg.IsAdmin(email) {
+			slog.WarnContext(r.Context(), "管理者ではない利用者が管理画面にアクセスしました", "user", email, "path", r.URL.Path)
+			http.Error(w, "この画面は管理者のみ使用できます", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ServeCharacters は登録されているキャラクターの一覧を表示します。退役したキャラクターも含めて表示し、退役・復帰を操作できます。
+func (h *Handler) ServeCharacters(w http.ResponseWriter, r *http.Request) {
+	snap, err := h.characters.Load(r.Context())
+	if err != nil {
+		h.handleError(w, r, "キャラクター定義の読み込みに失敗しました", "", err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, no-cache")
+	h.render(w, r, http.StatusOK, "characters.html", "キャラクター管理", characterListData{
+		Version: snap.Version,
+		Records: snap.Records,
+		Path:    h.cfg.GetGCSObjectURL(h.cfg.CharacterRegistryPath),
+	})
+}
+
+// ServeCharacterForm はキャラクターの追加 (/admin/characters/new) または編集 (/admin/characters/{id}) の画面を表示します。
+func (h *Handler) ServeCharacterForm(w http.ResponseWriter, r *http.Request) {
+	snap, err := h.characters.Load(r.Context())
+	if err != nil {
+		h.handleError(w, r, "キャラクター定義の読み込みに失敗しました", "", err, http.StatusInternalServerError)
+		return
+	}
+
+	data := characterFormData{
+		Version:         snap.Version,
+		New:             true,
+		MaxNameLen:      characters.MaxNameLen,
+		MaxVisualCues:   characters.MaxVisualCues,
+		MaxVisualCueLen: characters.MaxVisualCueLen,
+	}
+	title := "キャラクターの追加"
+	if id := chi.URLParam(r, "id"); id != "" {
+		rec, ok := snap.Record(id)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		data.New = false
+		data.Record = rec
+		data.VisualCues = strings.Join(rec.VisualCues, "\n")
+		if rec.Seed != nil {
+			data.Seed = strconv.FormatInt(*rec.Seed, 10)
+		}
+		title = "キャラクターの編集: " + rec.Name
+	}
+
+	w.Header().Set("Cache-Control", "private, no-cache")
+	h.render(w, r, http.StatusOK, "character_form.html", title, data)
+}
+
+// CreateCharacter はキャラクターを追加します。追加したキャラクターは、次に始まるジョブから台本と画像の生成に使用します。
+func (h *Handler) CreateCharacter(w http.ResponseWriter, r *http.Request) {
+	c, ok := characterFromForm(w, r, r.PostFormValue("id"))
+	if !ok {
+		return
+	}
+	user := userEmailFromContext(r.Context())
+	if err := h.characters.Create(r.Context(), c, r.PostFormValue("version"), user); err != nil {
+		h.handleCharacterError(w, r, c.ID, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "キャラクターを追加しました", "character", c.ID, "user", user)
+	http.Redirect(w, r, charactersPath, http.StatusSeeOther)
+}
+
+// UpdateCharacter はキャラクターの定義を保存し直します。埋め込みのキャラクターは、保存した定義で上書きします。
+func (h *Handler) UpdateCharacter(w http.ResponseWriter, r *http.Request) {
+	c, ok := characterFromForm(w, r, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+	user := userEmailFromContext(r.Context())
+	if err := h.characters.Update(r.Context(), c, r.PostFormValue("version"), user); err != nil {
+		h.handleCharacterError(w, r, c.ID, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "キャラクターを更新しました", "character", c.ID, "user", user)
+	http.Redirect(w, r, charactersPath, http.StatusSeeOther)
+}
+
+// RetireCharacter はキャラクターを退役させます。退役したキャラクターは話者として選べなくなり、生成にも使用しません。
+func (h *Handler) RetireCharacter(w http.ResponseWriter, r *http.Request) {
+	h.setCharacterRetired(w, r, true)
+}
+
+// RestoreCharacter は退役したキャラクターを使用中に戻します。
+func (h *Handler) RestoreCharacter(w http.ResponseWriter, r *http.Request) {
+	h.setCharacterRetired(w, r, false)
+}
+
+func (h *Handler) setCharacterRetired(w http.ResponseWriter, r *http.Request, retired bool) {
+	id := chi.URLParam(r, "id")
+	user := userEmailFromContext(r.Context())
+	if err := h.characters.SetRetired(r.Context(), id, retired, r.PostFormValue("version"), user); err != nil {
+		h.handleCharacterError(w, r, id, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "キャラクターの退役状態を変更しました", "character", id, "retired", retired, "user", user)
+	http.Redirect(w, r, charactersPath, http.StatusSeeOther)
+}
+
+// characterFromForm は追加・編集画面のフォームから ID が id のキャラクター定義を組み立てます。
+// 入力に誤りがある場合はエラーのレスポンスを書き込み、false を返します。
+func characterFromForm(w http.ResponseWriter, r *http.Request, id string) (characters.Character, bool) {
+	if err := r.ParseForm(); err != nil {
+		slog.WarnContext(r.Context(), "フォームの解析に失敗しました", "error", err)
+		http.Error(w, "リクエストの解析に失敗しました", http.StatusBadRequest)
+		return characters.Character{}, false
+	}
+
+	c := characters.Character{
+		ID:           strings.TrimSpace(id),
+		Name:         strings.TrimSpace(r.PostFormValue("name")),
+		ReferenceURL: strings.TrimSpace(r.PostFormValue("reference_url")),
+		VisualCues:   characters.ParseVisualCues(r.PostFormValue("visual_cues")),
+		IsDefault:    r.PostFormValue("is_default") == "true",
+	}
+	if v := strings.TrimSpace(r.PostFormValue("seed")); v != "" {
+		seed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Seed 値には整数を指定してください", http.StatusBadRequest)
+			return characters.Character{}, false
+		}
+		c.Seed = &seed
+	}
+	return c, true
+}
+
+// handleCharacterError はキャラクター定義の保存に失敗した場合のレスポンスを書き込みます。
+func (h *Handler) handleCharacterError(w http.ResponseWriter, r *http.Request, id string, err error) {
+	switch {
+	case errors.Is(err, characters.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, characters.ErrNotFound):
+		http.NotFound(w, r)
+	case errors.Is(err, characters.ErrExists):
+		http.Error(w, "同じ ID のキャラクターが登録されています（退役したキャラクターを含みます）: "+id, http.StatusConflict)
+	case errors.Is(err, characters.ErrConflict):
+		http.Error(w, "編集中にキャラクター定義が更新されました。画面を開き直してください。", http.StatusConflict)
+	default:
+		slog.ErrorContext(r.Context(), "キャラクター定義の保存に失敗しました", "character", id, "error", err)
+		http.Error(w, "キャラクター定義の保存に失敗しました", http.StatusInternalServerError)
+	}
+}
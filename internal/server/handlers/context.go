@@ -4,6 +4,8 @@ import "context"
 
 type csrfTokenContextKey struct{}
 
+type userEmailContextKey struct{}
+
 // WithCSRFToken は、テンプレートに公開すべきCSRFトークンをコンテキストに保存します。
 func WithCSRFToken(ctx context.Context, token string) context.Context {
 	return context.WithValue(ctx, csrfTokenContextKey{}, token)
@@ -17,3 +19,17 @@ func csrfTokenFromContext(ctx context.Context) string {
 	}
 	return token
 }
+
+// WithUserEmail は、ログイン中の利用者のメールアドレスをコンテキストに保存します。
+func WithUserEmail(ctx context.Context, email string) context.Context {
+	return context.WithValue(ctx, userEmailContextKey{}, email)
+}
+
+// userEmailFromContext は、コンテキストに保存された利用者のメールアドレスを取得します。
+func userEmailFromContext(ctx context.Context) string {
+	email, ok := ctx.Value(userEmailContextKey{}).(string)
+	if !ok {
+		return ""
+	}
+	return email
+}
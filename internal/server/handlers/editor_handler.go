@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shouni/go-manga-kit/asset"
+
+	"ap-manga-web/internal/characters"
+	"ap-manga-web/internal/domain"
+)
+
+// editorData はテンプレート「editor.html」に渡すデータ構造体です。
+type editorData struct {
+	Title        string
+	BaseURL      string
+	DisplayTitle string
+	// Revision は編集を始めた時点の台本の版です。保存時に台本が更新されていないかを確認します。
+	Revision string
+	Panels   []editorPanel
+	// Characters は話者として選べるキャラクターです。台本にある退役・未登録のキャラクターも含めます。
+	Characters []characterOption
+	// Running は作品の生成・作り直しが実行中 (または実行待ち) であることを示します。実行中は保存できません。
+	Running  bool
+	JobLabel string
+	// MaxDialogueLen と MaxVisualAnchorLen は入力欄の最大文字数です。
+	MaxDialogueLen     int
+	MaxVisualAnchorLen int
+}
+
+// characterOption は画面の選択肢に表示するキャラクターの ID と名前です。
+type characterOption struct {
+	ID   string
+	Name string
+}
+
+// editorPanel は編集画面に並べるパネル1枚分の入力欄です。
+type editorPanel struct {
+	domain.PlotPanel
+	// Index はパネルのインデックス (0 始まり) で、入力欄の名前に使用します。
+	Index int
+	// Page はパネルが入るページの番号 (1 始まり) です。
+	Page int
+	// Image は参考に表示する現在のパネル画像です。画像がない場合は空です。
+	Image imageSources
+}
+
+// ServeEditor は作品の台本 (manga_plot.json) を編集する画面を表示します。
+// パネルごとに話者・セリフ・描写を編集でき、パネルの追加や削除、並べ替えはできません。
+func (h *Handler) ServeEditor(w http.ResponseWriter, r *http.Request) {
+	title := chi.URLParam(r, "title")
+	ctx := r.Context()
+
+	manga, revision, err := h.loadPlot(r, title)
+	if err != nil {
+		h.handleViewerError(w, r, title, err)
+		return
+	}
+	job, err := h.loadJob(r, title)
+	if err != nil {
+		slog.WarnContext(ctx, "ジョブ記録の読み込みに失敗しました", "title", title, "error", err)
+	}
+	chars, err := h.characters.Load(ctx)
+	if err != nil {
+		h.handleError(w, r, "キャラクター定義の読み込みに失敗しました", title, err, http.StatusInternalServerError)
+		return
+	}
+
+	data := editorData{
+		Title:              title,
+		BaseURL:            h.titleURL(title),
+		DisplayTitle:       cmp.Or(manga.Title, title),
+		Revision:           revision,
+		Panels:             h.editorPanels(r, title, manga, job),
+		Characters:         editorCharacters(chars, manga),
+		Running:            job.Running(),
+		JobLabel:           jobLabel(job),
+		MaxDialogueLen:     domain.MaxEditDialogueLen,
+		MaxVisualAnchorLen: domain.MaxEditVisualAnchorLen,
+	}
+	if m, err := h.loadMeta(r, title); err != nil {
+		slog.WarnContext(ctx, "メタデータの読み込みに失敗しました", "title", title, "error", err)
+	} else if m != nil {
+		data.DisplayTitle = cmp.Or(m.DisplayTitle, data.DisplayTitle)
+	}
+
+	// 保存時に版を確認するため、常に最新の台本を表示します
+	w.Header().Set("Cache-Control", "private, no-cache")
+	h.render(w, r, http.StatusOK, "editor.html", "台本の編集: "+data.DisplayTitle, data)
+}
+
+// SaveEditor は編集画面から送信された台本を検証し、改訂した台本による作品の更新を依頼します。
+// 更新は既存の作品を上書きする regenerate コマンドのジョブとして実行します。ジョブが保存済みの台本との差分を求めて台本を保存し、
+// 変更に応じたパネルとページだけを作り直します。進行状況はプレビュー画面で表示します。
+func (h *Handler) SaveEditor(w http.ResponseWriter, r *http.Request) {
+	title := chi.URLParam(r, "title")
+	ctx := r.Context()
+
+	if err := r.ParseForm(); err != nil {
+		slog.WarnContext(ctx, "フォームの解析に失敗しました", "error", err)
+		http.Error(w, "リクエストの解析に失敗しました", http.StatusBadRequest)
+		return
+	}
+
+	// 1. 台本とジョブ記録の確認（実行中のジョブや、編集中に更新された台本は上書きしません）
+	before, revision, err := h.loadPlot(r, title)
+	if err != nil {
+		h.handleViewerError(w, r, title, err)
+		return
+	}
+	job, err := h.loadJob(r, title)
+	if err != nil {
+		h.handleError(w, r, "ジョブ記録の読み込みに失敗しました", title, err, http.StatusInternalServerError)
+		return
+	}
+	if job.Running() {
+		http.Error(w, "作品の生成中は台本を保存できません。生成が終わってから編集し直してください。", http.StatusConflict)
+		return
+	}
+	if r.PostFormValue("revision") != revision {
+		http.Error(w, "編集中に台本が更新されました。編集画面を開き直してください。", http.StatusConflict)
+		return
+	}
+
+	// 2. 入力の反映と検証（話者は使用中のキャラクターから選ばせます）
+	chars, err := h.characters.Characters(ctx)
+	if err != nil {
+		h.handleError(w, r, "キャラクター定義の読み込みに失敗しました", title, err, http.StatusInternalServerError)
+		return
+	}
+	after := before
+	after.Panels = slices.Clone(before.Panels)
+	known := func(id string) bool { return chars.GetCharacter(id) != nil }
+	if err := after.ApplyEdits(panelEdits(r, before), known); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	changed := domain.ChangedPanels(&before, &after)
+	if len(changed) == 0 {
+		http.Redirect(w, r, h.editedTitleURL(title, ""), http.StatusSeeOther)
+		return
+	}
+
+	// 3. 作品の更新を依頼（台本の保存と検索索引の更新はジョブが行います）
+	if err := h.enqueueRegeneration(r, title, &after, job); err != nil {
+		h.handleError(w, r, "台本の更新を依頼できませんでした", title, err, http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(ctx, "台本の更新を依頼しました", "title", title, "panels", changed, "user", userEmailFromContext(ctx))
+
+	http.Redirect(w, r, h.editedTitleURL(title, ""), http.StatusSeeOther)
+}
+
+// enqueueRegeneration は、改訂した台本 manga で作品を更新するジョブを依頼します。ページ画像のある作品では、ページも作り直させます。
+// ワーカーが処理を始めるまでの間もプレビュー画面で進行状況を表示し、重ねて編集されないよう、ジョブ記録を実行待ち (queued) にしてから依頼します。
+func (h *Handler) enqueueRegeneration(r *http.Request, title string, manga *domain.MangaPlot, job *domain.JobRecord) error {
+	ctx := r.Context()
+
+	hasPages := job.Includes(domain.JobStepPage)
+	if !hasPages {
+		pages, err := h.listImagePaths(r, title, asset.PageFileRegex)
+		if err != nil {
+			return err
+		}
+		hasPages = len(pages) > 0
+	}
+
+	input, err := json.Marshal(manga)
+	if err != nil {
+		return fmt.Errorf("台本のエンコードに失敗しました: %w", err)
+	}
+	payload := domain.GenerateTaskPayload{
+		Command:      "regenerate",
+		Title:        title,
+		InputText:    string(input),
+		ComposePages: hasPages,
+		Owner:        userEmailFromContext(ctx),
+	}
+
+	// 元のジョブの描画指定で作り直します。記録のない古い作品は既定の描画指定を使用します
+	rec := domain.JobRecord{Command: payload.Command, Owner: payload.Owner}
+	if job != nil {
+		rec = *job
+		payload.ColorMode = job.ColorMode
+		payload.PageRenderer = job.PageRenderer
+	}
+	rec.Status = domain.JobQueued
+	rec.Step = domain.JobStepPanel
+	rec.Error = ""
+	rec.Changes = nil
+	rec.Panels = len(manga.Panels)
+	rec.UpdatedAt = time.Now()
+
+	jobPath, err := h.validateAndCleanPath(title, domain.JobRecordFile)
+	if err != nil {
+		return err
+	}
+	jobFile := h.cfg.GetGCSObjectURL(jobPath)
+	if err := h.titles.SaveJob(ctx, jobFile, rec); err != nil {
+		return fmt.Errorf("ジョブ記録の保存に失敗しました: %w", err)
+	}
+
+	if err := h.taskEnqueuer.Enqueue(ctx, payload); err != nil {
+		// 実行待ちのまま残さないよう、失敗として記録し直します
+		rec.Status = domain.JobFailed
+		rec.Error = "作り直しのジョブを登録できませんでした"
+		rec.UpdatedAt = time.Now()
+		if saveErr := h.titles.SaveJob(ctx, jobFile, rec); saveErr != nil {
+			slog.WarnContext(ctx, "ジョブ記録の保存に失敗しました", "title", title, "error", saveErr)
+		}
+		return fmt.Errorf("タスクのエンキューに失敗しました: %w", err)
+	}
+	return nil
+}
+
+// panelEdits は編集画面のフォームから、台本 manga のパネルごとの入力を取り出します。
+// 入力欄の名前はパネルのインデックス i (と吹き出しのインデックス j) を付けた speaker_{i}, dialogue_{i}, anchor_{i},
+// line_speaker_{i}_{j}, line_text_{i}_{j} です。
+func panelEdits(r *http.Request, manga domain.MangaPlot) []domain.PanelEdit {
+	edits := make([]domain.PanelEdit, len(manga.Panels))
+	for i, panel := range manga.Panels {
+		edits[i] = domain.PanelEdit{
+			SpeakerID:    r.PostFormValue(fmt.Sprintf("speaker_%d", i)),
+			Dialogue:     r.PostFormValue(fmt.Sprintf("dialogue_%d", i)),
+			VisualAnchor: r.PostFormValue(fmt.Sprintf("anchor_%d", i)),
+		}
+		for j := range panel.Lines {
+			edits[i].Lines = append(edits[i].Lines, domain.DialogueLine{
+				SpeakerID: r.PostFormValue(fmt.Sprintf("line_speaker_%d_%d", i, j)),
+				Text:      r.PostFormValue(fmt.Sprintf("line_text_%d_%d", i, j)),
+			})
+		}
+	}
+	return edits
+}
+
+// editorPanels は編集画面に並べるパネルを、参考に表示する現在のパネル画像とともに組み立てます。
+// 画像を取得できない場合も、画像なしで編集できるようにします。
+func (h *Handler) editorPanels(r *http.Request, title string, manga domain.MangaPlot, job *domain.JobRecord) []editorPanel {
+	panels := make([]editorPanel, len(manga.Panels))
+	perPage := cmp.Or(h.cfg.MaxPanelsPerPage, domain.DefaultPanelsPerPage)
+	for i, p := range manga.Panels {
+		panels[i] = editorPanel{PlotPanel: p, Index: i, Page: i/perPage + 1}
+	}
+
+	images, err := h.listTitleImages(r, title)
+	if err != nil {
+		slog.WarnContext(r.Context(), "画像の取得に失敗しました", "title", title, "error", err)
+		return panels
+	}
+	paths := slices.Concat(images.panels, images.variants)
+	urls, err := h.imageURLs(r, title, job, paths)
+	if err != nil {
+		slog.WarnContext(r.Context(), "画像URLの生成に失敗しました", "title", title, "error", err)
+	}
+	// 台本の画像パスを書き換えるため、複製に対して対応付けます
+	resolved := manga
+	resolved.Panels = slices.Clone(manga.Panels)
+	found := h.resolvePanelURLs(&resolved, images.panels, urls[:len(images.panels)])
+	variants := groupVariants(images.variants, urls[len(images.panels):])
+	for i, p := range resolved.Panels {
+		if found[i] {
+			panels[i].Image = variants.sources(p.ReferenceURL)
+		}
+	}
+	return panels
+}
+
+// editorCharacters は話者の選択肢として、使用中のキャラクターを返します。
+// 退役したキャラクターや登録されていないキャラクターIDが台本にある場合は、選び直さなくても保存できるよう選択肢に加えます。
+func editorCharacters(chars *characters.Snapshot, manga domain.MangaPlot) []characterOption {
+	var options []characterOption
+	for _, c := range chars.Active() {
+		options = append(options, characterOption{ID: c.ID, Name: c.Name})
+	}
+	for _, panel := range manga.Panels {
+		for _, id := range panel.SpeakerIDs() {
+			if slices.ContainsFunc(options, func(o characterOption) bool { return o.ID == id }) {
+				continue
+			}
+			option := characterOption{ID: id, Name: id}
+			if rec, ok := chars.Record(id); ok {
+				option.Name = rec.Name + "（退役）"
+			}
+			options = append(options, option)
+		}
+	}
+	return options
+}
@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"image"
+	_ "image/jpeg" // サムネイルのサイズ取得用
+	_ "image/png"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shouni/go-manga-kit/asset"
+
+	"ap-manga-web/internal/config"
+	"ap-manga-web/internal/share"
+)
+
+const (
+	// embedDefaultWidth と embedDefaultHeight は oEmbed で返す iframe の既定の大きさです。縦長のページに合わせています。
+	embedDefaultWidth  = 640
+	embedDefaultHeight = 900
+	// providerName は oEmbed の provider_name です。
+	providerName = "AP Manga Web"
+)
+
+type shareAccessContextKey struct{}
+
+// shareAccess は共有リンクのトークンで許可されたアクセスです。
+type shareAccess struct {
+	Claims share.Claims
+	Token  string
+}
+
+// embedData はテンプレート「embed.html」に渡すためのデータ構造体
+type embedData struct {
+	Title string
+	Pages []imageSources
+	// RTL は右綴じ（左へページ送り）で表示するかを示します。
+	RTL bool
+	// ViewerURL は通常のプレビュー画面（共有リンクの場合は共有リンクの画面）の URL です。
+	ViewerURL string
+}
+
+// oEmbedResponse は oEmbed 1.0 の rich タイプの応答です。
+type oEmbedResponse struct {
+	Version         string `json:"version"`
+	Type            string `json:"type"`
+	Title           string `json:"title,omitempty"`
+	ProviderName    string `json:"provider_name"`
+	ProviderURL     string `json:"provider_url"`
+	HTML            string `json:"html"`
+	Width           int    `json:"width"`
+	Height          int    `json:"height"`
+	ThumbnailURL    string `json:"thumbnail_url,omitempty"`
+	ThumbnailWidth  int    `json:"thumbnail_width,omitempty"`
+	ThumbnailHeight int    `json:"thumbnail_height,omitempty"`
+	CacheAge        int    `json:"cache_age,omitempty"`
+}
+
+// ShareOrAuth は、共有リンクのトークンを持つリクエストはトークンを検証して next に渡し、
+// それ以外のリクエストには auth でログインを求めるミドルウェアを返します。
+// トークンはクエリの token、または oEmbed の url に含まれる共有リンクから取得します。
+func (h *Handler) ShareOrAuth(auth func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		authed := auth(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := shareTokenFromRequest(r)
+			if token == "" || h.shares == nil {
+				authed.ServeHTTP(w, r)
+				return
+			}
+			claims, err := h.shares.Resolve(r.Context(), token)
+			if err != nil {
+				h.handleShareError(w, r, err)
+				return
+			}
+			ctx := withShareAccess(r.Context(), shareAccess{Claims: claims, Token: token})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// withShareAccess は共有リンクのトークンで許可されたアクセスをコンテキストに保存します。
+func withShareAccess(ctx context.Context, a shareAccess) context.Context {
+	return context.WithValue(ctx, shareAccessContextKey{}, a)
+}
+
+// shareAccessFromContext は ShareOrAuth が検証した共有リンクのアクセスを返します。ログインしている場合は false です。
+func shareAccessFromContext(ctx context.Context) (shareAccess, bool) {
+	a, ok := ctx.Value(shareAccessContextKey{}).(shareAccess)
+	return a, ok
+}
+
+// shareTokenFromRequest はクエリの token、または url (例: https://example.com/share/{token}) から共有リンクのトークンを取り出します。
+func shareTokenFromRequest(r *http.Request) string {
+	q := r.URL.Query()
+	if token := q.Get("token"); token != "" {
+		return token
+	}
+	u, err := url.Parse(q.Get("url"))
+	if err != nil {
+		return ""
+	}
+	if token := u.Query().Get("token"); token != "" {
+		return token
+	}
+	if rest, ok := strings.CutPrefix(u.Path, "/share/"); ok {
+		token, _, _ := strings.Cut(rest, "/")
+		return token
+	}
+	return ""
+}
+
+// ServeEmbed は iframe への埋め込み用に、ページ画像だけを1枚ずつめくって表示する画面を表示します。
+// 既定は右綴じ（左へページ送り）で、?dir=ltr で左綴じにできます。
+// 共有リンクのトークン (?token=) がある場合は、ログインしていない相手にも表示します。
+func (h *Handler) ServeEmbed(w http.ResponseWriter, r *http.Request) {
+	title := chi.URLParam(r, "title")
+	access, shared := shareAccessFromContext(r.Context())
+	if shared && access.Claims.Title != title {
+		http.NotFound(w, r)
+		return
+	}
+
+	viewer, err := h.loadViewer(r, title)
+	if err != nil {
+		h.handleViewerError(w, r, title, err)
+		return
+	}
+	data := embedData{
+		Title:     h.displayTitle(r, title, viewer.DisplayTitle),
+		Pages:     viewer.Pages,
+		RTL:       r.URL.Query().Get("dir") != "ltr",
+		ViewerURL: viewer.BaseURL,
+	}
+
+	if shared {
+		data.ViewerURL = h.shareURL(access.Token)
+		w.Header().Set("Cache-Control", "private, no-store")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+	} else {
+		w.Header().Set("Cache-Control", viewer.cacheControl())
+	}
+	h.renderEmbed(w, http.StatusOK, "embed.html", data.Title, data)
+}
+
+// ServeOEmbed は url で指定した作品の oEmbed (JSON) を返します。
+// url にはプレビュー画面・埋め込み画面・共有リンクの URL を指定でき、共有リンクの場合はログインなしで応答します。
+func (h *Handler) ServeOEmbed(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := r.URL.Query()
+	if format := q.Get("format"); format != "" && format != "json" {
+		http.Error(w, "JSON のみ対応しています", http.StatusNotImplemented)
+		return
+	}
+
+	access, shared := shareAccessFromContext(ctx)
+	title := access.Claims.Title
+	if !shared {
+		var ok bool
+		if title, ok = h.titleFromURL(q.Get("url")); !ok {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	manga, err := h.loadMangaJSON(r, title)
+	if err != nil {
+		h.handleError(w, r, "プロットJSONの読み込みに失敗しました", title, err, http.StatusNotFound)
+		return
+	}
+
+	src := h.serviceURL("/embed/" + title)
+	if shared {
+		src += "?" + url.Values{"token": {access.Token}}.Encode()
+	}
+	width, height := fitSize(embedDefaultWidth, embedDefaultHeight, positiveInt(q.Get("maxwidth")), positiveInt(q.Get("maxheight")))
+	displayTitle := h.displayTitle(r, title, cmp.Or(manga.Title, title))
+
+	resp := oEmbedResponse{
+		Version:      "1.0",
+		Type:         "rich",
+		Title:        displayTitle,
+		ProviderName: providerName,
+		ProviderURL:  h.serviceURL("/"),
+		HTML: fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" style="border:0" loading="lazy" allowfullscreen title="%s"></iframe>`,
+			html.EscapeString(src), width, height, html.EscapeString(displayTitle)),
+		Width:  width,
+		Height: height,
+		// サムネイルの署名付きURLが切れる前に取り直してもらいます
+		CacheAge: int(config.SignedURLExpiration.Seconds()) / 2,
+	}
+	if err := h.setOEmbedThumbnail(r, title, &resp); err != nil {
+		slog.WarnContext(ctx, "サムネイルの取得に失敗しました", "title", title, "error", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", resp.CacheAge))
+	if shared {
+		// 共有リンクの oEmbed はブラウザから直接取得されることもあるため、どのオリジンにも許可します
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.ErrorContext(ctx, "レスポンスの書き込みに失敗しました", "error", err)
+	}
+}
+
+// setOEmbedThumbnail は1ページ目（なければ最初のパネル）の画像をサムネイルとして設定します。
+// oEmbed ではサムネイルの幅と高さが必須のため、画像のヘッダーからサイズを読み取ります。
+func (h *Handler) setOEmbedThumbnail(r *http.Request, title string, resp *oEmbedResponse) error {
+	paths, err := h.listImagePaths(r, title, asset.PageFileRegex)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		if paths, err = h.listImagePaths(r, title, asset.PanelFileRegex); err != nil {
+			return err
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	rc, err := h.remoteIO.Reader.Open(r.Context(), paths[0])
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	cfg, _, err := image.DecodeConfig(rc)
+	if err != nil {
+		return fmt.Errorf("画像サイズの取得に失敗しました: %w", err)
+	}
+
+	u, err := h.signedURLs.Sign(r.Context(), paths[0])
+	if err != nil {
+		return err
+	}
+	resp.ThumbnailURL, resp.ThumbnailWidth, resp.ThumbnailHeight = u.URL, cfg.Width, cfg.Height
+	return nil
+}
+
+// titleFromURL はプレビュー画面 (/{base}/{title}) または埋め込み画面 (/embed/{title}) の URL から作品を取り出します。
+func (h *Handler) titleFromURL(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	p := strings.TrimSuffix(u.Path, "/")
+	for _, prefix := range []string{h.titleURL(""), "/embed/"} {
+		if title, ok := strings.CutPrefix(p, prefix); ok && validTitle.MatchString(title) {
+			return title, true
+		}
+	}
+	return "", false
+}
+
+// displayTitle は meta.json の表示タイトルを返します。未設定または読み込めない場合は fallback を返します。
+func (h *Handler) displayTitle(r *http.Request, title, fallback string) string {
+	m, err := h.loadMeta(r, title)
+	if err != nil {
+		slog.WarnContext(r.Context(), "メタデータの読み込みに失敗しました", "title", title, "error", err)
+		return fallback
+	}
+	if m == nil || m.DisplayTitle == "" {
+		return fallback
+	}
+	return m.DisplayTitle
+}
+
+// serviceURL は SERVICE_URL を基準にした絶対 URL を返します。
+func (h *Handler) serviceURL(p string) string {
+	return strings.TrimSuffix(h.cfg.ServiceURL, "/") + p
+}
+
+// fitSize は幅 w・高さ h の縦横比を保ったまま、maxW・maxH (0 は制限なし) に収まる大きさを返します。
+func fitSize(w, h, maxW, maxH int) (int, int) {
+	if maxW > 0 && w > maxW {
+		w, h = maxW, h*maxW/w
+	}
+	if maxH > 0 && h > maxH {
+		w, h = w*maxH/h, maxH
+	}
+	return w, h
+}
+
+// positiveInt は s を正の整数として解釈します。数値でない場合は 0 を返します。
+func positiveInt(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
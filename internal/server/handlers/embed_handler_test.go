@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestShareOrAuthEmbed(t *testing.T) {
+	env := newTestEnv(t)
+	valid, expired, revoked, tampered := env.tokens(t)
+
+	tests := []struct {
+		name   string
+		target string
+		want   int
+	}{
+		{name: "有効なトークン", target: "/embed/t1?token=" + valid, want: http.StatusOK},
+		{name: "期限切れ", target: "/embed/t1?token=" + expired, want: http.StatusGone},
+		{name: "取り消し済み", target: "/embed/t1?token=" + revoked, want: http.StatusGone},
+		{name: "改ざん", target: "/embed/t1?token=" + tampered, want: http.StatusNotFound},
+		{name: "別の作品のトークン", target: "/embed/t2?token=" + valid, want: http.StatusNotFound},
+		{name: "トークンなしはログインが必要", target: "/embed/t1", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := env.serve(httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			if got := rec.Header().Get("Cache-Control"); got != "private, no-store" {
+				t.Errorf("Cache-Control = %q", got)
+			}
+			body := rec.Body.String()
+			if !strings.Contains(body, "https://signed.example/b/output/t1/images/manga_page_1.png") {
+				t.Errorf("body does not show the page image:\n%s", body)
+			}
+			// 「通常の画面で開く」リンクも共有リンクにします
+			if !strings.Contains(body, testServiceURL+"/share/"+valid) {
+				t.Errorf("body does not link to the share page:\n%s", body)
+			}
+		})
+	}
+}
+
+func TestServeOEmbedShareURL(t *testing.T) {
+	env := newTestEnv(t)
+	valid, _, revoked, _ := env.tokens(t)
+
+	oembed := func(target string) *httptest.ResponseRecorder {
+		return env.serve(httptest.NewRequest(http.MethodGet, "/oembed?"+url.Values{"url": {target}}.Encode(), nil))
+	}
+
+	rec := oembed(testServiceURL + "/share/" + valid)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	var resp oEmbedResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Type != "rich" || resp.Title != "テスト作品" {
+		t.Errorf("response = %+v", resp)
+	}
+	if want := `src="` + testServiceURL + "/embed/t1?token=" + valid + `"`; !strings.Contains(resp.HTML, want) {
+		t.Errorf("html = %s, want iframe %s", resp.HTML, want)
+	}
+	if resp.ThumbnailWidth != 40 || resp.ThumbnailHeight != 60 {
+		t.Errorf("thumbnail = %dx%d, want 40x60 (manga_page_1.png)", resp.ThumbnailWidth, resp.ThumbnailHeight)
+	}
+
+	if rec := oembed(testServiceURL + "/share/" + revoked); rec.Code != http.StatusGone {
+		t.Errorf("revoked share URL: status = %d, want 410", rec.Code)
+	}
+	// 共有リンクでない URL はログインが必要です
+	if rec := oembed(testServiceURL + "/output/t1"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("preview URL without login: status = %d, want 401", rec.Code)
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi/v5"
+
+	"ap-manga-web/internal/export"
+)
+
+// ServeExportCBZ は指定されたタイトルのページ画像を CBZ としてダウンロードさせます。
+// アーカイブは保存せず、GCS から読み込みながらレスポンスに直接書き出します。
+func (h *Handler) ServeExportCBZ(w http.ResponseWriter, r *http.Request) {
+	title := chi.URLParam(r, "title")
+
+	book, err := h.loadBook(r, title)
+	if err != nil {
+		h.handleExportError(w, r, title, err)
+		return
+	}
+	if len(book.Pages) == 0 {
+		http.Error(w, "ページ画像がまだ生成されていません", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.comicbook+zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.cbz"`, title))
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	// ヘッダー送信後のエラーはステータスを変更できないため、ログのみ記録します
+	if err := export.WriteCBZ(r.Context(), w, book, h.remoteIO.Reader); err != nil {
+		slog.ErrorContext(r.Context(), "CBZ の書き出しに失敗しました", "title", title, "error", err)
+	}
+}
+
+// ServeExportPDF は指定されたタイトルを PDF としてダウンロードさせます。
+// クエリ transcript=1 を指定すると、末尾にセリフ一覧を追加します。
+func (h *Handler) ServeExportPDF(w http.ResponseWriter, r *http.Request) {
+	title := chi.URLParam(r, "title")
+
+	book, err := h.loadBook(r, title)
+	if err != nil {
+		h.handleExportError(w, r, title, err)
+		return
+	}
+	if len(book.Pages) == 0 {
+		http.Error(w, "ページ画像がまだ生成されていません", http.StatusNotFound)
+		return
+	}
+
+	opts := export.PDFOptions{Transcript: r.URL.Query().Get("transcript") == "1"}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, title))
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	if err := export.WritePDF(r.Context(), w, book, h.remoteIO.Reader, opts); err != nil {
+		slog.ErrorContext(r.Context(), "PDF の書き出しに失敗しました", "title", title, "error", err)
+	}
+}
+
+// ServeExportEPUB は指定されたタイトルを EPUB 3 固定レイアウトとしてダウンロードさせます。
+// ページ送りの方向は EPUB_PAGE_DIRECTION に従います。
+func (h *Handler) ServeExportEPUB(w http.ResponseWriter, r *http.Request) {
+	title := chi.URLParam(r, "title")
+
+	book, err := h.loadBook(r, title)
+	if err != nil {
+		h.handleExportError(w, r, title, err)
+		return
+	}
+	if len(book.Pages) == 0 {
+		http.Error(w, "ページ画像がまだ生成されていません", http.StatusNotFound)
+		return
+	}
+
+	opts := export.EPUBOptions{LeftToRight: h.cfg.EPUBPageDirection == "ltr"}
+
+	w.Header().Set("Content-Type", "application/epub+zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.epub"`, title))
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	if err := export.WriteEPUB(r.Context(), w, book, h.remoteIO.Reader, opts); err != nil {
+		slog.ErrorContext(r.Context(), "EPUB の書き出しに失敗しました", "title", title, "error", err)
+	}
+}
+
+// ServeExportBundle は指定されたタイトルを、オフラインで閲覧できる静的 HTML の ZIP としてダウンロードさせます。
+// 画像は相対パスで参照するため、署名付きURLの期限切れで表示できなくなることはありません。
+func (h *Handler) ServeExportBundle(w http.ResponseWriter, r *http.Request) {
+	title := chi.URLParam(r, "title")
+
+	book, err := h.loadBook(r, title)
+	if err != nil {
+		h.handleExportError(w, r, title, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, title))
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	if err := export.WriteBundle(r.Context(), w, book, h.remoteIO.Reader); err != nil {
+		slog.ErrorContext(r.Context(), "HTML バンドルの書き出しに失敗しました", "title", title, "error", err)
+	}
+}
+
+// handleExportError は書き出し対象の読み込みエラーを、作品がない場合は 404、不正なタイトルの場合は 400 として返します。
+func (h *Handler) handleExportError(w http.ResponseWriter, r *http.Request, title string, err error) {
+	if errors.Is(err, os.ErrNotExist) {
+		slog.InfoContext(r.Context(), "書き出し対象の作品が見つかりません", "title", title, "error", err)
+		http.NotFound(w, r)
+		return
+	}
+	h.handleError(w, r, "エクスポート対象の読み込みに失敗しました", title, err, http.StatusInternalServerError)
+}
+
+// loadBook はタイトルの作業ディレクトリから、書き出し用の作品情報を組み立てます。
+func (h *Handler) loadBook(r *http.Request, title string) (export.Book, error) {
+	workDir, err := h.validateAndCleanPath(title, "")
+	if err != nil {
+		return export.Book{}, err
+	}
+	chars, err := h.characters.Characters(r.Context())
+	if err != nil {
+		return export.Book{}, err
+	}
+	return export.LoadBook(r.Context(), h.remoteIO.Reader, h.cfg.GetGCSObjectURL(workDir), chars, h.cfg.MaxPanelsPerPage)
+}
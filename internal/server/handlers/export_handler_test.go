@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeExportStatus(t *testing.T) {
+	env := newTestEnv(t)
+
+	for _, format := range []string{"cbz", "pdf", "epub", "zip"} {
+		tests := []struct {
+			name  string
+			title string
+			want  int
+		}{
+			{name: "作品あり", title: "t1", want: http.StatusOK},
+			{name: "作品なし", title: "missing", want: http.StatusNotFound},
+			{name: "不正なタイトル", title: "t1.bak", want: http.StatusBadRequest},
+		}
+		for _, tt := range tests {
+			t.Run(format+"/"+tt.name, func(t *testing.T) {
+				rec := env.serve(httptest.NewRequest(http.MethodGet, "/output/"+tt.title+"/export."+format, nil))
+				if rec.Code != tt.want {
+					t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+				}
+			})
+		}
+	}
+}
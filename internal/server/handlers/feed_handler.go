@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"cmp"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"ap-manga-web/internal/catalog"
+	"ap-manga-web/internal/feed"
+	"ap-manga-web/internal/imaging"
+)
+
+const (
+	// feedSize はフィードに載せる作品数です。
+	feedSize = 50
+	// feedThumbnailExpiration はフィードに載せるサムネイルの署名付きURLの有効期限です。
+	// フィードリーダーは取得から時間をおいて画像を読み込むため、画面表示より長くしています。
+	feedThumbnailExpiration = 24 * time.Hour
+)
+
+// ServeFeed は BaseOutputDir 以下の公開済みの作品を、新しい順に Atom (既定) または RSS (?format=rss) で返します。
+// OAuth のセッションを持たないフィードリーダーから購読できるよう、FEED_TOKEN と一致する ?token= を求めます。
+// 画像が1枚もない生成中の作品は載せません。
+func (h *Handler) ServeFeed(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if h.cfg.FeedToken == "" {
+		http.NotFound(w, r)
+		return
+	}
+	token := r.URL.Query().Get("token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.cfg.FeedToken)) != 1 {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	entries, err := h.catalog.Entries(ctx)
+	if err != nil {
+		h.handleError(w, r, "作品一覧の取得に失敗しました", "", err, http.StatusInternalServerError)
+		return
+	}
+	entries = slices.DeleteFunc(entries, func(e *catalog.Entry) bool { return e.Thumbnail() == "" })
+	// ピン留めはギャラリーの表示順のためのものなので、フィードでは作成日時のみで並べます
+	slices.SortStableFunc(entries, func(a, b *catalog.Entry) int {
+		return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), cmp.Compare(a.Name, b.Name))
+	})
+	entries = entries[:min(len(entries), feedSize)]
+
+	rss := r.URL.Query().Get("format") == "rss"
+	self := url.Values{"token": {token}}
+	if rss {
+		self.Set("format", "rss")
+	}
+	f := feed.Feed{
+		ID:      h.serviceURL("/feed.xml"),
+		Title:   providerName + " 新着",
+		Link:    h.serviceURL("/gallery"),
+		Self:    h.serviceURL("/feed.xml?" + self.Encode()),
+		Author:  providerName,
+		Entries: h.feedEntries(r, entries),
+	}
+	for _, e := range f.Entries {
+		if e.Updated.After(f.Updated) {
+			f.Updated = e.Updated
+		}
+	}
+	if f.Updated.IsZero() {
+		// 作品がない場合も Atom の updated は必須のため、現在時刻を入れます
+		f.Updated = time.Now()
+	}
+
+	write, contentType := feed.WriteAtom, "application/atom+xml; charset=utf-8"
+	if rss {
+		write, contentType = feed.WriteRSS, "application/rss+xml; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	// トークンを含むため共有キャッシュには残さず、フィードリーダーの再取得の間隔に合わせて短時間のみキャッシュします
+	w.Header().Set("Cache-Control", "private, max-age=900")
+	w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+	if err := write(w, f); err != nil {
+		slog.ErrorContext(ctx, "レスポンスの書き込みに失敗しました", "error", err)
+	}
+}
+
+// feedEntries は作品をフィードの項目に変換し、サムネイルの署名付きURLを並行して生成します。
+func (h *Handler) feedEntries(r *http.Request, entries []*catalog.Entry) []feed.Entry {
+	ctx := r.Context()
+	items := make([]feed.Entry, len(entries))
+	sem := make(chan struct{}, gallerySignConcurrency)
+	var wg sync.WaitGroup
+
+	for i, e := range entries {
+		link := h.serviceURL(h.titleURL(e.Name))
+		items[i] = feed.Entry{
+			ID:          link,
+			Title:       e.DisplayTitle(),
+			Description: e.Description,
+			Link:        link,
+			Published:   e.CreatedAt,
+			Updated:     e.CreatedAt,
+			Categories:  e.Tags(),
+		}
+		if e.Meta != nil && e.Meta.UpdatedAt.After(e.CreatedAt) {
+			items[i].Updated = e.Meta.UpdatedAt
+		}
+
+		thumb := feedThumbnail(e)
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			u, err := h.remoteIO.Signer.GenerateSignedURL(ctx, thumb, http.MethodGet, feedThumbnailExpiration)
+			if err != nil {
+				slog.ErrorContext(ctx, "署名付きURL生成失敗", "path", thumb, "error", err)
+				return
+			}
+			items[i].Thumbnail = u
+		}()
+	}
+	wg.Wait()
+	return items
+}
+
+// feedThumbnail はサムネイルに使う画像のパスを返します。JPEG の縮小画像があれば最も小さいものを、なければ元画像を使用します。
+// フィードリーダーは WebP に対応しないことがあるため、WebP は使用しません。
+func feedThumbnail(e *catalog.Entry) string {
+	thumb := e.Thumbnail()
+	best, bestWidth := thumb, 0
+	for _, v := range e.VariantsOf(thumb) {
+		pv, ok := imaging.ParseVariantName(path.Base(v))
+		if ok && pv.Format == imaging.VariantJPEG && (bestWidth == 0 || pv.Width < bestWidth) {
+			best, bestWidth = v, pv.Width
+		}
+	}
+	return best
+}
+
+// feedURL はトークンを含む新着フィードの購読用 URL を返します。FEED_TOKEN が未設定の場合は空です。
+func (h *Handler) feedURL() string {
+	if h.cfg.FeedToken == "" {
+		return ""
+	}
+	return h.serviceURL("/feed.xml?" + url.Values{"token": {h.cfg.FeedToken}}.Encode())
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ap-manga-web/internal/catalog"
+)
+
+const (
+	// galleryPageSize は1ページに表示する作品数です。
+	galleryPageSize = 24
+	// gallerySignConcurrency はサムネイルの署名付きURLを生成する際の同時実行数です。
+	gallerySignConcurrency = 8
+)
+
+var jst = time.FixedZone("Asia/Tokyo", 9*60*60)
+
+// galleryData はテンプレート「gallery.html」に渡すためのデータ構造体
+type galleryData struct {
+	Query    string
+	Tag      string
+	Favorite bool
+	Sort     string
+	Items    []galleryItem
+	Total    int
+	// Page は現在のページ番号 (1始まり) です。
+	Page       int
+	TotalPages int
+	PrevURL    string
+	NextURL    string
+	// ClearTagURL はタグの絞り込みを解除したリンクです。
+	ClearTagURL string
+	// FeedURL は新着フィードの購読用 URL です。FEED_TOKEN が未設定の場合は空です。
+	FeedURL string
+}
+
+// galleryItem は一覧の作品1件分の表示内容です。
+type galleryItem struct {
+	Name        string
+	URL         string
+	Title       string
+	Description string
+	Tags        []string
+	Owner       string
+	Pinned      bool
+	Favorite    bool
+	Command     string
+	Mode        string
+	CreatedAt   time.Time
+	Thumbnail   imageSources
+}
+
+// galleryQuery は一覧の絞り込みと並び順の指定です。
+type galleryQuery struct {
+	q        string
+	tag      string
+	favorite bool
+	sort     catalog.SortKey
+}
+
+// Gallery は BaseOutputDir 以下の作品を一覧表示します。
+// クエリ q でタイトル・タグを、tag でタグを、favorite=1 でお気に入りを絞り込み、
+// sort (new, old, title) で並べ替え、page でページを指定します。ピン留めした作品は先頭に表示します。
+func (h *Handler) Gallery(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	gq := galleryQuery{
+		q:        query.Get("q"),
+		tag:      strings.TrimSpace(query.Get("tag")),
+		favorite: query.Get("favorite") == "1",
+		sort:     catalog.ParseSortKey(query.Get("sort")),
+	}
+
+	entries, err := h.catalog.Entries(r.Context())
+	if err != nil {
+		h.handleError(w, r, "作品一覧の取得に失敗しました", "", err, http.StatusInternalServerError)
+		return
+	}
+	entries = catalog.FilterTag(catalog.Filter(entries, gq.q), gq.tag)
+	if gq.favorite {
+		entries = catalog.FilterFavorite(entries)
+	}
+	catalog.Sort(entries, gq.sort)
+
+	totalPages := max(1, (len(entries)+galleryPageSize-1)/galleryPageSize)
+	page, _ := strconv.Atoi(query.Get("page"))
+	page = min(max(page, 1), totalPages)
+	start := (page - 1) * galleryPageSize
+	end := min(start+galleryPageSize, len(entries))
+
+	data := galleryData{
+		Query:      gq.q,
+		Tag:        gq.tag,
+		Favorite:   gq.favorite,
+		Sort:       string(gq.sort),
+		Items:      h.galleryItems(r, entries[start:end]),
+		Total:      len(entries),
+		Page:       page,
+		TotalPages: totalPages,
+		FeedURL:    h.feedURL(),
+	}
+	if page > 1 {
+		data.PrevURL = gq.url(page - 1)
+	}
+	if page < totalPages {
+		data.NextURL = gq.url(page + 1)
+	}
+	if gq.tag != "" {
+		noTag := gq
+		noTag.tag = ""
+		data.ClearTagURL = noTag.url(1)
+	}
+
+	// 一覧は新しい作品の追加で変わるため、短時間のみキャッシュします
+	w.Header().Set("Cache-Control", "private, max-age=60")
+	h.render(w, r, http.StatusOK, "gallery.html", "Gallery", data)
+}
+
+// galleryItems は表示するページの作品について、サムネイルの署名付きURLを並行して生成します。
+func (h *Handler) galleryItems(r *http.Request, entries []*catalog.Entry) []galleryItem {
+	ctx := r.Context()
+	items := make([]galleryItem, len(entries))
+	sem := make(chan struct{}, gallerySignConcurrency)
+	var wg sync.WaitGroup
+
+	for i, e := range entries {
+		items[i] = galleryItem{
+			Name:        e.Name,
+			URL:         h.titleURL(e.Name),
+			Title:       e.DisplayTitle(),
+			Description: e.Description,
+			Tags:        e.Tags(),
+			Owner:       e.Owner(),
+			Pinned:      e.Pinned(),
+			Favorite:    e.Favorite(),
+			CreatedAt:   e.CreatedAt.In(jst),
+		}
+		if e.Job != nil {
+			items[i].Command = e.Job.Command
+			items[i].Mode = e.Job.Mode
+		}
+
+		thumb := e.Thumbnail()
+		if thumb == "" {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			paths := append([]string{thumb}, e.VariantsOf(thumb)...)
+			urls, err := h.imageURLs(r, e.Name, e.Job, paths)
+			if err != nil {
+				slog.ErrorContext(ctx, "画像URLの生成に失敗しました", "title", e.Name, "error", err)
+			}
+			if urls[0].URL == "" {
+				return
+			}
+			items[i].Thumbnail = groupVariants(paths[1:], urls[1:]).sources(urls[0].URL)
+		}()
+	}
+	wg.Wait()
+	return items
+}
+
+// url は同じ絞り込みと並び順で、一覧の指定ページへのリンクを返します。
+func (gq galleryQuery) url(page int) string {
+	v := url.Values{}
+	if gq.q != "" {
+		v.Set("q", gq.q)
+	}
+	if gq.tag != "" {
+		v.Set("tag", gq.tag)
+	}
+	if gq.favorite {
+		v.Set("favorite", "1")
+	}
+	if gq.sort != catalog.SortNewest {
+		v.Set("sort", string(gq.sort))
+	}
+	v.Set("page", strconv.Itoa(page))
+	return "/gallery?" + v.Encode()
+}
+
+// tagLink は作品のタグと、そのタグで絞り込んだ一覧へのリンクです。
+type tagLink struct {
+	Name string
+	URL  string
+}
+
+// tagLinks はタグごとに、そのタグで絞り込んだ一覧へのリンクを作成します。
+func tagLinks(tags []string) []tagLink {
+	links := make([]tagLink, len(tags))
+	for i, tag := range tags {
+		links[i] = tagLink{Name: tag, URL: "/gallery?" + url.Values{"tag": {tag}}.Encode()}
+	}
+	return links
+}
@@ -9,8 +9,13 @@ import (
 
 	"ap-manga-web/assets"
 	"ap-manga-web/internal/app"
+	"ap-manga-web/internal/catalog"
+	"ap-manga-web/internal/characters"
 	"ap-manga-web/internal/config"
 	"ap-manga-web/internal/domain"
+	"ap-manga-web/internal/search"
+	"ap-manga-web/internal/share"
+	"ap-manga-web/internal/signedurl"
 )
 
 const titleSuffix = " - AP Manga Web"
@@ -20,6 +25,15 @@ type Handler struct {
 	templateCache map[string]*template.Template
 	taskEnqueuer  *tasks.Enqueuer[domain.GenerateTaskPayload]
 	remoteIO      *app.RemoteIO
+	// characters は現在のキャラクター定義です。管理画面で追加・編集でき、再起動せずに反映します。
+	characters *characters.Registry
+	catalog    *catalog.Catalog
+	search     *search.Store
+	titles     domain.TitleStore
+	// shares は共有リンクを扱います。共有リンクを無効にしている場合は nil です。
+	shares *share.Store
+	// signedURLs は画面に表示する画像の署名付きURLをキャッシュします。
+	signedURLs *signedurl.Cache
 }
 
 // NewHandler は指定された構成に基づいて新しいハンドラーを初期化します。
@@ -28,6 +42,10 @@ func NewHandler(
 	cfg *config.Config,
 	taskEnqueuer *tasks.Enqueuer[domain.GenerateTaskPayload],
 	remoteIO *app.RemoteIO,
+	searchIndex *search.Store,
+	titles domain.TitleStore,
+	shares *share.Store,
+	chars *characters.Registry,
 ) (*Handler, error) {
 	cache := make(map[string]*template.Template)
 
@@ -76,5 +94,11 @@ func NewHandler(
 		templateCache: cache,
 		taskEnqueuer:  taskEnqueuer,
 		remoteIO:      remoteIO,
+		characters:    chars,
+		catalog:       catalog.New(remoteIO.Reader, cfg.GetGCSObjectURL(cfg.BaseOutputDir)),
+		search:        searchIndex,
+		titles:        titles,
+		shares:        shares,
+		signedURLs:    signedurl.NewCache(remoteIO.Signer, config.SignedURLExpiration),
 	}, nil
 }
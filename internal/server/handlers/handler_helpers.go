@@ -5,15 +5,61 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"path"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/shouni/go-manga-kit/asset"
 )
 
 var validTitle = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
 
+// layoutData はレイアウト「layout.html」に渡すデータ構造体です。
+type layoutData struct {
+	Title     string
+	Data      any
+	CSRFToken string
+	// Public はログインしていない相手に見せる画面であることを示します。ナビゲーションを表示しません。
+	Public bool
+	// Embed は iframe に埋め込む画面であることを示します。ナビゲーションとフッターを表示しません。
+	Embed bool
+	// Admin はログイン中の利用者が管理者であることを示します。ナビゲーションに管理画面へのリンクを表示します。
+	Admin bool
+}
+
 // render は HTML テンプレートをレンダリングし、レスポンスを書き込みます。
 func (h *Handler) render(w http.ResponseWriter, r *http.Request, status int, pageName string, title string, data any) {
+	h.renderLayout(w, status, pageName, layoutData{
+		Title:     title + titleSuffix,
+		Data:      data,
+		CSRFToken: csrfTokenFromContext(r.Context()),
+		Admin:     h.cfg.IsAdmin(userEmailFromContext(r.Context())),
+	})
+}
+
+// renderPublic は共有リンクなど、ログインしていない相手に見せる画面をレンダリングします。
+func (h *Handler) renderPublic(w http.ResponseWriter, status int, pageName string, title string, data any) {
+	h.renderLayout(w, status, pageName, layoutData{
+		Title:  title + titleSuffix,
+		Data:   data,
+		Public: true,
+	})
+}
+
+// renderEmbed は iframe に埋め込む画面をレンダリングします。
+func (h *Handler) renderEmbed(w http.ResponseWriter, status int, pageName string, title string, data any) {
+	h.renderLayout(w, status, pageName, layoutData{
+		Title:  title + titleSuffix,
+		Data:   data,
+		Public: true,
+		Embed:  true,
+	})
+}
+
+func (h *Handler) renderLayout(w http.ResponseWriter, status int, pageName string, renderData layoutData) {
 	tmpl, ok := h.templateCache[pageName]
 	if !ok {
 		slog.Error("キャッシュ内にテンプレートが見つかりません", "page", pageName)
@@ -21,16 +67,6 @@ func (h *Handler) render(w http.ResponseWriter, r *http.Request, status int, pag
 		return
 	}
 
-	renderData := struct {
-		Title     string
-		Data      any
-		CSRFToken string
-	}{
-		Title:     title + titleSuffix,
-		Data:      data,
-		CSRFToken: csrfTokenFromContext(r.Context()),
-	}
-
 	var buf bytes.Buffer
 	// レイアウトファイルをベースに実行します
 	if err := tmpl.ExecuteTemplate(&buf, "layout.html", renderData); err != nil {
@@ -46,17 +82,51 @@ func (h *Handler) render(w http.ResponseWriter, r *http.Request, status int, pag
 	}
 }
 
+// titleURL は作品のプレビュー画面のパス (例: /output/{title}) を返します。
+func (h *Handler) titleURL(title string) string {
+	return "/" + strings.Trim(h.cfg.BaseOutputDir, "/") + "/" + title
+}
+
+// editedTitleURL は作品を編集した後に戻るプレビュー画面の URL を返します。
+// プレビュー画面はブラウザにキャッシュさせるため、編集のたびに異なるクエリを付けてキャッシュを使わずに表示させます。
+// fragment には "#share" のように # から始まる値を指定します。
+func (h *Handler) editedTitleURL(title, fragment string) string {
+	return h.titleURL(title) + "?" + url.Values{"edited": {strconv.FormatInt(time.Now().UnixMilli(), 36)}}.Encode() + fragment
+}
+
 // validateAndCleanPath タイトルを検証し、指定されたワークスペース内に安全でクリーンなファイル パスを構築します
+// 不正なタイトルやパスの場合は ErrInvalidPath をラップしたエラーを返します。
 func (h *Handler) validateAndCleanPath(title, file string) (string, error) {
 	if title == "" || !validTitle.MatchString(title) {
-		return "", fmt.Errorf("invalid title: %s", title)
+		return "", fmt.Errorf("%w: invalid title: %s", ErrInvalidPath, title)
 	}
 
 	baseDir := h.cfg.GetWorkDir(title)
 	cleaned := path.Clean(path.Join(baseDir, file))
 
 	if !strings.HasPrefix(cleaned, baseDir) {
-		return "", fmt.Errorf("potential traversal: %s", cleaned)
+		return "", fmt.Errorf("%w: potential traversal: %s", ErrInvalidPath, cleaned)
 	}
 	return cleaned, nil
 }
+
+// requireTitle は作品の台本があることを確認します。
+// 存在しない作品の作業ディレクトリに meta.json などだけが作られないよう、書き込みの前に使用します。
+// 作品がない場合はエラーのレスポンスを書き込み、false を返します。
+func (h *Handler) requireTitle(w http.ResponseWriter, r *http.Request, title string) bool {
+	plotPath, err := h.validateAndCleanPath(title, asset.DefaultMangaPlotJson)
+	if err != nil {
+		h.handleError(w, r, "不正なタイトルです", title, err, http.StatusBadRequest)
+		return false
+	}
+	exists, err := h.remoteIO.Reader.Exists(r.Context(), h.cfg.GetGCSObjectURL(plotPath))
+	if err != nil {
+		h.handleError(w, r, "作品の確認に失敗しました", title, err, http.StatusInternalServerError)
+		return false
+	}
+	if !exists {
+		http.NotFound(w, r)
+		return false
+	}
+	return true
+}
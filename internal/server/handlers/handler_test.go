@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ap-manga-web/assets"
+	"ap-manga-web/internal/adapters"
+	"ap-manga-web/internal/app"
+	"ap-manga-web/internal/characters"
+	"ap-manga-web/internal/config"
+	"ap-manga-web/internal/share"
+	"ap-manga-web/internal/storagetest"
+)
+
+const (
+	testServiceURL = "https://manga.example"
+	testPlot       = `{"title":"テスト作品","panels":[{"page":1,"speaker_id":"zundamon","visual_anchor":"a","dialogue":"やあ","reference_url":"images/panel_1.png"}]}`
+)
+
+// testEnv はテスト用のストレージと共有リンクを備えた Handler です。
+type testEnv struct {
+	router http.Handler
+	mem    storagetest.Memory
+	signer *share.Signer
+	shares *share.Store
+}
+
+// newTestEnv は作品 t1 と t2 (台本とページ・パネル画像) を置いたストレージで Handler を作成します。
+// ログインが必要なルートは、ログインしていないものとして 401 を返します。
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	mem := storagetest.Memory{}
+	for _, title := range []string{"t1", "t2"} {
+		dir := "gs://b/output/" + title
+		mem[dir+"/manga_plot.json"] = testPlot
+		mem[dir+"/images/manga_page_1.png"] = testPNG(t, 40, 60)
+		mem[dir+"/images/panel_1.png"] = testPNG(t, 40, 30)
+		mem[dir+"/images/raw/panel_1.png"] = testPNG(t, 40, 30)
+	}
+
+	signer, err := share.NewSigner("0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shares := share.NewStore(signer, mem, mem, "gs://b/output")
+	cfg := &config.Config{ServiceURL: testServiceURL, GCSBucket: "b", BaseOutputDir: "output", MaxPanelsPerPage: 6}
+	rio := &app.RemoteIO{Reader: mem, Writer: mem, Signer: storagetest.Signer{}}
+	chars, err := characters.NewRegistry(mem, mem, "gs://b/characters.json", assets.DefaultCharacters())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHandler(cfg, nil, rio, nil, adapters.NewTitleStoreAdapter(mem, mem), shares, chars)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requireLogin := func(http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "login required", http.StatusUnauthorized)
+		})
+	}
+	r := chi.NewRouter()
+	r.Get("/share/{token}", h.ServeShare)
+	r.Group(func(r chi.Router) {
+		r.Use(h.ShareOrAuth(requireLogin))
+		r.Get("/embed/{title}", h.ServeEmbed)
+		r.Get("/oembed", h.ServeOEmbed)
+	})
+	r.Get("/output/{title}/img/*", h.ServeImage)
+	r.Get("/output/{title}/export.cbz", h.ServeExportCBZ)
+	r.Get("/output/{title}/export.pdf", h.ServeExportPDF)
+	r.Get("/output/{title}/export.epub", h.ServeExportEPUB)
+	r.Get("/output/{title}/export.zip", h.ServeExportBundle)
+
+	return &testEnv{router: r, mem: mem, signer: signer, shares: shares}
+}
+
+// tokens は作品 t1 の有効・期限切れ・取り消し済み・改ざんしたトークンを発行します。
+func (e *testEnv) tokens(t *testing.T) (valid, expired, revoked, tampered string) {
+	t.Helper()
+	ctx := context.Background()
+	link, valid, err := e.shares.Create(ctx, "t1", "a@example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired = e.signer.Sign(share.Claims{Title: "t1", ID: link.ID, ExpiresAt: time.Now().Add(-time.Minute).Truncate(time.Second)})
+
+	gone, revoked, err := e.shares.Create(ctx, "t1", "a@example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.shares.Revoke(ctx, "t1", gone.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// 署名の途中の1文字を置き換えます (末尾の文字は使われないビットを含むため避けます)
+	b := []byte(valid)
+	i := len(b) - 5
+	if b[i] == 'A' {
+		b[i] = 'B'
+	} else {
+		b[i] = 'A'
+	}
+	return valid, expired, revoked, string(b)
+}
+
+// serve は router にリクエストを送り、レスポンスを返します。
+func (e *testEnv) serve(req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	e.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// testPNG は w×h の PNG 画像を返します。
+func testPNG(t *testing.T, w, h int) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
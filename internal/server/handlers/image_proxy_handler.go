@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shouni/go-manga-kit/asset"
+
+	"ap-manga-web/internal/domain"
+	"ap-manga-web/internal/imaging"
+	"ap-manga-web/internal/signedurl"
+)
+
+const (
+	// maxProxyImageSize は画像プロキシで配信する画像の上限サイズです。ETag の計算と範囲リクエストのため、画像はメモリに読み込みます。
+	maxProxyImageSize = 32 << 20
+	// proxyImageMaxAge はブラウザが画像を再検証せずに使う期間です。
+	proxyImageMaxAge = 24 * time.Hour
+)
+
+// ServeImage は作品の画像 (images/ 直下のページ・パネル画像と images/variants/ の縮小画像) をストレージから配信します。
+// ETag と Last-Modified による条件付きリクエストと範囲リクエストに対応し、ブラウザにのみ長めにキャッシュさせます。
+func (h *Handler) ServeImage(w http.ResponseWriter, r *http.Request) {
+	title := chi.URLParam(r, "title")
+	file := chi.URLParam(r, "*")
+	ctx := r.Context()
+	if !isProxyImageFile(file) {
+		http.NotFound(w, r)
+		return
+	}
+	relPath, err := h.validateAndCleanPath(title, path.Join(asset.DefaultImageDir, file))
+	if err != nil {
+		h.handleError(w, r, "不正なパスです", title, err, http.StatusBadRequest)
+		return
+	}
+
+	rc, err := h.remoteIO.Reader.Open(ctx, h.cfg.GetGCSObjectURL(relPath))
+	if errors.Is(err, os.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		h.handleError(w, r, "画像の読み込みに失敗しました", title, err, http.StatusInternalServerError)
+		return
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxProxyImageSize+1))
+	if err == nil && len(data) > maxProxyImageSize {
+		err = fmt.Errorf("画像が大きすぎます (%s)", file)
+	}
+	if err != nil {
+		h.handleError(w, r, "画像の読み込みに失敗しました", title, err, http.StatusInternalServerError)
+		return
+	}
+
+	// 同じ名前で画像を作り直しても検出できるよう、ETag は内容から求めます
+	sum := sha256.Sum256(data)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(proxyImageMaxAge.Seconds())))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, path.Base(file), objectModTime(rc), bytes.NewReader(data))
+}
+
+// isProxyImageFile は画像プロキシで配信できるファイル (images/ からの相対パス) かを返します。
+// 写植前の raw/ など、画面に表示しない画像は配信しません。
+func isProxyImageFile(file string) bool {
+	dir, name := path.Split(file)
+	switch dir {
+	case "":
+		return asset.PageFileRegex.MatchString(name) || asset.PanelFileRegex.MatchString(name)
+	case imaging.VariantDir + "/":
+		return imaging.VariantFileRegex.MatchString(name)
+	default:
+		return false
+	}
+}
+
+// objectModTime はストレージのオブジェクトの更新日時を返します。
+// GCS の Reader とローカルファイルから取得でき、取得できない場合はゼロ値 (Last-Modified を付けない) を返します。
+func objectModTime(rc io.ReadCloser) time.Time {
+	switch o := rc.(type) {
+	case interface{ LastModified() (time.Time, error) }:
+		if t, err := o.LastModified(); err == nil {
+			return t
+		}
+	case interface{ Stat() (fs.FileInfo, error) }:
+		if fi, err := o.Stat(); err == nil {
+			return fi.ModTime()
+		}
+	}
+	return time.Time{}
+}
+
+// useImageProxy は、この画面の画像を画像プロキシ経由で表示するかを返します。
+// 画像プロキシはログインが必要なため、共有リンクのトークンで表示する画面では署名付きURLを使用します。
+func (h *Handler) useImageProxy(r *http.Request) bool {
+	if !h.cfg.ImageProxy {
+		return false
+	}
+	_, shared := shareAccessFromContext(r.Context())
+	return !shared
+}
+
+// imageURLs は作品の画像の表示用URLを paths と同じ順に返します。
+// 画像プロキシを使う場合は /{BaseOutputDir}/{title}/img/{file} (期限なし)、それ以外は署名付きURLです。
+// 画像プロキシの URL には、画像を同じ名前で作り直した後にブラウザのキャッシュを使わないよう、ジョブ記録の更新日時をクエリに付けます。
+// URL を作れなかった画像は空の URL になります。
+func (h *Handler) imageURLs(r *http.Request, title string, job *domain.JobRecord, paths []string) ([]signedurl.URL, error) {
+	if !h.useImageProxy(r) {
+		return h.signedURLs.SignAll(r.Context(), paths, previewSignConcurrency)
+	}
+
+	urls := make([]signedurl.URL, len(paths))
+	imageDir, err := h.validateAndCleanPath(title, asset.DefaultImageDir)
+	if err != nil {
+		return urls, err
+	}
+	prefix := h.cfg.GetGCSObjectURL(imageDir) + "/"
+	query := imageVersionQuery(job)
+	for i, p := range paths {
+		if file, ok := strings.CutPrefix(p, prefix); ok {
+			urls[i] = signedurl.URL{URL: h.titleURL(title) + "/img/" + file + query}
+		}
+	}
+	return urls, nil
+}
+
+// imageVersionQuery は画像プロキシの URL に付ける版のクエリ (例: ?v=m1abcd) を返します。ジョブ記録や更新日時がない場合は空です。
+func imageVersionQuery(job *domain.JobRecord) string {
+	if job == nil || job.UpdatedAt.IsZero() {
+		return ""
+	}
+	return "?v=" + strconv.FormatInt(job.UpdatedAt.Unix(), 36)
+}
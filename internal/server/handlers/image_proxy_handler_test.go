@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestServeImageRejectsHiddenFiles(t *testing.T) {
+	env := newTestEnv(t)
+
+	for _, file := range []string{
+		"../manga_plot.json",
+		"variants/../panel_1.png",
+		"raw/panel_1.png",
+	} {
+		rec := env.serve(httptest.NewRequest(http.MethodGet, "/output/t1/img/"+file, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET img/%s: status = %d, want 404", file, rec.Code)
+		}
+	}
+}
+
+func TestServeImageConditionalAndRange(t *testing.T) {
+	env := newTestEnv(t)
+	const target = "/output/t1/img/panel_1.png"
+	body := env.mem["gs://b/output/t1/images/panel_1.png"]
+
+	rec := env.serve(httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != body {
+		t.Fatalf("status = %d, body length = %d, want 200 and %d bytes", rec.Code, rec.Body.Len(), len(body))
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("ETag is not set")
+	}
+	if got := rec.Header().Get("Content-Type"); got != "image/png" {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("If-None-Match", etag)
+	if rec := env.serve(req); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("If-None-Match: status = %d, body length = %d, want 304 and empty", rec.Code, rec.Body.Len())
+	}
+
+	// 画像を作り直すと ETag が変わり、古い ETag では 304 になりません
+	env.mem["gs://b/output/t1/images/panel_1.png"] = testPNG(t, 20, 20)
+	if rec := env.serve(req); rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Errorf("after regeneration: status = %d, ETag = %s", rec.Code, rec.Header().Get("ETag"))
+	}
+	env.mem["gs://b/output/t1/images/panel_1.png"] = body
+
+	req = httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Range", "bytes=0-9")
+	rec = env.serve(req)
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("Range: status = %d, want 206", rec.Code)
+	}
+	if rec.Body.String() != body[:10] {
+		t.Errorf("Range: body = %q, want %q", rec.Body.String(), body[:10])
+	}
+	if got, want := rec.Header().Get("Content-Range"), "bytes 0-9/"+strconv.Itoa(len(body)); got != want {
+		t.Errorf("Content-Range = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"ap-manga-web/internal/imaging"
+	"ap-manga-web/internal/signedurl"
+)
+
+// imageSources は1枚の画像の表示用URLです。縮小画像がない場合は Src のみを持ちます。
+type imageSources struct {
+	Src string // 元画像の URL
+	// Srcset は <img> の srcset です（JPEG の縮小画像と元画像）。
+	Srcset string
+	// WebPSrcset は <source type="image/webp"> の srcset です（WebP の縮小画像と元画像）。WebP がない場合は空です。
+	WebPSrcset string
+}
+
+// variantURL は縮小画像1件分の署名付きURLです。
+type variantURL struct {
+	URL   string
+	Width int
+	// SourceWidth は元画像の幅です。
+	SourceWidth int
+	// Format は imaging.VariantJPEG または imaging.VariantWebP です。
+	Format string
+}
+
+// imageVariants は元画像のファイル名 (拡張子なし) ごとの縮小画像です。
+type imageVariants map[string][]variantURL
+
+// groupVariants は縮小画像のパスと、同じ順に並んだ署名付きURLを元画像ごとにまとめます。署名できなかった画像は除きます。
+func groupVariants(paths []string, urls []signedurl.URL) imageVariants {
+	variants := make(imageVariants)
+	for i, p := range paths {
+		v, ok := imaging.ParseVariantName(path.Base(p))
+		if !ok || urls[i].URL == "" {
+			continue
+		}
+		variants[v.Stem] = append(variants[v.Stem], variantURL{URL: urls[i].URL, Width: v.Width, SourceWidth: v.SourceWidth, Format: v.Format})
+	}
+	return variants
+}
+
+// sources は元画像の URL に対応する表示用URLを組み立てます。
+// 縮小画像のファイル名に含まれる元画像の幅を使い、形式ごとの srcset に元画像を最大の候補として加えます。
+func (v imageVariants) sources(src string) imageSources {
+	out := imageSources{Src: src}
+	if src == "" {
+		return out
+	}
+	name := path.Base(strings.Split(src, "?")[0])
+	variants := v[strings.TrimSuffix(name, path.Ext(name))]
+	if len(variants) == 0 {
+		return out
+	}
+	original := variantURL{URL: src, Width: variants[0].SourceWidth}
+	out.Srcset = srcset(variantsOfFormat(variants, imaging.VariantJPEG), original)
+	out.WebPSrcset = srcset(variantsOfFormat(variants, imaging.VariantWebP), original)
+	return out
+}
+
+func variantsOfFormat(variants []variantURL, format string) []variantURL {
+	var out []variantURL
+	for _, v := range variants {
+		if v.Format == format {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// srcset は縮小画像と元画像を幅の小さい順に "URL 480w, URL 960w, URL 1024w" の形式で並べます。縮小画像がない場合は空です。
+func srcset(variants []variantURL, original variantURL) string {
+	if len(variants) == 0 {
+		return ""
+	}
+	sorted := append(slices.Clone(variants), original)
+	slices.SortFunc(sorted, func(a, b variantURL) int { return a.Width - b.Width })
+	parts := make([]string, len(sorted))
+	for i, v := range sorted {
+		parts[i] = fmt.Sprintf("%s %dw", v.URL, v.Width)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"testing"
+
+	"ap-manga-web/internal/signedurl"
+)
+
+func TestImageVariantSources(t *testing.T) {
+	paths := []string{
+		"gs://b/output/t1/images/variants/panel_1_w960_of1024.jpg",
+		"gs://b/output/t1/images/variants/panel_1_w480_of1024.jpg",
+		"gs://b/output/t1/images/variants/panel_1_w480_of1024.webp",
+		"gs://b/output/t1/images/variants/panel_2_w480_of1024.jpg",
+	}
+	urls := []signedurl.URL{{URL: "j960"}, {URL: "j480"}, {URL: "w480"}, {URL: "p2"}}
+	variants := groupVariants(paths, urls)
+
+	got := variants.sources("https://example.com/output/t1/images/panel_1.png?sig=x")
+	if want := "j480 480w, j960 960w, https://example.com/output/t1/images/panel_1.png?sig=x 1024w"; got.Srcset != want {
+		t.Errorf("Srcset = %q, want %q", got.Srcset, want)
+	}
+	if want := "w480 480w, https://example.com/output/t1/images/panel_1.png?sig=x 1024w"; got.WebPSrcset != want {
+		t.Errorf("WebPSrcset = %q, want %q", got.WebPSrcset, want)
+	}
+
+	// WebP のない画像は <source> を出力しない
+	if got := variants.sources("https://example.com/panel_2.png"); got.Srcset == "" || got.WebPSrcset != "" {
+		t.Errorf("sources(panel_2) = %+v, want JPEG srcset only", got)
+	}
+	if got := variants.sources("https://example.com/panel_3.png"); got != (imageSources{Src: "https://example.com/panel_3.png"}) {
+		t.Errorf("sources(panel_3) = %+v, want Src only", got)
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ap-manga-web/internal/domain"
+)
+
+// UpdateMeta はプレビュー画面の編集フォームから送信された作品のメタデータを meta.json に保存し、
+// 一覧のキャッシュと検索索引を更新してからプレビュー画面へ戻ります。
+func (h *Handler) UpdateMeta(w http.ResponseWriter, r *http.Request) {
+	title := chi.URLParam(r, "title")
+	ctx := r.Context()
+
+	if err := r.ParseForm(); err != nil {
+		slog.WarnContext(ctx, "フォームの解析に失敗しました", "error", err)
+		http.Error(w, "リクエストの解析に失敗しました", http.StatusBadRequest)
+		return
+	}
+
+	meta := domain.TitleMeta{
+		DisplayTitle: r.FormValue("display_title"),
+		Tags:         domain.ParseTags(r.FormValue("tags")),
+		Owner:        r.FormValue("owner"),
+		Pinned:       r.FormValue("pinned") == "1",
+		Favorite:     r.FormValue("favorite") == "1",
+		Notes:        r.FormValue("notes"),
+	}
+	meta.Normalize()
+	if err := meta.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	meta.UpdatedAt = time.Now().UTC()
+
+	if !h.requireTitle(w, r, title) {
+		return
+	}
+
+	metaPath, err := h.validateAndCleanPath(title, domain.MetaFile)
+	if err != nil {
+		h.handleError(w, r, "不正なタイトルです", title, err, http.StatusBadRequest)
+		return
+	}
+	if err := h.titles.SaveMeta(ctx, h.cfg.GetGCSObjectURL(metaPath), meta); err != nil {
+		h.handleError(w, r, "メタデータの保存に失敗しました", title, err, http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(ctx, "メタデータを更新しました", "title", title, "user", userEmailFromContext(ctx))
+
+	h.catalog.Invalidate(title)
+	if err := h.search.Reindex(ctx, title); err != nil {
+		// 検索索引は rebuild-search で作り直せるため、保存自体は成功として扱います
+		slog.WarnContext(ctx, "検索索引の更新に失敗しました", "title", title, "error", err)
+	}
+
+	http.Redirect(w, r, h.editedTitleURL(title, ""), http.StatusSeeOther)
+}
+
+// loadMeta は作品の meta.json を読み込みます。ファイルがない場合は nil を返します。
+func (h *Handler) loadMeta(r *http.Request, title string) (*domain.TitleMeta, error) {
+	relPath, err := h.validateAndCleanPath(title, domain.MetaFile)
+	if err != nil {
+		return nil, err
+	}
+	return h.titles.LoadMeta(r.Context(), h.cfg.GetGCSObjectURL(relPath))
+}
@@ -1,89 +1,309 @@
 package handlers
 
 import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"os"
 	"path"
 	"regexp"
-	"sort"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/shouni/go-manga-kit/asset"
-	"github.com/shouni/go-manga-kit/ports"
 
-	"ap-manga-web/internal/config"
+	"ap-manga-web/internal/domain"
+	"ap-manga-web/internal/export"
+	"ap-manga-web/internal/imaging"
+	"ap-manga-web/internal/signedurl"
+)
+
+const (
+	// previewSignConcurrency はプレビュー画面の署名付きURLを生成する際の同時実行数です。
+	previewSignConcurrency = 8
+	// previewCacheMargin は、ブラウザのキャッシュから表示した画面の画像を読み込み終えるまでに残しておく署名付きURLの有効期間です。
+	previewCacheMargin = time.Minute
+	// viewerRefreshInterval は、生成中の作品の画面を自動で再読み込みする間隔です。
+	viewerRefreshInterval = 15 * time.Second
 )
 
 // mangaViewData はテンプレート「manga_view.html」に渡すためのデータ構造体
 type mangaViewData struct {
-	Title         string
-	OriginalTitle string
-	Manga         ports.MangaResponse // JSONからデコードしURL置換済みのデータ
-	PageURLs      []string            // ページ全体画像の署名付きURL
+	Title          string
+	OriginalTitle  string
+	BaseURL        string            // 作品ごとのルートURL (例: /output/{title})。エクスポート等のリンクに使用
+	Manga          domain.MangaPlot  // JSONからデコードしURL置換済みのデータ
+	Pages          []imageSources    // 生成済みのページ全体画像の署名付きURL（縮小画像があれば srcset 付き）
+	PageSlots      []imageSlot       // ページタブに並べるページ。生成中・失敗したページは画像のない枠になります
+	PanelImages    []imageSlot       // Manga.Panels と同じ順のパネル画像（縮小画像があれば srcset 付き）
+	Job            *domain.JobRecord // job.json の内容（ない場合は nil）
+	JobLabel       string            // 生成中・失敗したジョブの進行状況（終了したジョブでは空）
+	RefreshSeconds int               // 生成中の作品の画面を自動で再読み込みする間隔（秒）。0 の場合は再読み込みしません
+	Meta           domain.TitleMeta  // meta.json の内容（ない場合は空）
+	DisplayTitle   string            // 表示タイトル。未設定の場合は台本のタイトル
+	TagsText       string            // 編集フォーム用のカンマ区切りのタグ
+	TagLinks       []tagLink         // タグと、そのタグで絞り込んだ一覧へのリンク
+	ShareEnabled   bool              // 共有リンクを発行できるか
+	ShareLinks     []shareLinkView   // 有効な共有リンク
+	ReadOnly       bool              // 共有リンクからの閲覧（編集・エクスポート・共有の操作を表示しません）
+	ExpiresAt      time.Time         // 画面内の署名付きURLのうち最も早い有効期限（画像がない場合はゼロ値）
 }
 
 // ServePreview は指定されたタイトルの漫画成果物を取得し、プレビュー画面を表示します。
 func (h *Handler) ServePreview(w http.ResponseWriter, r *http.Request) {
 	title := chi.URLParam(r, "title")
 
-	// 1. JSONプロットの取得
-	manga, err := h.loadMangaJSON(r, title)
+	// 1. 台本と署名付き画像URLの取得
+	data, err := h.loadViewer(r, title)
 	if err != nil {
-		h.handleError(w, r, "プロットJSONの読み込みに失敗しました", title, err, http.StatusInternalServerError)
+		h.handleViewerError(w, r, title, err)
 		return
 	}
 
-	// 2. 署名付き画像URLリストの取得（Page用）
-	signedPageURLs, err := h.loadSignedImageURLs(r, title, asset.PageFileRegex)
+	// 2. メタデータの取得（失敗しても作品は表示します）
+	if m, err := h.loadMeta(r, title); err != nil {
+		slog.WarnContext(r.Context(), "メタデータの読み込みに失敗しました", "title", title, "error", err)
+	} else if m != nil {
+		data.Meta = *m
+	}
+	data.DisplayTitle = cmp.Or(data.Meta.DisplayTitle, data.DisplayTitle)
+	data.TagsText = strings.Join(data.Meta.Tags, ", ")
+	data.TagLinks = tagLinks(data.Meta.Tags)
+
+	// 3. 共有リンクの取得（失敗しても作品は表示します）
+	if h.shares != nil {
+		data.ShareEnabled = true
+		data.ShareLinks, err = h.shareLinks(r, title)
+		if err != nil {
+			slog.WarnContext(r.Context(), "共有リンクの読み込みに失敗しました", "title", title, "error", err)
+		}
+	}
+
+	// 4. キャッシュ制御
+	// 署名付きURLの有効期限が切れる前までキャッシュを許可します（生成中の作品は毎回再取得させます）。
+	// 編集後は editedTitleURL で別の URL に戻すため、保存した内容はすぐに表示されます
+	w.Header().Set("Cache-Control", data.cacheControl())
+
+	// 5. テンプレートのレンダリング
+	h.render(w, r, http.StatusOK, "manga_view.html", data.DisplayTitle, data)
+}
+
+// loadViewer は作品の台本を読み込み、画像を署名付きURLに置き換えた表示内容を組み立てます。
+// プレビュー画面と共有リンクの閲覧画面で共通に使用します。
+// 生成途中の作品や一部の画像の生成に失敗した作品は、ジョブ記録 (job.json) をもとに、ない画像を状態付きの枠として表示します。
+// 台本もジョブ記録もない場合は os.ErrNotExist を返します。
+func (h *Handler) loadViewer(r *http.Request, title string) (mangaViewData, error) {
+	ctx := r.Context()
+
+	// 1. JSONプロットとジョブ記録の取得（台本の作成中は、ジョブ記録だけで表示します）
+	job, err := h.loadJob(r, title)
 	if err != nil {
-		h.handleError(w, r, "ページ画像の取得に失敗しました", title, err, http.StatusInternalServerError)
-		return
+		slog.WarnContext(ctx, "ジョブ記録の読み込みに失敗しました", "title", title, "error", err)
+	}
+	manga, err := h.loadMangaJSON(r, title)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && job != nil:
+		manga = domain.MangaPlot{Panels: make([]domain.PlotPanel, job.Panels)}
+	case err != nil:
+		return mangaViewData{}, fmt.Errorf("プロットJSONの読み込みに失敗しました: %w", err)
 	}
 
-	// 3. 署名付き画像URLリストの取得（Panel用）
-	signedPanelURLs, err := h.loadSignedImageURLs(r, title, asset.PanelFileRegex)
+	// 2. ページ・パネル・縮小画像を1回の一覧取得で振り分け
+	images, err := h.listTitleImages(r, title)
 	if err != nil {
-		h.handleError(w, r, "パネル画像の取得に失敗しました", title, err, http.StatusInternalServerError)
-		return
+		return mangaViewData{}, fmt.Errorf("画像の取得に失敗しました: %w", err)
+	}
+
+	// 3. 表示用URLの生成（署名付きURLはキャッシュにないものだけを並行して署名します。失敗した画像は表示しません）
+	paths := slices.Concat(images.pages, images.panels, images.variants)
+	urls, err := h.imageURLs(r, title, job, paths)
+	if err != nil {
+		slog.ErrorContext(ctx, "画像URLの生成に失敗しました", "title", title, "error", err)
 	}
+	pageURLs := urls[:len(images.pages)]
+	panelURLs := urls[len(images.pages) : len(images.pages)+len(images.panels)]
+	variantURLs := urls[len(images.pages)+len(images.panels):]
 
 	// 4. マッピング処理：パネル内の相対パスを署名付きURLに置換
-	h.resolvePanelURLs(&manga, signedPanelURLs)
+	found := h.resolvePanelURLs(&manga, images.panels, panelURLs)
 
-	// 5. キャッシュ制御（署名付きURLの有効期限に同期）
-	cacheAgeSec := int64(config.SignedURLExpiration.Seconds())
-	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", cacheAgeSec))
+	// 5. 縮小画像の対応付け（生成前の古い作品にはないため、ない場合は元画像で表示します）
+	variants := groupVariants(images.variants, variantURLs)
+	signedPages := signedURLStrings(pageURLs)
+	pages := make([]imageSources, len(signedPages))
+	for i, u := range signedPages {
+		pages[i] = variants.sources(u)
+	}
+	panelImages := make([]imageSlot, len(manga.Panels))
+	for i, p := range manga.Panels {
+		if !found[i] {
+			panelImages[i] = missingSlot(job.MissingImageState(domain.JobStepPanel))
+			continue
+		}
+		panelImages[i] = imageSlot{imageSources: variants.sources(p.ReferenceURL)}
+	}
 
-	// 6. テンプレートのレンダリング
-	h.render(w, r, http.StatusOK, "manga_view.html", title, mangaViewData{
+	data := mangaViewData{
 		Title:         title,
 		OriginalTitle: manga.Title,
+		BaseURL:       h.titleURL(title),
 		Manga:         manga,
-		PageURLs:      signedPageURLs,
-	})
+		Pages:         pages,
+		PageSlots:     pageSlots(images.pages, pageURLs, variants, job, domain.PageCount(len(manga.Panels), h.cfg.MaxPanelsPerPage)),
+		PanelImages:   panelImages,
+		Job:           job,
+		JobLabel:      jobLabel(job),
+		DisplayTitle:  cmp.Or(manga.Title, title),
+		ExpiresAt:     signedurl.Earliest(urls...),
+	}
+	if job.Running() {
+		data.RefreshSeconds = int(viewerRefreshInterval.Seconds())
+	}
+	return data, nil
+}
+
+// cacheControl はプレビュー画面の Cache-Control です。生成中の作品は自動で再読み込みするため、キャッシュさせません。
+func (d mangaViewData) cacheControl() string {
+	if d.Job.Running() {
+		return "private, no-cache"
+	}
+	return previewCacheControl(d.ExpiresAt)
+}
+
+// handleViewerError は作品の画面を組み立てられなかった場合のエラーレスポンスを返します。台本もジョブ記録もない作品は 404 とします。
+func (h *Handler) handleViewerError(w http.ResponseWriter, r *http.Request, title string, err error) {
+	if errors.Is(err, os.ErrNotExist) {
+		slog.InfoContext(r.Context(), "作品が見つかりません", "title", title, "error", err)
+		http.NotFound(w, r)
+		return
+	}
+	h.handleError(w, r, "作品の読み込みに失敗しました", title, err, http.StatusInternalServerError)
+}
+
+// loadJob は作品のジョブ記録 (job.json) を読み込みます。ない場合は nil を返します。
+func (h *Handler) loadJob(r *http.Request, title string) (*domain.JobRecord, error) {
+	relPath, err := h.validateAndCleanPath(title, domain.JobRecordFile)
+	if err != nil {
+		return nil, err
+	}
+	return h.titles.LoadJob(r.Context(), h.cfg.GetGCSObjectURL(relPath))
 }
 
-// resolvePanelURLs は取得した署名付きURLを使って、MangaResponse内のReferenceURLを更新します。
-func (h *Handler) resolvePanelURLs(manga *ports.MangaResponse, signedURLs []string) {
-	panelMap := make(map[string]string)
-	for _, u := range signedURLs {
-		// クエリパラメータを除去してファイル名を取得
-		cleanPath := strings.Split(u, "?")[0]
-		fileName := path.Base(cleanPath)
-		panelMap[fileName] = u
+// resolvePanelURLs は台本内の ReferenceURL を、同じファイル名のパネル画像の表示用URLに置き換え、パネルごとに画像があるかを返します。
+// ReferenceURL がまだ書き込まれていないパネル（生成途中の作品）は、連番のファイル名 (panel_N.png) で対応付けます。
+// 画像がないパネルの ReferenceURL は空にします。
+func (h *Handler) resolvePanelURLs(manga *domain.MangaPlot, paths []string, urls []signedurl.URL) []bool {
+	byName := make(map[string]string, len(paths))
+	for i, p := range paths {
+		if urls[i].URL != "" {
+			byName[path.Base(p)] = urls[i].URL
+		}
 	}
 
+	found := make([]bool, len(manga.Panels))
 	for i := range manga.Panels {
 		p := &manga.Panels[i]
-		fileName := path.Base(p.ReferenceURL)
-		if signed, ok := panelMap[fileName]; ok {
-			p.ReferenceURL = signed
+		name := path.Base(p.ReferenceURL)
+		if p.ReferenceURL == "" {
+			name = indexedFileName(asset.DefaultPanelFileName, i+1)
+		}
+		p.ReferenceURL, found[i] = byName[name]
+	}
+	return found
+}
+
+// pageSlots はページタブに並べるページを組み立てます。
+// ページを生成するジョブでは、パネル数から求めたページ数 (expected) の枠を連番のファイル名 (manga_page_N.png) で対応付け、
+// まだない・生成できなかったページを状態付きの枠にします。それ以外の作品は生成済みのページだけを並べます。
+func pageSlots(paths []string, urls []signedurl.URL, variants imageVariants, job *domain.JobRecord, expected int) []imageSlot {
+	if !job.Includes(domain.JobStepPage) {
+		slots := make([]imageSlot, 0, len(paths))
+		for _, u := range urls {
+			if u.URL != "" {
+				slots = append(slots, imageSlot{imageSources: variants.sources(u.URL)})
+			}
+		}
+		return slots
+	}
+
+	byName := make(map[string]string, len(paths))
+	for i, p := range paths {
+		if urls[i].URL != "" {
+			byName[path.Base(p)] = urls[i].URL
+		}
+	}
+	slots := make([]imageSlot, max(expected, len(paths)))
+	for i := range slots {
+		u, ok := byName[indexedFileName(asset.DefaultPageFileName, i+1)]
+		if !ok {
+			slots[i] = missingSlot(job.MissingImageState(domain.JobStepPage))
+			continue
 		}
+		slots[i] = imageSlot{imageSources: variants.sources(u)}
 	}
+	return slots
+}
+
+// imageSlot は画面に並べる画像1枚分の枠です。画像がない場合は Missing が true になり、Status にその理由を表示します。
+type imageSlot struct {
+	imageSources
+	Missing bool
+	State   domain.ImageState
+	Status  string
+}
+
+// imageStateLabels は画像がない枠に表示する状態です。
+var imageStateLabels = map[domain.ImageState]string{
+	domain.ImagePending:    "待機中",
+	domain.ImageGenerating: "生成中",
+	domain.ImageFailed:     "生成失敗",
+	domain.ImageMissing:    "画像なし",
+}
+
+// missingSlot は画像がない枠を返します。
+func missingSlot(state domain.ImageState) imageSlot {
+	return imageSlot{Missing: true, State: state, Status: imageStateLabels[state]}
+}
+
+// jobStepLabels はジョブの工程の表示名です。
+var jobStepLabels = map[domain.JobStep]string{
+	domain.JobStepScript: "台本",
+	domain.JobStepPanel:  "パネル",
+	domain.JobStepPage:   "ページ",
+}
+
+// jobLabel は生成中・失敗したジョブの進行状況を返します。終了したジョブと記録がない場合は空です。
+func jobLabel(job *domain.JobRecord) string {
+	if job == nil {
+		return ""
+	}
+	step := cmp.Or(jobStepLabels[job.Step], string(job.Step))
+	switch {
+	case job.Status == domain.JobQueued:
+		return "作り直しの開始を待っています"
+	case job.Status == domain.JobRunning:
+		return step + "を生成中です"
+	case job.Status == domain.JobFailed && step == "":
+		return "生成に失敗しました"
+	case job.Status == domain.JobFailed:
+		return step + "の生成に失敗しました"
+	default:
+		return ""
+	}
+}
+
+// indexedFileName は連番付きのファイル名を返します（例: panel.png, 2 → panel_2.png）。
+func indexedFileName(base string, index int) string {
+	ext := path.Ext(base)
+	return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(base, ext), index, ext)
 }
 
 // handleError は一貫したロギングとエラーレスポンスを提供します。
@@ -98,30 +318,108 @@ func (h *Handler) handleError(w http.ResponseWriter, r *http.Request, msg, title
 }
 
 // loadMangaJSON は GCS から manga_plot.json を読み込み、ドメインモデルにデコードします。
-func (h *Handler) loadMangaJSON(r *http.Request, title string) (ports.MangaResponse, error) {
-	var manga ports.MangaResponse
+func (h *Handler) loadMangaJSON(r *http.Request, title string) (domain.MangaPlot, error) {
+	manga, _, err := h.loadPlot(r, title)
+	return manga, err
+}
+
+// loadPlot は manga_plot.json を読み込み、台本と、内容から求めた版 (revision) を返します。
+// 版は編集画面で、開いてから保存するまでの間に台本が更新されていないかの確認に使用します。
+func (h *Handler) loadPlot(r *http.Request, title string) (domain.MangaPlot, string, error) {
+	var manga domain.MangaPlot
 	relPath, err := h.validateAndCleanPath(title, asset.DefaultMangaPlotJson)
 	if err != nil {
-		return manga, err
+		return manga, "", err
 	}
 
 	plotPath := h.cfg.GetGCSObjectURL(relPath)
 	rc, err := h.remoteIO.Reader.Open(r.Context(), plotPath)
 	if err != nil {
-		return manga, fmt.Errorf("JSONファイルが見つかりません: %w", err)
+		return manga, "", fmt.Errorf("JSONファイルが見つかりません: %w", err)
 	}
 	defer rc.Close()
 
-	if err := json.NewDecoder(rc).Decode(&manga); err != nil {
-		return manga, fmt.Errorf("JSONの解析に失敗しました: %w", err)
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return manga, "", fmt.Errorf("JSONファイルの読み込みに失敗しました: %w", err)
 	}
-	return manga, nil
+	if err := json.Unmarshal(data, &manga); err != nil {
+		return manga, "", fmt.Errorf("JSONの解析に失敗しました: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return manga, hex.EncodeToString(sum[:8]), nil
 }
 
-// loadSignedImageURLs は指定されたタイトルの画像をリストし、一時的な署名付きURLを生成します。
-func (h *Handler) loadSignedImageURLs(r *http.Request, title string, regex *regexp.Regexp) ([]string, error) {
-	ctx := r.Context()
+// titleImages は作品の images/ 以下の画像を種類ごとに振り分けた GCS パスです。
+type titleImages struct {
+	pages    []string
+	panels   []string
+	variants []string
+}
+
+// listTitleImages は作品の images/ を1回だけ一覧し、ページ・パネル・縮小画像 (images/variants/) に振り分けます。
+// ページとパネルは連番順に並べます。一覧取得は再帰的な前方一致検索 (GCS) を前提としています。
+func (h *Handler) listTitleImages(r *http.Request, title string) (titleImages, error) {
 	prefix, err := h.validateAndCleanPath(title, asset.DefaultImageDir)
+	if err != nil {
+		return titleImages{}, err
+	}
+	imageDir := h.cfg.GetGCSObjectURL(prefix)
+	variantDir := imageDir + "/" + imaging.VariantDir
+
+	var images titleImages
+	err = h.remoteIO.Reader.List(r.Context(), imageDir, func(gcsPath string) error {
+		name := path.Base(gcsPath)
+		switch {
+		case export.IsDirectChild(imageDir, gcsPath) && asset.PageFileRegex.MatchString(name):
+			images.pages = append(images.pages, gcsPath)
+		case export.IsDirectChild(imageDir, gcsPath) && asset.PanelFileRegex.MatchString(name):
+			images.panels = append(images.panels, gcsPath)
+		case export.IsDirectChild(variantDir, gcsPath) && imaging.VariantFileRegex.MatchString(name):
+			images.variants = append(images.variants, gcsPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return titleImages{}, fmt.Errorf("ストレージのリスト取得に失敗: %w", err)
+	}
+
+	export.SortIndexedPaths(images.pages)
+	export.SortIndexedPaths(images.panels)
+	return images, nil
+}
+
+// signedURLStrings は生成できた署名付きURLだけを順に返します。
+func signedURLStrings(urls []signedurl.URL) []string {
+	out := make([]string, 0, len(urls))
+	for _, u := range urls {
+		if u.URL != "" {
+			out = append(out, u.URL)
+		}
+	}
+	return out
+}
+
+// previewCacheControl は、ブラウザにキャッシュさせた画面の画像が読み込めなくならないよう、
+// 署名付きURLのうち最も早い有効期限から previewCacheMargin を差し引いた期間だけキャッシュを許可します。
+// 画像がない場合や残りが短い場合は毎回再取得させます。
+func previewCacheControl(expiresAt time.Time) string {
+	maxAge := time.Until(expiresAt) - previewCacheMargin
+	if expiresAt.IsZero() || maxAge < time.Second {
+		return "private, no-cache"
+	}
+	return fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds()))
+}
+
+// listImagePaths は指定されたタイトルの画像のうち regex に一致するものを、連番順に並べた GCS パスとして返します。
+func (h *Handler) listImagePaths(r *http.Request, title string, regex *regexp.Regexp) ([]string, error) {
+	return h.listDirPaths(r, title, asset.DefaultImageDir, regex)
+}
+
+// listDirPaths は作業ディレクトリ内の dir 直下のファイルのうち regex に一致するものを、連番順に並べた GCS パスとして返します。
+// サブディレクトリ (写植前の raw/ や縮小画像の variants/) のファイルは含めません。
+func (h *Handler) listDirPaths(r *http.Request, title, dir string, regex *regexp.Regexp) ([]string, error) {
+	prefix, err := h.validateAndCleanPath(title, dir)
 	if err != nil {
 		return nil, err
 	}
@@ -129,8 +427,8 @@ func (h *Handler) loadSignedImageURLs(r *http.Request, title string, regex *rege
 	gcsPrefix := h.cfg.GetGCSObjectURL(prefix)
 	var filePaths []string
 
-	err = h.remoteIO.Reader.List(ctx, gcsPrefix, func(gcsPath string) error {
-		if regex.MatchString(path.Base(gcsPath)) {
+	err = h.remoteIO.Reader.List(r.Context(), gcsPrefix, func(gcsPath string) error {
+		if export.IsDirectChild(gcsPrefix, gcsPath) && regex.MatchString(path.Base(gcsPath)) {
 			filePaths = append(filePaths, gcsPath)
 		}
 		return nil
@@ -139,17 +437,6 @@ func (h *Handler) loadSignedImageURLs(r *http.Request, title string, regex *rege
 		return nil, fmt.Errorf("ストレージのリスト取得に失敗: %w", err)
 	}
 
-	sort.Strings(filePaths)
-
-	var signedURLs []string
-	for _, gcsPath := range filePaths {
-		u, err := h.remoteIO.Signer.GenerateSignedURL(ctx, gcsPath, http.MethodGet, config.SignedURLExpiration)
-		if err != nil {
-			slog.ErrorContext(ctx, "署名付きURL生成失敗", "path", gcsPath, "error", err)
-			continue
-		}
-		signedURLs = append(signedURLs, u)
-	}
-
-	return signedURLs, nil
+	export.SortIndexedPaths(filePaths)
+	return filePaths, nil
 }
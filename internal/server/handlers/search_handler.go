@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ap-manga-web/internal/search"
+)
+
+// searchResultLimit は検索結果として表示する作品数の上限です。
+const searchResultLimit = 50
+
+// searchFieldLabels は該当箇所の種類の表示名です。
+var searchFieldLabels = map[search.Field]string{
+	search.FieldTitle:        "タイトル",
+	search.FieldTag:          "タグ",
+	search.FieldDescription:  "あらすじ",
+	search.FieldSpeaker:      "キャラクター",
+	search.FieldDialogue:     "セリフ",
+	search.FieldNarration:    "ナレーション",
+	search.FieldVisualAnchor: "Visual Anchor",
+}
+
+// searchData はテンプレート「search.html」に渡すためのデータ構造体
+type searchData struct {
+	Query   string
+	Results []searchResult
+	// Total は一致した作品の総数です。表示は searchResultLimit 件までです。
+	Total int
+}
+
+// searchResult は検索に一致した作品1件分の表示内容です。
+type searchResult struct {
+	Name        string
+	URL         string
+	Title       string
+	Description string
+	Tags        []tagLink
+	Hits        []searchHit
+}
+
+// searchHit は該当箇所1件分の表示内容です。パネルの該当箇所はプレビュー画面の該当パネルへリンクします。
+type searchHit struct {
+	Label   string
+	Snippet string
+	URL     string
+}
+
+// Search は全文検索の索引から、クエリ q のすべての語を含む作品を表示します。
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	data := searchData{Query: q}
+
+	if q != "" {
+		results, err := h.search.Search(r.Context(), q)
+		if err != nil {
+			h.handleError(w, r, "検索索引の読み込みに失敗しました", "", err, http.StatusInternalServerError)
+			return
+		}
+		data.Total = len(results)
+		for _, res := range results[:min(len(results), searchResultLimit)] {
+			data.Results = append(data.Results, h.searchResult(res))
+		}
+	}
+
+	w.Header().Set("Cache-Control", "private, no-cache")
+	h.render(w, r, http.StatusOK, "search.html", "Search", data)
+}
+
+// searchResult は検索結果を表示用に変換します。
+func (h *Handler) searchResult(res search.Result) searchResult {
+	base := h.titleURL(res.Name)
+	out := searchResult{
+		Name:        res.Name,
+		URL:         base,
+		Title:       res.Title,
+		Description: res.Description,
+		Tags:        tagLinks(res.Tags),
+	}
+	// タイトルをクリックした場合も、最初に該当したパネルを表示します
+	if n := res.FirstPanel(); n > 0 {
+		out.URL = fmt.Sprintf("%s#panel-%d", base, n)
+	}
+	for _, hit := range res.Hits {
+		sh := searchHit{Label: searchFieldLabels[hit.Field], Snippet: hit.Snippet, URL: base}
+		if hit.Panel > 0 {
+			sh.Label = fmt.Sprintf("PANEL %d・%s", hit.Panel, sh.Label)
+			sh.URL = fmt.Sprintf("%s#panel-%d", base, hit.Panel)
+		}
+		out.Hits = append(out.Hits, sh)
+	}
+	return out
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"cmp"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ap-manga-web/internal/share"
+)
+
+// defaultShareTTL は有効期間の指定がない場合の共有リンクの有効期間です。
+const defaultShareTTL = "7d"
+
+// shareTTLs は共有リンクの有効期間の選択肢です。
+var shareTTLs = map[string]time.Duration{
+	"1h":  time.Hour,
+	"1d":  24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+// shareLinkView はプレビュー画面に表示する共有リンク1件分の内容です。
+type shareLinkView struct {
+	ID        string
+	URL       string
+	CreatedBy string
+	ExpiresAt time.Time
+	RevokeURL string
+}
+
+// CreateShareLink は作品の共有リンクを発行し、共有リンクの一覧を開いたプレビュー画面へ戻ります。
+// フォームの expires_in (1h, 1d, 7d, 30d) で有効期間を指定します。
+func (h *Handler) CreateShareLink(w http.ResponseWriter, r *http.Request) {
+	title := chi.URLParam(r, "title")
+	ctx := r.Context()
+	if h.shares == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		slog.WarnContext(ctx, "フォームの解析に失敗しました", "error", err)
+		http.Error(w, "リクエストの解析に失敗しました", http.StatusBadRequest)
+		return
+	}
+	ttl, ok := shareTTLs[cmp.Or(r.FormValue("expires_in"), defaultShareTTL)]
+	if !ok {
+		http.Error(w, "不正な有効期間です", http.StatusBadRequest)
+		return
+	}
+	if !h.requireTitle(w, r, title) {
+		return
+	}
+
+	user := userEmailFromContext(ctx)
+	link, _, err := h.shares.Create(ctx, title, user, ttl)
+	if err != nil {
+		h.handleError(w, r, "共有リンクの発行に失敗しました", title, err, http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(ctx, "共有リンクを発行しました", "title", title, "link_id", link.ID, "expires_at", link.ExpiresAt, "user", user)
+
+	http.Redirect(w, r, h.editedTitleURL(title, "#share"), http.StatusSeeOther)
+}
+
+// RevokeShareLink は共有リンクを取り消し、共有リンクの一覧を開いたプレビュー画面へ戻ります。
+func (h *Handler) RevokeShareLink(w http.ResponseWriter, r *http.Request) {
+	title := chi.URLParam(r, "title")
+	id := chi.URLParam(r, "id")
+	ctx := r.Context()
+	if h.shares == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if _, err := h.validateAndCleanPath(title, ""); err != nil {
+		h.handleError(w, r, "不正なタイトルです", title, err, http.StatusBadRequest)
+		return
+	}
+
+	err := h.shares.Revoke(ctx, title, id)
+	if errors.Is(err, share.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		h.handleError(w, r, "共有リンクの取り消しに失敗しました", title, err, http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(ctx, "共有リンクを取り消しました", "title", title, "link_id", id, "user", userEmailFromContext(ctx))
+
+	http.Redirect(w, r, h.editedTitleURL(title, "#share"), http.StatusSeeOther)
+}
+
+// ServeShare は共有リンクのトークンを検証し、ログインなしで閲覧できる読み取り専用のプレビュー画面を表示します。
+// 画像の署名付きURLは signedurl のキャッシュから取得し、有効期間 (SignedURLExpiration) の半分までは同じ URL を使い回します。
+// このため共有リンクを取り消しても、それまでに表示した画面の画像 URL は、その URL の有効期限 (最長で SignedURLExpiration) まで有効です。
+func (h *Handler) ServeShare(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if h.shares == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	token := chi.URLParam(r, "token")
+	claims, err := h.shares.Resolve(ctx, token)
+	if err != nil {
+		h.handleShareError(w, r, err)
+		return
+	}
+	r = r.WithContext(withShareAccess(ctx, shareAccess{Claims: claims, Token: token}))
+
+	data, err := h.loadViewer(r, claims.Title)
+	if err != nil {
+		h.handleViewerError(w, r, claims.Title, err)
+		return
+	}
+	// メモや担当者は共有しないため、表示タイトルのみ反映します
+	data.DisplayTitle = h.displayTitle(r, claims.Title, data.DisplayTitle)
+	data.ReadOnly = true
+	slog.InfoContext(ctx, "共有リンクが閲覧されました", "title", claims.Title, "link_id", claims.ID)
+
+	// トークンを含む URL をキャッシュや画像の取得先に残さないようにします
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+	h.renderPublic(w, http.StatusOK, "manga_view.html", data.DisplayTitle, data)
+}
+
+// handleShareError は共有リンクの検証エラーをレスポンスに変換します。
+// 取り消し・期限切れは 410、トークンが正しくない場合は 404 とします。
+func (h *Handler) handleShareError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, share.ErrExpired), errors.Is(err, share.ErrRevoked):
+		http.Error(w, "この共有リンクは無効になりました", http.StatusGone)
+	case errors.Is(err, share.ErrInvalidToken):
+		http.NotFound(w, r)
+	default:
+		h.handleError(w, r, "共有リンクの確認に失敗しました", "", err, http.StatusInternalServerError)
+	}
+}
+
+// shareLinks は作品の有効な共有リンクを表示用に変換します。
+func (h *Handler) shareLinks(r *http.Request, title string) ([]shareLinkView, error) {
+	links, err := h.shares.Links(r.Context(), title)
+	if err != nil {
+		return nil, err
+	}
+	views := make([]shareLinkView, len(links))
+	for i, l := range links {
+		views[i] = shareLinkView{
+			ID:        l.ID,
+			URL:       h.shareURL(h.shares.Token(title, l)),
+			CreatedBy: l.CreatedBy,
+			ExpiresAt: l.ExpiresAt.In(jst),
+			RevokeURL: h.titleURL(title) + "/share/" + l.ID + "/revoke",
+		}
+	}
+	return views, nil
+}
+
+// shareURL は共有リンクの URL (例: https://example.com/share/{token}) を返します。
+func (h *Handler) shareURL(token string) string {
+	return h.serviceURL("/share/" + token)
+}
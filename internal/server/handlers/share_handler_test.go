@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeShare(t *testing.T) {
+	env := newTestEnv(t)
+	valid, expired, revoked, tampered := env.tokens(t)
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{name: "有効なトークン", token: valid, want: http.StatusOK},
+		{name: "期限切れ", token: expired, want: http.StatusGone},
+		{name: "取り消し済み", token: revoked, want: http.StatusGone},
+		{name: "改ざん", token: tampered, want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := env.serve(httptest.NewRequest(http.MethodGet, "/share/"+tt.token, nil))
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			if got := rec.Header().Get("Cache-Control"); got != "private, no-store" {
+				t.Errorf("Cache-Control = %q", got)
+			}
+			if got := rec.Header().Get("Referrer-Policy"); got != "no-referrer" {
+				t.Errorf("Referrer-Policy = %q", got)
+			}
+			body := rec.Body.String()
+			// 共有リンクの画面は署名付きURLで画像を表示します
+			if !strings.Contains(body, "テスト作品") || !strings.Contains(body, "https://signed.example/b/output/t1/images/panel_1.png") {
+				t.Errorf("body does not show the shared title:\n%s", body)
+			}
+		})
+	}
+}
@@ -34,6 +34,20 @@ func (h *Handler) HandleSubmit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	colorMode, err := domain.ParseColorMode(r.FormValue("color_mode"))
+	if err != nil {
+		slog.WarnContext(r.Context(), "color_mode に不正な値が指定されました", "input", r.FormValue("color_mode"))
+		http.Error(w, "不正なカラーモードです。", http.StatusBadRequest)
+		return
+	}
+
+	pageRenderer, err := domain.ParsePageRenderer(r.FormValue("page_renderer"))
+	if err != nil {
+		slog.WarnContext(r.Context(), "page_renderer に不正な値が指定されました", "input", r.FormValue("page_renderer"))
+		http.Error(w, "不正なページ作成方法です。", http.StatusBadRequest)
+		return
+	}
+
 	payload := domain.GenerateTaskPayload{
 		Command:      r.FormValue("command"),
 		ScriptURL:    r.FormValue("script_url"),
@@ -41,6 +55,9 @@ func (h *Handler) HandleSubmit(w http.ResponseWriter, r *http.Request) {
 		Mode:         r.FormValue("mode"),
 		Seed:         seed,
 		TargetPanels: targetPanels,
+		ColorMode:    string(colorMode),
+		PageRenderer: string(pageRenderer),
+		Owner:        userEmailFromContext(r.Context()),
 	}
 
 	if payload.Command == "" {
@@ -54,6 +54,28 @@ func setupRoutes(
 		})
 	}
 
+	// --- 共有リンク (ログイン不要) ---
+	// トークンの署名・有効期限・取り消しの記録を検証し、読み取り専用の画面のみを表示します
+	if h.Web != nil {
+		r.Get("/share/{token}", h.Web.ServeShare)
+	}
+
+	// --- 新着フィード (ログイン不要) ---
+	// フィードリーダーから購読できるよう、セッションの代わりに FEED_TOKEN で確認します
+	if h.Web != nil {
+		r.Get("/feed.xml", h.Web.ServeFeed)
+	}
+
+	// --- 埋め込み (iframe / oEmbed) ---
+	// 共有リンクのトークンがあればログイン不要、なければログインを求めます
+	if h.Web != nil && h.Auth != nil {
+		r.Group(func(r chi.Router) {
+			r.Use(h.Web.ShareOrAuth(h.Auth.Middleware))
+			r.Get("/embed/{title}", h.Web.ServeEmbed)
+			r.Get("/oembed", h.Web.ServeOEmbed)
+		})
+	}
+
 	// --- 認証が必要なルート (Web UI 用) ---
 	r.Group(func(r chi.Router) {
 		if h.Auth == nil {
@@ -79,7 +101,11 @@ func setupRoutes(
 					}
 					csrfToken = token
 				}
-				r = r.WithContext(handlers.WithCSRFToken(r.Context(), csrfToken))
+				ctx := handlers.WithCSRFToken(r.Context(), csrfToken)
+				if h.CurrentUser != nil {
+					ctx = handlers.WithUserEmail(ctx, h.CurrentUser(r))
+				}
+				r = r.WithContext(ctx)
 				next.ServeHTTP(w, r)
 			})
 		})
@@ -90,10 +116,13 @@ func setupRoutes(
 			r.Get("/script", h.Web.Script)
 			r.Get("/panel", h.Web.Panel)
 			r.Get("/page", h.Web.Page)
+			r.Get("/gallery", h.Web.Gallery)
+			r.Get("/search", h.Web.Search)
 
 			r.Post("/generate", h.Web.HandleSubmit)
 
 			setupOutputRoutes(r, cfg.BaseOutputDir, h.Web)
+			setupAdminRoutes(r, h.Web)
 		}
 	})
 
@@ -115,6 +144,21 @@ func setupRoutes(
 	})
 }
 
+// setupAdminRoutes は、ADMIN_EMAILS の利用者のみが使用できる管理画面のルートを設定します。
+func setupAdminRoutes(r chi.Router, webHandler *handlers.Handler) {
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(webHandler.RequireAdmin)
+
+		r.Get("/characters", webHandler.ServeCharacters)
+		r.Post("/characters", webHandler.CreateCharacter)
+		r.Get("/characters/new", webHandler.ServeCharacterForm)
+		r.Get("/characters/{id}", webHandler.ServeCharacterForm)
+		r.Post("/characters/{id}", webHandler.UpdateCharacter)
+		r.Post("/characters/{id}/retire", webHandler.RetireCharacter)
+		r.Post("/characters/{id}/restore", webHandler.RestoreCharacter)
+	})
+}
+
 // setupOutputRoutes は、指定されたベースディレクトリとハンドラを使用して、指定されたルーター上の出力関連のルートを設定します。
 func setupOutputRoutes(r chi.Router, baseDir string, webHandler *handlers.Handler) {
 	prefix := "/" + strings.Trim(baseDir, "/")
@@ -124,6 +168,16 @@ func setupOutputRoutes(r chi.Router, baseDir string, webHandler *handlers.Handle
 
 	r.Route(prefix, func(r chi.Router) {
 		r.Get("/{title}", webHandler.ServePreview)
+		r.Get("/{title}/export.cbz", webHandler.ServeExportCBZ)
+		r.Get("/{title}/export.pdf", webHandler.ServeExportPDF)
+		r.Get("/{title}/export.epub", webHandler.ServeExportEPUB)
+		r.Get("/{title}/export.zip", webHandler.ServeExportBundle)
+		r.Get("/{title}/img/*", webHandler.ServeImage)
+		r.Post("/{title}/meta", webHandler.UpdateMeta)
+		r.Get("/{title}/edit", webHandler.ServeEditor)
+		r.Post("/{title}/edit", webHandler.SaveEditor)
+		r.Post("/{title}/share", webHandler.CreateShareLink)
+		r.Post("/{title}/share/{id}/revoke", webHandler.RevokeShareLink)
 		r.Get("/{title}/", func(w http.ResponseWriter, r *http.Request) {
 			http.Redirect(w, r, strings.TrimSuffix(r.URL.Path, "/"), http.StatusMovedPermanently)
 		})
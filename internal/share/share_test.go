@@ -0,0 +1,82 @@
+package share
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"ap-manga-web/internal/storagetest"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func TestSignerRoundTrip(t *testing.T) {
+	s, err := NewSigner(testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Claims{Title: "20260101_000000_abcd1234", ID: "id1", ExpiresAt: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)}
+	token := s.Sign(want)
+	got, err := s.Parse(token)
+	if err != nil || got != want {
+		t.Fatalf("Parse() = %+v, %v, want %+v", got, err, want)
+	}
+
+	other, _ := NewSigner(strings.Repeat("x", minSecretLen))
+	tampered := s.Sign(Claims{Title: "other", ID: "id1", ExpiresAt: want.ExpiresAt})
+	p, _, _ := strings.Cut(tampered, ".")
+	_, sig, _ := strings.Cut(token, ".")
+	for _, bad := range []string{"", "abc", p + "." + sig, other.Sign(want)} {
+		if _, err := s.Parse(bad); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalidToken", bad, err)
+		}
+	}
+
+	if _, err := NewSigner("short"); err == nil {
+		t.Error("NewSigner(short) = nil error")
+	}
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	signer, _ := NewSigner(testSecret)
+	mem := storagetest.Memory{}
+	s := NewStore(signer, mem, mem, "gs://b/output/")
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	link, token, err := s.Create(ctx, "t1", "a@example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mem["gs://b/output/t1/shares.json"]; !ok {
+		t.Fatal("shares.json was not written")
+	}
+	if c, err := s.Resolve(ctx, token); err != nil || c.Title != "t1" || c.ID != link.ID {
+		t.Fatalf("Resolve() = %+v, %v", c, err)
+	}
+	if token != s.Token("t1", link) {
+		t.Error("Token() differs from the token returned by Create()")
+	}
+
+	if err := s.Revoke(ctx, "t1", link.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Resolve(ctx, token); !errors.Is(err, ErrRevoked) {
+		t.Errorf("Resolve(revoked) error = %v, want ErrRevoked", err)
+	}
+	if err := s.Revoke(ctx, "t1", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Revoke(missing) error = %v, want ErrNotFound", err)
+	}
+
+	_, token2, _ := s.Create(ctx, "t1", "", 2*time.Hour)
+	if links, _ := s.Links(ctx, "t1"); len(links) != 1 {
+		t.Errorf("Links() = %+v, want only the active link", links)
+	}
+	now = now.Add(3 * time.Hour)
+	if _, err := s.Resolve(ctx, token2); !errors.Is(err, ErrExpired) {
+		t.Errorf("Resolve(expired) error = %v, want ErrExpired", err)
+	}
+}
@@ -0,0 +1,215 @@
+package share
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shouni/go-remote-io/remoteio"
+)
+
+// LinksFile は作業ディレクトリに保存する共有リンクの記録のファイル名です。
+const LinksFile = "shares.json"
+
+// maxLinksPerTitle は1作品あたりの有効な共有リンクの上限です。
+const maxLinksPerTitle = 20
+
+// ErrNotFound は指定した共有リンクの記録がないことを示します。
+var ErrNotFound = errors.New("共有リンクが見つかりません")
+
+// Link は発行した共有リンクの記録です。トークンは Claims から作り直せるため保存しません。
+type Link struct {
+	ID        string     `json:"id"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Active は now の時点でリンクが有効かを返します。
+func (l Link) Active(now time.Time) bool {
+	return l.RevokedAt == nil && now.Before(l.ExpiresAt)
+}
+
+// Store は共有リンクの発行・取り消し・検証を行います。
+type Store struct {
+	signer *Signer
+	reader remoteio.InputReader
+	writer remoteio.Writer
+	// root は作品の作業ディレクトリを置くディレクトリ (例: gs://bucket/output) です。
+	root string
+	now  func() time.Time
+
+	// mu は shares.json の読み込みから書き込みまでを直列化します。
+	mu sync.Mutex
+}
+
+// NewStore は root (例: gs://bucket/output) 以下の作品の共有リンクを扱う Store を作成します。
+func NewStore(signer *Signer, reader remoteio.InputReader, writer remoteio.Writer, root string) *Store {
+	return &Store{signer: signer, reader: reader, writer: writer, root: strings.TrimSuffix(root, "/"), now: time.Now}
+}
+
+// Create は有効期間 ttl の共有リンクを発行し、記録とトークンを返します。
+func (s *Store) Create(ctx context.Context, title, createdBy string, ttl time.Duration) (Link, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	links, err := s.load(ctx, title)
+	if err != nil {
+		return Link{}, "", err
+	}
+	now := s.now().UTC()
+	links = prune(links, now)
+	if countActive(links, now) >= maxLinksPerTitle {
+		return Link{}, "", fmt.Errorf("有効な共有リンクは1作品あたり %d 件までです", maxLinksPerTitle)
+	}
+
+	link := Link{
+		ID:        newID(),
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl).Truncate(time.Second),
+	}
+	if err := s.save(ctx, title, append(links, link)); err != nil {
+		return Link{}, "", err
+	}
+	return link, s.Token(title, link), nil
+}
+
+// Revoke は共有リンクを取り消します。記録がない場合は ErrNotFound を返します。
+func (s *Store) Revoke(ctx context.Context, title, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	links, err := s.load(ctx, title)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(links, func(l Link) bool { return l.ID == id })
+	if i < 0 {
+		return ErrNotFound
+	}
+	if links[i].RevokedAt == nil {
+		now := s.now().UTC()
+		links[i].RevokedAt = &now
+	}
+	return s.save(ctx, title, prune(links, s.now()))
+}
+
+// Links は作品の有効な共有リンクを、有効期限の近い順に返します。
+func (s *Store) Links(ctx context.Context, title string) ([]Link, error) {
+	links, err := s.load(ctx, title)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	var active []Link
+	for _, l := range links {
+		if l.Active(now) {
+			active = append(active, l)
+		}
+	}
+	slices.SortFunc(active, func(a, b Link) int { return a.ExpiresAt.Compare(b.ExpiresAt) })
+	return active, nil
+}
+
+// Token は共有リンクのトークンを返します。
+func (s *Store) Token(title string, l Link) string {
+	return s.signer.Sign(Claims{Title: title, ID: l.ID, ExpiresAt: l.ExpiresAt})
+}
+
+// Resolve はトークンを検証し、共有する作品を含む Claims を返します。
+// 署名が正しくない場合は ErrInvalidToken、期限切れの場合は ErrExpired、
+// 取り消し済みまたは記録にない場合は ErrRevoked を返します。
+func (s *Store) Resolve(ctx context.Context, token string) (Claims, error) {
+	c, err := s.signer.Parse(token)
+	if err != nil {
+		return Claims{}, err
+	}
+	if !s.now().Before(c.ExpiresAt) {
+		return Claims{}, ErrExpired
+	}
+
+	links, err := s.load(ctx, c.Title)
+	if err != nil {
+		return Claims{}, err
+	}
+	i := slices.IndexFunc(links, func(l Link) bool { return l.ID == c.ID })
+	if i < 0 || links[i].RevokedAt != nil {
+		return Claims{}, ErrRevoked
+	}
+	return c, nil
+}
+
+func (s *Store) path(title string) string {
+	return s.root + "/" + title + "/" + LinksFile
+}
+
+// load は作品の共有リンクの記録を読み込みます。ファイルがない場合は空の記録を返します。
+func (s *Store) load(ctx context.Context, title string) ([]Link, error) {
+	p := s.path(title)
+	exists, err := s.reader.Exists(ctx, p)
+	if err != nil {
+		return nil, fmt.Errorf("共有リンクの記録の確認に失敗しました: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	rc, err := s.reader.Open(ctx, p)
+	if err != nil {
+		return nil, fmt.Errorf("共有リンクの記録の読み込みに失敗しました: %w", err)
+	}
+	defer rc.Close()
+
+	var links []Link
+	if err := json.NewDecoder(rc).Decode(&links); err != nil {
+		return nil, fmt.Errorf("共有リンクの記録の解析に失敗しました: %w", err)
+	}
+	return links, nil
+}
+
+func (s *Store) save(ctx context.Context, title string, links []Link) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(links); err != nil {
+		return fmt.Errorf("共有リンクの記録の生成に失敗しました: %w", err)
+	}
+	if err := s.writer.Write(ctx, s.path(title), &buf,
+		remoteio.WithContentType("application/json"),
+		remoteio.WithCacheControl("no-cache")); err != nil {
+		return fmt.Errorf("共有リンクの記録の保存に失敗しました: %w", err)
+	}
+	return nil
+}
+
+// prune は有効期限の切れた記録を取り除きます。期限切れのトークンは署名の検証で拒否できるため、記録は不要です。
+func prune(links []Link, now time.Time) []Link {
+	return slices.DeleteFunc(links, func(l Link) bool { return !now.Before(l.ExpiresAt) })
+}
+
+func countActive(links []Link, now time.Time) int {
+	n := 0
+	for _, l := range links {
+		if l.Active(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// newID はリンク ID として 8 バイトの乱数を16進数で返します。
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
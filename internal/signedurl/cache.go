@@ -0,0 +1,133 @@
+// Package signedurl は画像の署名付きURLを生成し、有効期限が切れる前に破棄する前提でキャッシュします。
+// 同じ作品を何度開いても、URL の署名はオブジェクトごとに一定の間隔で1回だけ行います。
+package signedurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/shouni/go-remote-io/remoteio"
+)
+
+// maxEntries はキャッシュする URL 数の目安です。超えた時点で使い回せなくなった URL を破棄します。
+const maxEntries = 10000
+
+// URL は署名付きURLと、その有効期限です。ExpiresAt がゼロ値の URL は期限がないものとして扱います。
+type URL struct {
+	URL       string
+	ExpiresAt time.Time
+}
+
+type entry struct {
+	URL
+	// reuseUntil はキャッシュした URL を返す期限です。
+	reuseUntil time.Time
+}
+
+// Cache はオブジェクトのパスごとに署名付きURLをキャッシュします。
+type Cache struct {
+	signer remoteio.URLSigner
+	// expiration は生成する URL の有効期間です。
+	expiration time.Duration
+	// reuse はキャッシュした URL を使い回す期間です。
+	// 返した URL には、少なくとも expiration - reuse の有効期間が残ります。
+	reuse time.Duration
+	now   func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+// NewCache は有効期間 expiration の署名付きURLを生成する Cache を作成します。
+// キャッシュした URL は有効期間の半分まで使い回すため、返す URL には常に expiration/2 以上の有効期間が残ります。
+func NewCache(signer remoteio.URLSigner, expiration time.Duration) *Cache {
+	return &Cache{
+		signer:     signer,
+		expiration: expiration,
+		reuse:      expiration / 2,
+		now:        time.Now,
+		entries:    make(map[string]entry),
+	}
+}
+
+// Sign は p の署名付きURLを返します。使い回せる URL がキャッシュにあればそれを返します。
+func (c *Cache) Sign(ctx context.Context, p string) (URL, error) {
+	now := c.now()
+	if u, ok := c.cached(p, now); ok {
+		return u, nil
+	}
+
+	u, err := c.signer.GenerateSignedURL(ctx, p, http.MethodGet, c.expiration)
+	if err != nil {
+		return URL{}, fmt.Errorf("署名付きURLの生成に失敗しました (%s): %w", p, err)
+	}
+	e := entry{URL: URL{URL: u, ExpiresAt: now.Add(c.expiration)}, reuseUntil: now.Add(c.reuse)}
+	c.remember(p, e, now)
+	return e.URL, nil
+}
+
+// SignAll は paths の署名付きURLを、最大 concurrency 件ずつ並行して生成します。
+// 結果は paths と同じ順に並び、生成に失敗したパスは空の URL になります。失敗したパスのエラーはまとめて返します。
+func (c *Cache) SignAll(ctx context.Context, paths []string, concurrency int) ([]URL, error) {
+	urls := make([]URL, len(paths))
+	errs := make([]error, len(paths))
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+
+	for i, p := range paths {
+		if u, ok := c.cached(p, c.now()); ok {
+			urls[i] = u
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			urls[i], errs[i] = c.Sign(ctx, p)
+		}()
+	}
+	wg.Wait()
+	return urls, errors.Join(errs...)
+}
+
+// Earliest は urls のうち最も早い有効期限を返します。空の URL と期限のない URL は無視し、対象がない場合はゼロ値を返します。
+func Earliest(urls ...URL) time.Time {
+	var earliest time.Time
+	for _, u := range urls {
+		if u.URL == "" || u.ExpiresAt.IsZero() {
+			continue
+		}
+		if earliest.IsZero() || u.ExpiresAt.Before(earliest) {
+			earliest = u.ExpiresAt
+		}
+	}
+	return earliest
+}
+
+func (c *Cache) cached(p string, now time.Time) (URL, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[p]
+	if !ok || !now.Before(e.reuseUntil) {
+		return URL{}, false
+	}
+	return e.URL, true
+}
+
+func (c *Cache) remember(p string, e entry, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxEntries {
+		for k, v := range c.entries {
+			if !now.Before(v.reuseUntil) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	c.entries[p] = e
+}
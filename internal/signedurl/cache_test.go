@@ -0,0 +1,96 @@
+package signedurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingSigner は署名した回数を数えるテスト用の URLSigner です。
+type countingSigner struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (s *countingSigner) GenerateSignedURL(_ context.Context, p, _ string, _ time.Duration) (string, error) {
+	if strings.Contains(p, "broken") {
+		return "", errors.New("署名できません")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[p]++
+	return fmt.Sprintf("https://signed.example.com/%s?v=%d", p, s.calls[p]), nil
+}
+
+func TestCacheReusesUntilHalfExpiration(t *testing.T) {
+	signer := &countingSigner{calls: make(map[string]int)}
+	c := NewCache(signer, 4*time.Minute)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	first, err := c.Sign(ctx, "a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := now.Add(4 * time.Minute); !first.ExpiresAt.Equal(want) {
+		t.Fatalf("ExpiresAt = %v, want %v", first.ExpiresAt, want)
+	}
+
+	now = now.Add(time.Minute + 59*time.Second)
+	second, _ := c.Sign(ctx, "a.png")
+	if second != first {
+		t.Fatalf("有効期間の半分までは同じ URL を返すはずです: %+v, %+v", second, first)
+	}
+
+	now = now.Add(time.Second)
+	third, _ := c.Sign(ctx, "a.png")
+	if third.URL == first.URL || signer.calls["a.png"] != 2 {
+		t.Fatalf("有効期間の半分を過ぎたら署名し直すはずです: %+v (calls=%d)", third, signer.calls["a.png"])
+	}
+	if remaining := third.ExpiresAt.Sub(now); remaining != 4*time.Minute {
+		t.Fatalf("残りの有効期間 = %v, want 4m", remaining)
+	}
+}
+
+func TestSignAll(t *testing.T) {
+	signer := &countingSigner{calls: make(map[string]int)}
+	c := NewCache(signer, 4*time.Minute)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, err := c.Sign(ctx, "p1.png"); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute)
+
+	paths := []string{"p1.png", "p2.png", "broken.png", "p3.png"}
+	urls, err := c.SignAll(ctx, paths, 2)
+	if err == nil || !strings.Contains(err.Error(), "broken.png") {
+		t.Fatalf("SignAll() error = %v, want broken.png のエラー", err)
+	}
+	if len(urls) != len(paths) {
+		t.Fatalf("len(urls) = %d, want %d", len(urls), len(paths))
+	}
+	for i, p := range paths {
+		if got := urls[i].URL; (p == "broken.png") != (got == "") || (got != "" && !strings.Contains(got, p)) {
+			t.Errorf("urls[%d] = %q, want %s の URL", i, got, p)
+		}
+	}
+	if signer.calls["p1.png"] != 1 {
+		t.Errorf("キャッシュ済みの p1.png を署名し直しました (calls=%d)", signer.calls["p1.png"])
+	}
+
+	// p1.png は1分前に署名したため、最も早く期限が切れます
+	if got, want := Earliest(urls...), now.Add(3*time.Minute); !got.Equal(want) {
+		t.Errorf("Earliest() = %v, want %v", got, want)
+	}
+	if got := Earliest(URL{}); !got.IsZero() {
+		t.Errorf("Earliest(空) = %v, want ゼロ値", got)
+	}
+}
@@ -0,0 +1,80 @@
+// Package storagetest はテスト用のストレージを提供します。
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shouni/go-remote-io/remoteio"
+)
+
+var (
+	_ remoteio.InputReader  = Memory(nil)
+	_ remoteio.OutputWriter = Memory(nil)
+	_ remoteio.URLSigner    = Signer{}
+)
+
+// Memory はパスと内容の対応をメモリ上に保持するストレージです。
+// remoteio.InputReader と remoteio.OutputWriter を満たし、List は GCS と同じく前方一致で返します。
+type Memory map[string]string
+
+// Open は path の内容を返します。存在しない場合は os.ErrNotExist を返します。
+func (m Memory) Open(_ context.Context, path string) (io.ReadCloser, error) {
+	s, ok := m[path]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", path, os.ErrNotExist)
+	}
+	return io.NopCloser(strings.NewReader(s)), nil
+}
+
+// List は prefix で始まるパスを辞書順に fn へ渡します。
+func (m Memory) List(_ context.Context, prefix string, fn func(string) error) error {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		if err := fn(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Exists は path が存在するかを返します。
+func (m Memory) Exists(_ context.Context, path string) (bool, error) {
+	_, ok := m[path]
+	return ok, nil
+}
+
+// Write は r の内容を path に保存します。書き込みオプションは無視します。
+func (m Memory) Write(_ context.Context, path string, r io.Reader, _ ...remoteio.WriteOption) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m[path] = string(b)
+	return nil
+}
+
+// Delete は path を削除します。存在しない場合も成功します。
+func (m Memory) Delete(_ context.Context, path string) error {
+	delete(m, path)
+	return nil
+}
+
+// Signer はパスをそのまま埋め込んだ URL (https://signed.example/{path}) を返す署名付きURLの生成器です。
+type Signer struct{}
+
+// GenerateSignedURL は path に対応する URL を返します。
+func (Signer) GenerateSignedURL(_ context.Context, path, _ string, _ time.Duration) (string, error) {
+	return "https://signed.example/" + strings.TrimPrefix(path, "gs://"), nil
+}
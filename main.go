@@ -7,13 +7,20 @@ import (
 	"os/signal"
 	"syscall"
 
+	"ap-manga-web/internal/cli"
 	"ap-manga-web/internal/config"
 	"ap-manga-web/internal/server"
 )
 
 func main() {
 	// 1. ロガーの設定（構造化ログの復元）
-	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	// CLI として実行する場合は、標準出力を成果物の出力に使えるようログを標準エラー出力に出します
+	runCLI := len(os.Args) > 1 && cli.IsCommand(os.Args[1])
+	if runCLI {
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	} else {
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	}
 
 	// 2. シグナルに反応するコンテキストの作成
 	// これにより、SIGINT/SIGTERM受信時に ctx.Done() が閉じる
@@ -26,6 +33,16 @@ func main() {
 		slog.Error("Config loading failed", "error", err)
 		os.Exit(1)
 	}
+
+	// CLI のサブコマンドは Web サーバー用の必須設定（OAuth 等）を必要としないため、検証せずに実行します
+	if runCLI {
+		if err := cli.Run(ctx, cfg, os.Args[1:]); err != nil {
+			slog.Error("Command failed", "command", os.Args[1], "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err := cfg.ValidateEssentialConfig(); err != nil {
 		slog.Error("Config validation failed", "error", err)
 		os.Exit(1)